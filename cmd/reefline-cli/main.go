@@ -0,0 +1,339 @@
+// Command reefline-cli submits analyses to a Reefline server and waits for
+// the results, for use from CI:
+//
+//	reefline-cli analyze --image nginx:1.25 --wait --fail-on high --min-score 70
+//	reefline-cli status job_abc123
+//
+// The server URL and API key come from --server/--api-key or REEFLINE_API_URL
+// and REEFLINE_API_KEY. Exit codes: 0 success, 1 error or failed job,
+// 2 unknown command, 3 a --fail-on/--min-score gate tripped.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/siddhantprateek/reefline/internal/handlers"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+)
+
+// Exit codes
+const (
+	exitError = 1
+	exitUsage = 2
+	exitGate  = 3
+)
+
+// errGate is returned when the finished job trips a --fail-on/--min-score gate
+var errGate = errors.New("quality gate failed")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "analyze":
+		err = runAnalyze(os.Args[2:])
+	case "status":
+		err = runStatus(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errGate):
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitGate)
+	case errors.Is(err, flag.ErrHelp):
+		// Flags were already printed
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitError)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage:
+  reefline-cli analyze [flags]   Submit an image and/or Dockerfile for analysis
+  reefline-cli status <job-id>   Print a job's status
+
+Run "reefline-cli <command> -h" for the command's flags.`)
+}
+
+// client calls the Reefline API
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// clientFlags registers --server and --api-key on fs
+func clientFlags(fs *flag.FlagSet) func() *client {
+	server := fs.String("server", envOr("REEFLINE_API_URL", "http://localhost:8080"), "Reefline server URL (REEFLINE_API_URL)")
+	apiKey := fs.String("api-key", os.Getenv("REEFLINE_API_KEY"), "API key sent as a bearer token (REEFLINE_API_KEY)")
+	return func() *client {
+		return &client{
+			baseURL: strings.TrimSuffix(*server, "/") + "/api/v1",
+			apiKey:  *apiKey,
+			http:    &http.Client{Timeout: 60 * time.Second},
+		}
+	}
+}
+
+// do sends a JSON request and decodes a JSON response into out
+func (c *client) do(method, path string, body interface{}, headers map[string]string, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s (%d)", method, path, apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("%s %s: unexpected status %d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// submitResponse is the reply to POST /analyze
+type submitResponse struct {
+	JobID     string `json:"job_id"`
+	Status    string `json:"status"`
+	StreamURL string `json:"stream_url"`
+}
+
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	newClient := clientFlags(fs)
+	image := fs.String("image", "", "image reference to analyze")
+	dockerfile := fs.String("dockerfile", "", "path to a Dockerfile to analyze")
+	analysisType := fs.String("type", "", "analysis type: full (default), sbom, vuln or hygiene")
+	appContext := fs.String("app-context", "", "what the application does, for the report")
+	timeoutSeconds := fs.Int("tool-timeout", 0, "per-tool timeout in seconds (server default when 0)")
+	idempotencyKey := fs.String("idempotency-key", "", "reuse the job of an earlier submission with the same key (safe CI retries)")
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	pollInterval := fs.Duration("poll-interval", 5*time.Second, "how often to poll the job with --wait")
+	waitTimeout := fs.Duration("timeout", 30*time.Minute, "give up waiting after this long")
+	failOn := fs.String("fail-on", "", "with --wait, exit 3 if any vulnerability is at or above this severity: critical, high, medium or low")
+	minScore := fs.Int("min-score", 0, "with --wait, exit 3 if the security score is below this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *image == "" && *dockerfile == "" {
+		return errors.New("--image or --dockerfile is required")
+	}
+	if *failOn != "" {
+		if _, err := severityAtOrAbove(scoring.VulnerabilityCounts{}, *failOn); err != nil {
+			return err
+		}
+	}
+	if (*failOn != "" || *minScore > 0) && !*wait {
+		return errors.New("--fail-on and --min-score need --wait")
+	}
+
+	req := handlers.AnalysisRequest{
+		ImageRef:       *image,
+		AppContext:     *appContext,
+		AnalysisType:   *analysisType,
+		TimeoutSeconds: *timeoutSeconds,
+	}
+	if *dockerfile != "" {
+		content, err := os.ReadFile(*dockerfile)
+		if err != nil {
+			return err
+		}
+		req.Dockerfile = string(content)
+	}
+
+	var headers map[string]string
+	if *idempotencyKey != "" {
+		headers = map[string]string{"Idempotency-Key": *idempotencyKey}
+	}
+
+	c := newClient()
+	var submitted submitResponse
+	if err := c.do(http.MethodPost, "/analyze", req, headers, &submitted); err != nil {
+		return err
+	}
+	fmt.Printf("Submitted job %s (%s)\n", submitted.JobID, submitted.Status)
+	if !*wait {
+		return nil
+	}
+
+	job, err := c.waitForJob(submitted.JobID, *pollInterval, *waitTimeout)
+	if err != nil {
+		return err
+	}
+	if job.Status != string(models.JobStatusCompleted) {
+		return fmt.Errorf("job %s ended %s", job.JobID, job.Status)
+	}
+	if job.Degraded {
+		fmt.Printf("Warning: completed without %s\n", strings.Join(job.FailedTools, ", "))
+	}
+
+	var card scoring.ScoreCard
+	if err := c.do(http.MethodGet, "/jobs/"+job.JobID+"/score.json", nil, nil, &card); err != nil {
+		if *failOn == "" && *minScore == 0 {
+			fmt.Printf("No score card: %v\n", err)
+			return nil
+		}
+		return fmt.Errorf("gates need the score card: %w", err)
+	}
+	printScoreCard(&card)
+	return checkGates(&card, *failOn, *minScore)
+}
+
+// waitForJob polls the job until it reaches a terminal status, printing progress
+func (c *client) waitForJob(jobID string, interval, timeout time.Duration) (*handlers.JobReportResponse, error) {
+	deadline := time.Now().Add(timeout)
+	lastStatus, lastProgress := "", -1
+	for {
+		var job handlers.JobReportResponse
+		if err := c.do(http.MethodGet, "/jobs/"+jobID, nil, nil, &job); err != nil {
+			return nil, err
+		}
+		if job.Status != lastStatus || job.Progress != lastProgress {
+			fmt.Printf("[%s] %s %d%%\n", time.Now().Format("15:04:05"), job.Status, job.Progress)
+			lastStatus, lastProgress = job.Status, job.Progress
+		}
+
+		switch models.JobStatus(job.Status) {
+		case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled, models.JobStatusSkipped:
+			return &job, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("job %s still %s after %s", jobID, job.Status, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func printScoreCard(card *scoring.ScoreCard) {
+	fmt.Printf("Security score: %d (%s)\n", card.SecurityScore, card.SecurityStatus)
+	if v := card.Vulnerabilities; v != nil {
+		fmt.Printf("Vulnerabilities: %d critical, %d high, %d medium, %d low, %d unknown\n",
+			v.Critical, v.High, v.Medium, v.Low, v.Unknown)
+	}
+	if len(card.Missing) > 0 {
+		fmt.Printf("Missing results: %s\n", strings.Join(card.Missing, ", "))
+	}
+}
+
+// checkGates returns errGate if the score card trips --fail-on or --min-score
+func checkGates(card *scoring.ScoreCard, failOn string, minScore int) error {
+	var failures []string
+	if failOn != "" {
+		if card.Vulnerabilities == nil {
+			failures = append(failures, "no vulnerability results to check --fail-on against")
+		} else if n, _ := severityAtOrAbove(*card.Vulnerabilities, failOn); n > 0 {
+			failures = append(failures, fmt.Sprintf("%d vulnerabilities at or above %s", n, failOn))
+		}
+	}
+	if minScore > 0 && card.SecurityScore < minScore {
+		failures = append(failures, fmt.Sprintf("security score %d is below %d", card.SecurityScore, minScore))
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %s", errGate, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// severityAtOrAbove counts the vulnerabilities at or above severity
+func severityAtOrAbove(v scoring.VulnerabilityCounts, severity string) (int, error) {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return v.Critical, nil
+	case "high":
+		return v.Critical + v.High, nil
+	case "medium":
+		return v.Critical + v.High + v.Medium, nil
+	case "low":
+		return v.Critical + v.High + v.Medium + v.Low, nil
+	default:
+		return 0, fmt.Errorf("invalid --fail-on %q: want critical, high, medium or low", severity)
+	}
+}
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	newClient := clientFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: reefline-cli status <job-id>")
+	}
+
+	var job handlers.JobReportResponse
+	if err := newClient().do(http.MethodGet, "/jobs/"+fs.Arg(0), nil, nil, &job); err != nil {
+		return err
+	}
+	out, _ := json.MarshalIndent(job, "", "  ")
+	fmt.Println(string(out))
+	return nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,107 @@
+// Command reencrypt migrates stored integration credentials to the active
+// ENCRYPTION_KEY after a key rotation. Set ENCRYPTION_KEY to the new key and
+// ENCRYPTION_KEY_PREVIOUS to the retired key(s), then run:
+//
+//	go run ./cmd/reencrypt [-dry-run] [-mark-reauth]
+//
+// Rows already using the active key are left alone, so it can be re-run at
+// any time and is safe while the server is live: each row is only rewritten
+// if its ciphertext has not changed since it was read.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/joho/godotenv"
+	"github.com/siddhantprateek/reefline/pkg/crypto"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+)
+
+// statusReauthRequired matches the status the API sets on undecryptable integrations
+const statusReauthRequired = "reauth_required"
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	markReauth := flag.Bool("mark-reauth", false, "set unrecoverable integrations to reauth_required")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: No .env file found")
+	}
+
+	db, err := database.Initialize(database.GetConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	if err := crypto.Init(); err != nil {
+		log.Fatalf("Failed to initialize encryption: %v", err)
+	}
+
+	var total, migrated, current, unrecoverable, conflicts int
+	var batch []models.Integration
+	result := db.Where("credentials <> ''").FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for _, integration := range batch {
+			total++
+			out, changed, err := crypto.Reencrypt(integration.Credentials)
+			if err != nil {
+				unrecoverable++
+				log.Printf("Unrecoverable: user=%s integration=%s: %v", integration.UserID, integration.IntegrationID, err)
+				if *markReauth && !*dryRun && errors.Is(err, crypto.ErrDecrypt) {
+					db.Model(&models.Integration{}).Where("id = ?", integration.ID).Update("status", statusReauthRequired)
+				}
+				continue
+			}
+
+			updates := map[string]interface{}{}
+			if changed {
+				updates["credentials"] = out
+			}
+			// Credentials readable again (e.g. the old key was supplied) — restore the integration
+			if integration.Status == statusReauthRequired {
+				updates["status"] = "connected"
+			}
+			if len(updates) == 0 {
+				current++
+				continue
+			}
+			if *dryRun {
+				migrated++
+				continue
+			}
+
+			// Only overwrite if nobody reconnected the integration in the meantime
+			res := db.Model(&models.Integration{}).
+				Where("id = ? AND credentials = ?", integration.ID, integration.Credentials).
+				Updates(updates)
+			if res.Error != nil {
+				return fmt.Errorf("updating integration %d: %w", integration.ID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				conflicts++
+				continue
+			}
+			migrated++
+		}
+		return nil
+	})
+	if result.Error != nil {
+		log.Fatalf("Re-encryption aborted: %v", result.Error)
+	}
+
+	verb := "Migrated"
+	if *dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Printf("Integrations scanned:   %d\n", total)
+	fmt.Printf("%s:%*s%d\n", verb, 23-len(verb), "", migrated)
+	fmt.Printf("Already current:        %d\n", current)
+	fmt.Printf("Changed concurrently:   %d\n", conflicts)
+	fmt.Printf("Unrecoverable:          %d\n", unrecoverable)
+}
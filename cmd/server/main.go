@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/contrib/otelfiber"
 	"github.com/gofiber/fiber/v2"
@@ -13,8 +16,11 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	"github.com/siddhantprateek/reefline/internal/handlers"
+	"github.com/siddhantprateek/reefline/internal/integration"
 	"github.com/siddhantprateek/reefline/internal/queue"
 	"github.com/siddhantprateek/reefline/internal/routes"
+	"github.com/siddhantprateek/reefline/internal/webhook"
 	"github.com/siddhantprateek/reefline/pkg/crypto"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
@@ -43,7 +49,10 @@ func main() {
 	defer database.Close()
 
 	// Run migrations (add your models here)
-	if err := database.AutoMigrate(db, &models.Integration{}, &models.Job{}); err != nil {
+	if err := models.DedupeIdempotencyKeys(db); err != nil {
+		log.Fatalf("Failed to deduplicate idempotency keys: %v", err)
+	}
+	if err := database.AutoMigrate(db, &models.Integration{}, &models.Job{}, &models.ProviderUsage{}, &models.UserStorage{}, &models.ReportTemplate{}, &models.FlowPreferences{}, &models.ToolStats{}, &models.JobEvent{}, &models.Watch{}); err != nil {
 		log.Fatalf("Failed to run database migrations: %v", err)
 	}
 
@@ -60,13 +69,26 @@ func main() {
 	}
 	log.Println("Encryption subsystem initialized (AES-256-GCM)")
 
+	// Registries with self-signed certificates; every other pull verifies TLS
+	tools.InsecureRegistries = tools.ParseRegistryPatterns(os.Getenv("IMAGE_INSECURE_REGISTRIES"))
+
+	// Local docker-archive/OCI inputs to /analyze are confined to this directory (unset = disabled)
+	tools.LocalImageRoot = os.Getenv("LOCAL_IMAGE_ROOT")
+
 	// Initialize image inspector (skopeo-like inspect via containers/image)
 	enableInspector := os.Getenv("IMAGE_INSPECTOR_ENABLED")
 	if enableInspector == "true" {
 		log.Println("Initializing image inspector...")
+		signatureConfig, err := tools.SignatureConfigFromEnv()
+		if err != nil {
+			log.Printf("Warning: image signature check disabled: %v", err)
+		}
 		inspectorConfig := tools.ImageInspectorConfig{
 			Enable:                true,
+			Timeout:               tools.TimeoutFromEnv("INSPECT_TIMEOUT"),
 			InsecureSkipTLSVerify: os.Getenv("IMAGE_INSPECTOR_INSECURE_TLS") == "true",
+			Sources:               tools.ParseSources(os.Getenv("IMAGE_INSPECTOR_SOURCES")),
+			Signatures:            signatureConfig,
 		}
 		tools.ImgInspector = tools.NewImageInspector(inspectorConfig, slog.Default())
 		tools.ImgInspector.Init()
@@ -75,6 +97,92 @@ func main() {
 		log.Println("Image inspector is disabled (set IMAGE_INSPECTOR_ENABLED=true to enable)")
 	}
 
+	// Idempotency-Key retention window for POST /analyze
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+		if ttl, err := time.ParseDuration(v); err == nil && ttl > 0 {
+			handlers.IdempotencyKeyTTL = ttl
+		} else {
+			log.Printf("Warning: invalid IDEMPOTENCY_KEY_TTL %q, using %s", v, handlers.IdempotencyKeyTTL)
+		}
+	}
+
+	// Per-user artifact storage quota (0 = unlimited)
+	if v := os.Getenv("STORAGE_QUOTA_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			handlers.StorageQuotaBytes = n
+		} else {
+			log.Printf("Warning: invalid STORAGE_QUOTA_BYTES %q, quota disabled", v)
+		}
+	}
+
+	// Upper bound for provider API calls made by the integration endpoints
+	if v := os.Getenv("INTEGRATION_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			handlers.IntegrationRequestTimeout = d
+		} else {
+			log.Printf("Warning: invalid INTEGRATION_REQUEST_TIMEOUT %q, using %s", v, handlers.IntegrationRequestTimeout)
+		}
+	}
+
+	// Operator endpoints under /api/v1/admin (disabled when unset)
+	handlers.AdminToken = os.Getenv("ADMIN_API_TOKEN")
+
+	// Purge soft-deleted jobs (and their artifacts) once the grace period ends
+	if v := os.Getenv("JOB_PURGE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			handlers.JobPurgeGracePeriod = d
+		} else {
+			log.Printf("Warning: invalid JOB_PURGE_AFTER %q, using %s", v, handlers.JobPurgeGracePeriod)
+		}
+	}
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			n, err := handlers.PurgeDeletedJobs(reaperCtx, handlers.JobPurgeGracePeriod)
+			if err != nil {
+				log.Printf("[Reaper] failed to purge deleted jobs: %v", err)
+			} else if n > 0 {
+				log.Printf("[Reaper] purged %d deleted job(s)", n)
+			}
+			select {
+			case <-reaperCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Periodically re-validate connected integrations
+	if v := os.Getenv("INTEGRATION_HEALTH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			handlers.IntegrationHealthInterval = d
+		} else {
+			log.Printf("Warning: invalid INTEGRATION_HEALTH_INTERVAL %q, using %s", v, handlers.IntegrationHealthInterval)
+		}
+	}
+	if v := os.Getenv("LISTING_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			handlers.ListingCacheTTL = d
+		} else {
+			log.Printf("Warning: invalid LISTING_CACHE_TTL %q, using %s", v, handlers.ListingCacheTTL)
+		}
+	}
+	handlers.IntegrationWebhooks = webhook.ParseURLs(os.Getenv("INTEGRATION_WEBHOOK_URLS"))
+	go handlers.RunIntegrationHealthChecks(reaperCtx)
+
+	// Materialized tool performance stats for GET /metrics/tools
+	if v := os.Getenv("TOOL_STATS_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			handlers.ToolStatsInterval = d
+		} else {
+			log.Printf("Warning: invalid TOOL_STATS_INTERVAL %q, using %s", v, handlers.ToolStatsInterval)
+		}
+	}
+	go handlers.RunToolStatsAggregation(reaperCtx)
+
 	// Initialize Job Queue
 	var q queue.Queue
 	redisHost := os.Getenv("REDIS_HOST")
@@ -87,6 +195,7 @@ func main() {
 		redisPass := os.Getenv("REDIS_PASSWORD")
 		q = queue.NewRedisQueue(redisAddr, redisPass)
 		log.Printf("Using Redis job queue at %s", redisAddr)
+		handlers.ListingCache = integration.NewRedisCache(redisAddr, redisPass)
 	} else {
 		// Fallback to In-Memory
 		q = queue.NewInMemoryQueue(100)
@@ -116,15 +225,54 @@ func main() {
 	// 	}
 	defer q.Stop()
 
+	// Rescan watched images when their digest changes
+	if v := os.Getenv("WATCH_POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			handlers.WatchPollInterval = d
+		} else {
+			log.Printf("Warning: invalid WATCH_POLL_INTERVAL %q, using %s", v, handlers.WatchPollInterval)
+		}
+	}
+	handlers.WatchWebhooks = webhook.ParseURLs(os.Getenv("WATCH_WEBHOOK_URLS"))
+	go handlers.RunWatchPoller(reaperCtx, q)
+
+	// Largest accepted request body; bigger requests get 413
+	bodyLimit := 1 << 20
+	if v := os.Getenv("BODY_LIMIT_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			bodyLimit = n
+		} else {
+			log.Printf("Warning: invalid BODY_LIMIT_BYTES %q, using %d", v, bodyLimit)
+		}
+	}
+
+	if v := os.Getenv("SOURCE_MAX_UPLOAD_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			handlers.SourceMaxUploadBytes = n
+		} else {
+			log.Printf("Warning: invalid SOURCE_MAX_UPLOAD_BYTES %q, using %d", v, handlers.SourceMaxUploadBytes)
+		}
+	}
+
+	// Bodies are streamed so source uploads aren't buffered whole;
+	// handlers.BodyLimit enforces bodyLimit on every other route
 	app := fiber.New(fiber.Config{
-		AppName: "Reefline Server",
+		AppName:                      "Reefline Server",
+		BodyLimit:                    bodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		ErrorHandler:                 handlers.ErrorHandler,
 	})
 
 	// Add telemetry middleware first
 	app.Use(otelfiber.Middleware())
-	app.Use(cors.New())
+	corsConfig := handlers.CORSConfigFromEnv()
+	log.Printf("CORS allowed origins: %s (credentials: %t)", corsConfig.AllowOrigins, corsConfig.AllowCredentials)
+	app.Use(cors.New(corsConfig))
 	app.Use(logger.New())
 	app.Use(recover.New())
+	app.Use(handlers.ErrorCodes)
+	app.Use(handlers.BodyLimit(bodyLimit, "/api/v1/analyze/source"))
 
 	routes.Setup(app, q)
 
@@ -133,23 +281,45 @@ func main() {
 		port = "8080"
 	}
 
+	// How long in-flight requests (SSE streams, slow provider calls) get to
+	// finish once shutdown starts
+	shutdownTimeout := 30 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			shutdownTimeout = d
+		} else {
+			log.Printf("Warning: invalid SHUTDOWN_TIMEOUT %q, using %s", v, shutdownTimeout)
+		}
+	}
+
 	// Create channel for graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
+	// Stop accepting connections first, then drain in-flight requests
+	httpStopped := make(chan struct{})
 	go func() {
 		<-c
-		log.Println("Gracefully shutting down server...")
-
-		// Stop image inspector if initialized
-		if tools.ImgInspector != nil {
-			log.Println("Stopping image inspector...")
-			tools.ImgInspector.Stop()
+		log.Printf("Gracefully shutting down server (waiting up to %s for in-flight requests)...", shutdownTimeout)
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.Printf("Warning: in-flight requests did not finish: %v", err)
 		}
-
-		app.Shutdown()
+		close(httpStopped)
 	}()
 
 	log.Printf("Starting Reefline Server on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+	if err := app.Listen(":" + port); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	// Listen returns as soon as the listener closes; wait for the drain
+	<-httpStopped
+
+	// HTTP is down, so nothing can reach the tools or background jobs any more.
+	// The queue, DB and telemetry close after this via the deferred calls above.
+	stopReaper()
+	if tools.ImgInspector != nil {
+		log.Println("Stopping image inspector...")
+		tools.ImgInspector.Stop()
+	}
+	log.Println("Server stopped")
 }
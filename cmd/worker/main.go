@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/siddhantprateek/reefline/internal/flows"
 	"github.com/siddhantprateek/reefline/internal/queue"
+	scannertools "github.com/siddhantprateek/reefline/internal/tools"
+	"github.com/siddhantprateek/reefline/internal/webhook"
 	"github.com/siddhantprateek/reefline/internal/worker"
 	"github.com/siddhantprateek/reefline/pkg/crypto"
 	"github.com/siddhantprateek/reefline/pkg/database"
@@ -16,6 +22,7 @@ import (
 	"github.com/siddhantprateek/reefline/pkg/storage"
 	"github.com/siddhantprateek/reefline/pkg/telemetry"
 	"github.com/siddhantprateek/reefline/pkg/tools"
+	"github.com/siddhantprateek/reefline/pkg/version"
 )
 
 func main() {
@@ -38,7 +45,7 @@ func main() {
 	defer database.Close()
 
 	// Run migrations (add your models here)
-	if err := database.AutoMigrate(db, &models.Integration{}); err != nil {
+	if err := database.AutoMigrate(db, &models.Integration{}, &models.ProviderUsage{}, &models.UserStorage{}); err != nil {
 		log.Fatalf("Failed to run database migrations: %v", err)
 	}
 
@@ -55,23 +62,40 @@ func main() {
 	}
 	log.Println("Encryption subsystem initialized (AES-256-GCM)")
 
+	// Registries with self-signed certificates; every other pull verifies TLS
+	tools.InsecureRegistries = tools.ParseRegistryPatterns(os.Getenv("IMAGE_INSECURE_REGISTRIES"))
+
 	// Initialize vulnerability scanner
 	enableScanner := os.Getenv("VULNERABILITY_SCANNER_ENABLED")
 	if enableScanner == "true" {
 		log.Println("Initializing vulnerability scanner...")
 		scannerConfig := tools.ImageScans{
-			Enable: true,
-			Exclusions: tools.Exclusions{
-				Namespaces: []string{},
-				Labels:     map[string][]string{},
-			},
+			Enable:     true,
+			Timeout:    tools.TimeoutFromEnv("GRYPE_TIMEOUT"),
+			Exclusions: tools.ExclusionsFromEnv(),
+			// Prefer SBOMs attached to the image (OCI referrers) unless disabled
+			AttachedSBOMs: os.Getenv("SBOM_USE_ATTACHED") != "false",
 		}
 		tools.ImgScanner = tools.NewImageScanner(scannerConfig, slog.Default())
 
-		// Initialize scanner in background
+		// Announce newer vulnerability DBs (and fire VULN_DB_WEBHOOK_URLS, if set)
+		worker.DBUpdateWebhooks = webhook.ParseURLs(os.Getenv("VULN_DB_WEBHOOK_URLS"))
+		tools.ImgScanner.OnDBUpdate(worker.HandleDBUpdate)
+
+		// Page on new Critical/High CVEs found by scheduled rescans
+		worker.ScanDiffWebhooks = webhook.ParseURLs(os.Getenv("SCAN_DIFF_WEBHOOK_URLS"))
+
+		// Initialize scanner in background (DB load retries with backoff)
 		go func() {
-			tools.ImgScanner.Init("reefline", "1.0.0")
-			log.Println("Vulnerability scanner initialized successfully")
+			tools.ImgScanner.Init("reefline", version.Version)
+			if tools.ImgScanner.IsInitialized() {
+				log.Println("Vulnerability scanner initialized successfully")
+				if err := scannertools.PublishDBStatus(context.Background()); err != nil {
+					log.Printf("Warning: failed to publish vulnerability DB status: %v", err)
+				}
+			} else {
+				log.Println("Vulnerability DB unavailable, scanner will keep retrying in background")
+			}
 		}()
 	} else {
 		log.Println("Vulnerability scanner is disabled (set VULNERABILITY_SCANNER_ENABLED=true to enable)")
@@ -82,7 +106,8 @@ func main() {
 	if enableDockle == "true" {
 		log.Println("Initializing dockle scanner...")
 		dockleConfig := tools.DockleConfig{
-			Enable: true,
+			Enable:  true,
+			Timeout: tools.TimeoutFromEnv("DOCKLE_TIMEOUT"),
 		}
 		tools.DockleScn = tools.NewDockleScanner(dockleConfig, slog.Default())
 		tools.DockleScn.Init()
@@ -95,9 +120,16 @@ func main() {
 	enableInspector := os.Getenv("IMAGE_INSPECTOR_ENABLED")
 	if enableInspector == "true" {
 		log.Println("Initializing image inspector...")
+		signatureConfig, err := tools.SignatureConfigFromEnv()
+		if err != nil {
+			log.Printf("Warning: image signature check disabled: %v", err)
+		}
 		inspectorConfig := tools.ImageInspectorConfig{
 			Enable:                true,
+			Timeout:               tools.TimeoutFromEnv("INSPECT_TIMEOUT"),
 			InsecureSkipTLSVerify: os.Getenv("IMAGE_INSPECTOR_INSECURE_TLS") == "true",
+			Sources:               tools.ParseSources(os.Getenv("IMAGE_INSPECTOR_SOURCES")),
+			Signatures:            signatureConfig,
 		}
 		tools.ImgInspector = tools.NewImageInspector(inspectorConfig, slog.Default())
 		tools.ImgInspector.Init()
@@ -106,19 +138,53 @@ func main() {
 		log.Println("Image inspector is disabled (set IMAGE_INSPECTOR_ENABLED=true to enable)")
 	}
 
+	// Initialize shared layer cache (reused by dive remote pulls)
+	if os.Getenv("LAYER_CACHE_ENABLED") != "false" {
+		maxBytes, _ := strconv.ParseInt(os.Getenv("LAYER_CACHE_MAX_BYTES"), 10, 64)
+		cache, err := tools.NewBlobCache(tools.BlobCacheConfig{
+			Dir:      os.Getenv("LAYER_CACHE_DIR"),
+			MaxBytes: maxBytes,
+		}, slog.Default())
+		if err != nil {
+			log.Printf("Layer cache disabled: %v", err)
+		} else {
+			tools.LayerCache = cache
+			log.Println("Layer cache initialized")
+		}
+	}
+
+	// Initialize the scratch directory for the tools' temp files. After the
+	// layer cache, whose default directory follows TMPDIR.
+	scratch, err := tools.NewScratchDir(tools.ScratchConfig{
+		Dir:    os.Getenv("SCRATCH_DIR"),
+		MaxAge: tools.TimeoutFromEnv("SCRATCH_MAX_AGE"),
+	}, slog.Default())
+	if err != nil {
+		log.Printf("Warning: scratch dir unavailable, using %s: %v", os.TempDir(), err)
+	} else {
+		tools.Scratch = scratch
+		scratch.Sweep()
+		scratch.LogUsage("startup")
+		log.Printf("Scratch dir initialized at %s", scratch.Path())
+	}
+
 	// Initialize dive analyzer (image layer efficiency analysis)
 	enableDive := os.Getenv("DIVE_ANALYZER_ENABLED")
 	if enableDive == "true" {
 		log.Println("Initializing dive analyzer...")
+		// DIVE_IMAGE_SOURCES is an ordered fallback list (default: docker,podman,remote);
+		// the legacy DIVE_IMAGE_SOURCE pins a single source. Its old docker-archive
+		// value is ignored with a warning: archives are now docker-archive:<path> refs.
+		sources := os.Getenv("DIVE_IMAGE_SOURCES")
+		if sources == "" {
+			sources = os.Getenv("DIVE_IMAGE_SOURCE")
+		}
 		diveConfig := tools.DiveConfig{
 			Enable:       true,
-			Source:       os.Getenv("DIVE_IMAGE_SOURCE"),
+			Timeout:      tools.TimeoutFromEnv("DIVE_TIMEOUT"),
+			Sources:      tools.ParseSources(sources),
 			IgnoreErrors: os.Getenv("DIVE_IGNORE_ERRORS") == "true",
 		}
-		// Default to docker if not specified
-		if diveConfig.Source == "" {
-			diveConfig.Source = "docker"
-		}
 		tools.DiveAnalyzer = tools.NewDiveAnalyzer(diveConfig, slog.Default())
 		tools.DiveAnalyzer.Init()
 		log.Println("Dive analyzer initialized (image efficiency analysis)")
@@ -156,8 +222,66 @@ func main() {
 	log.Printf("Flow service URL: %s", flowURL)
 	log.Printf("Flow provider:    %s", flowProvider)
 
+	// Retries and circuit breaker for AI provider calls of the report flow
+	if v := os.Getenv("FLOW_RETRY_COUNT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flows.RetryCount = n
+		} else {
+			log.Printf("Invalid FLOW_RETRY_COUNT %q, using %d", v, flows.RetryCount)
+		}
+	}
+	if v := os.Getenv("FLOW_BREAKER_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flows.BreakerThreshold = n
+		} else {
+			log.Printf("Invalid FLOW_BREAKER_THRESHOLD %q, using %d", v, flows.BreakerThreshold)
+		}
+	}
+	if v := os.Getenv("FLOW_BREAKER_COOLDOWN_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			flows.BreakerCooldown = time.Duration(n) * time.Second
+		} else {
+			log.Printf("Invalid FLOW_BREAKER_COOLDOWN_SECONDS %q, using %s", v, flows.BreakerCooldown)
+		}
+	}
+
+	// Artifact read budget of one report flow run (0 disables a limit)
+	if v := os.Getenv("FLOW_MAX_ARTIFACT_READS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flows.MaxArtifactReads = n
+		} else {
+			log.Printf("Invalid FLOW_MAX_ARTIFACT_READS %q, using %d", v, flows.MaxArtifactReads)
+		}
+	}
+	if v := os.Getenv("FLOW_MAX_ARTIFACT_READ_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			flows.MaxArtifactReadBytes = n
+		} else {
+			log.Printf("Invalid FLOW_MAX_ARTIFACT_READ_BYTES %q, using %d", v, flows.MaxArtifactReadBytes)
+		}
+	}
+
+	// Condense grype.json for the report flow above this size (0 disables)
+	if v := os.Getenv("GRYPE_SUMMARY_THRESHOLD_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			worker.GrypeSummaryThreshold = n
+		} else {
+			log.Printf("Invalid GRYPE_SUMMARY_THRESHOLD_BYTES %q, using %d", v, worker.GrypeSummaryThreshold)
+		}
+	}
+
+	// Largest uploaded source tree the worker extracts for a directory scan
+	if v := os.Getenv("SOURCE_MAX_EXTRACT_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			worker.MaxSourceBytes = n
+		} else {
+			log.Printf("Invalid SOURCE_MAX_EXTRACT_BYTES %q, using %d", v, worker.MaxSourceBytes)
+		}
+	}
+
 	// Register Handler
 	q.RegisterHandler("analyze_image", worker.ProcessAnalyzeJob)
+	q.RegisterHandler("refresh_vuln_db", worker.ProcessRefreshDBJob)
 
 	// Start Queue
 	log.Println("Starting worker...")
@@ -197,5 +321,8 @@ func main() {
 	}
 
 	q.Stop()
+	if tools.Scratch != nil {
+		tools.Scratch.Cleanup()
+	}
 	log.Println("Worker stopped")
 }
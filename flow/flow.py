@@ -1,60 +1,117 @@
 import logging
+import re
 from typing import Literal
 from agents import Agent, Runner, function_tool, handoff
 from agents.models.openai_chatcompletions import OpenAIChatCompletionsModel
+from pydantic import BaseModel
 
 from provider import ProviderConfig
 from integration.minio import read_artifact, write_artifact
 
 log = logging.getLogger(__name__)
 
-ALLOWED_READ  = {"grype.json", "dockle.json", "dive.json", "draft.md", "report.md"}
+ALLOWED_READ  = {"grype.summary.json", "grype.json", "harbor-scan.json", "dockle.json", "dive.json", "history.json", "dockerfile-layer-map.json", "score.json", "baseline.json", "platforms.json", "draft.md", "report.md"}
 ALLOWED_WRITE = {"report.md", "draft.md"}
+# Per-platform grype results of a multi-arch image, e.g. grype.linux-arm64.json or grype.linux-arm-v7.json
+PLATFORM_SCAN = re.compile(r"^grype\.[a-z0-9]+-[a-z0-9]+(-[a-z0-9]+)?\.json$")
+
+
+# ── Scoring (mirrors pkg/scoring.Weights; defaults are the built-in weights) ──
+
+class Deductions(BaseModel):
+    critical: int = 10
+    high: int = 5
+    medium: int = 0
+    low: int = 0
+    fatal: int = 8  # dockle FATAL
+    warn: int = 3   # dockle WARN
+    info: int = 0   # dockle INFO
+
+
+class Scoring(BaseModel):
+    base_score: int = 100
+    deductions: Deductions = Deductions()
+
+    def rule(self) -> str:
+        """The scoring rule for the prompt, e.g. "start at 100. Deduct Critical=-10, ..."."""
+        d = self.deductions
+        levels = [("Critical", d.critical), ("High", d.high), ("Medium", d.medium), ("Low", d.low),
+                  ("FATAL", d.fatal), ("WARN", d.warn), ("INFO", d.info)]
+        rule = f"start at {self.base_score}."
+        deductions = [f"{name}=-{points}" for name, points in levels if points > 0]
+        if deductions:
+            rule += " Deduct " + ", ".join(deductions) + "."
+        return rule
 
 
 # ── Tool factories (per job_id) ───────────────────────────────────────────────
 
-def make_scan_tools(job_id: str):
-    """Return read_file and write_file tools bound to the given job_id."""
+def make_scan_tools(job_id: str, user_id: str = ""):
+    """Return read_file and write_file tools bound to the given job_id (owned by user_id)."""
 
     @function_tool
-    def read_file(filename: Literal["grype.json", "dockle.json", "dive.json", "draft.md", "report.md"]) -> str:
+    def read_file(filename: Literal["grype.summary.json", "grype.json", "harbor-scan.json", "dockle.json", "dive.json", "history.json", "dockerfile-layer-map.json", "score.json", "baseline.json", "platforms.json", "draft.md", "report.md"]) -> str:
         """Read a scan artifact or report file for the current job.
-        Use 'grype.json' for vulnerability data, 'dockle.json' for CIS benchmark,
-        'dive.json' for layer efficiency, 'draft.md' or 'report.md' to re-read a report.
+        Use 'grype.summary.json' (written for large scans: counts plus Critical/High findings)
+        or 'grype.json' for vulnerability data ('harbor-scan.json' when imported from Harbor),
+        'dockle.json' for CIS benchmark,
+        'dive.json' for layer efficiency, 'history.json' for the build history (the command behind each layer),
+        'dockerfile-layer-map.json' for the submitted Dockerfile's lines mapped to the layers they produced,
+        'score.json' for the authoritative Score Card numbers,
+        'baseline.json' for the changes since the previous scan of the same image,
+        'platforms.json' for the per-platform comparison of a multi-arch image,
+        'draft.md' or 'report.md' to re-read a report.
         """
         if filename not in ALLOWED_READ:
             return f"Error: '{filename}' not allowed. Choose from: {', '.join(sorted(ALLOWED_READ))}"
         try:
-            return read_artifact(job_id, filename).decode()
+            return read_artifact(job_id, filename, user_id).decode()
         except Exception as e:
             return f"{filename} not available: {e}"
 
+    @function_tool
+    def read_platform_scan(artifact: str) -> str:
+        """Read the full grype result of one platform of a multi-arch image, named by the "artifact"
+        field of a platforms.json entry (e.g. 'grype.linux-arm64.json').
+        """
+        if not PLATFORM_SCAN.fullmatch(artifact):
+            return f"Error: '{artifact}' is not a per-platform grype result (grype.<os>-<arch>[-<variant>].json)"
+        try:
+            return read_artifact(job_id, artifact, user_id).decode()
+        except Exception as e:
+            return f"{artifact} not available: {e}"
+
     @function_tool
     def write_file(filename: Literal["report.md", "draft.md"], content: str) -> str:
         """Write report content to report.md or draft.md for the current job."""
         if filename not in ALLOWED_WRITE:
             return f"Error: '{filename}' not allowed. Choose from: {', '.join(sorted(ALLOWED_WRITE))}"
         try:
-            write_artifact(job_id, filename, content.encode(), "text/markdown")
+            write_artifact(job_id, filename, content.encode(), "text/markdown", user_id)
             return f"OK: {filename} saved ({len(content)} bytes)"
         except Exception as e:
             return f"Error writing {filename}: {e}"
 
-    return [read_file, write_file]
+    return [read_file, read_platform_scan, write_file]
 
 
 # ── Flow ──────────────────────────────────────────────────────────────────────
 
-async def run_flow(job_id: str, cfg: ProviderConfig) -> str:
+async def run_flow(job_id: str, cfg: ProviderConfig, unavailable_tools: list[str] | None = None, user_id: str = "",
+                   scoring: Scoring | None = None) -> str:
     """
     Supervisor writes draft.md → hands off to Critique → Critique hands off back
     to Supervisor (REVISE) or finishes (APPROVE). Max 3 revisions.
+
+    unavailable_tools names scanners that failed for this job; the report notes
+    them as unavailable instead of the whole flow failing. scoring is the owner's
+    report template weights, used when score.json is missing.
     """
+    scoring = scoring or Scoring()
     client = cfg.openai_client()
     model = OpenAIChatCompletionsModel(model=cfg.model_id, openai_client=client)
 
-    scan_tools = make_scan_tools(job_id)
+    scan_tools = make_scan_tools(job_id, user_id)
 
     # Forward-declare so agents can reference each other
     supervisor: Agent
@@ -66,14 +123,29 @@ async def run_flow(job_id: str, cfg: ProviderConfig) -> str:
         instructions="""You are a container image security analyst writing a professional report.
 
 ## WORKFLOW:
-1. Call read_file(filename="grype.json") for vulnerability data.
+1. Call read_file(filename="grype.summary.json") for vulnerability data (counts plus every Critical/High finding).
+   If it is not available, call read_file(filename="grype.json") instead.
+   If neither exists, call read_file(filename="harbor-scan.json"): the vulnerabilities were imported from
+   Harbor's built-in scanner (named in its "scanner" field). Say so in the Vulnerability Analysis section.
 2. Call read_file(filename="dockle.json") for CIS benchmark data.
 3. Call read_file(filename="dive.json") for layer efficiency data.
-4. If you received critique feedback, call read_file(filename="draft.md") to read the previous draft.
-5. Write your complete Markdown report using write_file(filename="draft.md", content=...).
-6. Hand off to CritiqueAgent for review.
+   Also call read_file(filename="history.json"): the image's build history from its config, with the command
+   (createdBy) that produced each layer.
+   Also call read_file(filename="dockerfile-layer-map.json"). It exists only when a Dockerfile was submitted: it maps
+   each Dockerfile line to the layer it produced, with drift findings (different_dockerfile, large_layer, cache_busting).
+4. Call read_file(filename="score.json") for the authoritative Score Card numbers.
+   Also call read_file(filename="baseline.json"). If it exists (the image was scanned before), add a short
+   "Change since last scan" paragraph to the Summary: new and fixed CVEs (cves_compared=false means they
+   weren't compared) and the image size change. If it doesn't exist, leave that paragraph out.
+   Also call read_file(filename="platforms.json"). If it exists, the image is multi-arch and every platform was
+   scanned; the other data is for linux/amd64. Add per-platform severity counts to the Vulnerability Analysis and
+   call out each platform_specific finding with the platforms that have it. Use read_platform_scan with a platform's
+   "artifact" only when you need more detail than platforms.json gives.
+5. If you received critique feedback, call read_file(filename="draft.md") to read the previous draft.
+6. Write your complete Markdown report using write_file(filename="draft.md", content=...).
+7. Hand off to CritiqueAgent for review.
 
-## Report Structure (all 7 sections required):
+## Report Structure (all 8 sections required):
 ### Summary
 ### Vulnerability Analysis
 Start with a summary table using **bold** labels:
@@ -84,7 +156,11 @@ Start with a summary table using **bold** labels:
 | **Medium** | N |
 | **Low** | N |
 | **Total** | N |
-Then discuss the highest-risk components and recommended actions.
+Then a **Quick wins** subsection: list the quick_wins from score.json (Critical/High CVEs remediable by a
+version bump) as | **CVE ID** | **Package** | **Installed Version** | **Fix Version** |, noting the fix_available
+counts. Omit the subsection if quick_wins is empty.
+Then discuss the highest-risk components and recommended actions, putting Critical CVEs with a fix available
+ahead of won't-fix or unfixed ones.
 ### CIS Benchmark Findings
 ### Layer Efficiency Analysis
 Start with a summary table using **bold** labels:
@@ -95,7 +171,17 @@ Start with a summary table using **bold** labels:
 | **Efficiency** | X% |
 | **Wasted Bytes** | X bytes (~X% user-space) |
 Then show relevant Dockerfile layer commands in ```dockerfile code blocks to illustrate inefficiencies.
+### Build History
+From history.json: | **#** | **Command** | **Layer** | **Size (MB)** |, oldest first, with each createdBy truncated to
+100 characters and the size taken from dive.json. Fold emptyLayer steps (ENV, CMD, ...) into one row per run of them.
+Call out risky steps: piping downloads into a shell, secrets in ENV/ARG, ADD from URLs, chmod 777, package installs
+without cleanup. If history.json is not available, write "Build history unavailable".
 ### Key Findings & Risk Assessment
+If score.json has a "signature" object: signed=false is a supply-chain finding (the image is not signed; recommend
+signing it with cosign); signed=true with verified=false means the signature could not be verified (quote its error);
+a "skipped" reason means the image came from a local daemon and its signature was not checked: report that as a
+finding (quote the reason) and recommend scanning it from the registry, without calling the image unsigned.
+Mention verified SLSA provenance and its builder when present.
 ### Score Card
 | **Metric** | **Value** | **Status** |
 |---|---|---|
@@ -103,14 +189,18 @@ Then show relevant Dockerfile layer commands in ```dockerfile code blocks to ill
 | **Image Efficiency** | X% | 🔴/🟡/🟢 |
 | **CIS Compliance** | X / Y passed | 🔴/🟡/🟢 |
 | **Critical CVEs** | N | 🔴/🟡/🟢 |
-Score: start at 100. Deduct Critical=-10, High=-5, FATAL=-8, WARN=-3.
+Copy security_score, efficiency, CIS passed/checked, critical_cves and each *_status (green=🟢, yellow=🟡, red=🔴)
+from score.json verbatim — do NOT recalculate them.
+Only if score.json is unavailable: """ + scoring.rule() + """
 ### Recommended Dockerfile Improvements
 Every recommendation MUST include a concrete ```dockerfile code block showing the improved Dockerfile snippet. Show before/after where applicable.
+If dockerfile-layer-map.json is available, tie each change to its Dockerfile line number and layer size, and address
+every finding in it.
 
 ## STRICT OUTPUT RULES — violating any rule will trigger a revision:
 - The report title MUST be: `# Image Security Report` — no job IDs, UUIDs, or agent names in the title.
 - Do NOT include job IDs, UUIDs, or internal identifiers anywhere in the report.
-- Do NOT reference scan file names (grype.json, dockle.json, dive.json) in the report body.
+- Do NOT reference scan file names (grype.summary.json, grype.json, harbor-scan.json, dockle.json, dive.json, history.json, dockerfile-layer-map.json, score.json, baseline.json, platforms.json or grype.<platform>.json) in the report body.
 - Do NOT add footers, sign-offs, "Prepared by", "Next step", "handoff" notes, or any meta-commentary.
 - Do NOT mention agent names (SupervisorAgent, CritiqueAgent, Reefline) anywhere.
 - Do NOT include any trailing text after the last section — no signatures, no "next steps", no attribution lines.
@@ -129,15 +219,15 @@ NEVER fabricate CVE IDs, dockle codes, or file paths.""",
 
 ## WORKFLOW:
 1. Call read_file(filename="draft.md") to read the current draft report.
-2. Review it carefully.
+2. Review it carefully. To verify a CVE ID, package or severity cited in the draft, you may read grype.json (the full scan), or harbor-scan.json when vulnerabilities were imported from Harbor.
 3. If APPROVED: call write_file(filename="report.md", content=<the full draft content>) to publish, then output your verdict.
 4. If REVISE: hand off back to SupervisorAgent with your feedback.
 
 ## APPROVE if ALL true:
-- All 7 sections present: Summary, Vulnerability Analysis, CIS Benchmark Findings, Layer Efficiency Analysis, Key Findings & Risk Assessment, Score Card, Recommended Dockerfile Improvements
+- All 8 sections present: Summary, Vulnerability Analysis, CIS Benchmark Findings, Layer Efficiency Analysis, Build History, Key Findings & Risk Assessment, Score Card, Recommended Dockerfile Improvements
 - Vulnerability Analysis starts with a severity breakdown table (Critical/High/Medium/Low/Total)
 - Layer Efficiency Analysis starts with a metrics table (Total Image Size, User-space Size, Efficiency, Wasted Bytes)
-- Score Card has real numbers (not placeholders)
+- Score Card has real numbers (not placeholders) that match score.json (call read_file(filename="score.json") to check)
 - Recommended Dockerfile Improvements include ```dockerfile code blocks
 - No empty tables or unexplained N/A
 - Title is exactly `# Image Security Report` with no UUIDs or job IDs
@@ -159,9 +249,18 @@ Output (10 lines max):
 
     log.info("Starting flow for job=%s provider=%s model=%s", job_id, cfg.provider, cfg.model_id)
 
+    task = "Fetch all available scan data, analyze the results, and produce a complete Image Security Report as draft.md."
+    if unavailable_tools:
+        missing = ", ".join(unavailable_tools)
+        task += (
+            f"\n\nThe following analyses failed and their data is unavailable: {missing}. "
+            "Do not try to read their files. In each affected section write a single line such as "
+            "\"Dive analysis unavailable for this image.\" and base the rest of the report on the data that exists."
+        )
+
     result = await Runner.run(
         supervisor,
-        "Fetch all available scan data, analyze the results, and produce a complete Image Security Report as draft.md.",
+        task,
         max_turns=1000,
     )
 
@@ -3,6 +3,7 @@ import json
 
 import psycopg2
 import psycopg2.extras
+from cryptography.exceptions import InvalidTag
 from cryptography.hazmat.primitives.ciphers.aead import AESGCM
 
 from settings import settings
@@ -22,13 +23,21 @@ def _decrypt_credentials(encrypted: str) -> dict:
     """
     Decrypt AES-256-GCM credentials stored by the Go server.
     Format: base64(nonce[12] || ciphertext+tag)
-    Key: base64-encoded 32-byte key from ENCRYPTION_KEY env var.
+    Key: base64-encoded 32-byte key from ENCRYPTION_KEY env var, falling back
+    to ENCRYPTION_KEY_PREVIOUS keys during a rotation.
     """
-    key = base64.b64decode(settings.encryption_key)
+    keys = [settings.encryption_key] + [
+        k.strip() for k in settings.encryption_key_previous.split(",") if k.strip()
+    ]
     data = base64.b64decode(encrypted)
     nonce, ciphertext = data[:12], data[12:]
-    plaintext = AESGCM(key).decrypt(nonce, ciphertext, None)
-    return json.loads(plaintext)
+    for i, key in enumerate(keys):
+        try:
+            plaintext = AESGCM(base64.b64decode(key)).decrypt(nonce, ciphertext, None)
+            return json.loads(plaintext)
+        except InvalidTag:
+            if i == len(keys) - 1:
+                raise
 
 
 def get_ai_credentials(provider: str, user_id: str = "admin") -> dict | None:
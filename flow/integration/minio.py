@@ -1,4 +1,7 @@
+import gzip
 import json
+import re
+
 from minio import Minio
 from settings import settings
 
@@ -17,21 +20,42 @@ def get_client() -> Minio:
     return _client
 
 
-def read_artifact(job_id: str, filename: str) -> bytes:
+def artifact_location(job_id: str, user_id: str = "") -> tuple[str, str]:
+    """Return (bucket, job prefix) for a job — mirrors storage.ArtifactLocation in Go."""
     bucket = settings.minio_default_bucket
-    object_name = f"{job_id}/artifacts/{filename}"
+    layout = settings.artifact_layout.lower()
+    if not user_id:
+        return bucket, f"{job_id}/"
+    if layout == "user":
+        segment = user_id.replace("/", "_").replace("\\", "_")
+        if segment in (".", ".."):
+            segment = "_"
+        return bucket, f"{segment}/{job_id}/"
+    if layout == "bucket":
+        name = bucket + "-" + re.sub(r"[^a-z0-9-]+", "-", user_id.lower())
+        return name[:63].strip("-"), f"{job_id}/"
+    return bucket, f"{job_id}/"
+
+
+def read_artifact(job_id: str, filename: str, user_id: str = "") -> bytes:
+    bucket, prefix = artifact_location(job_id, user_id)
+    object_name = f"{prefix}artifacts/{filename}"
     response = get_client().get_object(bucket, object_name)
     try:
-        return response.read()
+        data = response.read()
+        # The worker gzips JSON artifacts (Content-Encoding: gzip)
+        if data[:2] == b"\x1f\x8b":
+            data = gzip.decompress(data)
+        return data
     finally:
         response.close()
         response.release_conn()
 
 
-def write_artifact(job_id: str, filename: str, content: bytes, content_type: str = "text/plain") -> None:
+def write_artifact(job_id: str, filename: str, content: bytes, content_type: str = "text/plain", user_id: str = "") -> None:
     import io
-    bucket = settings.minio_default_bucket
-    object_name = f"{job_id}/artifacts/{filename}"
+    bucket, prefix = artifact_location(job_id, user_id)
+    object_name = f"{prefix}artifacts/{filename}"
     get_client().put_object(
         bucket, object_name,
         io.BytesIO(content), len(content),
@@ -7,7 +7,7 @@ from dotenv import load_dotenv
 from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel
 
-from flow import run_flow
+from flow import Scoring, run_flow
 from integration.db import get_ai_credentials, get_job
 from provider import ProviderConfig
 
@@ -40,6 +40,8 @@ app = FastAPI(title="Reefline Flow Service", lifespan=lifespan)
 class ReportRequest(BaseModel):
     job_id: str
     provider: str = "openai"  # used to pick the integration row if multiple exist
+    unavailable_tools: list[str] = []  # tools that failed; their artifacts are missing
+    scoring: Scoring = Scoring()  # the owner's report template weights
 
 
 class ReportResponse(BaseModel):
@@ -71,7 +73,7 @@ async def generate_report(req: ReportRequest):
 
     # 3. Run the Supervisor → Critique flow
     try:
-        report = await run_flow(req.job_id, cfg)
+        report = await run_flow(req.job_id, cfg, req.unavailable_tools, job.get("user_id") or "", req.scoring)
     except Exception as e:
         raise HTTPException(status_code=500, detail=str(e))
 
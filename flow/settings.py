@@ -11,6 +11,8 @@ class Settings(BaseSettings):
 
     # Encryption (AES-256-GCM — must match Go server's ENCRYPTION_KEY)
     encryption_key: str = ""
+    # Retired keys (comma-separated) still accepted for decryption during a rotation
+    encryption_key_previous: str = ""
 
     # MinIO
     minio_endpoint: str = "localhost:9000"
@@ -18,6 +20,8 @@ class Settings(BaseSettings):
     minio_secret_key: str = "minioadmin"
     minio_use_ssl: bool = False
     minio_default_bucket: str = "reefline"
+    # Artifact layout — must match the Go server's ARTIFACT_LAYOUT (job | user | bucket)
+    artifact_layout: str = "job"
 
     class Config:
         env_file = "../.env"
@@ -0,0 +1,76 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/adk/middlewares/reduction"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+)
+
+// NewCompareAgent creates the Compare Agent, which explains what changed
+// between a baseline job and a candidate job (e.g. before and after applying
+// the report's recommendations).
+// tools should include: list_scan_files, read_scan_file, write_comparison.
+func NewCompareAgent(ctx context.Context, cm model.ToolCallingChatModel, tools []tool.BaseTool, baselineJobID, candidateJobID string) (adk.Agent, error) {
+	instruction := fmt.Sprintf(`You are the Compare Agent for Reefline — a container image security and hygiene analysis platform.
+
+Baseline Job ID: %s
+Candidate Job ID: %s
+
+## MANDATORY WORKFLOW — follow in order:
+
+1. Call list_scan_files for both job IDs to confirm which artifacts exist.
+2. For each job, read the vulnerability data: grype.summary.json if listed, otherwise grype.json.
+3. For each job, read score.json if listed.
+4. If both jobs have dive.json, read them for the size and layer comparison.
+5. **REQUIRED — call write_comparison with your complete Markdown comparison. Do NOT output it in your reply.**
+
+**Paginating large files:** read_scan_file returns at most ~40 KB per call. If the response contains "[TRUNCATED]", call read_scan_file again with the returned offset value.
+
+---
+
+## Comparison Structure
+
+### Summary
+Two or three sentences: did the candidate improve, regress or stay the same, and why.
+
+### Vulnerabilities
+A table of counts per severity (Critical, High, Medium, Low) for baseline, candidate and the change.
+Then list the CVEs fixed in the candidate and the CVEs newly introduced, with package and version, Critical and High first.
+
+### Score and Image Size
+Security score, efficiency, image size and layer count for both jobs, from score.json and dive.json.
+
+### Takeaways
+A short bullet list of what caused the changes and what to do next.
+
+Only compare what both jobs have data for; say which artifact is missing otherwise.
+NEVER fabricate CVE IDs, package names or numbers.`, baselineJobID, candidateJobID)
+
+	// Two jobs' grype results can be large; clear old tool results like the supervisor does
+	clearMiddleware, err := reduction.NewClearToolResult(ctx, &reduction.ClearToolResultConfig{
+		ToolResultTokenThreshold:   24000,
+		KeepRecentTokens:           40000,
+		ClearToolResultPlaceholder: "[scan data already processed — not repeated]",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating reduction middleware: %w", err)
+	}
+
+	return adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
+		Name:        "CompareAgent",
+		Description: "Reads the scan artifacts of two jobs and writes a narrative of what changed between them.",
+		Instruction: instruction,
+		Model:       cm,
+		ToolsConfig: adk.ToolsConfig{
+			ToolsNodeConfig: compose.ToolsNodeConfig{
+				Tools: tools,
+			},
+		},
+		Middlewares: []adk.AgentMiddleware{clearMiddleware},
+	})
+}
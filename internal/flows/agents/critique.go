@@ -10,14 +10,18 @@ import (
 
 // NewCritiqueAgent creates the Critique Agent.
 // The draft report is injected directly into the user message — no tools needed.
-func NewCritiqueAgent(ctx context.Context, cm model.ToolCallingChatModel, jobID string) (adk.Agent, error) {
+// tmpl must match the supervisor's; nil uses DefaultReportTemplate.
+func NewCritiqueAgent(ctx context.Context, cm model.ToolCallingChatModel, jobID string, tmpl *ReportTemplate) (adk.Agent, error) {
+	if tmpl == nil {
+		tmpl = DefaultReportTemplate()
+	}
 	instruction := fmt.Sprintf(`You are the Critique Agent for Reefline. Job ID: %s
 
 Review the security report provided in the user message. Be brief.
 
 ## APPROVE if all of these are true:
-- All 8 sections present: Overview, Summary, Vulnerability Analysis, CIS Benchmark Findings, Layer Efficiency Analysis, Key Findings & Risk Assessment, Score Card, Recommended Dockerfile Improvements
-- Score Card filled in with real numbers (not placeholders)
+- All %d sections present: %s
+- Score Card filled in with real numbers (not placeholders). When score.json is included below the report, the Score Card must match it exactly; otherwise it must follow: %s
 - No empty tables or "N/A" without explanation
 
 ## REVISE if any section is missing or Score Card has placeholder values.
@@ -27,7 +31,7 @@ Review the security report provided in the user message. Be brief.
 **Issues:** bullet list of specific problems (omit if none)
 **Fix:** numbered list of exact corrections for Supervisor (omit if APPROVE)
 
-Do NOT rewrite the report. Be concise — 10 lines max.`, jobID)
+Do NOT rewrite the report. Be concise — 10 lines max.`, jobID, len(tmpl.Sections), tmpl.sectionTitles(), tmpl.scoringRules())
 
 	return adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
 		Name:        "CritiqueAgent",
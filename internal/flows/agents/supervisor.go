@@ -13,7 +13,11 @@ import (
 
 // NewSupervisorAgent creates the Supervisor Agent for a specific job.
 // tools should include: list_scan_files, read_scan_file, write_draft.
-func NewSupervisorAgent(ctx context.Context, cm model.ToolCallingChatModel, tools []tool.BaseTool, jobID string) (adk.Agent, error) {
+// tmpl sets the report sections and scoring; nil uses DefaultReportTemplate.
+func NewSupervisorAgent(ctx context.Context, cm model.ToolCallingChatModel, tools []tool.BaseTool, jobID string, tmpl *ReportTemplate) (adk.Agent, error) {
+	if tmpl == nil {
+		tmpl = DefaultReportTemplate()
+	}
 	instruction := fmt.Sprintf(`You are the Supervisor Agent for Reefline — a container image security and hygiene analysis platform.
 
 Current Job ID: %s
@@ -21,11 +25,19 @@ Current Job ID: %s
 ## MANDATORY WORKFLOW — follow in order, every time:
 
 1. Call list_scan_files to confirm which artifacts exist.
-2. Call read_scan_file with filename="grype.json" to read vulnerability data.
+2. Read vulnerability data: if list_scan_files shows grype.summary.json, read that (severity counts plus every Critical/High finding) instead of grype.json; otherwise read grype.json.
+   If only harbor-scan.json exists, read it instead: the vulnerabilities were imported from Harbor's built-in scanner (named in its "scanner" field). Say so in the vulnerability section.
+   If list_scan_files shows platforms.json, read it too: the image is multi-arch and every platform was scanned. The other data is for linux/amd64; add per-platform severity counts to the vulnerability section and call out each platform_specific finding with the platforms that have it.
 3. Call read_scan_file with filename="dockle.json" to read CIS benchmark data.
 4. Call read_scan_file with filename="dive.json" to read layer efficiency data.
-5. If you received a REVISE message, call read_scan_file with filename="report.md" to re-read the previous report.
-6. **REQUIRED — call write_draft with your complete Markdown report. Do NOT output the report in your reply — write it using the write_draft tool. Your turn is not complete until write_draft succeeds.**
+   If list_scan_files shows history.json, read it too: the image's build history from its config, with the command (createdBy) that produced each layer.
+   If list_scan_files shows dockerfile-layer-map.json, read it too: it maps each line of the submitted Dockerfile to the layer it produced, with drift findings.
+   If an artifact is missing (the tool failed), skip its read step and write "<Tool> analysis unavailable" in that report section instead of stopping.
+5. Call read_scan_file with filename="score.json". It holds the authoritative Score Card numbers computed from the scan data.
+   If list_scan_files shows baseline.json, read it too: it compares this scan with the previous scan of the same image.
+   Add a short "Change since last scan" paragraph to the Summary with the new and fixed CVEs (cves_compared=false means they weren't compared) and the image size change.
+6. If you received a REVISE message, call read_scan_file with filename="draft.md" to re-read your latest draft (earlier versions are draft.v1.md, draft.v2.md, ...).
+7. **REQUIRED — call write_draft with your complete Markdown report. Do NOT output the report in your reply — write it using the write_draft tool. Your turn is not complete until write_draft succeeds.**
 
 **Paginating large files:** read_scan_file returns at most ~40 KB per call. If the response contains "[TRUNCATED]", call read_scan_file again with the returned offset value.
 
@@ -35,48 +47,16 @@ Current Job ID: %s
 
 Produce a document with exactly these sections in order:
 
-### Overview
-- Image name, total size (MB/GB from dive), layer count, scan timestamp
-
-### Summary
-5-8 sentences. Most critical finding first. Direct, no filler language.
-
-### Vulnerability Analysis
-- Severity breakdown table: Critical / High / Medium / Low / Unknown counts
-- Table for every Critical and High CVE: | CVE ID | Package | Installed Version | Fix Version | Severity |
-- Cite CVE IDs verbatim from grype. Do not fabricate CVE numbers.
-
-### CIS Benchmark Findings
-- Summary table: Fatal / Warn / Info / Pass counts
-- Table for every FATAL and WARN: | Code | Title | Level | Alert Detail |
-- Cite dockle codes verbatim (e.g. CIS-DI-0001). Do not fabricate codes.
-
-### Layer Efficiency Analysis (Dive)
-- Efficiency score %%, total size, wasted bytes (human-readable)
-- Layer table: index, command (truncated to 80 chars), size in MB
-- Top inefficiencies: paths and wasted bytes
-
-### Key Findings & Risk Assessment
-Prioritized list (Critical first). For each:
-- **Finding**, **Evidence** (CVE ID / dockle code / layer), **Risk**, **Recommended Action**
-
-### Score Card
-| Metric | Value | Status |
-|---|---|---|
-| Security Score | X / 100 | 🔴/🟡/🟢 |
-| Image Efficiency | X%% | 🔴/🟡/🟢 |
-| CIS Compliance | X / Y passed | 🔴/🟡/🟢 |
-| Critical CVEs | N | 🔴/🟡/🟢 |
-
-Score: start at 100. Deduct Critical CVE=-10, High=-5, FATAL dockle=-8, WARN dockle=-3.
+%s
 
-### Recommended Dockerfile Improvements
-Concrete changes with before/after snippets. Based strictly on scan data.
+Score Card rules: copy security_score, efficiency, CIS passed/checked, critical_cves and each *_status
+(green=🟢, yellow=🟡, red=🔴) from score.json verbatim — do NOT recalculate them. Only if score.json
+is missing, compute them yourself: %s
 
 ## References
 [N]: source · field = "value"
 
-NEVER fabricate CVE IDs, dockle codes, or file paths.`, jobID)
+NEVER fabricate CVE IDs, dockle codes, or file paths.`, jobID, tmpl.structure(), tmpl.scoringRules())
 
 	// Clear old tool results when they exceed ~24k tokens, keeping the last ~40k tokens intact.
 	// This prevents the agent from exceeding the model's 128k context window when grype.json
@@ -92,7 +72,7 @@ NEVER fabricate CVE IDs, dockle codes, or file paths.`, jobID)
 
 	return adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
 		Name:        "SupervisorAgent",
-		Description: "Reads Grype/Dockle/Dive scan artifacts and writes a complete security report as report.md.",
+		Description: "Reads Grype/Dockle/Dive scan artifacts and writes a complete security report draft.",
 		Instruction: instruction,
 		Model:       cm,
 		ToolsConfig: adk.ToolsConfig{
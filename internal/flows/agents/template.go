@@ -0,0 +1,172 @@
+package agents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+)
+
+// ReportTemplate parameterizes the report the agents produce: which sections
+// it has, how the security score is computed and where the 🔴/🟡/🟢 status
+// thresholds sit. Supervisor and critique instructions are both built from
+// the same template so they always agree.
+type ReportTemplate struct {
+	Sections   []ReportSection    `json:"sections"`
+	BaseScore  int                `json:"base_score"`
+	Deductions scoring.Deductions `json:"deductions"`
+	Thresholds scoring.Thresholds `json:"thresholds"`
+}
+
+// ReportSection is a single "### Title" section and what it must contain
+type ReportSection struct {
+	Title    string `json:"title"`
+	Guidance string `json:"guidance"` // Markdown instructions for the supervisor
+}
+
+// DefaultReportTemplate returns the built-in Reefline report structure.
+func DefaultReportTemplate() *ReportTemplate {
+	weights := scoring.DefaultWeights()
+	return &ReportTemplate{
+		Sections: []ReportSection{
+			{Title: "Overview", Guidance: "- Image name, total size (MB/GB from dive), layer count, scan timestamp"},
+			{Title: "Summary", Guidance: "5-8 sentences. Most critical finding first. Direct, no filler language."},
+			{Title: "Vulnerability Analysis", Guidance: "- Severity breakdown table: Critical / High / Medium / Low / Unknown counts\n" +
+				"- Table for every Critical and High CVE: | CVE ID | Package | Installed Version | Fix Version | Severity |\n" +
+				"- Quick wins subsection: list score.json quick_wins (Critical/High CVEs a version bump fixes) as | CVE ID | Package | Installed Version | Fix Version |, with fix_available counts; omit it if there are none\n" +
+				"- List CVEs with a fix available before won't-fix/unfixed ones of the same severity\n" +
+				"- Cite CVE IDs verbatim from grype. Do not fabricate CVE numbers."},
+			{Title: "CIS Benchmark Findings", Guidance: "- Summary table: Fatal / Warn / Info / Pass counts\n" +
+				"- Table for every FATAL and WARN: | Code | Title | Level | Alert Detail |\n" +
+				"- Cite dockle codes verbatim (e.g. CIS-DI-0001). Do not fabricate codes."},
+			{Title: "Layer Efficiency Analysis (Dive)", Guidance: "- Efficiency score %, total size, wasted bytes (human-readable)\n" +
+				"- Layer table: index, command (truncated to 80 chars), size in MB\n" +
+				"- Top inefficiencies: paths and wasted bytes"},
+			{Title: "Build History", Guidance: "- From history.json: | # | Command (createdBy, truncated to 100 chars) | Layer | Size (MB, from dive) |, oldest first; fold emptyLayer steps (ENV, CMD, ...) into one row per run of them\n" +
+				"- Call out risky steps: piping downloads into a shell, secrets in ENV/ARG, ADD from URLs, chmod 777, package installs without cleanup\n" +
+				"- If history.json is missing, write \"Build history unavailable\""},
+			{Title: "Key Findings & Risk Assessment", Guidance: "Prioritized list (Critical with a fix available first, then Critical without one, then High). For each:\n" +
+				"- **Finding**, **Evidence** (CVE ID / dockle code / layer), **Risk**, **Recommended Action**\n" +
+				"- If score.json has a signature: signed=false is a supply-chain finding (image not signed; recommend signing with cosign); signed but verified=false means the signature couldn't be verified (give its error); a skipped reason means it came from a local daemon and wasn't checked: report that (quote it) and recommend a registry scan, without calling the image unsigned; note verified SLSA provenance and its builder"},
+			{Title: "Score Card", Guidance: "| Metric | Value | Status |\n|---|---|---|\n" +
+				"| Security Score | X / 100 | 🔴/🟡/🟢 |\n" +
+				"| Image Efficiency | X% | 🔴/🟡/🟢 |\n" +
+				"| CIS Compliance | X / Y passed | 🔴/🟡/🟢 |\n" +
+				"| Critical CVEs | N | 🔴/🟡/🟢 |"},
+			{Title: "Recommended Dockerfile Improvements", Guidance: "Concrete changes with before/after snippets. Based strictly on scan data.\n" +
+				"- If dockerfile-layer-map.json exists, tie each change to its Dockerfile line and layer size, and address every finding (different_dockerfile, large_layer, cache_busting)"},
+		},
+		BaseScore:  weights.BaseScore,
+		Deductions: weights.Deductions,
+		Thresholds: weights.Thresholds,
+	}
+}
+
+// Weights returns the template's scoring configuration for pkg/scoring.
+func (t *ReportTemplate) Weights() scoring.Weights {
+	return scoring.Weights{
+		BaseScore:  t.BaseScore,
+		Deductions: t.Deductions,
+		Thresholds: t.Thresholds,
+	}
+}
+
+// LoadReportTemplate reads a JSON ReportTemplate from path.
+func LoadReportTemplate(path string) (*ReportTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading report template: %w", err)
+	}
+	return ParseReportTemplate(data)
+}
+
+// ParseReportTemplate decodes and validates a JSON ReportTemplate.
+func ParseReportTemplate(data []byte) (*ReportTemplate, error) {
+	var t ReportTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing report template: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks that the template can produce a sensible report.
+func (t *ReportTemplate) Validate() error {
+	if len(t.Sections) == 0 {
+		return errors.New("report template needs at least one section")
+	}
+	seen := map[string]bool{}
+	for i, s := range t.Sections {
+		title := strings.TrimSpace(s.Title)
+		if title == "" {
+			return fmt.Errorf("section %d has no title", i+1)
+		}
+		if seen[strings.ToLower(title)] {
+			return fmt.Errorf("duplicate section %q", title)
+		}
+		seen[strings.ToLower(title)] = true
+	}
+	if t.BaseScore <= 0 {
+		return errors.New("base_score must be positive")
+	}
+	d := t.Deductions
+	if d.Critical < 0 || d.High < 0 || d.Medium < 0 || d.Low < 0 || d.Fatal < 0 || d.Warn < 0 || d.Info < 0 {
+		return errors.New("deductions must not be negative")
+	}
+	if t.Thresholds.Yellow < 0 || t.Thresholds.Green <= t.Thresholds.Yellow || t.Thresholds.Green > t.BaseScore {
+		return fmt.Errorf("thresholds must satisfy 0 <= yellow < green <= base_score (%d)", t.BaseScore)
+	}
+	return nil
+}
+
+// sectionTitles returns the section titles as a comma-separated list.
+func (t *ReportTemplate) sectionTitles() string {
+	titles := make([]string, len(t.Sections))
+	for i, s := range t.Sections {
+		titles[i] = s.Title
+	}
+	return strings.Join(titles, ", ")
+}
+
+// structure renders the sections as Markdown for the supervisor instruction.
+func (t *ReportTemplate) structure() string {
+	var sb strings.Builder
+	for _, s := range t.Sections {
+		fmt.Fprintf(&sb, "### %s\n", s.Title)
+		if s.Guidance != "" {
+			sb.WriteString(s.Guidance)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// scoringRules describes how to compute the score and its status.
+func (t *ReportTemplate) scoringRules() string {
+	d := t.Deductions
+	var deductions []string
+	for _, r := range []struct {
+		name   string
+		points int
+	}{
+		{"Critical CVE", d.Critical}, {"High", d.High}, {"Medium", d.Medium}, {"Low", d.Low},
+		{"FATAL dockle", d.Fatal}, {"WARN dockle", d.Warn}, {"INFO dockle", d.Info},
+	} {
+		if r.points > 0 {
+			deductions = append(deductions, fmt.Sprintf("%s=-%d", r.name, r.points))
+		}
+	}
+	rules := fmt.Sprintf("Score: start at %d.", t.BaseScore)
+	if len(deductions) > 0 {
+		rules += " Deduct " + strings.Join(deductions, ", ") + "."
+	}
+	rules += " Never go below 0."
+	return rules + fmt.Sprintf(" Status: 🟢 if score >= %d, 🟡 if score >= %d, otherwise 🔴.",
+		t.Thresholds.Green, t.Thresholds.Yellow)
+}
@@ -0,0 +1,89 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Retry and circuit breaker settings for AI provider calls. Set from
+// FLOW_RETRY_COUNT, FLOW_BREAKER_THRESHOLD and FLOW_BREAKER_COOLDOWN_SECONDS
+// by the worker on startup.
+var (
+	// RetryCount is how often a request failing with a transient error (5xx,
+	// timeout, dropped connection) is retried. Rate limits (429) are retried
+	// separately; other errors (invalid key, bad request) never are.
+	RetryCount = 1
+	// BreakerThreshold is the number of consecutive transient failures after
+	// which a provider is skipped in favour of the next connected one. 0
+	// disables the breaker.
+	BreakerThreshold = 3
+	// BreakerCooldown is how long a tripped provider is skipped
+	BreakerCooldown = time.Minute
+)
+
+// breaker tracks provider health across flows run by this process
+var breaker = &circuitBreaker{
+	failures:  make(map[Provider]int),
+	openUntil: make(map[Provider]time.Time),
+}
+
+// circuitBreaker counts consecutive transient failures per provider
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  map[Provider]int
+	openUntil map[Provider]time.Time
+}
+
+// allow reports whether p should be used, i.e. its circuit isn't open
+func (b *circuitBreaker) allow(p Provider) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().After(b.openUntil[p])
+}
+
+// success resets p's failure count
+func (b *circuitBreaker) success(p Provider) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[p] = 0
+}
+
+// failure records a transient failure of p, opening its circuit for
+// BreakerCooldown once BreakerThreshold are reached in a row
+func (b *circuitBreaker) failure(p Provider) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[p]++
+	if BreakerThreshold > 0 && b.failures[p] >= BreakerThreshold {
+		b.openUntil[p] = time.Now().Add(BreakerCooldown)
+		b.failures[p] = 0
+		log.Printf("[Flow] provider=%s failed %d times in a row — skipping it for %s", p, BreakerThreshold, BreakerCooldown)
+	}
+}
+
+// transientStatus reports whether an HTTP status is worth retrying (the
+// provider is overloaded or down, not rejecting the request)
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout, 529: // 529: Anthropic "overloaded"
+		return true
+	}
+	return false
+}
+
+// transientError reports whether a transport error is worth retrying. The
+// request's own cancellation isn't.
+func transientError(req *http.Request, err error) bool {
+	if req.Context().Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
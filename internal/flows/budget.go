@@ -0,0 +1,78 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Artifact read limits per flow run. Set from FLOW_MAX_ARTIFACT_READS and
+// FLOW_MAX_ARTIFACT_READ_BYTES by the worker on startup; 0 disables a limit.
+var (
+	// MaxArtifactReads caps the read_scan_file and list_scan_files calls of
+	// one run, so a model stuck re-reading the same chunk can't loop forever
+	MaxArtifactReads = 200
+	// MaxArtifactReadBytes caps the bytes fetched from object storage in one
+	// run. A read at an offset fetches and skips everything before it, so
+	// paging through a large file costs far more than its size.
+	MaxArtifactReadBytes int64 = 64 << 20
+)
+
+// ErrReadBudgetExceeded is returned by the read tools once a run used up its
+// artifact read budget. Tool errors end the agent run, and with it the flow.
+var ErrReadBudgetExceeded = errors.New("artifact read budget exceeded")
+
+// readBudget counts the artifact reads of one flow run
+type readBudget struct {
+	mu       sync.Mutex
+	maxReads int
+	maxBytes int64
+	reads    int
+	bytes    int64
+}
+
+type readBudgetKey struct{}
+
+// withReadBudget returns a context whose read tools share a fresh budget of
+// MaxArtifactReads and MaxArtifactReadBytes
+func withReadBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readBudgetKey{}, &readBudget{
+		maxReads: MaxArtifactReads,
+		maxBytes: MaxArtifactReadBytes,
+	})
+}
+
+// readBudgetFor returns the budget carried by ctx, or nil (unlimited)
+func readBudgetFor(ctx context.Context) *readBudget {
+	b, _ := ctx.Value(readBudgetKey{}).(*readBudget)
+	return b
+}
+
+// read records a tool call, failing once the run has made maxReads calls or
+// fetched maxBytes
+func (b *readBudget) read() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxBytes > 0 && b.bytes >= b.maxBytes {
+		return fmt.Errorf("%w: %d bytes read (limit %d)", ErrReadBudgetExceeded, b.bytes, b.maxBytes)
+	}
+	if b.maxReads > 0 && b.reads >= b.maxReads {
+		return fmt.Errorf("%w: %d reads (limit %d)", ErrReadBudgetExceeded, b.reads, b.maxReads)
+	}
+	b.reads++
+	return nil
+}
+
+// fetched records n bytes fetched from object storage
+func (b *readBudget) fetched(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += n
+}
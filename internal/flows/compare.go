@@ -0,0 +1,122 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+	"github.com/siddhantprateek/reefline/internal/flows/agents"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+)
+
+// comparisonArtifact is where RunCompareFlow stores its narrative, among the
+// candidate job's artifacts
+const comparisonArtifact = "comparison.md"
+
+type writeComparisonArgs struct {
+	Content string `json:"content" jsonschema:"description=Full Markdown content of the comparison"`
+}
+
+// NewWriteComparisonTool stores the comparison of baselineJobID and
+// candidateJobID as comparison.md in the candidate job's artifacts.
+func NewWriteComparisonTool(candidate storage.Location, baselineJobID string) (tool.BaseTool, error) {
+	return utils.InferTool(
+		"write_comparison",
+		"Save the Markdown comparison of the baseline and candidate jobs.",
+		func(ctx context.Context, args writeComparisonArgs) (string, error) {
+			if err := putMarkdown(ctx, candidate, comparisonArtifact, args.Content); err != nil {
+				return "", fmt.Errorf("writing %s: %w", comparisonArtifact, err)
+			}
+			return fmt.Sprintf("%s written (%d bytes, baseline %q)", comparisonArtifact, len(args.Content), baselineJobID), nil
+		},
+	)
+}
+
+// RunCompareFlow has the Compare Agent read the artifacts of both jobs and
+// write a narrative of what changed to the candidate job's comparison.md. The
+// read tools are limited to the two jobs, which must belong to the same user.
+func RunCompareFlow(ctx context.Context, baselineJobID, candidateJobID string, opts ...RunOption) error {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	creds, err := resolveCredentials(candidateJobID)
+	if err != nil {
+		return fmt.Errorf("resolving credentials: %w", err)
+	}
+	var baseline models.Job
+	if err := database.DB.WithContext(ctx).Select("user_id").Where("job_id = ?", baselineJobID).First(&baseline).Error; err != nil {
+		return fmt.Errorf("fetching job %s: %w", baselineJobID, err)
+	}
+	if baseline.UserID != creds.UserID {
+		return fmt.Errorf("jobs %s and %s belong to different users", baselineJobID, candidateJobID)
+	}
+
+	modelID, err := creds.model()
+	if err != nil {
+		return err
+	}
+	log.Printf("[Flow] compare provider=%s model=%s baseline=%s candidate=%s", creds.ProviderID, modelID, baselineJobID, candidateJobID)
+
+	usage := &usageTracker{}
+	defer func() {
+		if err := usage.flush(context.WithoutCancel(ctx), creds.UserID, creds.ProviderID, modelID); err != nil {
+			log.Printf("[Flow] failed to record usage for comparison of job=%s: %v", candidateJobID, err)
+		}
+	}()
+
+	cm, err := newChatModel(ctx, creds, modelID, o)
+	if err != nil {
+		return err
+	}
+
+	loc := storage.ArtifactLocation(creds.UserID, candidateJobID)
+	ctx = storage.WithArtifactMeta(ctx, storage.ArtifactMeta{
+		JobID:       candidateJobID,
+		Tool:        "compare-flow",
+		ToolVersion: creds.ProviderID + "/" + modelID,
+	})
+	ctx = withReadBudget(ctx)
+
+	listTool, err := NewListScanFilesTool(baselineJobID, candidateJobID)
+	if err != nil {
+		return fmt.Errorf("list_scan_files tool: %w", err)
+	}
+	readTool, err := NewReadScanFileTool(baselineJobID, candidateJobID)
+	if err != nil {
+		return fmt.Errorf("read_scan_file tool: %w", err)
+	}
+	writeTool, err := NewWriteComparisonTool(loc, baselineJobID)
+	if err != nil {
+		return fmt.Errorf("write_comparison tool: %w", err)
+	}
+
+	agent, err := agents.NewCompareAgent(ctx, cm, []tool.BaseTool{listTool, readTool, writeTool}, baselineJobID, candidateJobID)
+	if err != nil {
+		return fmt.Errorf("creating compare agent: %w", err)
+	}
+	input := &adk.AgentInput{Messages: []*schema.Message{schema.UserMessage(fmt.Sprintf(
+		"Compare baseline job_id=%q with candidate job_id=%q, then save the comparison with write_comparison.",
+		baselineJobID, candidateJobID,
+	))}}
+	if _, err := drainAgent(ctx, agent.Run(ctx, input), "CompareAgent", usage); err != nil {
+		return fmt.Errorf("running compare agent: %w", err)
+	}
+
+	exists, err := storage.Exists(ctx, loc.Bucket, loc.Key(comparisonArtifact))
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", comparisonArtifact, err)
+	}
+	if !exists {
+		return fmt.Errorf("compare agent finished without writing %s", comparisonArtifact)
+	}
+	log.Printf("[Flow] %s published for job=%s (baseline %s)", comparisonArtifact, candidateJobID, baselineJobID)
+	return nil
+}
@@ -2,26 +2,109 @@ package flows
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/siddhantprateek/reefline/pkg/crypto"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
 )
 
-// aiProviderPriority is the order in which we pick a connected AI integration.
+// aiProviderPriority is the default order in which we pick a connected AI
+// integration. FLOW_PROVIDER_PRIORITY and the user's flow preferences override it.
 var aiProviderPriority = []string{"openai", "anthropic", "google", "openrouter"}
 
+// ErrNoProvider is returned when the job owner has no connected AI integration
+var ErrNoProvider = errors.New("no connected AI provider")
+
+// NoProviderNote explains a job that completed without an AI report because
+// its owner hasn't connected a provider
+const NoProviderNote = "No AI report was generated because no AI provider is connected. " +
+	"The scan results are available as artifacts; connect OpenAI, Anthropic, Google AI or OpenRouter " +
+	"under Integrations (POST /api/v1/integrations/:id/connect) and re-run the scan to get a report."
+
+// HasProvider reports whether userID has connected any AI integration, i.e.
+// whether a report can be generated for their jobs
+func HasProvider(userID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Integration{}).
+		Where("user_id = ? AND integration_id IN ? AND status = ?", userID, aiProviderPriority, "connected").
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ParseProviderPriority validates a provider order, e.g. from
+// "openrouter,anthropic". Providers it leaves out keep their default order
+// after the listed ones.
+func ParseProviderPriority(providers []string) ([]string, error) {
+	order := make([]string, 0, len(aiProviderPriority))
+	for _, p := range providers {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if !slices.Contains(aiProviderPriority, p) {
+			return nil, fmt.Errorf("unknown AI provider %q (expected %s)", p, strings.Join(aiProviderPriority, ", "))
+		}
+		if slices.Contains(order, p) {
+			return nil, fmt.Errorf("AI provider %q listed twice", p)
+		}
+		order = append(order, p)
+	}
+	for _, p := range aiProviderPriority {
+		if !slices.Contains(order, p) {
+			order = append(order, p)
+		}
+	}
+	return order, nil
+}
+
+// ProviderPriorityFor returns the order in which userID's connected AI
+// integrations are tried: the user's saved preference, else
+// FLOW_PROVIDER_PRIORITY, else the built-in order. Invalid settings are
+// logged and skipped.
+func ProviderPriorityFor(userID string) []string {
+	var prefs models.FlowPreferences
+	if err := database.DB.Where("user_id = ?", userID).First(&prefs).Error; err == nil && prefs.ProviderPriority != "" {
+		order, err := ParseProviderPriority(strings.Split(prefs.ProviderPriority, ","))
+		if err == nil {
+			return order
+		}
+		log.Printf("[Flow] ignoring invalid provider priority for user=%s: %v", userID, err)
+	}
+
+	if v := os.Getenv("FLOW_PROVIDER_PRIORITY"); v != "" {
+		order, err := ParseProviderPriority(strings.Split(v, ","))
+		if err == nil {
+			return order
+		}
+		log.Printf("[Flow] ignoring FLOW_PROVIDER_PRIORITY: %v", err)
+	}
+
+	return slices.Clone(aiProviderPriority)
+}
+
 // resolvedCredentials holds everything needed to build the chat model.
 type resolvedCredentials struct {
+	UserID     string
 	ProviderID string
 	APIKey     string
 	ModelID    string // may be empty — RunFlow falls back to the provider default
+	// CritiqueModelID is the model for the critique agent; empty uses
+	// FLOW_<PROVIDER>_CRITIQUE_MODEL, else the supervisor's model
+	CritiqueModelID string
 }
 
 // resolveCredentials looks up the job owner's connected AI integration and
 // returns decrypted credentials. This keeps all DB + crypto logic out of the handler.
 func resolveCredentials(jobID string) (*resolvedCredentials, error) {
+	if err := validateJobID(jobID); err != nil {
+		return nil, err
+	}
 	// 1. Find the job to get user_id
 	var job models.Job
 	if err := database.DB.Where("job_id = ?", jobID).First(&job).Error; err != nil {
@@ -31,23 +114,39 @@ func resolveCredentials(jobID string) (*resolvedCredentials, error) {
 		return nil, fmt.Errorf("job %s has no user_id", jobID)
 	}
 
-	// 2. Find the first connected AI provider for this user
-	var integration models.Integration
+	// 2. Find the first connected AI provider for this user, skipping providers
+	// whose circuit breaker is open unless none is left
+	var (
+		integration models.Integration
+		tripped     *models.Integration
+	)
 	found := false
-	for _, providerID := range aiProviderPriority {
+	for _, providerID := range ProviderPriorityFor(job.UserID) {
+		var candidate models.Integration
 		err := database.DB.
 			Where("user_id = ? AND integration_id = ? AND status = ?", job.UserID, providerID, "connected").
-			First(&integration).Error
-		if err == nil {
-			found = true
-			break
+			First(&candidate).Error
+		if err != nil {
+			continue
+		}
+		if !breaker.allow(Provider(providerID)) {
+			log.Printf("[Flow] provider=%s is failing — trying the next connected provider", providerID)
+			if tripped == nil {
+				tripped = &candidate
+			}
+			continue
 		}
+		integration, found = candidate, true
+		break
+	}
+	if !found && tripped != nil {
+		integration, found = *tripped, true
 	}
 	if !found {
-		return nil, fmt.Errorf("no connected AI provider found for user %s", job.UserID)
+		return nil, fmt.Errorf("%w for user %s", ErrNoProvider, job.UserID)
 	}
 
-	// 3. Decrypt credentials → {"apiKey": "...", "model": "..."}
+	// 3. Decrypt credentials → {"apiKey": "...", "model": "...", "critique_model": "..."}
 	raw, err := crypto.Decrypt(integration.Credentials)
 	if err != nil {
 		return nil, fmt.Errorf("decrypting credentials for %s: %w", integration.IntegrationID, err)
@@ -63,8 +162,38 @@ func resolveCredentials(jobID string) (*resolvedCredentials, error) {
 	}
 
 	return &resolvedCredentials{
+		UserID:     job.UserID,
 		ProviderID: integration.IntegrationID,
 		APIKey:     apiKey,
 		ModelID:    creds["model"],
+
+		CritiqueModelID: creds["critique_model"],
 	}, nil
 }
+
+// model returns the model to use: the user's configured model, else
+// FLOW_<PROVIDER>_MODEL, else the registry default
+func (c *resolvedCredentials) model() (string, error) {
+	if c.ModelID != "" {
+		return c.ModelID, nil
+	}
+	id, ok := defaultModelFor(Provider(c.ProviderID))
+	if !ok {
+		return "", fmt.Errorf("unknown provider %q", c.ProviderID)
+	}
+	return id, nil
+}
+
+// critiqueModel returns the model for the critique agent: the user's
+// configured critique model, else FLOW_<PROVIDER>_CRITIQUE_MODEL, else
+// supervisorModel. Checking a draft needs less than writing it, so a cheaper
+// model (e.g. gpt-4o-mini under gpt-4o) usually does.
+func (c *resolvedCredentials) critiqueModel(supervisorModel string) string {
+	if c.CritiqueModelID != "" {
+		return c.CritiqueModelID
+	}
+	if id := strings.TrimSpace(os.Getenv("FLOW_" + strings.ToUpper(c.ProviderID) + "_CRITIQUE_MODEL")); id != "" {
+		return id
+	}
+	return supervisorModel
+}
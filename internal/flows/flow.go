@@ -14,17 +14,21 @@ import (
 
 	einoopenai "github.com/cloudwego/eino-ext/libs/acl/openai"
 	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
-	"github.com/minio/minio-go/v7"
 	"github.com/siddhantprateek/reefline/internal/flows/agents"
+	"github.com/siddhantprateek/reefline/internal/integration/ai"
 	"github.com/siddhantprateek/reefline/pkg/storage"
 )
 
-// retryTransport retries on HTTP 429 with exponential backoff, honouring Retry-After.
+// retryTransport retries on HTTP 429 with exponential backoff, honouring
+// Retry-After, and up to RetryCount times on transient errors. Outcomes feed
+// the provider's circuit breaker.
 type retryTransport struct {
 	base     http.RoundTripper
+	provider Provider
 	maxRetry int
 }
 
@@ -41,8 +45,9 @@ func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 
 	var (
-		resp *http.Response
-		err  error
+		resp      *http.Response
+		err       error
+		transient int // Transient failures so far
 	)
 	for attempt := 0; attempt <= t.maxRetry; attempt++ {
 		if bodyBytes != nil {
@@ -50,13 +55,34 @@ func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 
 		resp, err = t.base.RoundTrip(req)
-		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		var wait time.Duration
+		switch {
+		case err != nil && transientError(req, err), err == nil && transientStatus(resp.StatusCode):
+			transient++
+			if transient > RetryCount {
+				breaker.failure(t.provider)
+				return resp, err
+			}
+			wait = time.Duration(transient) * 2 * time.Second
+			if err != nil {
+				log.Printf("[Flow] provider=%s request failed (%v) — retrying in %s (%d/%d)", t.provider, err, wait, transient, RetryCount)
+			} else {
+				resp.Body.Close()
+				log.Printf("[Flow] provider=%s returned %d — retrying in %s (%d/%d)", t.provider, resp.StatusCode, wait, transient, RetryCount)
+			}
+		case err != nil:
 			return resp, err
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait = t.backoff(attempt, resp)
+			resp.Body.Close()
+			log.Printf("[Flow] 429 rate-limited — waiting %s before retry %d/%d", wait, attempt+1, t.maxRetry)
+		default:
+			// Answered, or rejected the request itself (bad key, bad request):
+			// either way the provider is up
+			breaker.success(t.provider)
+			return resp, nil
 		}
 
-		wait := t.backoff(attempt, resp)
-		resp.Body.Close()
-		log.Printf("[Flow] 429 rate-limited — waiting %s before retry %d/%d", wait, attempt+1, t.maxRetry)
 		select {
 		case <-req.Context().Done():
 			return nil, req.Context().Err()
@@ -84,16 +110,38 @@ func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration
 	return time.Duration(secs) * time.Second
 }
 
-func newRetryHTTPClient() *http.Client {
+func newRetryHTTPClient(p Provider) *http.Client {
+	var base http.RoundTripper = http.DefaultTransport
+	if p == ProviderOpenRouter {
+		base = &headerTransport{base: base, headers: ai.OpenRouterHeaders()}
+	}
 	return &http.Client{
-		Transport: &retryTransport{base: http.DefaultTransport, maxRetry: 5},
+		Transport: &retryTransport{base: base, provider: p, maxRetry: 5},
 	}
 }
 
+// headerTransport sets fixed headers on every request (e.g. OpenRouter app attribution).
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// maxRevisions is how often critique can send the report back to the supervisor
+const maxRevisions = 3
+
 const (
 	nodeSupervisor = "supervisor"
 	nodeCritique   = "critique"
 	nodePublish    = "publish_report"
+	nodeStructure  = "structure_report"
 )
 
 // flowState is shared across all graph nodes for a single run.
@@ -102,57 +150,131 @@ type flowState struct {
 	CritiqueFeedback string // critique's REVISE message, forwarded to supervisor
 }
 
+// ReproducibleSeed is the sampling seed of reproducible runs, for providers
+// that support one
+const ReproducibleSeed = 42
+
+// seedProviders are the providers whose OpenAI-compatible API honours a seed
+var seedProviders = map[Provider]bool{ProviderOpenAI: true, ProviderOpenRouter: true, ProviderGoogle: true}
+
+// RunOption configures a RunFlow call
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	reproducible bool
+}
+
+// WithReproducible makes the run as deterministic as the provider allows, for
+// golden-testing report structure: temperature 0, a fixed seed (where
+// supported) and a single supervisor pass without critique revisions.
+func WithReproducible() RunOption {
+	return func(o *runOptions) {
+		o.reproducible = true
+	}
+}
+
+// newChatModel builds the chat model for creds' provider and modelID
+func newChatModel(ctx context.Context, creds *resolvedCredentials, modelID string, o runOptions) (model.ToolCallingChatModel, error) {
+	p := Provider(creds.ProviderID)
+	baseURL, ok := providerBaseURLs[p]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", creds.ProviderID)
+	}
+	cfg := &einoopenai.Config{
+		APIKey:      creds.APIKey,
+		BaseURL:     string(baseURL),
+		Model:       modelID,
+		HTTPClient:  newRetryHTTPClient(p),
+		ExtraFields: providerExtraFields(p, modelID),
+	}
+	if o.reproducible {
+		temperature := float32(0)
+		cfg.Temperature = &temperature
+		if seedProviders[p] {
+			seed := ReproducibleSeed
+			cfg.Seed = &seed
+		}
+	}
+	cm, err := einoopenai.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building chat model: %w", err)
+	}
+	return cm, nil
+}
+
 // RunFlow builds a graph: Supervisor → Critique → branch(APPROVE→publish→END | REVISE→Supervisor)
 //
 //	Graph:
-//	  START → supervisor → critique → [APPROVE] → publish_report → END
+//	  START → supervisor → critique → [APPROVE] → publish_report → structure_report → END
 //	                          ↑                                       |
 //	                          └──────────── [REVISE] ←───────────────┘
 //	                                      (max 3 revisions)
-func RunFlow(ctx context.Context, jobID, bucket string) error {
+//
+// With WithReproducible the supervisor's draft is published directly:
+// START → supervisor → publish_report → structure_report → END.
+func RunFlow(ctx context.Context, jobID string, opts ...RunOption) error {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	creds, err := resolveCredentials(jobID)
 	if err != nil {
 		return fmt.Errorf("resolving credentials: %w", err)
 	}
 
-	p := Provider(creds.ProviderID)
-
-	modelID := creds.ModelID
-	if modelID == "" {
-		info, ok := defaultModels[p]
-		if !ok {
-			return fmt.Errorf("unknown provider %q", creds.ProviderID)
-		}
-		modelID = info.ID
+	modelID, err := creds.model()
+	if err != nil {
+		return err
 	}
 
-	baseURL, ok := providerBaseURLs[p]
-	if !ok {
-		return fmt.Errorf("unknown provider %q", creds.ProviderID)
-	}
+	critiqueModelID := creds.critiqueModel(modelID)
 
-	log.Printf("[Flow] provider=%s model=%s job=%s", creds.ProviderID, modelID, jobID)
+	log.Printf("[Flow] provider=%s model=%s critique_model=%s job=%s reproducible=%t", creds.ProviderID, modelID, critiqueModelID, jobID, o.reproducible)
 
-	cm, err := einoopenai.NewClient(ctx, &einoopenai.Config{
-		APIKey:     creds.APIKey,
-		BaseURL:    string(baseURL),
-		Model:      modelID,
-		HTTPClient: newRetryHTTPClient(),
-	})
+	// Record token usage even if the flow fails part-way — the tokens were still spent.
+	// Each agent's usage is recorded against its own model so it's priced right.
+	usage := &usageTracker{}
+	critiqueUsage := &usageTracker{}
+	defer func() {
+		if err := usage.flush(context.WithoutCancel(ctx), creds.UserID, creds.ProviderID, modelID); err != nil {
+			log.Printf("[Flow] failed to record usage for job=%s: %v", jobID, err)
+		}
+		if err := critiqueUsage.flush(context.WithoutCancel(ctx), creds.UserID, creds.ProviderID, critiqueModelID); err != nil {
+			log.Printf("[Flow] failed to record critique usage for job=%s: %v", jobID, err)
+		}
+	}()
+
+	cm, err := newChatModel(ctx, creds, modelID, o)
 	if err != nil {
-		return fmt.Errorf("building chat model: %w", err)
+		return err
+	}
+	critiqueCM := cm
+	if critiqueModelID != modelID {
+		if critiqueCM, err = newChatModel(ctx, creds, critiqueModelID, o); err != nil {
+			return err
+		}
 	}
 
-	// Build MinIO tools
-	listTool, err := NewListScanFilesTool(bucket)
+	// Build MinIO tools; drafts and reports are tagged with the model that wrote them
+	loc := storage.ArtifactLocation(creds.UserID, jobID)
+	ctx = storage.WithArtifactMeta(ctx, storage.ArtifactMeta{
+		JobID:       jobID,
+		Tool:        "report-flow",
+		ToolVersion: creds.ProviderID + "/" + modelID,
+	})
+	// The read tools share one budget per run; exceeding it fails the flow
+	ctx = withReadBudget(ctx)
+
+	listTool, err := NewListScanFilesTool(jobID)
 	if err != nil {
 		return fmt.Errorf("list_scan_files tool: %w", err)
 	}
-	readTool, err := NewReadScanFileTool(bucket)
+	readTool, err := NewReadScanFileTool(jobID)
 	if err != nil {
 		return fmt.Errorf("read_scan_file tool: %w", err)
 	}
-	writeTool, err := NewWriteDraftTool(bucket)
+	writeTool, err := NewWriteDraftTool(jobID)
 	if err != nil {
 		return fmt.Errorf("write_draft tool: %w", err)
 	}
@@ -160,18 +282,23 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 	supervisorTools := []tool.BaseTool{listTool, readTool, writeTool}
 
 	// Build agents
-	supervisor, err := agents.NewSupervisorAgent(ctx, cm, supervisorTools, jobID)
+	// Supervisor and critique share one template so their section lists agree
+	tmpl := ReportTemplateFor(creds.UserID)
+	supervisor, err := agents.NewSupervisorAgent(ctx, cm, supervisorTools, jobID, tmpl)
 	if err != nil {
 		return fmt.Errorf("creating supervisor agent: %w", err)
 	}
 	// Critique has no tools — draft content is passed directly in the message
-	critique, err := agents.NewCritiqueAgent(ctx, cm, jobID)
+	critique, err := agents.NewCritiqueAgent(ctx, critiqueCM, jobID, tmpl)
 	if err != nil {
 		return fmt.Errorf("creating critique agent: %w", err)
 	}
 
 	// supervisorLambda: on first run uses the initial prompt; on REVISE runs injects critique feedback.
 	supervisorLambda := compose.InvokableLambda(func(ctx context.Context, msgs []*schema.Message) ([]*schema.Message, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var feedback string
 		_ = compose.ProcessState(ctx, func(_ context.Context, s *flowState) error {
 			feedback = s.CritiqueFeedback
@@ -182,7 +309,7 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 		if feedback != "" {
 			trigger = schema.UserMessage(fmt.Sprintf(
 				"REVISE the report for job_id=%q based on critique feedback.\n\n"+
-					"Read report.md, apply all corrections, then call write_draft to save the updated report.\n\n"+
+					"Read draft.md (your latest draft), apply all corrections, then call write_draft to save the updated report.\n\n"+
 					"Critique feedback:\n%s",
 				jobID, feedback,
 			))
@@ -196,29 +323,50 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 		}
 		input := &adk.AgentInput{Messages: []*schema.Message{trigger}}
 		iter := supervisor.Run(ctx, input)
-		return drainAgent(iter, "SupervisorAgent")
+		return drainAgent(ctx, iter, "SupervisorAgent", usage)
 	})
 
-	// critiqueLambda: reads report.md directly and passes it in the message — no tool calls needed.
+	// critiqueLambda: reads the latest draft directly and passes it in the message — no tool calls needed.
 	critiqueLambda := compose.InvokableLambda(func(ctx context.Context, msgs []*schema.Message) ([]*schema.Message, error) {
-		draft, err := readMinIOFile(ctx, bucket, fmt.Sprintf("%s/artifacts/report.md", jobID))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		draft, err := readMinIOFile(ctx, loc.Bucket, loc.Key("draft.md"))
 		if err != nil {
-			return nil, fmt.Errorf("reading report.md for critique: %w", err)
+			return nil, fmt.Errorf("reading draft.md for critique: %w", err)
+		}
+		prompt := fmt.Sprintf("Review this security report and return APPROVE or REVISE:\n\n%s", draft)
+		if score, err := readMinIOFile(ctx, loc.Bucket, loc.Key("score.json")); err == nil {
+			prompt += fmt.Sprintf("\n\n---\nscore.json (authoritative Score Card values):\n%s", score)
 		}
-		trigger := schema.UserMessage(fmt.Sprintf("Review this security report and return APPROVE or REVISE:\n\n%s", draft))
+		trigger := schema.UserMessage(prompt)
 		input := &adk.AgentInput{Messages: []*schema.Message{trigger}}
 		iter := critique.Run(ctx, input)
-		return drainAgent(iter, "CritiqueAgent")
+		return drainAgent(ctx, iter, "CritiqueAgent", critiqueUsage)
 	})
 
-	// publish_report: report.md was written directly by the supervisor — just confirm it exists.
+	// publish_report: promote the approved (or last) draft version to report.md.
+	// Earlier versions stay as draft.vN.md for the audit trail.
 	publishLambda := compose.InvokableLambda(func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
-		content, err := readMinIOFile(ctx, bucket, fmt.Sprintf("%s/artifacts/report.md", jobID))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		version, err := promoteDraft(ctx, loc)
 		if err != nil {
-			return nil, fmt.Errorf("report.md not found after supervisor: %w", err)
+			return nil, fmt.Errorf("publishing report.md: %w", err)
 		}
-		log.Printf("[Flow] report.md confirmed for job=%s (%d bytes)", jobID, len(content))
-		return schema.AssistantMessage("report.md confirmed", nil), nil
+		log.Printf("[Flow] report.md published from %s for job=%s", draftName(version), jobID)
+		return schema.AssistantMessage("report.md published", nil), nil
+	})
+
+	// structure_report: also store the approved report as report.json for
+	// programmatic consumers. report.md is already published, so a failure
+	// here is logged rather than failing the flow.
+	structureLambda := compose.InvokableLambda(func(ctx context.Context, msg *schema.Message) (*schema.Message, error) {
+		if err := WriteStructuredReport(ctx, loc, jobID); err != nil {
+			log.Printf("[Flow] failed to write report.json for job=%s: %v", jobID, err)
+		}
+		return msg, nil
 	})
 
 	// Build graph: []*schema.Message → *schema.Message
@@ -231,17 +379,69 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 	if err := g.AddLambdaNode(nodeSupervisor, supervisorLambda); err != nil {
 		return fmt.Errorf("adding supervisor node: %w", err)
 	}
-	if err := g.AddLambdaNode(nodeCritique, critiqueLambda); err != nil {
-		return fmt.Errorf("adding critique node: %w", err)
-	}
 	if err := g.AddLambdaNode(nodePublish, publishLambda); err != nil {
 		return fmt.Errorf("adding publish node: %w", err)
 	}
+	if err := g.AddLambdaNode(nodeStructure, structureLambda); err != nil {
+		return fmt.Errorf("adding structure node: %w", err)
+	}
 
-	// Edges: START → supervisor → critique
+	// Edges: START → supervisor → critique (or straight to publish when reproducible)
 	if err := g.AddEdge(compose.START, nodeSupervisor); err != nil {
 		return fmt.Errorf("edge START→supervisor: %w", err)
 	}
+	if o.reproducible {
+		if err := g.AddEdge(nodeSupervisor, nodePublish); err != nil {
+			return fmt.Errorf("edge supervisor→publish: %w", err)
+		}
+	} else if err := addCritiqueLoop(g, critiqueLambda, jobID); err != nil {
+		return err
+	}
+
+	// publish → structure → END
+	if err := g.AddEdge(nodePublish, nodeStructure); err != nil {
+		return fmt.Errorf("edge publish→structure: %w", err)
+	}
+	if err := g.AddEdge(nodeStructure, compose.END); err != nil {
+		return fmt.Errorf("edge structure→END: %w", err)
+	}
+
+	// Compile and run
+	runnable, err := g.Compile(ctx)
+	if err != nil {
+		return fmt.Errorf("compiling graph: %w", err)
+	}
+
+	initialPrompt := []*schema.Message{
+		schema.UserMessage(fmt.Sprintf(
+			"Generate a security report for job_id=%q. "+
+				"List available artifacts, read grype.json, dockle.json and dive.json, "+
+				"then save a complete report with write_draft.",
+			jobID,
+		)),
+	}
+
+	result, err := runnable.Invoke(ctx, initialPrompt)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("[Flow] cancelled job=%s: %v", jobID, ctx.Err())
+			return fmt.Errorf("flow cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("running flow: %w", err)
+	}
+
+	if result != nil {
+		log.Printf("[Flow] done job=%s result=%s", jobID, truncate(result.Content, 80))
+	}
+	return nil
+}
+
+// addCritiqueLoop adds the critique node after the supervisor and the branch
+// that publishes an approved draft or sends it back for revision
+func addCritiqueLoop(g *compose.Graph[[]*schema.Message, *schema.Message], critiqueLambda *compose.Lambda, jobID string) error {
+	if err := g.AddLambdaNode(nodeCritique, critiqueLambda); err != nil {
+		return fmt.Errorf("adding critique node: %w", err)
+	}
 	if err := g.AddEdge(nodeSupervisor, nodeCritique); err != nil {
 		return fmt.Errorf("edge supervisor→critique: %w", err)
 	}
@@ -262,7 +462,7 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 			for i := len(msgs) - 1; i >= 0; i-- {
 				if msgs[i].Role == schema.Assistant && msgs[i].Content != "" {
 					verdict := msgs[i].Content
-					if strings.Contains(verdict, "APPROVE") || revision >= 3 {
+					if strings.Contains(verdict, "APPROVE") || revision >= maxRevisions {
 						log.Printf("[Flow] Critique verdict=APPROVE (revision=%d) job=%s", revision, jobID)
 						return nodePublish, nil
 					}
@@ -286,45 +486,46 @@ func RunFlow(ctx context.Context, jobID, bucket string) error {
 	if err := g.AddBranch(nodeCritique, branch); err != nil {
 		return fmt.Errorf("adding critique branch: %w", err)
 	}
-
-	// publish → END
-	if err := g.AddEdge(nodePublish, compose.END); err != nil {
-		return fmt.Errorf("edge publish→END: %w", err)
-	}
-
-	// Compile and run
-	runnable, err := g.Compile(ctx)
-	if err != nil {
-		return fmt.Errorf("compiling graph: %w", err)
-	}
-
-	initialPrompt := []*schema.Message{
-		schema.UserMessage(fmt.Sprintf(
-			"Generate a security report for job_id=%q. "+
-				"List available artifacts, read grype.json, dockle.json and dive.json, "+
-				"then write a complete report.md.",
-			jobID,
-		)),
-	}
-
-	result, err := runnable.Invoke(ctx, initialPrompt)
-	if err != nil {
-		return fmt.Errorf("running flow: %w", err)
-	}
-
-	if result != nil {
-		log.Printf("[Flow] done job=%s result=%s", jobID, truncate(result.Content, 80))
-	}
 	return nil
 }
 
-// drainAgent consumes an adk.AsyncIterator, logs each message, and returns all messages seen.
-func drainAgent(iter *adk.AsyncIterator[*adk.AgentEvent], name string) ([]*schema.Message, error) {
+// drainAgent consumes an adk.AsyncIterator, logs each message, records token
+// usage, and returns all messages seen. It returns ctx.Err() as soon as ctx is
+// cancelled; remaining events are then discarded in the background so the
+// agent's goroutine can finish.
+func drainAgent(ctx context.Context, iter *adk.AsyncIterator[*adk.AgentEvent], name string, usage *usageTracker) ([]*schema.Message, error) {
+	events := make(chan *adk.AgentEvent)
+	go func() {
+		defer close(events)
+		for {
+			event, ok := iter.Next()
+			if !ok {
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				for {
+					if _, ok := iter.Next(); !ok {
+						return
+					}
+				}
+			}
+		}
+	}()
+
 	var msgs []*schema.Message
 	for {
-		event, ok := iter.Next()
-		if !ok {
-			break
+		var event *adk.AgentEvent
+		select {
+		case <-ctx.Done():
+			log.Printf("[Flow][%s] cancelled: %v", name, ctx.Err())
+			return msgs, ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return msgs, nil
+			}
+			event = e
 		}
 		if event.Err != nil {
 			return msgs, fmt.Errorf("[%s] agent error: %w", name, event.Err)
@@ -334,11 +535,11 @@ func drainAgent(iter *adk.AsyncIterator[*adk.AgentEvent], name string) ([]*schem
 				if msg.Content != "" {
 					log.Printf("[Flow][%s] %s", name, truncate(msg.Content, 120))
 				}
+				usage.add(msg)
 				msgs = append(msgs, msg)
 			}
 		}
 	}
-	return msgs, nil
 }
 
 // lastUserMessage returns the last user-role message from a slice, or nil.
@@ -351,18 +552,16 @@ func lastUserMessage(msgs []*schema.Message) *schema.Message {
 	return nil
 }
 
-// readMinIOFile reads the full content of an object from MinIO.
+// readMinIOFile reads the full (decompressed) content of an object from MinIO.
 func readMinIOFile(ctx context.Context, bucket, objectName string) (string, error) {
-	obj, err := storage.Client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
-	defer obj.Close()
-	var buf bytes.Buffer
-	if _, err := buf.ReadFrom(obj); err != nil {
+	data, err := storage.ReadArtifact(ctx, bucket, objectName)
+	if err != nil {
 		return "", err
 	}
-	return buf.String(), nil
+	return string(data), nil
 }
 
 func truncate(s string, n int) string {
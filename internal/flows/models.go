@@ -1,5 +1,10 @@
 package flows
 
+import (
+	"os"
+	"strings"
+)
+
 // ─── Model Registry ──────────────────────────────────────────────────────────
 
 // ModelInfo describes an AI model available from a provider.
@@ -33,6 +38,17 @@ var defaultModels = map[Provider]ModelInfo{
 	},
 }
 
+// defaultModelFor returns the model used when the user hasn't configured one.
+// FLOW_<PROVIDER>_MODEL (e.g. FLOW_OPENAI_MODEL=gpt-4o-mini) overrides the
+// registry default so operators can control cost without recompiling.
+func defaultModelFor(p Provider) (string, bool) {
+	if id := strings.TrimSpace(os.Getenv("FLOW_" + strings.ToUpper(string(p)) + "_MODEL")); id != "" {
+		return id, true
+	}
+	info, ok := defaultModels[p]
+	return info.ID, ok
+}
+
 // availableModels lists the well-known models per provider.
 var availableModels = map[Provider][]ModelInfo{
 	ProviderOpenAI: {
@@ -0,0 +1,159 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/siddhantprateek/reefline/pkg/storage"
+)
+
+// Rough token accounting for PreviewCost. The supervisor re-sends its whole
+// conversation on every tool call, so each artifact chunk is counted once per
+// later call, averaged to half the calls.
+const (
+	bytesPerToken         = 4
+	instructionTokens     = 2500 // System prompt and report template, per call
+	toolCallTokens        = 150  // Completion tokens of a tool call
+	minReportTokens       = 1500
+	maxReportTokens       = 8000
+	critiqueVerdictTokens = 500
+)
+
+// TokenEstimate is the estimated token usage and cost of a flow run
+type TokenEstimate struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// CostPreview estimates what generating a job's report would cost, without
+// calling the model
+type CostPreview struct {
+	JobID         string           `json:"job_id"`
+	Provider      string           `json:"provider"`
+	Model         string           `json:"model"`
+	CritiqueModel string           `json:"critique_model"`
+	Artifacts     map[string]int64 `json:"artifacts"`     // Bytes the supervisor would read, per artifact
+	PricingKnown  bool             `json:"pricing_known"` // false: a model has no list price and its calls cost 0
+	Min           TokenEstimate    `json:"min"`           // Approved on the first critique
+	Max           TokenEstimate    `json:"max"`           // Every allowed revision used
+	MaxRevisions  int              `json:"max_revisions"`
+}
+
+// flowArtifacts are the artifacts the supervisor reads, in workflow order.
+// Of the vulnerability artifacts only the first present is read.
+var (
+	vulnArtifacts = []string{"grype.summary.json", "grype.json", "harbor-scan.json"}
+	flowArtifacts = []string{"dockle.json", "dive.json", "history.json", "dockerfile-layer-map.json", "score.json", "baseline.json", "platforms.json"}
+)
+
+// PreviewCost estimates the token usage and cost of running the report flow
+// for jobID with its owner's provider and model, from the sizes of the
+// artifacts the flow would read.
+func PreviewCost(ctx context.Context, jobID string) (*CostPreview, error) {
+	creds, err := resolveCredentials(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("resolving credentials: %w", err)
+	}
+	modelID, err := creds.model()
+	if err != nil {
+		return nil, err
+	}
+	critiqueModelID := creds.critiqueModel(modelID)
+
+	loc := storage.ArtifactLocation(creds.UserID, jobID)
+	sizes := map[string]int64{}
+	for _, name := range vulnArtifacts {
+		n, ok, err := artifactSize(ctx, loc, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sizes[name] = n
+			break
+		}
+	}
+	for _, name := range flowArtifacts {
+		n, ok, err := artifactSize(ctx, loc, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sizes[name] = n
+		}
+	}
+
+	var artifactTokens, reads int64
+	for _, n := range sizes {
+		artifactTokens += n / bytesPerToken
+		reads += (n + readMaxBytes - 1) / readMaxBytes
+	}
+	reportTokens := min(max(minReportTokens, artifactTokens/10), maxReportTokens)
+
+	// One pass: list + reads + write_draft, then one critique call. A
+	// revision re-reads the artifacts and the draft and is critiqued again.
+	calls := reads + 2
+	supervisor := TokenEstimate{
+		PromptTokens:     calls*instructionTokens + artifactTokens*(calls+1)/2,
+		CompletionTokens: (calls-1)*toolCallTokens + reportTokens,
+	}
+	critique := TokenEstimate{
+		PromptTokens:     instructionTokens + reportTokens + sizes["score.json"]/bytesPerToken,
+		CompletionTokens: critiqueVerdictTokens,
+	}
+	revision := TokenEstimate{
+		PromptTokens:     supervisor.PromptTokens + (calls+1)*reportTokens,
+		CompletionTokens: supervisor.CompletionTokens + toolCallTokens,
+	}
+
+	preview := &CostPreview{
+		JobID:         jobID,
+		Provider:      creds.ProviderID,
+		Model:         modelID,
+		CritiqueModel: critiqueModelID,
+		Artifacts:     sizes,
+		PricingKnown:  EstimateCost(modelID, 1e6, 0) > 0 && EstimateCost(critiqueModelID, 1e6, 0) > 0,
+		MaxRevisions:  maxRevisions,
+	}
+	// Min: one supervisor pass and one critique. Max: each revision adds both.
+	for _, run := range []struct {
+		e      *TokenEstimate
+		passes int64
+	}{{&preview.Min, 1}, {&preview.Max, 1 + maxRevisions}} {
+		supPrompt := supervisor.PromptTokens + (run.passes-1)*revision.PromptTokens
+		supCompletion := supervisor.CompletionTokens + (run.passes-1)*revision.CompletionTokens
+		critPrompt := run.passes * critique.PromptTokens
+		critCompletion := run.passes * critique.CompletionTokens
+
+		run.e.PromptTokens = supPrompt + critPrompt
+		run.e.CompletionTokens = supCompletion + critCompletion
+		run.e.TotalTokens = run.e.PromptTokens + run.e.CompletionTokens
+		run.e.EstimatedCostUSD = EstimateCost(modelID, supPrompt, supCompletion) +
+			EstimateCost(critiqueModelID, critPrompt, critCompletion)
+	}
+	return preview, nil
+}
+
+// artifactSize returns the uncompressed size of a job artifact and whether it exists
+func artifactSize(ctx context.Context, loc storage.Location, name string) (int64, bool, error) {
+	objectName := loc.Key(name)
+	exists, err := storage.Exists(ctx, loc.Bucket, objectName)
+	if err != nil {
+		return 0, false, fmt.Errorf("checking %s: %w", objectName, err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+	obj, err := storage.OpenArtifact(ctx, loc.Bucket, objectName)
+	if err != nil {
+		return 0, false, fmt.Errorf("opening %s: %w", objectName, err)
+	}
+	defer obj.Close()
+	n, err := io.Copy(io.Discard, obj)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading %s: %w", objectName, err)
+	}
+	return n, true, nil
+}
@@ -1,5 +1,10 @@
 package flows
 
+import (
+	"os"
+	"strings"
+)
+
 // Provider identifies which AI provider backend is being used.
 type Provider string
 
@@ -19,3 +24,22 @@ var providerBaseURLs = map[Provider]string{
 	ProviderGoogle:     "https://generativelanguage.googleapis.com/v1beta/openai/",
 	ProviderOpenRouter: "https://openrouter.ai/api/v1/",
 }
+
+// providerExtraFields returns provider-specific request body fields.
+// For OpenRouter, FLOW_OPENROUTER_FALLBACK_MODELS (comma-separated) becomes the
+// "models" fallback list tried in order when the primary model is unavailable.
+func providerExtraFields(p Provider, modelID string) map[string]any {
+	if p != ProviderOpenRouter {
+		return nil
+	}
+	var models []string
+	for _, m := range strings.Split(os.Getenv("FLOW_OPENROUTER_FALLBACK_MODELS"), ",") {
+		if m = strings.TrimSpace(m); m != "" && m != modelID {
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	return map[string]any{"models": append([]string{modelID}, models...)}
+}
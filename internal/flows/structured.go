@@ -0,0 +1,167 @@
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+)
+
+// StructuredReport is the machine-readable form of report.md, stored as
+// report.json: the Markdown split into sections, findings and
+// recommendations, with the numbers taken from score.json rather than the prose
+type StructuredReport struct {
+	JobID           string                       `json:"job_id"`
+	GeneratedAt     time.Time                    `json:"generated_at"`
+	ScoreCard       *scoring.ScoreCard           `json:"score_card,omitempty"`
+	Vulnerabilities *scoring.VulnerabilityCounts `json:"vulnerabilities,omitempty"`
+	Summary         string                       `json:"summary,omitempty"`
+	TopFindings     []ReportFinding              `json:"top_findings"`
+	Recommendations []string                     `json:"recommendations"`
+	Sections        []ReportSectionText          `json:"sections"`
+}
+
+// ReportFinding is one entry of the report's prioritized findings
+type ReportFinding struct {
+	Title    string `json:"title"`
+	Evidence string `json:"evidence,omitempty"`
+	Risk     string `json:"risk,omitempty"`
+	Action   string `json:"action,omitempty"`
+}
+
+// ReportSectionText is a "### Title" section of report.md and its Markdown body
+type ReportSectionText struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+var (
+	// reportHeading matches the section headings of report.md
+	reportHeading = regexp.MustCompile(`^#{2,3}\s+(.+)$`)
+	// findingField matches the labelled lines of a finding, e.g. "- **Risk**: ..."
+	findingField = regexp.MustCompile(`\*\*(Finding|Evidence|Risk|Recommended Action):?\*\*:?\s*(.*)$`)
+	// listItem matches a top-level list item or a "####" sub-heading
+	listItem = regexp.MustCompile(`^(?:[-*]|\d+[.)]|####)\s+(.+)$`)
+)
+
+// ParseReport splits a report.md into a StructuredReport. Findings come from
+// the "Key Findings" section, recommendations from the "Recommended ..."
+// section; a template without them just yields empty lists.
+func ParseReport(markdown string) *StructuredReport {
+	report := &StructuredReport{
+		TopFindings:     []ReportFinding{},
+		Recommendations: []string{},
+		Sections:        []ReportSectionText{},
+	}
+
+	var current *ReportSectionText
+	var body []string
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(body, "\n"))
+			report.Sections = append(report.Sections, *current)
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(markdown, "\n") {
+		if m := reportHeading.FindStringSubmatch(strings.TrimRight(line, " \r")); m != nil {
+			flush()
+			current = &ReportSectionText{Title: strings.TrimSpace(m[1])}
+			continue
+		}
+		body = append(body, strings.TrimRight(line, "\r"))
+	}
+	flush()
+
+	for _, s := range report.Sections {
+		title := strings.ToLower(s.Title)
+		switch {
+		case title == "summary" && report.Summary == "":
+			report.Summary = s.Content
+		case strings.HasPrefix(title, "key findings"):
+			report.TopFindings = append(report.TopFindings, parseFindings(s.Content)...)
+		case strings.HasPrefix(title, "recommend"):
+			report.Recommendations = append(report.Recommendations, topLevelItems(s.Content)...)
+		}
+	}
+	return report
+}
+
+// parseFindings reads "**Finding** / **Evidence** / **Risk** / **Recommended
+// Action**" entries, falling back to one finding per top-level list item
+func parseFindings(content string) []ReportFinding {
+	var findings []ReportFinding
+	for _, line := range strings.Split(content, "\n") {
+		m := findingField.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value := strings.TrimSpace(m[2])
+		if m[1] == "Finding" || len(findings) == 0 {
+			findings = append(findings, ReportFinding{})
+		}
+		f := &findings[len(findings)-1]
+		switch m[1] {
+		case "Finding":
+			f.Title = value
+		case "Evidence":
+			f.Evidence = value
+		case "Risk":
+			f.Risk = value
+		case "Recommended Action":
+			f.Action = value
+		}
+	}
+	if len(findings) > 0 {
+		return findings
+	}
+	for _, item := range topLevelItems(content) {
+		findings = append(findings, ReportFinding{Title: item})
+	}
+	return findings
+}
+
+// topLevelItems returns the unindented list items and "####" headings of content
+func topLevelItems(content string) []string {
+	var items []string
+	for _, line := range strings.Split(content, "\n") {
+		if m := listItem.FindStringSubmatch(line); m != nil {
+			items = append(items, strings.TrimSpace(m[1]))
+		}
+	}
+	return items
+}
+
+// WriteStructuredReport parses the job's report.md, adds the score.json
+// numbers and stores the result as report.json
+func WriteStructuredReport(ctx context.Context, loc storage.Location, jobID string) error {
+	markdown, err := readMinIOFile(ctx, loc.Bucket, loc.Key("report.md"))
+	if err != nil {
+		return fmt.Errorf("reading report.md: %w", err)
+	}
+	report := ParseReport(markdown)
+	report.JobID = jobID
+	report.GeneratedAt = time.Now().UTC()
+
+	if score, err := storage.ReadArtifact(ctx, loc.Bucket, loc.Key("score.json")); err == nil {
+		var card scoring.ScoreCard
+		if err := json.Unmarshal(score, &card); err == nil {
+			report.ScoreCard = &card
+			report.Vulnerabilities = card.Vulnerabilities
+		}
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if _, err := storage.PutArtifact(ctx, loc.Bucket, loc.Key("report.json"), data, "application/json"); err != nil {
+		return fmt.Errorf("writing report.json: %w", err)
+	}
+	return nil
+}
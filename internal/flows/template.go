@@ -0,0 +1,34 @@
+package flows
+
+import (
+	"log"
+	"os"
+
+	"github.com/siddhantprateek/reefline/internal/flows/agents"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// ReportTemplateFor returns the report template for userID: the user's saved
+// template, else the REPORT_TEMPLATE_FILE template, else the built-in default.
+// Invalid templates are logged and skipped rather than failing the report.
+func ReportTemplateFor(userID string) *agents.ReportTemplate {
+	var stored models.ReportTemplate
+	if err := database.DB.Where("user_id = ?", userID).First(&stored).Error; err == nil {
+		tmpl, err := agents.ParseReportTemplate([]byte(stored.Content))
+		if err == nil {
+			return tmpl
+		}
+		log.Printf("[Flow] ignoring invalid report template for user=%s: %v", userID, err)
+	}
+
+	if path := os.Getenv("REPORT_TEMPLATE_FILE"); path != "" {
+		tmpl, err := agents.LoadReportTemplate(path)
+		if err == nil {
+			return tmpl
+		}
+		log.Printf("[Flow] ignoring REPORT_TEMPLATE_FILE: %v", err)
+	}
+
+	return agents.DefaultReportTemplate()
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
-	"github.com/minio/minio-go/v7"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
 	"github.com/siddhantprateek/reefline/pkg/storage"
 )
 
@@ -18,7 +22,7 @@ const readMaxBytes = 40000 // ~40 KB per chunk to stay within context limits
 
 type readScanFileArgs struct {
 	JobID    string `json:"job_id"    jsonschema:"description=The job ID whose scan artifact to read"`
-	Filename string `json:"filename"  jsonschema:"description=Artifact to read: grype.json | dockle.json | dive.json | draft.md | report.md"`
+	Filename string `json:"filename"  jsonschema:"description=Artifact to read: grype.summary.json | grype.json | harbor-scan.json | dockle.json | dive.json | history.json | dockerfile-layer-map.json | score.json | baseline.json | platforms.json | draft.md (latest draft) | draft.vN.md (draft version N) | report.md"`
 	Offset   int    `json:"offset"    jsonschema:"description=Byte offset to start reading from (0 for the beginning). Use this to paginate large files — if the response contains TRUNCATED, call again with the returned next_offset value."`
 }
 
@@ -27,34 +31,86 @@ type listScanFilesArgs struct {
 }
 
 type writeDraftArgs struct {
-	JobID   string `json:"job_id"  jsonschema:"description=The job ID under which report.md will be written"`
-	Content string `json:"content" jsonschema:"description=Full Markdown content to write into report.md"`
+	JobID   string `json:"job_id"  jsonschema:"description=The job ID under which the draft will be written"`
+	Content string `json:"content" jsonschema:"description=Full Markdown content of the report draft"`
 }
 
 // ─── Tool constructors ────────────────────────────────────────────────────────
 
-// NewReadScanFileTool reads a specific scan artifact from MinIO for the given job.
-// Object path pattern: {job_id}/artifacts/{filename}
-func NewReadScanFileTool(bucket string) (tool.BaseTool, error) {
+// jobIDPattern matches the job IDs the API issues (UUIDs) and other simple
+// IDs; anything that could change the object path, like "/" or "..", fails
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// validateJobID rejects a job ID that isn't safe to build an object path from.
+// Job IDs reach the tools from the model, so they're untrusted input.
+func validateJobID(jobID string) error {
+	if !jobIDPattern.MatchString(jobID) {
+		return fmt.Errorf("invalid job_id %q", jobID)
+	}
+	return nil
+}
+
+// jobLocation resolves where a job's artifacts live under the configured
+// storage.ArtifactLayout, which may depend on the job's owner. A tool built
+// for specific jobs (scope) refuses any other job ID.
+func jobLocation(ctx context.Context, scope []string, jobID string) (storage.Location, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Location{}, err
+	}
+	if err := validateJobID(jobID); err != nil {
+		return storage.Location{}, err
+	}
+	if len(scope) > 0 && !slices.Contains(scope, jobID) {
+		return storage.Location{}, fmt.Errorf("job %q is not part of this report; use %s", jobID, strings.Join(scope, " or "))
+	}
+	var job models.Job
+	if err := database.DB.WithContext(ctx).Select("user_id").Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return storage.Location{}, fmt.Errorf("job %q not found: %w", jobID, err)
+	}
+	return storage.ArtifactLocation(job.UserID, jobID), nil
+}
+
+// NewReadScanFileTool reads a specific scan artifact from MinIO for the given
+// job, one of jobIDs if any are given. Object path pattern: see storage.ArtifactLocation
+// Reads count against the run's budget (see MaxArtifactReads).
+func NewReadScanFileTool(jobIDs ...string) (tool.BaseTool, error) {
 	return utils.InferTool(
 		"read_scan_file",
-		"Read a scan artifact file (grype.json, dockle.json, dive.json, draft.md, or report.md) from object storage for the given job.",
+		"Read a scan artifact file (grype.summary.json, grype.json, harbor-scan.json, dockle.json, dive.json, history.json, dockerfile-layer-map.json, score.json, baseline.json, platforms.json, draft.md, draft.vN.md, or report.md) from object storage for the given job.",
 		func(ctx context.Context, args readScanFileArgs) (string, error) {
 			allowed := map[string]bool{
-				"grype.json":  true,
-				"dockle.json": true,
-				"dive.json":   true,
-				"draft.md":    true,
-				"report.md":   true,
+				"grype.summary.json":        true,
+				"score.json":                true,
+				"baseline.json":             true,
+				"platforms.json":            true,
+				"grype.json":                true,
+				"harbor-scan.json":          true,
+				"dockle.json":               true,
+				"dive.json":                 true,
+				"history.json":              true,
+				"dockerfile-layer-map.json": true,
+				"draft.md":                  true,
+				"report.md":                 true,
 			}
-			if !allowed[args.Filename] {
-				return "", fmt.Errorf("filename %q not allowed; choose: grype.json, dockle.json, dive.json, draft.md, report.md", args.Filename)
+			if !allowed[args.Filename] && !draftVersionPattern.MatchString(args.Filename) {
+				return "", fmt.Errorf("filename %q not allowed; choose: grype.summary.json, grype.json, harbor-scan.json, dockle.json, dive.json, history.json, dockerfile-layer-map.json, score.json, baseline.json, platforms.json, draft.md, draft.vN.md, report.md", args.Filename)
 			}
 
-			objectName := fmt.Sprintf("%s/artifacts/%s", args.JobID, args.Filename)
+			loc, err := jobLocation(ctx, jobIDs, args.JobID)
+			if err != nil {
+				return "", err
+			}
+			budget := readBudgetFor(ctx)
+			if err := budget.read(); err != nil {
+				return "", err
+			}
+			objectName := loc.Key(args.Filename)
 
-			obj, err := storage.Client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+			obj, err := storage.OpenArtifact(ctx, loc.Bucket, objectName)
 			if err != nil {
+				if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "does not exist") {
+					return fmt.Sprintf("artifact %q not found for job %q", args.Filename, args.JobID), nil
+				}
 				return "", fmt.Errorf("getting object %s: %w", objectName, err)
 			}
 			defer obj.Close()
@@ -71,6 +127,7 @@ func NewReadScanFileTool(bucket string) (tool.BaseTool, error) {
 
 			buf := make([]byte, readMaxBytes+1)
 			n, err := io.ReadFull(obj, buf)
+			budget.fetched(int64(args.Offset + n))
 			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
 				if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "does not exist") {
 					return fmt.Sprintf("artifact %q not found for job %q", args.Filename, args.JobID), nil
@@ -88,14 +145,22 @@ func NewReadScanFileTool(bucket string) (tool.BaseTool, error) {
 	)
 }
 
-// NewListScanFilesTool lists available scan artifacts in MinIO for the given job.
-func NewListScanFilesTool(bucket string) (tool.BaseTool, error) {
+// NewListScanFilesTool lists available scan artifacts in MinIO for the given
+// job, one of jobIDs if any are given.
+func NewListScanFilesTool(jobIDs ...string) (tool.BaseTool, error) {
 	return utils.InferTool(
 		"list_scan_files",
 		"List the scan artifact files available in object storage for the given job ID.",
 		func(ctx context.Context, args listScanFilesArgs) (string, error) {
-			prefix := fmt.Sprintf("%s/artifacts/", args.JobID)
-			objects, err := storage.ListFiles(ctx, bucket, prefix)
+			loc, err := jobLocation(ctx, jobIDs, args.JobID)
+			if err != nil {
+				return "", err
+			}
+			if err := readBudgetFor(ctx).read(); err != nil {
+				return "", err
+			}
+			prefix := loc.ArtifactsPrefix()
+			objects, err := storage.ListFiles(ctx, loc.Bucket, prefix)
 			if err != nil {
 				return "", fmt.Errorf("listing artifacts for job %q: %w", args.JobID, err)
 			}
@@ -116,21 +181,90 @@ func NewListScanFilesTool(bucket string) (tool.BaseTool, error) {
 	)
 }
 
-// NewWriteDraftTool writes (or overwrites) report.md in MinIO for the given job.
-func NewWriteDraftTool(bucket string) (tool.BaseTool, error) {
+// draftVersionPattern matches versioned drafts, e.g. draft.v2.md
+var draftVersionPattern = regexp.MustCompile(`^draft\.v(\d+)\.md$`)
+
+// NewWriteDraftTool stores a report draft for the given job as the next
+// version, draft.vN.md, and as draft.md (the latest draft). Drafts never
+// overwrite each other or report.md: the flow promotes the latest one to
+// report.md once critique approves. Rewriting the latest draft unchanged
+// doesn't add a version. Like the read tools it can be limited to jobIDs.
+func NewWriteDraftTool(jobIDs ...string) (tool.BaseTool, error) {
 	return utils.InferTool(
 		"write_draft",
-		"Write or overwrite report.md in object storage for the given job with the provided Markdown content.",
+		"Save the report draft for the given job with the provided Markdown content. Each call stores a new draft version (draft.v1.md, draft.v2.md, ...); the latest is also readable as draft.md.",
 		func(ctx context.Context, args writeDraftArgs) (string, error) {
-			objectName := fmt.Sprintf("%s/artifacts/report.md", args.JobID)
-			reader := strings.NewReader(args.Content)
-			_, err := storage.Client.PutObject(ctx, bucket, objectName, reader, int64(len(args.Content)), minio.PutObjectOptions{
-				ContentType: "text/markdown",
-			})
+			loc, err := jobLocation(ctx, jobIDs, args.JobID)
 			if err != nil {
-				return "", fmt.Errorf("writing report.md for job %q: %w", args.JobID, err)
+				return "", err
 			}
-			return fmt.Sprintf("report.md written for job %q (%d bytes)", args.JobID, len(args.Content)), nil
+			version, err := latestDraftVersion(ctx, loc)
+			if err != nil {
+				return "", err
+			}
+			if version > 0 {
+				if latest, err := readMinIOFile(ctx, loc.Bucket, loc.Key(draftName(version))); err == nil && latest == args.Content {
+					return fmt.Sprintf("%s for job %q already has this content (%d bytes)", draftName(version), args.JobID, len(args.Content)), nil
+				}
+			}
+
+			version++
+			for _, name := range []string{draftName(version), "draft.md"} {
+				if err := putMarkdown(ctx, loc, name, args.Content); err != nil {
+					return "", fmt.Errorf("writing %s for job %q: %w", name, args.JobID, err)
+				}
+			}
+			return fmt.Sprintf("%s written for job %q (%d bytes)", draftName(version), args.JobID, len(args.Content)), nil
 		},
 	)
 }
+
+// draftName is the object name of draft version
+func draftName(version int) string {
+	return fmt.Sprintf("draft.v%d.md", version)
+}
+
+// latestDraftVersion returns the highest draft version stored for a job, 0 if none
+func latestDraftVersion(ctx context.Context, loc storage.Location) (int, error) {
+	prefix := loc.ArtifactsPrefix()
+	objects, err := storage.ListFiles(ctx, loc.Bucket, loc.Key("draft.v"))
+	if err != nil {
+		return 0, fmt.Errorf("listing drafts: %w", err)
+	}
+	latest := 0
+	for _, obj := range objects {
+		m := draftVersionPattern.FindStringSubmatch(strings.TrimPrefix(obj.Key, prefix))
+		if m == nil {
+			continue
+		}
+		if v, err := strconv.Atoi(m[1]); err == nil && v > latest {
+			latest = v
+		}
+	}
+	return latest, nil
+}
+
+// promoteDraft publishes the latest draft as report.md and returns its version
+func promoteDraft(ctx context.Context, loc storage.Location) (int, error) {
+	version, err := latestDraftVersion(ctx, loc)
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("no draft was written")
+	}
+	content, err := readMinIOFile(ctx, loc.Bucket, loc.Key(draftName(version)))
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", draftName(version), err)
+	}
+	if err := putMarkdown(ctx, loc, "report.md", content); err != nil {
+		return 0, fmt.Errorf("writing report.md: %w", err)
+	}
+	return version, nil
+}
+
+// putMarkdown stores content as the job artifact name
+func putMarkdown(ctx context.Context, loc storage.Location, name, content string) error {
+	_, err := storage.UploadFile(ctx, loc.Bucket, loc.Key(name), strings.NewReader(content), int64(len(content)), "text/markdown")
+	return err
+}
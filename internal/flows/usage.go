@@ -0,0 +1,106 @@
+package flows
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ModelPricing is the list price of a model in USD per million tokens.
+type ModelPricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// modelPricing holds list prices for the well-known models. OpenRouter IDs
+// ("vendor/model") are matched on the part after the slash.
+var modelPricing = map[string]ModelPricing{
+	"gpt-4o":                         {InputPerMTok: 2.50, OutputPerMTok: 10.00},
+	"gpt-4o-mini":                    {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+	"gpt-4.1":                        {InputPerMTok: 2.00, OutputPerMTok: 8.00},
+	"gpt-4.1-mini":                   {InputPerMTok: 0.40, OutputPerMTok: 1.60},
+	"o3-mini":                        {InputPerMTok: 1.10, OutputPerMTok: 4.40},
+	"claude-sonnet-4-20250514":       {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+	"claude-3-5-haiku-20241022":      {InputPerMTok: 0.80, OutputPerMTok: 4.00},
+	"gemini-2.0-flash":               {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gemini-2.0-flash-001":           {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gemini-2.5-pro-preview-06-05":   {InputPerMTok: 1.25, OutputPerMTok: 10.00},
+	"gemini-2.5-flash-preview-05-20": {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+}
+
+// EstimateCost returns the estimated USD cost of a completion. Unknown models cost 0.
+func EstimateCost(modelID string, promptTokens, completionTokens int64) float64 {
+	p, ok := modelPricing[modelID]
+	if !ok {
+		if i := strings.LastIndex(modelID, "/"); i >= 0 {
+			p, ok = modelPricing[modelID[i+1:]]
+		}
+	}
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*p.InputPerMTok + float64(completionTokens)*p.OutputPerMTok) / 1e6
+}
+
+// usageTracker sums token usage across all chat model calls of a flow run.
+type usageTracker struct {
+	mu               sync.Mutex
+	requests         int64
+	promptTokens     int64
+	completionTokens int64
+	totalTokens      int64
+}
+
+// add records the usage reported on an assistant message, if any.
+func (u *usageTracker) add(msg *schema.Message) {
+	if u == nil || msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return
+	}
+	usage := msg.ResponseMeta.Usage
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests++
+	u.promptTokens += int64(usage.PromptTokens)
+	u.completionTokens += int64(usage.CompletionTokens)
+	u.totalTokens += int64(usage.TotalTokens)
+}
+
+// flush adds the accumulated usage to today's ProviderUsage row for the user.
+func (u *usageTracker) flush(ctx context.Context, userID, provider, modelID string) error {
+	u.mu.Lock()
+	row := models.ProviderUsage{
+		UserID:           userID,
+		Provider:         provider,
+		Day:              time.Now().UTC().Truncate(24 * time.Hour),
+		Requests:         u.requests,
+		PromptTokens:     u.promptTokens,
+		CompletionTokens: u.completionTokens,
+		TotalTokens:      u.totalTokens,
+		EstimatedCostUSD: EstimateCost(modelID, u.promptTokens, u.completionTokens),
+	}
+	u.mu.Unlock()
+
+	if row.Requests == 0 {
+		return nil
+	}
+
+	return database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "provider"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":           gorm.Expr("provider_usage.requests + ?", row.Requests),
+			"prompt_tokens":      gorm.Expr("provider_usage.prompt_tokens + ?", row.PromptTokens),
+			"completion_tokens":  gorm.Expr("provider_usage.completion_tokens + ?", row.CompletionTokens),
+			"total_tokens":       gorm.Expr("provider_usage.total_tokens + ?", row.TotalTokens),
+			"estimated_cost_usd": gorm.Expr("provider_usage.estimated_cost_usd + ?", row.EstimatedCostUSD),
+			"updated_at":         time.Now(),
+		}),
+	}).Create(&row).Error
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// AdminToken guards the /api/v1/admin endpoints until the auth middleware
+// provides an admin role. Requests must send it as X-Admin-Token. Empty
+// disables the admin API. Set from ADMIN_API_TOKEN.
+var AdminToken string
+
+// RequireAdmin rejects requests that don't carry the admin token.
+// TODO: Check the admin role from the auth middleware once it is in place
+func RequireAdmin(c *fiber.Ctx) error {
+	if AdminToken == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Admin API is disabled (set ADMIN_API_TOKEN to enable)",
+		})
+	}
+	token := c.Get("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) != 1 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Admin access required",
+		})
+	}
+	return c.Next()
+}
+
+// AdminHandler serves platform-wide operator endpoints
+type AdminHandler struct{}
+
+// NewAdminHandler creates a new AdminHandler instance
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{}
+}
+
+// adminIntegrationResponse is an integration record for operators — never credentials
+type adminIntegrationResponse struct {
+	ID            uint       `json:"id"`
+	UserID        string     `json:"user_id"`
+	IntegrationID string     `json:"integration_id"`
+	Status        string     `json:"status"`
+	ConnectedAt   *time.Time `json:"connected_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ListIntegrations returns integrations across all users, optionally
+// filtered by status, provider or user.
+//
+// GET /api/v1/admin/integrations?status=error&integration_id=github&user_id=...&page=1&limit=100
+// Response:
+//
+//	{ "total": 3, "page": 1, "limit": 100, "integrations": [{ "user_id": "...", "integration_id": "github", "status": "error", ... }] }
+func (h *AdminHandler) ListIntegrations(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	query := database.DB.WithContext(c.Context()).Model(&models.Integration{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if integrationID := c.Query("integration_id"); integrationID != "" {
+		query = query.Where("integration_id = ?", integrationID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count integrations: " + err.Error(),
+		})
+	}
+
+	var stored []models.Integration
+	if err := query.Omit("credentials").Order("updated_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&stored).Error; err != nil {
+		log.Printf("Failed to query integrations: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch integrations",
+		})
+	}
+
+	integrations := make([]adminIntegrationResponse, len(stored))
+	for i, s := range stored {
+		integrations[i] = adminIntegrationResponse{
+			ID:            s.ID,
+			UserID:        s.UserID,
+			IntegrationID: s.IntegrationID,
+			Status:        s.Status,
+			ConnectedAt:   s.ConnectedAt,
+			UpdatedAt:     s.UpdatedAt,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"total":        total,
+		"page":         page,
+		"limit":        limit,
+		"integrations": integrations,
+	})
+}
@@ -1,17 +1,33 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/siddhantprateek/reefline/internal/integration/harbor"
+	"github.com/siddhantprateek/reefline/internal/integration/registry"
 	"github.com/siddhantprateek/reefline/internal/queue"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
 	"github.com/siddhantprateek/reefline/pkg/tools"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// maxTimeoutSeconds caps per-request tool timeouts below the queue's task deadline
+const maxTimeoutSeconds = 30 * 60
+
+// IdempotencyKeyTTL is how long an Idempotency-Key maps to its job. A retried
+// request within this window returns the existing job instead of a new one.
+// Overridable via IDEMPOTENCY_KEY_TTL.
+var IdempotencyKeyTTL = 24 * time.Hour
+
 // AnalyzeHandler handles container image analysis requests
 type AnalyzeHandler struct {
 	Queue queue.Queue
@@ -24,10 +40,23 @@ func NewAnalyzeHandler(q queue.Queue) *AnalyzeHandler {
 
 // AnalysisRequest represents the request body for analysis
 type AnalysisRequest struct {
-	Dockerfile          string            `json:"dockerfile"`
-	ImageRef            string            `json:"image_ref"`
-	AppContext          string            `json:"app_context"`
-	RegistryCredentials map[string]string `json:"registry_credentials"`
+	Dockerfile          string              `json:"dockerfile"`
+	ImageRef            string              `json:"image_ref"`
+	ImageSource         string              `json:"image_source"` // Optional hint: "docker-archive", "oci", "local" (sniff)
+	AppContext          string              `json:"app_context"`
+	AnalysisType        string              `json:"analysis_type"`   // "full" (default), "sbom", "vuln", "hygiene"
+	Tools               map[string]bool     `json:"tools"`           // Optional per-tool override of analysis_type, e.g. {"dockle": false}
+	TimeoutSeconds      int                 `json:"timeout_seconds"` // Optional per-tool timeout override (max 1800)
+	MatchProfile        string              `json:"match_profile"`   // Grype CPE matching: "default", "cpe-aggressive", "exact-only"
+	AllPlatforms        bool                `json:"all_platforms"`   // Also scan the other platforms of a multi-arch image
+	RegistryCredentials map[string]string   `json:"registry_credentials"`
+	DryRun              bool                `json:"dry_run"`   // Inspect only; no job is created
+	Scheduled           bool                `json:"scheduled"` // Set by scheduled rescans; diffed against the previous scheduled run
+	IdempotencyKey      string              `json:"-"`         // From the Idempotency-Key header
+	RegistryIntegration string              `json:"-"`         // Connected registry whose credentials the worker pulls with
+	HarborScan          *harbor.ArtifactRef `json:"-"`         // Import Harbor's scan report for this artifact instead of running grype
+	JobID               string              `json:"-"`         // Preassigned job ID (a source upload is stored under it); generated when empty
+	SourceArchive       string              `json:"-"`         // Object key of an uploaded source tree to scan instead of an image
 }
 
 // Handle processes a new analysis request.
@@ -35,12 +64,24 @@ type AnalysisRequest struct {
 // Determines the input scenario (A: Dockerfile only, B: Image only, C: Both)
 // and enqueues an Asynq job for background processing.
 //
+// An optional Idempotency-Key header makes retries safe: if the same user
+// already submitted that key within IdempotencyKeyTTL, the existing job is
+// returned with 200 instead of creating a duplicate.
+//
 // POST /api/v1/analyze
 // Request body:
 //
 //	{
 //	  "dockerfile": "FROM ubuntu:22.04\n...",   // optional
-//	  "image_ref": "nginx:1.25",                // optional
+//	  "image_ref": "nginx:1.25",                // optional; also "oci:/path[:tag]" or "docker-archive:/path.tar" under LOCAL_IMAGE_ROOT
+//	  "image_source": "oci",                    // optional transport hint for bare paths: docker-archive | oci | local
+//	  "analysis_type": "sbom",                  // optional: full (default) | sbom | vuln | hygiene
+//	  "tools": { "dockle": false },             // optional: turn tools of the analysis_type on/off
+//	  "timeout_seconds": 900,                   // optional per-tool timeout override
+//	  "match_profile": "exact-only",            // optional: default | cpe-aggressive | exact-only
+//	  "scheduled": true,                        // optional, marks a scheduled rescan (see SCAN_DIFF_WEBHOOK_URLS)
+//	  "all_platforms": true,                    // optional, scan every platform of a multi-arch image (grype only)
+//	  "dry_run": true                           // optional, validate image_ref only
 //	}
 //
 // Response:
@@ -63,11 +104,63 @@ func (h *AnalyzeHandler) Handle(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one of 'dockerfile' or 'image_ref' must be provided"})
 	}
 
-	ctx := c.Context()
-	jobID := uuid.New().String()
+	if _, err := models.ParseAnalysisType(req.AnalysisType); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := models.ValidateToolOverrides(req.Tools); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := tools.ParseMatchProfile(req.MatchProfile); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxTimeoutSeconds {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("'timeout_seconds' must be between 0 (server default) and %d", maxTimeoutSeconds),
+		})
+	}
+
+	// Resolve local docker-archive/OCI inputs to their transport-prefixed form,
+	// confined to LOCAL_IMAGE_ROOT
+	if req.ImageRef != "" {
+		in, err := tools.ResolveRequestImage(req.ImageRef, req.ImageSource)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		req.ImageRef = in.String()
+	}
+
+	ctx := tools.WithTimeout(c.Context(), time.Duration(req.TimeoutSeconds)*time.Second)
+
+	if req.DryRun {
+		return h.dryRun(c, req)
+	}
+
+	userID := "admin" // TODO: Auth
+
+	// Return the existing job for a retried request, even once the quota is full
+	req.IdempotencyKey = c.Get("Idempotency-Key")
+	if req.IdempotencyKey != "" {
+		existing, err := findIdempotentJob(database.DB.WithContext(ctx), userID, req.IdempotencyKey)
+		if err == nil {
+			return idempotentReplay(c, existing)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to look up idempotency key"})
+		}
+	}
+
+	if err := checkStorageQuota(ctx, userID); err != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Pull private images (GHCR, Docker Hub, Harbor) with the user's connected registry
+	var auth *tools.ImageAuth
+	if cred := registryCredentialFor(userID, req.ImageRef); cred != nil {
+		req.RegistryIntegration = cred.IntegrationID
+		auth = &cred.Auth
+	}
 
 	var skopeoResult *tools.InspectResult
-	var metadataJSON []byte
 
 	// Step 1: Skopeo Inspect (if image provided)
 	if req.ImageRef != "" {
@@ -75,11 +168,6 @@ func (h *AnalyzeHandler) Handle(c *fiber.Ctx) error {
 			// Warn or Error? User said "we shall keep skopeo in main api server".
 			// If disabled, we might skip, but better to assume it's there.
 		} else {
-			// Prepare auth
-			var auth *tools.ImageAuth
-			// TODO: extract credentials from request or DB
-			// For now using empty/nil if public
-
 			res, err := tools.ImgInspector.InspectImage(ctx, req.ImageRef, auth)
 			if err != nil {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -90,72 +178,276 @@ func (h *AnalyzeHandler) Handle(c *fiber.Ctx) error {
 		}
 	}
 
+	// Step 2 + 3: Store in DB and enqueue
+	jobID, err := submitAnalysis(ctx, h.Queue, userID, req, skopeoResult)
+	var dup *duplicateRequestError
+	if errors.As(err, &dup) {
+		return idempotentReplay(c, &dup.job)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Return 202 Accepted with metadata
+	resp := fiber.Map{
+		"job_id":     jobID,
+		"status":     "QUEUED",
+		"stream_url": "/api/v1/jobs/" + jobID + "/stream",
+	}
 	if skopeoResult != nil {
-		metadataJSON, _ = json.Marshal(skopeoResult)
+		resp["image_info"] = imageInfo(skopeoResult)
 	}
 
-	// Step 2: Store in DB
-	queuedAt := time.Now()
-	job := models.Job{
-		ID:         jobID,
-		JobID:      jobID,
-		UserID:     "admin", // TODO: Auth
-		ImageRef:   req.ImageRef,
-		Dockerfile: req.Dockerfile,
-		Status:     models.JobStatusQueued,
-		Scenario:   "image", // simplified logic
-		Metadata:   string(metadataJSON),
-		Progress:   0,
-		QueuedAt:   &queuedAt,
-	}
-	if req.Dockerfile != "" && req.ImageRef != "" {
-		job.Scenario = "both"
-	} else if req.Dockerfile != "" {
-		job.Scenario = "dockerfile"
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
+
+// findIdempotentJob returns the user's job submitted with key within
+// IdempotencyKeyTTL, or gorm.ErrRecordNotFound.
+func findIdempotentJob(db *gorm.DB, userID, key string) (*models.Job, error) {
+	var existing models.Job
+	err := db.Where("user_id = ? AND idempotency_key = ? AND created_at > ?", userID, key, time.Now().Add(-IdempotencyKeyTTL)).
+		First(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// idempotentReplay answers a retried request with the job it already created
+func idempotentReplay(c *fiber.Ctx, job *models.Job) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"job_id":     job.JobID,
+		"status":     job.Status,
+		"stream_url": "/api/v1/jobs/" + job.JobID + "/stream",
+	})
+}
+
+// duplicateRequestError is returned by submitAnalysis when a concurrent
+// request with the same Idempotency-Key created its job first
+type duplicateRequestError struct {
+	job models.Job
+}
+
+func (e *duplicateRequestError) Error() string {
+	return "job " + e.job.JobID + " was already submitted with this Idempotency-Key"
+}
+
+// dryRun validates that req.ImageRef is reachable and pullable by running only
+// the inspector (manifest + platform check). No job is created or enqueued,
+// mirroring the test_only flow of the integration Connect handler.
+//
+// Response:
+//
+//	{
+//	  "dry_run": true,
+//	  "status": "ok",
+//	  "image_info": { "digest": "...", "size": 123, "arch": "amd64", "os": "linux", "layers": 5 }
+//	}
+func (h *AnalyzeHandler) dryRun(c *fiber.Ctx, req AnalysisRequest) error {
+	if req.ImageRef == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "'image_ref' is required for a dry run"})
+	}
+	if tools.ImgInspector == nil || !tools.ImgInspector.IsEnabled() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Image inspector is disabled (set IMAGE_INSPECTOR_ENABLED=true to enable)",
+		})
 	}
 
-	if err := database.DB.Create(&job).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create job record: " + err.Error(),
+	var auth *tools.ImageAuth
+	if cred := registryCredentialFor("admin", req.ImageRef); cred != nil { // TODO: Auth
+		auth = &cred.Auth
+	}
+
+	ctx := tools.WithTimeout(c.Context(), time.Duration(req.TimeoutSeconds)*time.Second)
+	res, err := tools.ImgInspector.InspectImage(ctx, req.ImageRef, auth)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"dry_run": true,
+			"status":  "error",
+			"error":   "Failed to inspect image: " + err.Error(),
+		})
+	}
+
+	// The scanners analyze linux/amd64; anything else would fail downstream
+	if res.Os != "linux" || res.Architecture != "amd64" {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"dry_run":    true,
+			"status":     "error",
+			"error":      fmt.Sprintf("Unsupported platform %s/%s (expected linux/amd64)", res.Os, res.Architecture),
+			"image_info": imageInfo(res),
 		})
 	}
 
-	// Step 3: Enqueue Job
+	return c.JSON(fiber.Map{
+		"dry_run":    true,
+		"status":     "ok",
+		"image_info": imageInfo(res),
+	})
+}
+
+// registryCredentialFor returns the user's connected registry integration that
+// hosts imageRef (e.g. GitHub for ghcr.io), or nil if there is none.
+func registryCredentialFor(userID, imageRef string) *registry.Credential {
+	if imageRef == "" {
+		return nil
+	}
+	creds, err := registry.LoadCredentials(userID)
+	if err != nil {
+		log.Printf("Failed to load registry credentials: %v", err)
+		return nil
+	}
+	return registry.Match(creds, imageRef)
+}
+
+// imageInfo summarizes an inspection result for API responses.
+func imageInfo(res *tools.InspectResult) fiber.Map {
+	var size int64
+	for _, l := range res.Layers {
+		size += l.Size
+	}
+
+	return fiber.Map{
+		"size":    size,
+		"arch":    res.Architecture,
+		"os":      res.Os,
+		"digest":  res.Digest,
+		"created": res.Created,
+		"layers":  len(res.Layers),
+		"source":  res.Source,
+	}
+}
+
+// submitAnalysis stores a new job record for req and enqueues it for the worker.
+// Returns the new job ID.
+func submitAnalysis(ctx context.Context, q queue.Queue, userID string, req AnalysisRequest, skopeoResult *tools.InspectResult) (string, error) {
+	jobID := req.JobID
+	if jobID == "" {
+		jobID = uuid.New().String()
+	}
+
+	analysisType, err := models.ParseAnalysisType(req.AnalysisType)
+	if err != nil {
+		return "", err
+	}
+
+	var metadataJSON []byte
+	if skopeoResult != nil {
+		metadataJSON, _ = json.Marshal(skopeoResult)
+	}
+
+	job := newQueuedJob(jobID, userID, req, analysisType, string(metadataJSON), time.Now())
+	if err := createJob(database.DB.WithContext(ctx), &job); err != nil {
+		return "", err
+	}
+	if err := models.RecordJobEvent(database.DB.WithContext(ctx), jobID, models.JobEventQueued, string(analysisType)); err != nil {
+		log.Printf("[Analyze] failed to record queued event for job %s: %v", jobID, err)
+	}
+
 	payload := map[string]interface{}{
-		"job_id":      jobID,
-		"dockerfile":  req.Dockerfile,
-		"image_ref":   req.ImageRef,
-		"app_context": req.AppContext,
-		"skopeo_meta": skopeoResult,
+		"job_id":          jobID,
+		"dockerfile":      req.Dockerfile,
+		"image_ref":       req.ImageRef,
+		"app_context":     req.AppContext,
+		"analysis_type":   analysisType,
+		"timeout_seconds": req.TimeoutSeconds,
+		"skopeo_meta":     skopeoResult,
+	}
+	if req.MatchProfile != "" {
+		payload["match_profile"] = req.MatchProfile
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.RegistryIntegration != "" {
+		payload["registry_integration"] = req.RegistryIntegration
+	}
+	if req.HarborScan != nil {
+		payload["harbor_scan"] = req.HarborScan
+	}
+	if req.SourceArchive != "" {
+		payload["source_archive"] = req.SourceArchive
+	}
+	if req.AllPlatforms {
+		payload["all_platforms"] = true
 	}
 
 	queueOpts := []queue.Option{}
-	_, err := h.Queue.Enqueue(ctx, "analyze_image", payload, queueOpts...)
-	if err != nil {
+	if _, err := q.Enqueue(ctx, "analyze_image", payload, queueOpts...); err != nil {
 		// Update DB to failed?
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to enqueue analysis job: " + err.Error()})
+		return "", fmt.Errorf("Failed to enqueue analysis job: %w", err)
 	}
 
-	// Return 202 Accepted with metadata
-	resp := fiber.Map{
-		"job_id":     jobID,
-		"status":     "QUEUED",
-		"stream_url": "/api/v1/jobs/" + jobID + "/stream",
+	return jobID, nil
+}
+
+// createJob inserts job. A job with an Idempotency-Key can lose the race
+// against a concurrent retry on the unique (user_id, idempotency_key) index;
+// the winner's row is then returned as a *duplicateRequestError. A key whose
+// job has outlived IdempotencyKeyTTL is released for the new job to take.
+func createJob(db *gorm.DB, job *models.Job) error {
+	if job.IdempotencyKey == "" {
+		if err := db.Create(job).Error; err != nil {
+			return fmt.Errorf("Failed to create job record: %w", err)
+		}
+		return nil
 	}
-	if skopeoResult != nil {
-		var size int64
-		for _, l := range skopeoResult.Layers {
-			size += l.Size
+
+	for attempt := 0; attempt < 2; attempt++ {
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+		if res.Error != nil {
+			return fmt.Errorf("Failed to create job record: %w", res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return nil
 		}
 
-		resp["image_info"] = fiber.Map{
-			"size":    size,
-			"arch":    skopeoResult.Architecture,
-			"os":      skopeoResult.Os,
-			"digest":  skopeoResult.Digest,
-			"created": skopeoResult.Created,
+		// Deleted jobs still hold their key in the index
+		var existing models.Job
+		err := db.Unscoped().Where("user_id = ? AND idempotency_key = ?", job.UserID, job.IdempotencyKey).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to look up idempotency key: %w", err)
 		}
+		if existing.DeletedAt.Valid || !existing.CreatedAt.After(time.Now().Add(-IdempotencyKeyTTL)) {
+			err = db.Unscoped().Model(&models.Job{}).
+				Where("id = ? AND idempotency_key = ?", existing.ID, job.IdempotencyKey).
+				Update("idempotency_key", "").Error
+			if err != nil {
+				return fmt.Errorf("Failed to release idempotency key: %w", err)
+			}
+			continue
+		}
+		return &duplicateRequestError{job: existing}
 	}
+	return fmt.Errorf("Failed to create job record: idempotency key %q is contended", job.IdempotencyKey)
+}
 
-	return c.Status(fiber.StatusAccepted).JSON(resp)
+// newQueuedJob builds the record of a job submitted at queuedAt. QueuedAt is
+// what the queue wait metrics measure from, so every job must carry it.
+func newQueuedJob(jobID, userID string, req AnalysisRequest, analysisType models.AnalysisType, metadata string, queuedAt time.Time) models.Job {
+	job := models.Job{
+		ID:             jobID,
+		JobID:          jobID,
+		UserID:         userID,
+		ImageRef:       req.ImageRef,
+		Dockerfile:     req.Dockerfile,
+		Status:         models.JobStatusQueued,
+		Scenario:       "image", // simplified logic
+		AnalysisType:   analysisType,
+		Scheduled:      req.Scheduled,
+		IdempotencyKey: req.IdempotencyKey,
+		Metadata:       metadata,
+		Progress:       0,
+		QueuedAt:       &queuedAt,
+	}
+	if req.SourceArchive != "" {
+		job.Scenario = "source"
+	} else if req.Dockerfile != "" && req.ImageRef != "" {
+		job.Scenario = "both"
+	} else if req.Dockerfile != "" {
+		job.Scenario = "dockerfile"
+	}
+	return job
 }
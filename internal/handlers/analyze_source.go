@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// SourceArchiveName is the artifact an uploaded source tree is stored as
+const SourceArchiveName = "source.tar.gz"
+
+// SourceRefPrefix marks the image_ref of a source scan job, followed by the
+// upload's display name
+const SourceRefPrefix = "source:"
+
+// SourceMaxUploadBytes bounds the request body of POST /api/v1/analyze/source,
+// set from SOURCE_MAX_UPLOAD_BYTES. The route is exempt from the API-wide
+// BODY_LIMIT_BYTES so that only uploads get the larger limit.
+var SourceMaxUploadBytes int64 = 256 << 20
+
+// sourceFormMemory is how much of the upload readSourceForm keeps in memory;
+// the rest of the archive is spooled to a temporary file
+const sourceFormMemory = 8 << 20
+
+var errSourceTooLarge = errors.New("upload exceeds SOURCE_MAX_UPLOAD_BYTES")
+
+// readSourceForm parses the multipart upload straight from the request body
+// stream, stopping at SourceMaxUploadBytes. The caller removes the form's
+// temporary files.
+func readSourceForm(c *fiber.Ctx) (*multipart.Form, error) {
+	if int64(c.Request().Header.ContentLength()) > SourceMaxUploadBytes {
+		return nil, errSourceTooLarge
+	}
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return nil, errors.New("expected a multipart/form-data body")
+	}
+
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	limited := &io.LimitedReader{R: body, N: SourceMaxUploadBytes + 1}
+	form, err := multipart.NewReader(limited, boundary).ReadForm(sourceFormMemory)
+	if err == nil {
+		// Read past the closing boundary so the connection can be reused
+		_, err = io.Copy(io.Discard, limited)
+	}
+	if err != nil || limited.N <= 0 {
+		if form != nil {
+			_ = form.RemoveAll()
+		}
+		if limited.N <= 0 {
+			return nil, errSourceTooLarge
+		}
+		return nil, err
+	}
+	return form, nil
+}
+
+// formValue returns the first value of a form field, or ""
+func formValue(form *multipart.Form, key string) string {
+	if v := form.Value[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// HandleSource accepts an uploaded tarball of a source checkout and enqueues
+// a scan of its dependencies. The worker extracts it and runs grype over the
+// directory, so the job produces the same grype.json, score and report as an
+// image scan (dockle and dive don't apply).
+//
+// POST /api/v1/analyze/source (multipart/form-data)
+// Form fields:
+//
+//	source           — tar or tar.gz of the source tree (required)
+//	name             — display name, e.g. "acme/api@main" (default: the file name)
+//	app_context      — optional, as for POST /api/v1/analyze
+//	timeout_seconds  — optional per-tool timeout override
+//	match_profile    — optional: default | cpe-aggressive | exact-only
+//
+// The body may be up to SourceMaxUploadBytes; larger uploads get 413.
+//
+// Response: as for POST /api/v1/analyze
+func (h *AnalyzeHandler) HandleSource(c *fiber.Ctx) error {
+	form, err := readSourceForm(c)
+	if err != nil {
+		c.Context().SetConnectionClose()
+	}
+	if errors.Is(err, errSourceTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("source upload is larger than %d bytes", SourceMaxUploadBytes),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid multipart body: " + err.Error()})
+	}
+	defer form.RemoveAll()
+	files := form.File["source"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "'source' file is required"})
+	}
+	file := files[0]
+
+	timeoutSeconds := 0
+	if v := formValue(form, "timeout_seconds"); v != "" {
+		if timeoutSeconds, err = strconv.Atoi(v); err != nil || timeoutSeconds < 0 || timeoutSeconds > maxTimeoutSeconds {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("'timeout_seconds' must be between 0 (server default) and %d", maxTimeoutSeconds),
+			})
+		}
+	}
+	matchProfile := formValue(form, "match_profile")
+	if _, err := tools.ParseMatchProfile(matchProfile); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	name := formValue(form, "name")
+	if name == "" {
+		name = file.Filename
+	}
+
+	ctx := tools.WithTimeout(c.Context(), time.Duration(timeoutSeconds)*time.Second)
+	userID := "admin" // TODO: Auth
+
+	if err := checkStorageQuota(ctx, userID); err != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Store the upload under the job's artifacts, where the worker picks it up
+	jobID := uuid.New().String()
+	loc := storage.ArtifactLocation(userID, jobID)
+	if err := storage.EnsureBucket(ctx, loc.Bucket); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare storage: " + err.Error()})
+	}
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read 'source' file"})
+	}
+	defer f.Close()
+	objectName := loc.Key(SourceArchiveName)
+	if _, err := storage.UploadFile(ctx, loc.Bucket, objectName, f, file.Size, "application/gzip"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to store source archive: " + err.Error()})
+	}
+	if err := models.AddUserStorage(database.DB.WithContext(ctx), userID, file.Size); err != nil {
+		log.Printf("[Analyze] warning: failed to record storage usage for %s: %v", userID, err)
+	}
+
+	req := AnalysisRequest{
+		JobID:          jobID,
+		ImageRef:       SourceRefPrefix + name,
+		AppContext:     formValue(form, "app_context"),
+		AnalysisType:   string(models.AnalysisTypeVuln),
+		TimeoutSeconds: timeoutSeconds,
+		MatchProfile:   matchProfile,
+		SourceArchive:  objectName,
+	}
+	if _, err := submitAnalysis(ctx, h.Queue, userID, req, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"job_id":     jobID,
+		"status":     "QUEUED",
+		"image_ref":  req.ImageRef,
+		"stream_url": "/api/v1/jobs/" + jobID + "/stream",
+	})
+}
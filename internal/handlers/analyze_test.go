@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+func TestNewQueuedJob(t *testing.T) {
+	queuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	job := newQueuedJob("job-1", "user-1", AnalysisRequest{ImageRef: "nginx:1.25", Dockerfile: "FROM nginx"}, models.AnalysisTypeFull, "", queuedAt)
+
+	if job.Status != models.JobStatusQueued {
+		t.Errorf("status = %s, want %s", job.Status, models.JobStatusQueued)
+	}
+	if job.QueuedAt == nil || !job.QueuedAt.Equal(queuedAt) {
+		t.Fatalf("queued_at = %v, want %s", job.QueuedAt, queuedAt)
+	}
+	if job.Scenario != "both" {
+		t.Errorf("scenario = %q, want both", job.Scenario)
+	}
+
+	// The queue wait is measured from QueuedAt once the worker sets StartedAt
+	startedAt := queuedAt.Add(3 * time.Second)
+	job.StartedAt = &startedAt
+	if got := job.GetQueueWaitDuration(); got != 3*time.Second {
+		t.Errorf("queue wait = %s, want 3s", got)
+	}
+}
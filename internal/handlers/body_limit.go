@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than limit with 413. The server
+// streams request bodies (fiber's StreamRequestBody) so that the routes in
+// skip can read their own, larger uploads; every other route gets its body
+// buffered here, up to limit, before the handler runs.
+func BodyLimit(limit int, skip ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, path := range skip {
+			if c.Path() == path {
+				return c.Next()
+			}
+		}
+
+		req := c.Request()
+		if req.Header.ContentLength() > limit {
+			return rejectBody(c, fiber.ErrRequestEntityTooLarge)
+		}
+		if stream := req.BodyStream(); stream != nil {
+			// Chunked, or the part of the body not yet read
+			body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+			if err != nil {
+				return rejectBody(c, fiber.NewError(fiber.StatusBadRequest, "Failed to read request body"))
+			}
+			if len(body) > limit {
+				return rejectBody(c, fiber.ErrRequestEntityTooLarge)
+			}
+			req.SetBody(body)
+		}
+		return c.Next()
+	}
+}
+
+// rejectBody closes the connection after the response: the rest of a
+// streamed body is left unread and would otherwise be parsed as the next
+// request on it.
+func rejectBody(c *fiber.Ctx, err error) error {
+	c.Context().SetConnectionClose()
+	return err
+}
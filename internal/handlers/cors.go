@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// Defaults used when the CORS_* variables are unset. Only the dashboard's
+// Vite dev server is allowed cross-origin; in production the dashboard is
+// served from the API's own origin and needs no CORS at all.
+const (
+	defaultCORSOrigins = "http://localhost:5173"
+	defaultCORSMethods = "GET,POST,PUT,DELETE,OPTIONS"
+	defaultCORSHeaders = "Origin,Content-Type,Accept,Authorization,Idempotency-Key"
+)
+
+// CORSConfigFromEnv builds the CORS middleware config from
+// CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS
+// (comma-separated). Credentials are only allowed when every origin is
+// listed explicitly: with "*" any site could make credentialed requests,
+// and browsers reject that combination anyway.
+func CORSConfigFromEnv() cors.Config {
+	origins := corsList(os.Getenv("CORS_ALLOWED_ORIGINS"), defaultCORSOrigins)
+	wildcard := false
+	for _, o := range strings.Split(origins, ",") {
+		if o == "*" {
+			wildcard = true
+		}
+	}
+	if wildcard {
+		origins = "*"
+	}
+
+	return cors.Config{
+		AllowOrigins:     origins,
+		AllowMethods:     corsList(os.Getenv("CORS_ALLOWED_METHODS"), defaultCORSMethods),
+		AllowHeaders:     corsList(os.Getenv("CORS_ALLOWED_HEADERS"), defaultCORSHeaders),
+		AllowCredentials: !wildcard,
+	}
+}
+
+// corsList normalizes a comma-separated env value, falling back to def when empty
+func corsList(v, def string) string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimRight(strings.TrimSpace(item), "/"); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return def
+	}
+	return strings.Join(items, ",")
+}
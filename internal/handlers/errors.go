@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/integration"
+)
+
+// ErrorHandler is the app-wide Fiber error handler. It renders errors that
+// handlers return (rather than write themselves), recovered panics and
+// framework errors such as an oversized body as { "error": ..., "code": ... },
+// mapping typed integration errors to their HTTP status.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := err.Error()
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	} else {
+		status = integration.HTTPStatus(err, fiber.StatusInternalServerError)
+	}
+
+	if status == fiber.StatusInternalServerError && fiberErr == nil {
+		// Unclassified: don't leak internals (panics, driver errors) to clients
+		log.Printf("Unhandled error on %s %s: %v", c.Method(), c.Path(), err)
+		message = "Internal server error"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": message,
+		"code":  status,
+	})
+}
+
+// ErrorCodes adds the HTTP status as "code" to JSON error responses written
+// directly by handlers ({ "error": ... } with a 4xx/5xx status), so every
+// error has the same shape as those rendered by ErrorHandler.
+func ErrorCodes(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	resp := c.Response()
+	status := resp.StatusCode()
+	if status < fiber.StatusBadRequest || !strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Body(), &body); err != nil {
+		return nil
+	}
+	if _, ok := body["error"]; !ok {
+		return nil
+	}
+	if _, ok := body["code"]; ok {
+		return nil
+	}
+	body["code"] = status
+	return c.JSON(body)
+}
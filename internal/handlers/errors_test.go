@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/integration"
+)
+
+func TestErrorShape(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(ErrorCodes)
+	app.Get("/written", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad input"})
+	})
+	app.Get("/upstream", func(c *fiber.Ctx) error {
+		return fmt.Errorf("listing repos: %w", integration.NewStatusError(http.StatusNotFound, nil))
+	})
+	app.Get("/internal", func(c *fiber.Ctx) error {
+		return errors.New("pq: connection refused")
+	})
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "error", "error": "shown with 200"})
+	})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantError  string
+		wantCode   bool
+	}{
+		{"/written", http.StatusBadRequest, "bad input", true},
+		{"/upstream", http.StatusNotFound, "listing repos: unexpected status 404", true},
+		{"/internal", http.StatusInternalServerError, "Internal server error", true},
+		{"/missing", http.StatusNotFound, "Cannot GET /missing", true},
+		{"/ok", http.StatusOK, "shown with 200", false},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("GET %s: decoding body: %v", tt.path, err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+		}
+		if body["error"] != tt.wantError {
+			t.Errorf("GET %s: error = %v, want %q", tt.path, body["error"], tt.wantError)
+		}
+		code, hasCode := body["code"]
+		if hasCode != tt.wantCode {
+			t.Errorf("GET %s: has code = %t, want %t", tt.path, hasCode, tt.wantCode)
+		}
+		if hasCode && code != float64(tt.wantStatus) {
+			t.Errorf("GET %s: code = %v, want %d", tt.path, code, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/flows"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FlowPreferencesHandler manages the user's AI report flow preferences
+type FlowPreferencesHandler struct{}
+
+// NewFlowPreferencesHandler creates a new FlowPreferencesHandler instance
+func NewFlowPreferencesHandler() *FlowPreferencesHandler {
+	return &FlowPreferencesHandler{}
+}
+
+// Get returns the provider order used for the user's reports and whether it
+// is their own ("user") or the server default ("default").
+//
+// GET /api/v1/flow-preferences
+func (h *FlowPreferencesHandler) Get(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	source := "user"
+	err := database.DB.Where("user_id = ?", userID).First(&models.FlowPreferences{}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		source = "default"
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch flow preferences",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source":            source,
+		"provider_priority": flows.ProviderPriorityFor(userID),
+	})
+}
+
+// Update saves the user's flow preferences. The report flow uses the first
+// connected AI integration in provider_priority; providers left out are tried
+// after the listed ones. An empty list reverts to the server default.
+//
+// PUT /api/v1/flow-preferences
+// Body: { "provider_priority": ["openrouter", "anthropic"] }
+func (h *FlowPreferencesHandler) Update(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	var req struct {
+		ProviderPriority []string `json:"provider_priority"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.ProviderPriority) == 0 {
+		if err := database.DB.Where("user_id = ?", userID).Delete(&models.FlowPreferences{}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to reset flow preferences",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"source":            "default",
+			"provider_priority": flows.ProviderPriorityFor(userID),
+		})
+	}
+
+	order, err := flows.ParseProviderPriority(req.ProviderPriority)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	row := models.FlowPreferences{UserID: userID, ProviderPriority: strings.Join(order, ",")}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"provider_priority", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		log.Printf("Failed to save flow preferences: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save flow preferences",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source":            "user",
+		"provider_priority": order,
+	})
+}
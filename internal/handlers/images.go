@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// ImagesHandler serves raw image metadata straight from the registry, without a job
+type ImagesHandler struct{}
+
+// NewImagesHandler creates a new ImagesHandler instance
+func NewImagesHandler() *ImagesHandler {
+	return &ImagesHandler{}
+}
+
+// imageRef returns the ?ref= query, once the inspector is known to be available
+func imageRef(c *fiber.Ctx) (string, error) {
+	ref := c.Query("ref")
+	if ref == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "'ref' query parameter is required")
+	}
+	if tools.ImgInspector == nil || !tools.ImgInspector.IsEnabled() {
+		return "", fiber.NewError(fiber.StatusServiceUnavailable, "Image inspector is disabled (set IMAGE_INSPECTOR_ENABLED=true to enable)")
+	}
+	return ref, nil
+}
+
+// imageAuth returns the user's connected registry credentials for ref, or nil
+func imageAuth(ref string) *tools.ImageAuth {
+	if cred := registryCredentialFor("admin", ref); cred != nil { // TODO: Auth
+		return &cred.Auth
+	}
+	return nil
+}
+
+// Manifest returns the raw manifest (or manifest list / OCI index) of an image
+// exactly as the registry serves it, with its media type as Content-Type.
+//
+// GET /api/v1/images/manifest?ref=nginx:1.25
+func (h *ImagesHandler) Manifest(c *fiber.Ctx) error {
+	ref, err := imageRef(c)
+	if err != nil {
+		return err
+	}
+
+	manifest, mimeType, err := tools.ImgInspector.GetRawManifest(c.Context(), ref, imageAuth(ref))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to fetch manifest: " + err.Error()})
+	}
+	if mimeType == "" {
+		mimeType = fiber.MIMEApplicationJSON
+	}
+
+	c.Set(fiber.HeaderContentType, mimeType)
+	return c.Send(manifest)
+}
+
+// Config returns the raw config blob of an image (for linux/amd64 when the ref
+// is a multi-platform index), including its "history" build steps.
+//
+// GET /api/v1/images/config?ref=nginx:1.25
+func (h *ImagesHandler) Config(c *fiber.Ctx) error {
+	ref, err := imageRef(c)
+	if err != nil {
+		return err
+	}
+
+	res, err := tools.ImgInspector.InspectImage(c.Context(), ref, imageAuth(ref))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to inspect image: " + err.Error()})
+	}
+	if len(res.RawConfig) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Image has no config blob"})
+	}
+
+	c.Set(fiber.HeaderContentType, configMediaType(res.RawManifest))
+	return c.Send(res.RawConfig)
+}
+
+// Referrers lists the artifacts attached to an image through the OCI
+// referrers API: SBOMs, signatures, attestations and others.
+//
+// GET /api/v1/images/referrers?ref=nginx:1.25
+func (h *ImagesHandler) Referrers(c *fiber.Ctx) error {
+	ref := c.Query("ref")
+	if ref == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "'ref' query parameter is required"})
+	}
+
+	referrers, err := tools.ListReferrers(tools.WithImageAuth(c.Context(), imageAuth(ref)), ref)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to list referrers: " + err.Error()})
+	}
+	return c.JSON(referrers)
+}
+
+// configMediaType returns the config media type named by an image manifest,
+// defaulting to application/json
+func configMediaType(manifest []byte) string {
+	var m struct {
+		Config struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+	}
+	if json.Unmarshal(manifest, &m) == nil && m.Config.MediaType != "" {
+		return m.Config.MediaType
+	}
+	return fiber.MIMEApplicationJSON
+}
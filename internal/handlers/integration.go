@@ -4,29 +4,48 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/integration"
 	"github.com/siddhantprateek/reefline/internal/integration/ai"
 	"github.com/siddhantprateek/reefline/internal/integration/dockerhub"
 	"github.com/siddhantprateek/reefline/internal/integration/github"
 	"github.com/siddhantprateek/reefline/internal/integration/harbor"
 	k8s "github.com/siddhantprateek/reefline/internal/integration/kubernetes"
+	"github.com/siddhantprateek/reefline/internal/integration/registry"
+	"github.com/siddhantprateek/reefline/internal/queue"
 	"github.com/siddhantprateek/reefline/pkg/crypto"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
 )
 
 // IntegrationHandler handles CRUD operations for user integrations
-type IntegrationHandler struct{}
+type IntegrationHandler struct {
+	Queue queue.Queue
+}
 
 // NewIntegrationHandler creates a new IntegrationHandler instance
-func NewIntegrationHandler() *IntegrationHandler {
-	return &IntegrationHandler{}
+func NewIntegrationHandler(q queue.Queue) *IntegrationHandler {
+	return &IntegrationHandler{Queue: q}
 }
 
+// k8sScanConfig holds the namespace/label exclusions applied to Kubernetes
+// image listing and cluster scans. Seeded from SCAN_EXCLUDED_NAMESPACES and
+// updatable at runtime via PUT /api/v1/integrations/kubernetes/exclusions.
+var (
+	k8sScanConfigMu sync.RWMutex
+	k8sScanConfig   = tools.ImageScans{Enable: true, Exclusions: tools.ExclusionsFromEnv()}
+)
+
 // knownIntegrations lists all supported integration IDs
 var knownIntegrations = []string{
 	"docker", "harbor", "github", "kubernetes",
@@ -35,10 +54,30 @@ var knownIntegrations = []string{
 
 // integrationStatusResponse is the API response for a single integration
 type integrationStatusResponse struct {
-	ID          string                 `json:"id"`
-	Status      string                 `json:"status"`
-	ConnectedAt *time.Time             `json:"connected_at,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	ID            string                   `json:"id"`
+	Status        string                   `json:"status"`
+	ConnectedAt   *time.Time               `json:"connected_at,omitempty"`
+	Metadata      map[string]interface{}   `json:"metadata,omitempty"`
+	LastTestedAt  *time.Time               `json:"last_tested_at,omitempty"`
+	LastLatencyMs *int64                   `json:"last_latency_ms,omitempty"`
+	TestHistory   []models.IntegrationTest `json:"test_history,omitempty"` // Oldest first, for health sparklines
+}
+
+// newIntegrationStatusResponse builds the API view of a stored integration
+func newIntegrationStatusResponse(integration *models.Integration) integrationStatusResponse {
+	resp := integrationStatusResponse{
+		ID:            integration.IntegrationID,
+		Status:        integration.Status,
+		ConnectedAt:   integration.ConnectedAt,
+		LastTestedAt:  integration.LastTestedAt,
+		LastLatencyMs: integration.LastLatencyMs,
+		TestHistory:   integration.Tests(),
+	}
+	if integration.Metadata != "" {
+		_ = json.Unmarshal([]byte(integration.Metadata), &resp.Metadata)
+		resp.Metadata = maskMetadata(integration.IntegrationID, resp.Metadata, nil)
+	}
+	return resp
 }
 
 // List returns all integrations with their connection status.
@@ -82,11 +121,7 @@ func (h *IntegrationHandler) List(c *fiber.Ctx) error {
 			continue
 		}
 		if s, ok := storedMap[id]; ok {
-			resp.Status = s.Status
-			resp.ConnectedAt = s.ConnectedAt
-			if s.Metadata != "" {
-				_ = json.Unmarshal([]byte(s.Metadata), &resp.Metadata)
-			}
+			resp = newIntegrationStatusResponse(s)
 		}
 		integrations = append(integrations, resp)
 	}
@@ -113,29 +148,23 @@ func (h *IntegrationHandler) Get(c *fiber.Ctx) error {
 		})
 	}
 
-	resp := integrationStatusResponse{
-		ID:          integrationID,
-		Status:      integration.Status,
-		ConnectedAt: integration.ConnectedAt,
-	}
-	if integration.Metadata != "" {
-		_ = json.Unmarshal([]byte(integration.Metadata), &resp.Metadata)
-	}
-
-	return c.JSON(resp)
+	return c.JSON(newIntegrationStatusResponse(&integration))
 }
 
-// Connect saves integration credentials after validating them.
+// Connect saves integration credentials after validating them. An AI
+// provider's "model" and "critique_model" are checked against the provider's
+// model list unless "force" is set.
 //
 // POST /api/v1/integrations/:id/connect
 func (h *IntegrationHandler) Connect(c *fiber.Ctx) error {
 	integrationID := c.Params("id")
 	userID := getUserID(c)
 
-	// Parse the envelope: { "data": "<base64-encoded credentials JSON>", "test_only": bool }
+	// Parse the envelope: { "data": "<base64-encoded credentials JSON>", "test_only": bool, "force": bool }
 	var envelope struct {
 		Data     string `json:"data"`
 		TestOnly bool   `json:"test_only"`
+		Force    bool   `json:"force"` // Save an AI model the provider doesn't list (yet)
 	}
 	if err := c.BodyParser(&envelope); err != nil || envelope.Data == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -164,14 +193,38 @@ func (h *IntegrationHandler) Connect(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
+	start := time.Now()
 	metadata, err := validateProviderCredentials(ctx, integrationID, credentials)
+	test := newIntegrationTest(start, err)
 	if err != nil {
 		return c.JSON(fiber.Map{
-			"id":     integrationID,
-			"status": "error",
-			"error":  fmt.Sprintf("Credential validation failed: %v", err),
+			"id":         integrationID,
+			"status":     "error",
+			"error":      fmt.Sprintf("Credential validation failed: %v", err),
+			"latency_ms": test.LatencyMs,
 		})
 	}
+	metadata = maskMetadata(integrationID, metadata, credentials)
+
+	// Catch a typo'd model now rather than when a report fails mid-generation
+	if aiProviders[integrationID] && !envelope.Force {
+		client := ai.NewClient(ai.Config{Provider: ai.Provider(integrationID), APIKey: credentials["apiKey"]})
+		for _, key := range []string{"model", "critique_model"} {
+			model := credentials[key]
+			if model == "" {
+				continue
+			}
+			if err := client.ValidateModel(ctx, model); errors.Is(err, ai.ErrUnknownModel) {
+				return c.JSON(fiber.Map{
+					"id":     integrationID,
+					"status": "error",
+					"error":  fmt.Sprintf("Model validation failed for %s: %v — check the model ID, or connect with \"force\": true to use it anyway", key, err),
+				})
+			} else if err != nil {
+				log.Printf("Could not validate %s %q for %s, accepting it: %v", key, model, integrationID, err)
+			}
+		}
+	}
 
 	// If test-only, return success without saving
 	if testOnly {
@@ -211,6 +264,7 @@ func (h *IntegrationHandler) Connect(c *fiber.Ctx) error {
 			Metadata:      string(metaJSON),
 			ConnectedAt:   &now,
 		}
+		integration.RecordTest(test)
 		if err := database.DB.Create(&integration).Error; err != nil {
 			log.Printf("Failed to create integration: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -223,6 +277,7 @@ func (h *IntegrationHandler) Connect(c *fiber.Ctx) error {
 		existing.Credentials = encryptedCreds
 		existing.Metadata = string(metaJSON)
 		existing.ConnectedAt = &now
+		existing.RecordTest(test)
 		if err := database.DB.Save(&existing).Error; err != nil {
 			log.Printf("Failed to update integration: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -232,9 +287,10 @@ func (h *IntegrationHandler) Connect(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"id":       integrationID,
-		"status":   "connected",
-		"metadata": metadata,
+		"id":         integrationID,
+		"status":     "connected",
+		"metadata":   metadata,
+		"latency_ms": test.LatencyMs,
 	})
 }
 
@@ -282,6 +338,14 @@ func (h *IntegrationHandler) TestConnection(c *fiber.Ctx) error {
 	decryptedJSON, err := crypto.Decrypt(integration.Credentials)
 	if err != nil {
 		log.Printf("Failed to decrypt credentials for %s: %v", integrationID, err)
+		if errors.Is(err, crypto.ErrDecrypt) {
+			markReauthRequired(&integration)
+			return c.JSON(fiber.Map{
+				"id":     integrationID,
+				"status": statusReauthRequired,
+				"error":  reauthRequiredMessage,
+			})
+		}
 		return c.JSON(fiber.Map{
 			"id":     integrationID,
 			"status": "error",
@@ -304,24 +368,174 @@ func (h *IntegrationHandler) TestConnection(c *fiber.Ctx) error {
 
 	start := time.Now()
 	_, err = validateProviderCredentials(ctx, integrationID, credentials)
-	latencyMs := time.Since(start).Milliseconds()
+	test := newIntegrationTest(start, err)
 
+	integration.RecordTest(test)
+	updates := map[string]interface{}{
+		"last_tested_at":  integration.LastTestedAt,
+		"last_latency_ms": integration.LastLatencyMs,
+		"test_history":    integration.TestHistory,
+	}
 	if err != nil {
-		// Update status to error in DB
-		database.DB.Model(&integration).Update("status", "error")
+		updates["status"] = "error"
+	} else if integration.Status == "error" {
+		updates["status"] = "connected" // Recovered
+	}
+	if dbErr := database.DB.Model(&integration).Updates(updates).Error; dbErr != nil {
+		log.Printf("Failed to record test for %s: %v", integrationID, dbErr)
+	}
 
+	if err != nil {
 		return c.JSON(fiber.Map{
-			"id":         integrationID,
-			"status":     "error",
-			"error":      fmt.Sprintf("Connection test failed: %v", err),
-			"latency_ms": latencyMs,
+			"id":           integrationID,
+			"status":       "error",
+			"error":        fmt.Sprintf("Connection test failed: %v", err),
+			"latency_ms":   test.LatencyMs,
+			"test_history": integration.Tests(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"id":         integrationID,
-		"status":     "connected",
-		"latency_ms": latencyMs,
+		"id":           integrationID,
+		"status":       "connected",
+		"latency_ms":   test.LatencyMs,
+		"test_history": integration.Tests(),
+	})
+}
+
+// newIntegrationTest records the outcome of a validation that began at start
+func newIntegrationTest(start time.Time, err error) models.IntegrationTest {
+	test := models.IntegrationTest{
+		At:        start,
+		Success:   err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		test.Error = err.Error()
+	}
+	return test
+}
+
+// IntegrationRequestTimeout bounds each provider API call made while serving
+// a request, so a slow upstream returns 504 instead of hanging the connection.
+// Set from INTEGRATION_REQUEST_TIMEOUT.
+var IntegrationRequestTimeout = 15 * time.Second
+
+// providerContext returns the request context bounded by IntegrationRequestTimeout
+func providerContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Context(), IntegrationRequestTimeout)
+}
+
+// Read-only provider listings (repositories, images, projects, tags) are
+// cached for ListingCacheTTL; ?refresh=true bypasses the cache. The server
+// replaces ListingCache with a Redis-backed one when REDIS_HOST is set and
+// sets the TTL from LISTING_CACHE_TTL (0 disables caching).
+var (
+	ListingCache    = integration.NewMemoryCache()
+	ListingCacheTTL = time.Minute
+)
+
+// listingCacheKey identifies a listing by user, endpoint and query parameters
+func listingCacheKey(c *fiber.Ctx) string {
+	queries := c.Queries()
+	params := make([]string, 0, len(queries))
+	for k, v := range queries {
+		if k != "refresh" {
+			params = append(params, k+"="+v)
+		}
+	}
+	sort.Strings(params)
+	return "reefline:listing:" + getUserID(c) + ":" + c.Path() + "?" + strings.Join(params, "&")
+}
+
+// serveCachedListing responds with the cached listing for this request, if
+// there is one, and reports whether it did
+func serveCachedListing(c *fiber.Ctx) (bool, error) {
+	if ListingCacheTTL <= 0 || c.QueryBool("refresh", false) {
+		return false, nil
+	}
+	body, ok := ListingCache.Get(c.Context(), listingCacheKey(c))
+	if !ok {
+		return false, nil
+	}
+	c.Set("X-Cache", "HIT")
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return true, c.Send(body)
+}
+
+// sendListing responds with v and caches it for later requests
+func sendListing(c *fiber.Ctx, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if ListingCacheTTL > 0 {
+		ListingCache.Set(c.Context(), listingCacheKey(c), body, ListingCacheTTL)
+	}
+	c.Set("X-Cache", "MISS")
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(body)
+}
+
+// aiProviders lists the integration IDs that track token usage
+var aiProviders = map[string]bool{
+	"openai": true, "anthropic": true, "google": true, "openrouter": true,
+}
+
+// Usage returns daily token counts and estimated cost for an AI provider.
+// The window is set with ?range=Nd (1-365 days, default 30d).
+//
+// GET /api/v1/integrations/:id/usage
+func (h *IntegrationHandler) Usage(c *fiber.Ctx) error {
+	integrationID := c.Params("id")
+	userID := getUserID(c)
+
+	if !aiProviders[integrationID] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Usage is only tracked for AI providers, not %q", integrationID),
+		})
+	}
+
+	rangeParam := c.Query("range", "30d")
+	days, err := strconv.Atoi(strings.TrimSuffix(rangeParam, "d"))
+	if err != nil || !strings.HasSuffix(rangeParam, "d") || days < 1 || days > 365 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid range — expected e.g. 7d or 30d (max 365d)",
+		})
+	}
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+	var daily []models.ProviderUsage
+	if err := database.DB.
+		Where("user_id = ? AND provider = ? AND day >= ?", userID, integrationID, since).
+		Order("day ASC").
+		Find(&daily).Error; err != nil {
+		log.Printf("Failed to query provider usage: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch usage",
+		})
+	}
+
+	var totals models.ProviderUsage
+	for _, d := range daily {
+		totals.Requests += d.Requests
+		totals.PromptTokens += d.PromptTokens
+		totals.CompletionTokens += d.CompletionTokens
+		totals.TotalTokens += d.TotalTokens
+		totals.EstimatedCostUSD += d.EstimatedCostUSD
+	}
+
+	return c.JSON(fiber.Map{
+		"provider": integrationID,
+		"range":    rangeParam,
+		"daily":    daily,
+		"totals": fiber.Map{
+			"requests":           totals.Requests,
+			"prompt_tokens":      totals.PromptTokens,
+			"completion_tokens":  totals.CompletionTokens,
+			"total_tokens":       totals.TotalTokens,
+			"estimated_cost_usd": totals.EstimatedCostUSD,
+		},
 	})
 }
 
@@ -335,18 +549,24 @@ func (h *IntegrationHandler) ListGitHubRepos(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
+
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
 	page := c.QueryInt("page", 1)
 	perPage := c.QueryInt("per_page", 20)
 
-	repos, err := client.ListRepositories(c.Context(), page, perPage)
+	repos, err := client.ListRepositories(ctx, page, perPage)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list repositories: %v", err),
 		})
 	}
 
-	return c.JSON(repos)
+	return sendListing(c, repos)
 }
 
 // GetGitHubDockerfile fetches a Dockerfile from a GitHub repository.
@@ -358,14 +578,17 @@ func (h *IntegrationHandler) GetGitHubDockerfile(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	ctx, cancel := providerContext(c)
+	defer cancel()
+
 	owner := c.Params("owner")
 	repo := c.Params("repo")
 	path := c.Query("path", "")
 	ref := c.Query("ref", "")
 
-	content, err := client.GetDockerfile(c.Context(), owner, repo, path, ref)
+	content, err := client.GetDockerfile(ctx, owner, repo, path, ref)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Dockerfile not found: %v", err),
 		})
 	}
@@ -376,32 +599,60 @@ func (h *IntegrationHandler) GetGitHubDockerfile(c *fiber.Ctx) error {
 	})
 }
 
-// ListGitHubContainerImages lists GHCR images.
+// ListGitHubContainerImages lists GHCR images. ?org=true lists an
+// organization's packages, ?visibility=public|private|internal and ?prefix=
+// filter them, and ?with_tags=true includes each image's tags.
 //
-// GET /api/v1/integrations/github/images
+// GET /api/v1/integrations/github/images?owner=acme&org=true&visibility=private&prefix=api-&with_tags=true
 func (h *IntegrationHandler) ListGitHubContainerImages(c *fiber.Ctx) error {
 	client, err := getGitHubClient(c)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
 
-	owner := c.Query("owner", "")
-	page := c.QueryInt("page", 1)
-	perPage := c.QueryInt("per_page", 20)
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
+	opts := github.ImageListOptions{
+		Org:        c.QueryBool("org", false),
+		Visibility: c.Query("visibility", ""),
+		NamePrefix: c.Query("prefix", ""),
+		Page:       c.QueryInt("page", 1),
+		PerPage:    c.QueryInt("per_page", 20),
+	}
+	switch opts.Visibility {
+	case "", "public", "private", "internal":
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "visibility must be public, private or internal",
+		})
+	}
+
+	owner := c.Query("owner", "")
 	if owner == "" {
+		if opts.Org {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "owner is required with org=true"})
+		}
 		// Use the authenticated user
-		owner, _ = client.ValidateCredentials(c.Context())
+		owner, _ = client.ValidateCredentials(ctx)
 	}
 
-	images, err := client.ListContainerImages(c.Context(), owner, page, perPage)
+	var images []github.ContainerImage
+	if c.QueryBool("with_tags", false) {
+		images, err = client.ListContainerImagesWithTags(ctx, owner, opts)
+	} else {
+		images, err = client.ListContainerImagesFiltered(ctx, owner, opts)
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list container images: %v", err),
 		})
 	}
 
-	return c.JSON(images)
+	return sendListing(c, images)
 }
 
 // CreateGitHubIssue creates a GitHub issue with optimization results.
@@ -413,6 +664,9 @@ func (h *IntegrationHandler) CreateGitHubIssue(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	ctx, cancel := providerContext(c)
+	defer cancel()
+
 	owner := c.Params("owner")
 	repo := c.Params("repo")
 
@@ -429,9 +683,9 @@ func (h *IntegrationHandler) CreateGitHubIssue(c *fiber.Ctx) error {
 	reportSummary := fmt.Sprintf("Container image optimization report for job %s", body.JobID)
 	recommendations := []string{"Report details will be populated when the analysis pipeline is implemented."}
 
-	issue, err := client.CreateOptimizationIssue(c.Context(), owner, repo, reportSummary, recommendations)
+	issue, err := client.CreateOptimizationIssue(ctx, owner, repo, reportSummary, recommendations)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to create issue: %v", err),
 		})
 	}
@@ -449,18 +703,24 @@ func (h *IntegrationHandler) ListDockerHubRepos(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
+
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 20)
 
-	repos, err := client.ListRepositories(c.Context(), page, pageSize)
+	repos, err := client.ListRepositories(ctx, page, pageSize)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list repositories: %v", err),
 		})
 	}
 
-	return c.JSON(repos)
+	return sendListing(c, repos)
 }
 
 // ListDockerHubTags lists tags for a Docker Hub repository.
@@ -471,20 +731,26 @@ func (h *IntegrationHandler) ListDockerHubTags(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
+
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
 	namespace := c.Params("namespace")
 	repo := c.Params("repo")
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 20)
 
-	tags, err := client.ListTags(c.Context(), namespace, repo, page, pageSize)
+	tags, err := client.ListTags(ctx, namespace, repo, page, pageSize)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list tags: %v", err),
 		})
 	}
 
-	return c.JSON(tags)
+	return sendListing(c, tags)
 }
 
 // === Harbor-specific endpoints ===
@@ -497,18 +763,24 @@ func (h *IntegrationHandler) ListHarborProjects(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
+
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 20)
 
-	projects, err := client.ListProjects(c.Context(), page, pageSize)
+	projects, err := client.ListProjects(ctx, page, pageSize)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list projects: %v", err),
 		})
 	}
 
-	return c.JSON(projects)
+	return sendListing(c, projects)
 }
 
 // ListHarborArtifacts lists artifacts for a Harbor repository.
@@ -519,20 +791,26 @@ func (h *IntegrationHandler) ListHarborArtifacts(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if hit, err := serveCachedListing(c); hit {
+		return err
+	}
+
+	ctx, cancel := providerContext(c)
+	defer cancel()
 
 	project := c.Params("project")
 	repo := c.Params("repo")
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 20)
 
-	artifacts, err := client.ListArtifacts(c.Context(), project, repo, page, pageSize)
+	artifacts, err := client.ListArtifacts(ctx, project, repo, page, pageSize)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(integration.HTTPStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list artifacts: %v", err),
 		})
 	}
 
-	return c.JSON(artifacts)
+	return sendListing(c, artifacts)
 }
 
 // === Kubernetes-specific endpoints ===
@@ -610,9 +888,292 @@ func (h *IntegrationHandler) ListKubernetesImages(c *fiber.Ctx) error {
 		})
 	}
 
+	if !c.QueryBool("include_excluded", false) {
+		images = filterExcludedImages(images)
+	}
+
 	return c.JSON(images)
 }
 
+// GetKubernetesExclusions returns the namespace/label exclusions applied to cluster scans.
+//
+// GET /api/v1/integrations/kubernetes/exclusions
+func (h *IntegrationHandler) GetKubernetesExclusions(c *fiber.Ctx) error {
+	k8sScanConfigMu.RLock()
+	defer k8sScanConfigMu.RUnlock()
+	return c.JSON(k8sScanConfig.Exclusions)
+}
+
+// UpdateKubernetesExclusions replaces the namespace/label exclusions applied to cluster scans.
+//
+// PUT /api/v1/integrations/kubernetes/exclusions
+// Request body:
+//
+//	{ "namespaces": ["kube-system"], "labels": { "app": ["debug"] } }
+func (h *IntegrationHandler) UpdateKubernetesExclusions(c *fiber.Ctx) error {
+	var exclusions tools.Exclusions
+	if err := c.BodyParser(&exclusions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if exclusions.Namespaces == nil {
+		exclusions.Namespaces = []string{}
+	}
+	if exclusions.Labels == nil {
+		exclusions.Labels = map[string][]string{}
+	}
+
+	k8sScanConfigMu.Lock()
+	k8sScanConfig.Exclusions = exclusions
+	k8sScanConfigMu.Unlock()
+
+	return c.JSON(exclusions)
+}
+
+// Cluster scan limits: how many images are resolved and enqueued at once, and
+// how long the whole scan request may take
+const (
+	defaultClusterScanConcurrency = 5
+	maxClusterScanConcurrency     = 50
+	defaultClusterScanTimeout     = 2 * time.Minute
+)
+
+// ScanKubernetesImages enqueues an analysis job for every unique image running
+// in the cluster (or a single namespace), skipping excluded namespaces and labels.
+// Each image is matched against the user's connected registry integrations so
+// private pulls use the stored credentials; images with no matching integration
+// are reported under "registries.unmatched". With dry_run, only that summary is
+// returned so missing registries can be connected before scanning.
+//
+// Tags are resolved to digests first, so an image running under several tags
+// (or in many pods) is scanned once. At most max_concurrent images are
+// resolved and enqueued at a time; images not reached within timeout_seconds
+// are reported as timed out rather than enqueued.
+//
+// POST /api/v1/integrations/kubernetes/scan
+// Request body:
+//
+//	{ "namespace": "default", "include_excluded": false, "dry_run": false, "max_concurrent": 5, "timeout_seconds": 120 }
+func (h *IntegrationHandler) ScanKubernetesImages(c *fiber.Ctx) error {
+	if !k8s.IsAvailable() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Not running inside a Kubernetes cluster",
+		})
+	}
+
+	var body struct {
+		Namespace       string `json:"namespace"`
+		IncludeExcluded bool   `json:"include_excluded"`
+		DryRun          bool   `json:"dry_run"`
+		MaxConcurrent   int    `json:"max_concurrent"`
+		TimeoutSeconds  int    `json:"timeout_seconds"` // For the whole scan request, not each job
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if body.MaxConcurrent < 0 || body.MaxConcurrent > maxClusterScanConcurrency {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("'max_concurrent' must be between 1 and %d", maxClusterScanConcurrency),
+		})
+	}
+	if body.TimeoutSeconds < 0 || body.TimeoutSeconds > maxTimeoutSeconds {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("'timeout_seconds' must be between 0 (server default) and %d", maxTimeoutSeconds),
+		})
+	}
+
+	client, err := k8s.NewInClusterClient()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create Kubernetes client: %v", err),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	images, err := client.ListContainerImages(ctx, body.Namespace)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list container images: %v", err),
+		})
+	}
+
+	total := len(images)
+	if !body.IncludeExcluded {
+		images = filterExcludedImages(images)
+	}
+
+	userID := getUserID(c)
+	if err := checkStorageQuota(c.Context(), userID); err != nil && !body.DryRun {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	registryCreds, err := registry.LoadCredentials(userID)
+	if err != nil {
+		log.Printf("Failed to load registry credentials: %v", err)
+	}
+
+	type matchedImage struct {
+		Image       string `json:"image"`
+		Registry    string `json:"registry"`
+		Integration string `json:"integration,omitempty"`
+		cred        *registry.Credential
+	}
+	var unique []matchedImage
+	unmatched := []matchedImage{}
+	matched := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, img := range images {
+		if seen[img.Image] {
+			continue
+		}
+		seen[img.Image] = true
+
+		m := matchedImage{Image: img.Image, Registry: tools.RegistryHost(img.Image)}
+		if cred := registry.Match(registryCreds, img.Image); cred != nil {
+			m.Integration = cred.IntegrationID
+			m.cred = cred
+			matched[cred.IntegrationID]++
+		} else {
+			unmatched = append(unmatched, m)
+		}
+		unique = append(unique, m)
+	}
+
+	registries := fiber.Map{
+		"matched":   matched,
+		"unmatched": unmatched, // Pulled anonymously — private images will fail
+	}
+
+	if body.DryRun {
+		return c.JSON(fiber.Map{
+			"dry_run":    true,
+			"images":     unique,
+			"registries": registries,
+			"excluded":   total - len(images),
+		})
+	}
+
+	maxConcurrent := body.MaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = defaultClusterScanConcurrency
+	}
+	timeout := defaultClusterScanTimeout
+	if body.TimeoutSeconds > 0 {
+		timeout = time.Duration(body.TimeoutSeconds) * time.Second
+	}
+	scanCtx, cancelScan := context.WithTimeout(c.Context(), timeout)
+	defer cancelScan()
+
+	type scannedImage struct {
+		Image       string `json:"image"`
+		Digest      string `json:"digest,omitempty"`
+		Status      string `json:"status"` // "enqueued", "duplicate", "failed" or "timed_out"
+		JobID       string `json:"job_id,omitempty"`
+		DuplicateOf string `json:"duplicate_of,omitempty"` // Image with the same digest that was enqueued instead
+		Error       string `json:"error,omitempty"`
+	}
+	results := make([]scannedImage, len(unique))
+	var mu sync.Mutex
+	enqueued := make(map[string]string) // Digest (or reference, if unresolved) -> enqueued image
+
+	// Resolve and enqueue in a bounded pool; each image keeps its result slot
+	sem := make(chan struct{}, maxConcurrent)
+	var wg sync.WaitGroup
+	for i, m := range unique {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := scannedImage{Image: m.Image, Status: "timed_out"}
+			defer func() { results[i] = r }()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-scanCtx.Done():
+				return
+			}
+
+			ctx := scanCtx
+			if m.cred != nil {
+				ctx = tools.WithImageAuth(ctx, &m.cred.Auth)
+			}
+			key := m.Image
+			if digest, err := tools.ResolveDigest(ctx, m.Image); err == nil {
+				r.Digest, key = digest, digest
+			} else if scanCtx.Err() != nil {
+				return
+			}
+
+			mu.Lock()
+			first, dup := enqueued[key]
+			if !dup {
+				enqueued[key] = m.Image
+			}
+			mu.Unlock()
+			if dup {
+				r.Status, r.DuplicateOf = "duplicate", first
+				return
+			}
+
+			req := AnalysisRequest{ImageRef: m.Image, RegistryIntegration: m.Integration}
+			jobID, err := submitAnalysis(scanCtx, h.Queue, userID, req, nil)
+			if err != nil {
+				r.Status, r.Error = "failed", err.Error()
+				return
+			}
+			r.Status, r.JobID = "enqueued", jobID
+		}()
+	}
+	wg.Wait()
+
+	summary := struct {
+		Enqueued             int `json:"enqueued"`
+		Duplicates           int `json:"duplicates"` // Same digest as an enqueued image
+		Failed               int `json:"failed"`
+		TimedOut             int `json:"timed_out"`
+		Excluded             int `json:"excluded"`              // Skipped by namespace/label exclusions
+		UnmatchedCredentials int `json:"unmatched_credentials"` // No connected registry; pulled anonymously
+	}{Excluded: total - len(images), UnmatchedCredentials: len(unmatched)}
+	for _, r := range results {
+		switch r.Status {
+		case "enqueued":
+			summary.Enqueued++
+		case "duplicate":
+			summary.Duplicates++
+		case "failed":
+			summary.Failed++
+		default:
+			summary.TimedOut++
+		}
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"jobs":       results,
+		"summary":    summary,
+		"registries": registries,
+		"excluded":   total - len(images),
+	})
+}
+
+// filterExcludedImages drops images whose namespace or pod labels match the configured exclusions.
+func filterExcludedImages(images []k8s.ContainerImage) []k8s.ContainerImage {
+	k8sScanConfigMu.RLock()
+	cfg := k8sScanConfig
+	k8sScanConfigMu.RUnlock()
+
+	filtered := make([]k8s.ContainerImage, 0, len(images))
+	for _, img := range images {
+		if cfg.ShouldExclude(img.Namespace, img.Labels) {
+			continue
+		}
+		filtered = append(filtered, img)
+	}
+	return filtered
+}
+
 // ListKubernetesNamespaces lists all namespaces in the cluster.
 //
 // GET /api/v1/integrations/kubernetes/namespaces
@@ -656,19 +1217,41 @@ func getUserID(c *fiber.Ctx) string {
 	return userID
 }
 
+// statusReauthRequired marks an integration whose stored credentials can no
+// longer be decrypted, e.g. after ENCRYPTION_KEY was rotated.
+const statusReauthRequired = "reauth_required"
+
+// reauthRequiredMessage tells the user how to recover from statusReauthRequired
+const reauthRequiredMessage = "stored credentials can no longer be decrypted (the server encryption key may have changed) — please reconnect this integration"
+
+// markReauthRequired flags integration so the UI prompts the user to reconnect.
+func markReauthRequired(integration *models.Integration) {
+	if err := database.DB.Model(integration).Update("status", statusReauthRequired).Error; err != nil {
+		log.Printf("Failed to mark %s as reauth_required: %v", integration.IntegrationID, err)
+	}
+}
+
 // getStoredCredentials retrieves and decrypts stored credentials for an integration.
 func getStoredCredentials(c *fiber.Ctx, integrationID string) (map[string]string, error) {
 	userID := getUserID(c)
 
 	var integration models.Integration
-	result := database.DB.Where("user_id = ? AND integration_id = ? AND status = ?", userID, integrationID, "connected").First(&integration)
+	result := database.DB.Where("user_id = ? AND integration_id = ? AND status IN ?", userID, integrationID, []string{"connected", statusReauthRequired}).First(&integration)
 	if result.Error != nil {
 		return nil, fmt.Errorf("%s is not connected — set it up first", integrationID)
 	}
+	if integration.Status == statusReauthRequired {
+		return nil, fmt.Errorf("%s: %s", integrationID, reauthRequiredMessage)
+	}
 
 	// Decrypt stored credentials (AES-256-GCM)
 	decryptedJSON, err := crypto.Decrypt(integration.Credentials)
 	if err != nil {
+		if errors.Is(err, crypto.ErrDecrypt) {
+			log.Printf("Credentials for %s no longer decrypt, marking reauth_required: %v", integrationID, err)
+			markReauthRequired(&integration)
+			return nil, fmt.Errorf("%s: %s", integrationID, reauthRequiredMessage)
+		}
 		return nil, fmt.Errorf("failed to decrypt stored credentials")
 	}
 
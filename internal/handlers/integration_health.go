@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/siddhantprateek/reefline/internal/webhook"
+	"github.com/siddhantprateek/reefline/pkg/crypto"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// EventIntegrationError is the webhook event sent when a health check moves an
+// integration from connected to error
+const EventIntegrationError = "integration.error"
+
+// IntegrationHealthInterval is how often connected integrations are
+// re-validated in the background. Set from INTEGRATION_HEALTH_INTERVAL;
+// 0 disables the checks.
+var IntegrationHealthInterval = 30 * time.Minute
+
+// IntegrationWebhooks are the URLs notified on EventIntegrationError, set from
+// INTEGRATION_WEBHOOK_URLS (comma-separated).
+var IntegrationWebhooks []string
+
+// healthCheckJitter is the fraction of IntegrationHealthInterval each round is
+// randomly shifted by, so restarts across replicas don't align
+const healthCheckJitter = 0.2
+
+// healthCheckTimeout bounds a single provider validation
+const healthCheckTimeout = 15 * time.Second
+
+// providerCheckSpacing is the minimum gap between two health checks against
+// the same provider, keeping a round well inside each provider's rate limit.
+// Docker Hub's auth endpoint is the most tightly limited.
+var providerCheckSpacing = map[string]time.Duration{
+	"github":     time.Second,
+	"docker":     3 * time.Second,
+	"harbor":     500 * time.Millisecond,
+	"openai":     time.Second,
+	"anthropic":  time.Second,
+	"google":     time.Second,
+	"openrouter": time.Second,
+}
+
+// defaultCheckSpacing applies to providers missing from providerCheckSpacing
+const defaultCheckSpacing = time.Second
+
+// RunIntegrationHealthChecks re-validates connected integrations every
+// IntegrationHealthInterval (± jitter) until ctx is cancelled.
+func RunIntegrationHealthChecks(ctx context.Context) {
+	if IntegrationHealthInterval <= 0 {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitter(IntegrationHealthInterval)):
+		}
+
+		checked, failed, err := CheckIntegrationHealth(ctx)
+		if err != nil {
+			log.Printf("[Health] integration health check failed: %v", err)
+		} else if checked > 0 {
+			log.Printf("[Health] checked %d integration(s), %d failing", checked, failed)
+		}
+	}
+}
+
+// jitter returns d shifted randomly by up to ±healthCheckJitter
+func jitter(d time.Duration) time.Duration {
+	spread := float64(d) * healthCheckJitter
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// CheckIntegrationHealth validates every stored integration that is connected
+// or in error, updating its status and test history. Kubernetes is
+// auto-detected rather than stored, and reauth_required integrations can't
+// recover without the user, so neither is checked. Returns how many were
+// checked and how many failed.
+func CheckIntegrationHealth(ctx context.Context) (checked, failed int, err error) {
+	var integrations []models.Integration
+	if err := database.DB.WithContext(ctx).
+		Where("status IN ? AND integration_id <> ?", []string{"connected", "error"}, "kubernetes").
+		Order("integration_id, id").
+		Find(&integrations).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to load integrations: %w", err)
+	}
+
+	lastCall := map[string]time.Time{}
+	for i := range integrations {
+		integration := &integrations[i]
+
+		// Respect the provider's rate limit
+		spacing, ok := providerCheckSpacing[integration.IntegrationID]
+		if !ok {
+			spacing = defaultCheckSpacing
+		}
+		if wait := time.Until(lastCall[integration.IntegrationID].Add(spacing)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return checked, failed, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		lastCall[integration.IntegrationID] = time.Now()
+
+		checked++
+		if !checkIntegration(ctx, integration) {
+			failed++
+		}
+	}
+	return checked, failed, nil
+}
+
+// checkIntegration validates a single integration and persists the outcome.
+// Returns whether it is healthy.
+func checkIntegration(ctx context.Context, integration *models.Integration) bool {
+	decryptedJSON, err := crypto.Decrypt(integration.Credentials)
+	if err != nil {
+		log.Printf("[Health] failed to decrypt credentials for %s (user %s): %v", integration.IntegrationID, integration.UserID, err)
+		if errors.Is(err, crypto.ErrDecrypt) {
+			markReauthRequired(integration)
+		}
+		return false
+	}
+	var credentials map[string]string
+	if err := json.Unmarshal(decryptedJSON, &credentials); err != nil {
+		log.Printf("[Health] failed to read credentials for %s (user %s): %v", integration.IntegrationID, integration.UserID, err)
+		return false
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = validateProviderCredentials(checkCtx, integration.IntegrationID, credentials)
+	if err != nil && ctx.Err() != nil {
+		return false // Shutting down — not the provider's fault
+	}
+	test := newIntegrationTest(start, err)
+
+	previous := integration.Status
+	integration.RecordTest(test)
+	updates := map[string]interface{}{
+		"last_tested_at":  integration.LastTestedAt,
+		"last_latency_ms": integration.LastLatencyMs,
+		"test_history":    integration.TestHistory,
+	}
+	if err != nil {
+		updates["status"] = "error"
+	} else {
+		updates["status"] = "connected"
+	}
+
+	// Skip rows the user disconnected or reconnected while we were validating
+	res := database.DB.Model(&models.Integration{}).
+		Where("id = ? AND status = ?", integration.ID, previous).
+		Updates(updates)
+	if res.Error != nil {
+		log.Printf("[Health] failed to record check for %s (user %s): %v", integration.IntegrationID, integration.UserID, res.Error)
+		return err == nil
+	}
+	if res.RowsAffected == 0 {
+		return err == nil
+	}
+
+	switch {
+	case err != nil && previous == "connected":
+		log.Printf("[Health] %s integration for user %s is failing: %v", integration.IntegrationID, integration.UserID, err)
+		go webhook.Send(IntegrationWebhooks, os.Getenv("INTEGRATION_WEBHOOK_TOKEN"), EventIntegrationError, map[string]interface{}{
+			"user_id":        integration.UserID,
+			"integration_id": integration.IntegrationID,
+			"error":          test.Error,
+			"tested_at":      test.At,
+		})
+	case err == nil && previous == "error":
+		log.Printf("[Health] %s integration for user %s recovered", integration.IntegrationID, integration.UserID)
+	}
+	return err == nil
+}
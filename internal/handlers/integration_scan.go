@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/integration/dockerhub"
+	"github.com/siddhantprateek/reefline/internal/integration/github"
+	"github.com/siddhantprateek/reefline/internal/integration/harbor"
+	"github.com/siddhantprateek/reefline/internal/integration/registry"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// === Scan from a registry selection ===
+
+// ScanHarborArtifact enqueues an analysis of a Harbor artifact using the
+// stored Harbor credentials. :ref is a tag or a sha256 digest. With
+// "use_harbor_scan": true the worker imports Harbor's own scan report instead
+// of running grype, falling back to grype if Harbor hasn't scanned it.
+//
+// POST /api/v1/integrations/harbor/projects/:project/repos/:repo/artifacts/:ref/scan
+func (h *IntegrationHandler) ScanHarborArtifact(c *fiber.Ctx) error {
+	client, err := getHarborClient(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	artifact := &harbor.ArtifactRef{
+		Project:    c.Params("project"),
+		Repository: c.Params("repo"),
+		Reference:  c.Params("ref"),
+	}
+	return h.scanRegistryImage(c, "harbor", client.ImageRef(artifact.Project, artifact.Repository, artifact.Reference), artifact)
+}
+
+// ScanDockerHubTag enqueues an analysis of a Docker Hub tag using the stored
+// Docker Hub credentials.
+//
+// POST /api/v1/integrations/docker/repos/:namespace/:repo/tags/:tag/scan
+func (h *IntegrationHandler) ScanDockerHubTag(c *fiber.Ctx) error {
+	return h.scanRegistryImage(c, "docker", dockerhub.ImageRef(c.Params("namespace"), c.Params("repo"), c.Params("tag")), nil)
+}
+
+// ScanGitHubContainerImage enqueues an analysis of a GHCR image tag using the
+// stored GitHub PAT.
+//
+// POST /api/v1/integrations/github/images/:owner/:image/tags/:tag/scan
+func (h *IntegrationHandler) ScanGitHubContainerImage(c *fiber.Ctx) error {
+	return h.scanRegistryImage(c, "github", github.GHCRImageRef(c.Params("owner"), c.Params("image"), c.Params("tag")), nil)
+}
+
+// scanRegistryImage submits imageRef for analysis, pulling with the stored
+// credentials of integrationID. The optional body carries the same tuning
+// fields as POST /analyze; use_harbor_scan only applies when harborArtifact is set.
+//
+// Request body (optional):
+//
+//	{ "analysis_type": "vuln", "timeout_seconds": 900, "match_profile": "exact-only", "app_context": "...", "use_harbor_scan": true, "scheduled": true }
+//
+// Response:
+//
+//	{ "job_id": "...", "status": "QUEUED", "image_ref": "...", "stream_url": "/api/v1/jobs/.../stream" }
+func (h *IntegrationHandler) scanRegistryImage(c *fiber.Ctx, integrationID, imageRef string, harborArtifact *harbor.ArtifactRef) error {
+	var body struct {
+		AnalysisType   string `json:"analysis_type"`
+		TimeoutSeconds int    `json:"timeout_seconds"`
+		MatchProfile   string `json:"match_profile"`
+		AppContext     string `json:"app_context"`
+		UseHarborScan  bool   `json:"use_harbor_scan"`
+		Scheduled      bool   `json:"scheduled"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if _, err := models.ParseAnalysisType(body.AnalysisType); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := tools.ParseMatchProfile(body.MatchProfile); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if body.TimeoutSeconds < 0 || body.TimeoutSeconds > maxTimeoutSeconds {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("'timeout_seconds' must be between 0 (server default) and %d", maxTimeoutSeconds),
+		})
+	}
+
+	userID := getUserID(c)
+	cred, err := registry.Lookup(userID, integrationID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx := tools.WithTimeout(c.Context(), time.Duration(body.TimeoutSeconds)*time.Second)
+	if err := checkStorageQuota(ctx, userID); err != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Inspect with the registry credentials so private images fail fast here
+	var skopeoResult *tools.InspectResult
+	if tools.ImgInspector != nil && tools.ImgInspector.IsEnabled() {
+		res, err := tools.ImgInspector.InspectImage(ctx, imageRef, &cred.Auth)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":     "Failed to inspect image: " + err.Error(),
+				"image_ref": imageRef,
+			})
+		}
+		skopeoResult = res
+	}
+
+	req := AnalysisRequest{
+		ImageRef:            imageRef,
+		AppContext:          body.AppContext,
+		AnalysisType:        body.AnalysisType,
+		TimeoutSeconds:      body.TimeoutSeconds,
+		MatchProfile:        body.MatchProfile,
+		Scheduled:           body.Scheduled,
+		RegistryIntegration: integrationID,
+	}
+	if body.UseHarborScan && harborArtifact != nil {
+		req.HarborScan = harborArtifact
+	}
+	jobID, err := submitAnalysis(ctx, h.Queue, userID, req, skopeoResult)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	resp := fiber.Map{
+		"job_id":     jobID,
+		"status":     "QUEUED",
+		"image_ref":  imageRef,
+		"stream_url": "/api/v1/jobs/" + jobID + "/stream",
+	}
+	if skopeoResult != nil {
+		resp["image_info"] = imageInfo(skopeoResult)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
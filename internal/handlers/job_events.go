@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// JobEventsResponse is the lifecycle history of a job, oldest first
+type JobEventsResponse struct {
+	JobID  string            `json:"job_id"`
+	Events []models.JobEvent `json:"events"`
+}
+
+// Events returns the lifecycle transitions of a job: queued, started,
+// retried, tool_started/tool_completed per tool, then completed or failed.
+//
+// GET /api/v1/jobs/:id/events
+// Response:
+//
+//	{
+//	  "job_id": "job_abc123",
+//	  "events": [
+//	    { "job_id": "job_abc123", "event_type": "queued", "timestamp": "2025-01-01T12:00:00Z", "detail": "full" },
+//	    { "job_id": "job_abc123", "event_type": "tool_completed", "timestamp": "2025-01-01T12:07:41Z", "detail": "grype succeeded in 7m12.4s" }
+//	  ]
+//	}
+func (h *JobsHandler) Events(c *fiber.Ctx) error {
+	ctx := c.Context()
+	jobID := c.Params("id")
+
+	if err := database.DB.WithContext(ctx).Select("job_id").Where("job_id = ? AND user_id = ?", jobID, getUserID(c)).First(&models.Job{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	events := []models.JobEvent{}
+	if err := database.DB.WithContext(ctx).Where("job_id = ?", jobID).Order("timestamp, id").Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch job events: " + err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(JobEventsResponse{JobID: jobID, Events: events})
+}
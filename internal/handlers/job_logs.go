@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+)
+
+// LogStreamPollInterval is how often StreamLogs checks for new log lines.
+// The worker writes logs.jsonl every couple of seconds, so polling faster
+// doesn't help.
+var LogStreamPollInterval = 2 * time.Second
+
+// logStreamKeepalive is the idle time after which StreamLogs sends a comment
+// to keep proxies from closing the connection
+const logStreamKeepalive = 15 * time.Second
+
+// DownloadLogs returns the worker log of a job, one JSON object per line.
+// It's written while the job runs, so a running job returns the lines so far.
+//
+// GET /api/v1/jobs/:id/logs
+//
+// Response (200, application/x-ndjson):
+//
+//	{"time":"2025-01-01T12:00:00Z","level":"info","message":"Running Grype scan for nginx:1.25..."}
+//	{"time":"2025-01-01T12:00:41Z","level":"error","message":"Grype scan failed: ..."}
+func (h *ReportHandler) DownloadLogs(c *fiber.Ctx) error {
+	return h.streamArtifact(c, "logs.jsonl", "application/x-ndjson")
+}
+
+// StreamLogs tails the worker log of a job as Server-Sent Events until the
+// job finishes.
+//
+// GET /api/v1/jobs/:id/logs/stream
+//
+// SSE Events:
+//   - event: log      — one log line, data is the JSON object
+//   - event: complete — job finished, data is {"status": "COMPLETED"}
+//   - (keepalive comments every 15s to prevent proxy timeouts)
+func (h *ReportHandler) StreamLogs(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	var job models.Job
+	if err := database.DB.WithContext(c.Context()).Select("user_id").Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+	loc := storage.ArtifactLocation(job.UserID, jobID)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is recycled once the handler returns
+		ctx := context.Background()
+		ticker := time.NewTicker(LogStreamPollInterval)
+		defer ticker.Stop()
+
+		var current models.Job
+		sent := 0
+		lastWrite := time.Now()
+		finishing := false
+		for {
+			lines, err := readLogLines(ctx, loc)
+			if err != nil {
+				data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			}
+			for _, line := range lines[min(sent, len(lines)):] {
+				fmt.Fprintf(w, "event: log\ndata: %s\n\n", line)
+				lastWrite = time.Now()
+			}
+			sent = max(sent, len(lines))
+
+			// The worker writes the last lines just after the final status,
+			// so read once more before completing
+			if finishing {
+				data, _ := json.Marshal(fiber.Map{"status": current.Status})
+				fmt.Fprintf(w, "event: complete\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			}
+			if err := database.DB.WithContext(ctx).Select("status").Where("job_id = ?", jobID).First(&current).Error; err != nil || jobFinished(current.Status) {
+				finishing = true
+			}
+
+			if time.Since(lastWrite) >= logStreamKeepalive {
+				fmt.Fprint(w, ": keepalive\n\n")
+				lastWrite = time.Now()
+			}
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+			<-ticker.C
+		}
+	})
+	return nil
+}
+
+// jobFinished reports whether a job in status will no longer log
+func jobFinished(status models.JobStatus) bool {
+	switch status {
+	case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled, models.JobStatusSkipped:
+		return true
+	}
+	return false
+}
+
+// readLogLines returns the lines of a job's logs.jsonl, none if it doesn't
+// exist yet
+func readLogLines(ctx context.Context, loc storage.Location) ([][]byte, error) {
+	objectName := loc.Key("logs.jsonl")
+	exists, err := storage.Exists(ctx, loc.Bucket, objectName)
+	if err != nil || !exists {
+		return nil, err
+	}
+	artifact, err := storage.OpenArtifact(ctx, loc.Bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer artifact.Close()
+	data, err := io.ReadAll(artifact)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")), nil
+}
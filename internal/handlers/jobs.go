@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/flows"
 	"github.com/siddhantprateek/reefline/internal/queue"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
@@ -24,17 +31,23 @@ func NewJobsHandler(q queue.Queue) *JobsHandler {
 
 // JobListResponse represents a single job in the list response
 type JobListResponse struct {
-	ID           string  `json:"id"`
-	JobID        string  `json:"job_id"`
-	ImageRef     string  `json:"image_ref,omitempty"`
-	Dockerfile   string  `json:"dockerfile,omitempty"`
-	Status       string  `json:"status"`
-	Scenario     string  `json:"scenario,omitempty"`
-	ErrorMessage string  `json:"error_message,omitempty"`
-	Progress     int     `json:"progress"`
-	CreatedAt    string  `json:"created_at"`
-	UpdatedAt    string  `json:"updated_at"`
-	CompletedAt  *string `json:"completed_at,omitempty"`
+	ID              string   `json:"id"`
+	JobID           string   `json:"job_id"`
+	ImageRef        string   `json:"image_ref,omitempty"`
+	Dockerfile      string   `json:"dockerfile,omitempty"`
+	Status          string   `json:"status"`
+	Scenario        string   `json:"scenario,omitempty"`
+	ErrorMessage    string   `json:"error_message,omitempty"`
+	FailureCategory string   `json:"failure_category,omitempty"` // Cause of error_message, e.g. "registry_auth"
+	Degraded        bool     `json:"degraded,omitempty"`
+	FailedTools     []string `json:"failed_tools,omitempty"`
+	Progress        int      `json:"progress"`
+	SecurityScore   *int     `json:"security_score,omitempty"`
+	CriticalCount   *int     `json:"critical_count,omitempty"`
+	HighCount       *int     `json:"high_count,omitempty"`
+	CreatedAt       string   `json:"created_at"`
+	UpdatedAt       string   `json:"updated_at"`
+	CompletedAt     *string  `json:"completed_at,omitempty"`
 }
 
 // List returns all jobs for the authenticated user.
@@ -44,6 +57,8 @@ type JobListResponse struct {
 //   - page (int, default 1)
 //   - limit (int, default 20)
 //   - status (string, optional filter: QUEUED | RUNNING | COMPLETED | FAILED)
+//   - sort (string, default created_at; see jobSortColumns)
+//   - order (asc | desc, default desc; jobs without the sorted value come last)
 //
 // Response:
 //
@@ -61,6 +76,12 @@ func (h *JobsHandler) List(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "100"))
 	statusFilter := c.Query("status", "")
+	orderBy, err := jobOrder(c.Query("sort"), c.Query("order"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	if page < 1 {
 		page = 1
@@ -88,7 +109,7 @@ func (h *JobsHandler) List(c *fiber.Ctx) error {
 
 	// Get jobs
 	var jobs []models.Job
-	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+	if err := query.Order(orderBy).Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch jobs: " + err.Error(),
 		})
@@ -98,16 +119,22 @@ func (h *JobsHandler) List(c *fiber.Ctx) error {
 	response := make([]JobListResponse, len(jobs))
 	for i, job := range jobs {
 		response[i] = JobListResponse{
-			ID:           job.ID,
-			JobID:        job.JobID,
-			ImageRef:     job.ImageRef,
-			Dockerfile:   job.Dockerfile,
-			Status:       string(job.Status),
-			Scenario:     job.Scenario,
-			ErrorMessage: job.ErrorMessage,
-			Progress:     job.Progress,
-			CreatedAt:    job.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:    job.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:              job.ID,
+			JobID:           job.JobID,
+			ImageRef:        job.ImageRef,
+			Dockerfile:      job.Dockerfile,
+			Status:          string(job.Status),
+			Scenario:        job.Scenario,
+			ErrorMessage:    job.ErrorMessage,
+			FailureCategory: job.FailureCategory,
+			Degraded:        job.Degraded,
+			FailedTools:     job.FailedToolList(),
+			Progress:        job.Progress,
+			SecurityScore:   job.SecurityScore,
+			CriticalCount:   job.CriticalCount,
+			HighCount:       job.HighCount,
+			CreatedAt:       job.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt:       job.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		}
 		if job.CompletedAt != nil {
 			completedAt := job.CompletedAt.Format("2006-01-02T15:04:05Z07:00")
@@ -118,11 +145,51 @@ func (h *JobsHandler) List(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
+// jobSortColumns are the columns the jobs list can be sorted by. The column
+// name goes into the ORDER BY as is, so only these are accepted.
+var jobSortColumns = []string{
+	"created_at", "completed_at", "security_score", "critical_count", "high_count",
+	"dockle_fatal_count", "image_size_bytes", "efficiency",
+}
+
+// jobOrder returns the ORDER BY of List for the sort and order params. Jobs
+// without a value for the column (e.g. no scan results) come last either way,
+// and ties go to the newest job.
+func jobOrder(sort, order string) (string, error) {
+	if sort == "" {
+		sort = "created_at"
+	}
+	if !slices.Contains(jobSortColumns, sort) {
+		return "", fmt.Errorf("invalid sort. Must be one of: %s", strings.Join(jobSortColumns, ", "))
+	}
+	switch order {
+	case "", "desc":
+		order = "DESC"
+	case "asc":
+		order = "ASC"
+	default:
+		return "", errors.New("invalid order. Must be asc or desc")
+	}
+	if sort == "created_at" {
+		return "created_at " + order, nil
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, created_at DESC", sort, order), nil
+}
+
 // JobReportResponse represents the job status response
 type JobReportResponse struct {
-	JobID         string `json:"job_id"`
-	Status        string `json:"status"`
-	InputScenario string `json:"input_scenario"`
+	JobID         string   `json:"job_id"`
+	Status        string   `json:"status"`
+	InputScenario string   `json:"input_scenario"`
+	Progress      int      `json:"progress"`              // 0-100
+	ReportStatus  string   `json:"report_status"`         // "ready", "pending" or "unavailable"
+	ReportNote    string   `json:"report_note,omitempty"` // Why the report is unavailable, e.g. no AI provider connected
+	Degraded      bool     `json:"degraded"`              // Completed with some tools failed
+	FailedTools   []string `json:"failed_tools,omitempty"`
+	BaselineJobID string   `json:"baseline_job_id,omitempty"` // Previous scan of the same image, compared in baseline.json
+
+	// Report is the structured form of report.md (report.json), once ready
+	Report *flows.StructuredReport `json:"report,omitempty"`
 }
 
 // Get returns the status and report for a specific job.
@@ -134,7 +201,15 @@ type JobReportResponse struct {
 //	  "job_id": "job_abc123",
 //	  "status": "COMPLETED",
 //	  "input_scenario": "both",
-//	  "report": { "measured": { ... }, "proposed": { ... }, "tool_data": { ... } }
+//	  "progress": 100,
+//	  "report_status": "ready",  // "pending" while generating, "unavailable" if the job ended without one
+//	  "report_note": "...",      // with "unavailable": why, e.g. no AI provider connected
+//	  "degraded": true,          // some tools failed; the report covers the rest
+//	  "failed_tools": ["dive"],
+//	  "baseline_job_id": "job_xyz789", // previous scan of the same image, if any
+//	  "report": { "score_card": {...}, "vulnerabilities": {...}, "summary": "...",
+//	              "top_findings": [{"title": "...", "evidence": "...", "risk": "...", "action": "..."}],
+//	              "recommendations": ["..."], "sections": [{"title": "...", "content": "..."}] }
 //	}
 func (h *JobsHandler) Get(c *fiber.Ctx) error {
 	ctx := c.Context()
@@ -158,17 +233,54 @@ func (h *JobsHandler) Get(c *fiber.Ctx) error {
 		JobID:         job.JobID,
 		Status:        string(job.Status),
 		InputScenario: job.Scenario,
+		Progress:      job.Progress,
+		ReportStatus:  "ready",
+		Degraded:      job.Degraded,
+		FailedTools:   job.FailedToolList(),
+		BaselineJobID: job.BaselineJobID,
+	}
+
+	// Distinguish a report that is still generating from a storage failure
+	loc := storage.ArtifactLocation(job.UserID, job.JobID)
+	exists, err := storage.Exists(ctx, loc.Bucket, loc.Key("report.md"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check report: " + err.Error(),
+		})
+	}
+	if exists {
+		if data, err := storage.ReadArtifact(ctx, loc.Bucket, loc.Key("report.json")); err == nil {
+			var report flows.StructuredReport
+			if err := json.Unmarshal(data, &report); err == nil {
+				response.Report = &report
+			}
+		}
+	} else {
+		switch job.Status {
+		case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled, models.JobStatusSkipped:
+			response.ReportStatus = "unavailable"
+			response.ReportNote = job.ReportNote
+		default:
+			response.ReportStatus = "pending"
+		}
 	}
 
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
-// Delete removes a job and all its associated artifacts.
+// JobPurgeGracePeriod is how long a soft-deleted job keeps its artifacts
+// before PurgeDeletedJobs removes it for good. Overridable via JOB_PURGE_AFTER.
+var JobPurgeGracePeriod = 7 * 24 * time.Hour
+
+// Delete soft-deletes a job: it disappears from listings but its artifacts are
+// kept for JobPurgeGracePeriod so it can be restored. With ?purge=true the job
+// and all its artifacts are removed immediately (this also works on jobs that
+// are already soft-deleted).
 //
-// DELETE /api/v1/jobs/:id
+// DELETE /api/v1/jobs/:id[?purge=true]
 // Response:
 //
-//	{ "message": "job deleted" }
+//	{ "message": "Job deleted successfully", "purge_after": "..." }
 func (h *JobsHandler) Delete(c *fiber.Ctx) error {
 	ctx := c.Context()
 	jobID := c.Params("id")
@@ -178,30 +290,32 @@ func (h *JobsHandler) Delete(c *fiber.Ctx) error {
 
 	// TODO: Get authenticated user from context
 	userID := "admin"
+	purge := c.QueryBool("purge", false)
 
 	// Fetch job from database to verify ownership
+	query := database.DB.WithContext(ctx)
+	if purge {
+		query = query.Unscoped()
+	}
 	var job models.Job
-	if err := database.DB.WithContext(ctx).Where("job_id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+	if err := query.Where("job_id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Job not found",
 		})
 	}
 
-	// Delete all artifacts under this job's prefix in MinIO
-	bucket := getEnv("MINIO_DEFAULT_BUCKET", "reefline")
-	prefix := fmt.Sprintf("%s/", jobID)
-
-	objects, err := storage.ListFiles(ctx, bucket, prefix)
-	if err != nil {
-		// Log but don't fail the delete — the DB record should still be removed
-		fmt.Printf("[Delete] warning: failed to list artifacts for job %s: %v\n", jobID, err)
-	} else {
-		for _, obj := range objects {
-			_ = storage.DeleteFile(ctx, bucket, obj.Key)
+	if purge {
+		if err := purgeJob(ctx, &job); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to purge job: " + err.Error(),
+			})
 		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Job purged successfully",
+		})
 	}
 
-	// Delete job record from database (soft delete)
+	// Soft delete — artifacts stay until the grace period ends
 	if err := database.DB.WithContext(ctx).Delete(&job).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete job: " + err.Error(),
@@ -209,14 +323,93 @@ func (h *JobsHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Job deleted successfully",
+		"message":     "Job deleted successfully",
+		"purge_after": time.Now().Add(JobPurgeGracePeriod).Format(time.RFC3339),
 	})
 }
 
-// getEnv retrieves an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Restore undeletes a soft-deleted job that has not been purged yet.
+//
+// POST /api/v1/jobs/:id/restore
+// Response:
+//
+//	{ "message": "Job restored successfully", "job_id": "..." }
+func (h *JobsHandler) Restore(c *fiber.Ctx) error {
+	ctx := c.Context()
+	jobID := c.Params("id")
+	if jobID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Job ID is required"})
+	}
+
+	// TODO: Get authenticated user from context
+	userID := "admin"
+
+	var job models.Job
+	if err := database.DB.WithContext(ctx).Unscoped().
+		Where("job_id = ? AND user_id = ? AND deleted_at IS NOT NULL", jobID, userID).
+		First(&job).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted job not found",
+		})
+	}
+
+	if err := database.DB.WithContext(ctx).Unscoped().Model(&job).Update("deleted_at", nil).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore job: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Job restored successfully",
+		"job_id":  job.JobID,
+	})
+}
+
+// PurgeDeletedJobs permanently removes jobs soft-deleted more than olderThan
+// ago, along with their artifacts. Returns the number of jobs purged.
+func PurgeDeletedJobs(ctx context.Context, olderThan time.Duration) (int, error) {
+	var jobs []models.Job
+	if err := database.DB.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-olderThan)).
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range jobs {
+		if err := purgeJob(ctx, &jobs[i]); err != nil {
+			log.Printf("[Reaper] failed to purge job %s: %v", jobs[i].JobID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeJob deletes all artifacts under the job's prefix in MinIO and then
+// hard-deletes the job record.
+func purgeJob(ctx context.Context, job *models.Job) error {
+	loc := storage.ArtifactLocation(job.UserID, job.JobID)
+
+	objects, err := storage.ListFiles(ctx, loc.Bucket, loc.Prefix)
+	if err != nil {
+		// Log but don't fail the purge — the DB record should still be removed
+		log.Printf("[Delete] warning: failed to list artifacts for job %s: %v", job.JobID, err)
+	} else {
+		var freed int64
+		for _, obj := range objects {
+			if storage.DeleteFile(ctx, loc.Bucket, obj.Key) == nil {
+				freed += obj.Size
+			}
+		}
+		// Give the freed bytes back to the owner's storage quota
+		if err := models.AddUserStorage(database.DB.WithContext(ctx), job.UserID, -freed); err != nil {
+			log.Printf("[Delete] warning: failed to update storage usage for %s: %v", job.UserID, err)
+		}
+	}
+
+	if err := database.DB.WithContext(ctx).Where("job_id = ?", job.JobID).Delete(&models.JobEvent{}).Error; err != nil {
+		log.Printf("[Delete] warning: failed to delete events of job %s: %v", job.JobID, err)
 	}
-	return defaultValue
+	return database.DB.WithContext(ctx).Unscoped().Delete(job).Error
 }
@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestJobOrder(t *testing.T) {
+	tests := []struct {
+		sort, order string
+		want        string
+		wantErr     bool
+	}{
+		{want: "created_at DESC"},
+		{sort: "created_at", order: "asc", want: "created_at ASC"},
+		{sort: "critical_count", order: "desc", want: "critical_count DESC NULLS LAST, created_at DESC"},
+		{sort: "security_score", order: "asc", want: "security_score ASC NULLS LAST, created_at DESC"},
+		{sort: "critical_count; DROP TABLE jobs", wantErr: true},
+		{sort: "user_id", wantErr: true},
+		{sort: "high_count", order: "sideways", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := jobOrder(tt.sort, tt.order)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("jobOrder(%q, %q) = %q, want an error", tt.sort, tt.order, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("jobOrder(%q, %q) = %q, %v, want %q", tt.sort, tt.order, got, err, tt.want)
+		}
+	}
+}
@@ -2,6 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,14 +33,32 @@ type QueueStatsResponse struct {
 	Completed  int     `json:"completed"`
 	Failed     int     `json:"failed"`
 	Throughput float64 `json:"throughput_per_hour"`
+	Window     string  `json:"throughput_window"` // Period the throughput is averaged over
 }
 
-// GetQueueStats returns real-time queue statistics
+// throughputWindows are the periods GetQueueStats can average throughput over
+var throughputWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// GetQueueStats returns real-time queue statistics. Throughput is the jobs
+// completed over the window, per hour; a longer window smooths it for
+// low-volume deployments.
 //
-// GET /api/v1/metrics/queue
+// GET /api/v1/metrics/queue?window=1h|6h|24h
 func (h *MetricsHandler) GetQueueStats(c *fiber.Ctx) error {
 	ctx := c.Context()
 
+	window := c.Query("window", "1h")
+	period, ok := throughputWindows[window]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid window. Must be one of: 1h, 6h, 24h",
+		})
+	}
+
 	// Get queue stats from queue implementation
 	stats, err := h.Queue.Stats(ctx)
 	if err != nil {
@@ -44,12 +67,11 @@ func (h *MetricsHandler) GetQueueStats(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate throughput (jobs completed in last hour)
-	oneHourAgo := time.Now().Add(-1 * time.Hour)
-	var completedLastHour int64
+	// Calculate throughput (jobs completed over the window, per hour)
+	var completed int64
 	database.DB.Model(&models.Job{}).
-		Where("status = ? AND completed_at > ?", models.JobStatusCompleted, oneHourAgo).
-		Count(&completedLastHour)
+		Where("status = ? AND completed_at > ?", models.JobStatusCompleted, time.Now().Add(-period)).
+		Count(&completed)
 
 	response := QueueStatsResponse{
 		Active:     stats.Active,
@@ -57,7 +79,8 @@ func (h *MetricsHandler) GetQueueStats(c *fiber.Ctx) error {
 		Scheduled:  stats.Scheduled,
 		Completed:  stats.Completed,
 		Failed:     stats.Failed,
-		Throughput: float64(completedLastHour), // jobs per hour
+		Throughput: float64(completed) / period.Hours(),
+		Window:     window,
 	}
 
 	return c.Status(fiber.StatusOK).JSON(response)
@@ -65,6 +88,10 @@ func (h *MetricsHandler) GetQueueStats(c *fiber.Ctx) error {
 
 // JobMetricsResponse represents job metrics and trends
 type JobMetricsResponse struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Granularity string    `json:"granularity"` // Width of a time_series bucket
+
 	Summary struct {
 		Total           int     `json:"total"`
 		Completed       int     `json:"completed"`
@@ -72,9 +99,15 @@ type JobMetricsResponse struct {
 		Running         int     `json:"running"`
 		SuccessRate     float64 `json:"success_rate_pct"`
 		AvgQueueWaitMs  int64   `json:"avg_queue_wait_ms"`
+		P50QueueWaitMs  int64   `json:"p50_queue_wait_ms"`
+		P95QueueWaitMs  int64   `json:"p95_queue_wait_ms"`
+		P99QueueWaitMs  int64   `json:"p99_queue_wait_ms"`
 		AvgProcessingMs int64   `json:"avg_processing_ms"`
 		AvgTotalMs      int64   `json:"avg_total_ms"`
 	} `json:"summary"`
+	// QueueWait is the distribution of queued_at → started_at. A rising tail
+	// with flat processing times means the worker pool is undersized.
+	QueueWait  Histogram `json:"queue_wait"`
 	TimeSeries []struct {
 		Timestamp time.Time `json:"timestamp"`
 		Completed int       `json:"completed"`
@@ -87,45 +120,170 @@ type JobMetricsResponse struct {
 		AvgDiveMs   int64 `json:"avg_dive_ms"`
 	} `json:"duration_breakdown"`
 	StatusDistribution map[string]int `json:"status_distribution"`
+	// FailureReasons counts the failure_category of failed and degraded jobs
+	// (and of jobs whose report failed), e.g. {"registry_auth": 3}
+	FailureReasons map[string]int `json:"failure_reasons"`
+}
+
+// Histogram is a cumulative histogram in the Prometheus exposition layout:
+// each bucket counts the observations less than or equal to its bound, the
+// last one ("+Inf") all of them
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   int               `json:"count"`
+	SumMs   int64             `json:"sum_ms"`
+}
+
+// HistogramBucket is one cumulative bucket of a Histogram
+type HistogramBucket struct {
+	Le    string `json:"le"` // Upper bound in seconds, or "+Inf"
+	Count int    `json:"count"`
+}
+
+// queueWaitBucketsMs are the bounds of the queue wait histogram
+var queueWaitBucketsMs = []int64{100, 500, 1000, 5000, 15000, 60000, 300000, 900000, 3600000}
+
+// newHistogram buckets the observations (in milliseconds) by boundsMs
+func newHistogram(observationsMs []int64, boundsMs []int64) Histogram {
+	h := Histogram{Buckets: make([]HistogramBucket, 0, len(boundsMs)+1), Count: len(observationsMs)}
+	for _, v := range observationsMs {
+		h.SumMs += v
+	}
+	for _, bound := range boundsMs {
+		n := 0
+		for _, v := range observationsMs {
+			if v <= bound {
+				n++
+			}
+		}
+		h.Buckets = append(h.Buckets, HistogramBucket{Le: strconv.FormatFloat(float64(bound)/1000, 'f', -1, 64), Count: n})
+	}
+	h.Buckets = append(h.Buckets, HistogramBucket{Le: "+Inf", Count: len(observationsMs)})
+	return h
+}
+
+// percentile returns the nearest-rank p-th percentile (0-100) of sorted
+// values, 0 if there are none
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// timeRangePresets are the time_range shortcuts of GetJobMetrics
+var timeRangePresets = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// granularities are the time_series bucket widths GetJobMetrics accepts
+var granularities = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// maxTimeSeriesBuckets bounds the buckets of one time series, so a fine
+// granularity over a long range can't produce an enormous response
+const maxTimeSeriesBuckets = 1000
+
+// metricsWindow is the period and bucket width of a GetJobMetrics request
+type metricsWindow struct {
+	From, To    time.Time
+	Granularity string
+	Bucket      time.Duration
+}
+
+// parseMetricsWindow resolves GetJobMetrics' query: from/to (RFC 3339) if
+// given, else the time_range preset ending now; granularity defaults to 1h
+// for up to two days and 1d beyond.
+func parseMetricsWindow(timeRange, from, to, granularity string, now time.Time) (metricsWindow, error) {
+	var w metricsWindow
+	if from == "" && to == "" {
+		if timeRange == "" {
+			timeRange = "24h"
+		}
+		d, ok := timeRangePresets[timeRange]
+		if !ok {
+			return w, errors.New("invalid time_range. Must be one of: 24h, 7d, 30d")
+		}
+		w.From, w.To = now.Add(-d), now
+	} else {
+		if from == "" {
+			return w, errors.New("from is required with to")
+		}
+		var err error
+		if w.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return w, errors.New("invalid from: expected RFC 3339, e.g. 2025-01-01T00:00:00Z")
+		}
+		w.To = now
+		if to != "" {
+			if w.To, err = time.Parse(time.RFC3339, to); err != nil {
+				return w, errors.New("invalid to: expected RFC 3339, e.g. 2025-01-02T00:00:00Z")
+			}
+		}
+		if !w.From.Before(w.To) {
+			return w, errors.New("from must be before to")
+		}
+	}
+
+	if granularity == "" {
+		granularity = "1d"
+		if w.To.Sub(w.From) <= 48*time.Hour {
+			granularity = "1h"
+		}
+	}
+	bucket, ok := granularities[granularity]
+	if !ok {
+		return w, errors.New("invalid granularity. Must be one of: 5m, 15m, 1h, 6h, 1d")
+	}
+	if n := w.To.Sub(w.From.Truncate(bucket)) / bucket; n >= maxTimeSeriesBuckets {
+		return w, fmt.Errorf("%s buckets over this range would be %d; use a coarser granularity or a shorter range (at most %d buckets)", granularity, n+1, maxTimeSeriesBuckets)
+	}
+	w.Granularity, w.Bucket = granularity, bucket
+	return w, nil
 }
 
-// GetJobMetrics returns job metrics and trends
+// GetJobMetrics returns job metrics and trends for the jobs created in a
+// period. The time series has a bucket for every granularity step of the
+// period, zero-filled where no job finished.
 //
 // GET /api/v1/metrics/jobs?time_range=24h|7d|30d
+// GET /api/v1/metrics/jobs?from=2025-01-01T00:00:00Z&to=2025-01-02T00:00:00Z&granularity=5m|15m|1h|6h|1d
+// GET /api/v1/metrics/jobs?image_ref=nginx — only jobs of one image (any tag) or image:tag
 func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 	ctx := c.Context()
-	timeRange := c.Query("time_range", "24h")
-
-	// Parse time range
-	var startTime time.Time
-	switch timeRange {
-	case "24h":
-		startTime = time.Now().Add(-24 * time.Hour)
-	case "7d":
-		startTime = time.Now().Add(-7 * 24 * time.Hour)
-	case "30d":
-		startTime = time.Now().Add(-30 * 24 * time.Hour)
-	default:
+
+	window, err := parseMetricsWindow(c.Query("time_range"), c.Query("from"), c.Query("to"), c.Query("granularity"), time.Now())
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid time_range. Must be one of: 24h, 7d, 30d",
+			"error": err.Error(),
 		})
 	}
 
-	var response JobMetricsResponse
+	response := JobMetricsResponse{From: window.From, To: window.To, Granularity: window.Granularity}
 
 	// Get summary statistics
+	query := database.DB.WithContext(ctx).Where("created_at >= ? AND created_at < ?", window.From, window.To)
+	if ref := c.Query("image_ref"); ref != "" {
+		query = query.Scopes(imageRefScope(ref))
+	}
 	var jobs []models.Job
-	if err := database.DB.WithContext(ctx).
-		Where("created_at >= ?", startTime).
-		Find(&jobs).Error; err != nil {
+	if err := query.Find(&jobs).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch jobs: " + err.Error(),
 		})
 	}
 
 	response.Summary.Total = len(jobs)
-	var totalQueueWait, totalProcessing, totalDuration int64
-	var queueWaitCount, processingCount, totalCount int
+	var totalProcessing, totalDuration int64
+	var processingCount, totalCount int
+	var queueWaits []int64
 
 	for _, job := range jobs {
 		switch job.Status {
@@ -139,8 +297,7 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 
 		// Calculate average durations
 		if queueWait := job.GetQueueWaitDuration(); queueWait > 0 {
-			totalQueueWait += queueWait.Milliseconds()
-			queueWaitCount++
+			queueWaits = append(queueWaits, queueWait.Milliseconds())
 		}
 		if processing := job.GetProcessingDuration(); processing > 0 {
 			totalProcessing += processing.Milliseconds()
@@ -155,8 +312,13 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 	if response.Summary.Total > 0 {
 		response.Summary.SuccessRate = float64(response.Summary.Completed) / float64(response.Summary.Total) * 100
 	}
-	if queueWaitCount > 0 {
-		response.Summary.AvgQueueWaitMs = totalQueueWait / int64(queueWaitCount)
+	slices.Sort(queueWaits)
+	response.QueueWait = newHistogram(queueWaits, queueWaitBucketsMs)
+	if len(queueWaits) > 0 {
+		response.Summary.AvgQueueWaitMs = response.QueueWait.SumMs / int64(len(queueWaits))
+		response.Summary.P50QueueWaitMs = percentile(queueWaits, 50)
+		response.Summary.P95QueueWaitMs = percentile(queueWaits, 95)
+		response.Summary.P99QueueWaitMs = percentile(queueWaits, 99)
 	}
 	if processingCount > 0 {
 		response.Summary.AvgProcessingMs = totalProcessing / int64(processingCount)
@@ -165,13 +327,8 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 		response.Summary.AvgTotalMs = totalDuration / int64(totalCount)
 	}
 
-	// Get time series data (group by hour for 24h, by day for 7d/30d)
-	var bucketSize time.Duration
-	if timeRange == "24h" {
-		bucketSize = 1 * time.Hour
-	} else {
-		bucketSize = 24 * time.Hour
-	}
+	// Get time series data, one bucket per granularity step
+	bucketSize := window.Bucket
 
 	timeBuckets := make(map[time.Time]struct {
 		Completed int
@@ -193,7 +350,9 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 		timeBuckets[bucket] = entry
 	}
 
-	for timestamp, counts := range timeBuckets {
+	// Walk every bucket of the window so charts get a continuous series
+	for timestamp := window.From.Truncate(bucketSize); timestamp.Before(window.To); timestamp = timestamp.Add(bucketSize) {
+		counts := timeBuckets[timestamp]
 		response.TimeSeries = append(response.TimeSeries, struct {
 			Timestamp time.Time `json:"timestamp"`
 			Completed int       `json:"completed"`
@@ -216,20 +375,22 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 
 		var toolMetrics map[string]struct {
 			DurationMs int64 `json:"duration_ms"`
+			Skipped    bool  `json:"skipped"`
 		}
 		if err := json.Unmarshal([]byte(job.ToolMetrics), &toolMetrics); err != nil {
 			continue
 		}
 
-		if grype, ok := toolMetrics["grype"]; ok {
+		// Tools skipped by the job's analysis_type didn't run and must not drag averages down
+		if grype, ok := toolMetrics["grype"]; ok && !grype.Skipped {
 			totalGrype += grype.DurationMs
 			grypeCount++
 		}
-		if dockle, ok := toolMetrics["dockle"]; ok {
+		if dockle, ok := toolMetrics["dockle"]; ok && !dockle.Skipped {
 			totalDockle += dockle.DurationMs
 			dockleCount++
 		}
-		if dive, ok := toolMetrics["dive"]; ok {
+		if dive, ok := toolMetrics["dive"]; ok && !dive.Skipped {
 			totalDive += dive.DurationMs
 			diveCount++
 		}
@@ -246,6 +407,14 @@ func (h *MetricsHandler) GetJobMetrics(c *fiber.Ctx) error {
 	}
 	response.DurationBreakdown.AvgQueueMs = response.Summary.AvgQueueWaitMs
 
+	// Failure reasons, as classified by the worker
+	response.FailureReasons = map[string]int{}
+	for _, job := range jobs {
+		if job.FailureCategory != "" {
+			response.FailureReasons[job.FailureCategory]++
+		}
+	}
+
 	// Status distribution
 	response.StatusDistribution = map[string]int{
 		"COMPLETED": response.Summary.Completed,
@@ -267,52 +436,33 @@ type ToolPerformanceResponse struct {
 		P95Ms         int64   `json:"p95_ms"`
 		P99Ms         int64   `json:"p99_ms"`
 	} `json:"tools"`
+	ComputedAt *time.Time `json:"computed_at"` // When the stats were last recomputed; null before the first run
 }
 
-// GetToolPerformance returns per-tool performance metrics
+// GetToolPerformance returns per-tool performance metrics from the tool_stats
+// table, which RunToolStatsAggregation refreshes every ToolStatsInterval.
+// recompute=true (or an empty table) recomputes it from all jobs first. With
+// image_ref, the stats are computed from that image's jobs on the fly.
 //
-// GET /api/v1/metrics/tools
+// GET /api/v1/metrics/tools?recompute=true
+// GET /api/v1/metrics/tools?image_ref=nginx
 func (h *MetricsHandler) GetToolPerformance(c *fiber.Ctx) error {
 	ctx := c.Context()
 
-	// Get all completed jobs with tool metrics
-	var jobs []models.Job
-	if err := database.DB.WithContext(ctx).
-		Where("tool_metrics IS NOT NULL AND tool_metrics != ''").
-		Find(&jobs).Error; err != nil {
+	var stats []models.ToolStats
+	var err error
+	if ref := c.Query("image_ref"); ref != "" {
+		// The materialized stats cover every job, so one image's are computed here
+		stats, err = computeToolStats(database.DB.WithContext(ctx).Scopes(imageRefScope(ref)))
+	} else if err = database.DB.WithContext(ctx).Order("tool").Find(&stats).Error; err == nil && (c.QueryBool("recompute") || len(stats) == 0) {
+		stats, err = RecomputeToolStats(ctx)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch jobs: " + err.Error(),
+			"error": "Failed to fetch tool stats: " + err.Error(),
 		})
 	}
 
-	// Aggregate tool metrics
-	toolStats := make(map[string]struct {
-		durations []int64
-		successes int
-		total     int
-	})
-
-	for _, job := range jobs {
-		var toolMetrics map[string]struct {
-			DurationMs int64 `json:"duration_ms"`
-			Success    bool  `json:"success"`
-		}
-		if err := json.Unmarshal([]byte(job.ToolMetrics), &toolMetrics); err != nil {
-			continue
-		}
-
-		for toolName, metric := range toolMetrics {
-			stats := toolStats[toolName]
-			stats.durations = append(stats.durations, metric.DurationMs)
-			stats.total++
-			if metric.Success {
-				stats.successes++
-			}
-			toolStats[toolName] = stats
-		}
-	}
-
-	// Calculate percentiles and averages
 	response := ToolPerformanceResponse{
 		Tools: make(map[string]struct {
 			AvgDurationMs int64   `json:"avg_duration_ms"`
@@ -324,30 +474,17 @@ func (h *MetricsHandler) GetToolPerformance(c *fiber.Ctx) error {
 		}),
 	}
 
-	for toolName, stats := range toolStats {
-		if len(stats.durations) == 0 {
-			continue
-		}
-
-		// Calculate average
-		var total int64
-		for _, d := range stats.durations {
-			total += d
-		}
-		avg := total / int64(len(stats.durations))
-
-		// Calculate percentiles (simplified - would use proper percentile calculation in production)
-		// For now, using basic approximation
-		p50 := avg
-		p95 := avg * 2
-		p99 := avg * 3
-
+	for _, s := range stats {
 		successRate := float64(0)
-		if stats.total > 0 {
-			successRate = float64(stats.successes) / float64(stats.total) * 100
+		if s.TotalRuns > 0 {
+			successRate = float64(s.Successes) / float64(s.TotalRuns) * 100
+		}
+		if response.ComputedAt == nil || s.ComputedAt.Before(*response.ComputedAt) {
+			computedAt := s.ComputedAt
+			response.ComputedAt = &computedAt
 		}
 
-		response.Tools[toolName] = struct {
+		response.Tools[s.Tool] = struct {
 			AvgDurationMs int64   `json:"avg_duration_ms"`
 			SuccessRate   float64 `json:"success_rate_pct"`
 			TotalRuns     int     `json:"total_runs"`
@@ -355,12 +492,12 @@ func (h *MetricsHandler) GetToolPerformance(c *fiber.Ctx) error {
 			P95Ms         int64   `json:"p95_ms"`
 			P99Ms         int64   `json:"p99_ms"`
 		}{
-			AvgDurationMs: avg,
+			AvgDurationMs: s.AvgDurationMs,
 			SuccessRate:   successRate,
-			TotalRuns:     stats.total,
-			P50Ms:         p50,
-			P95Ms:         p95,
-			P99Ms:         p99,
+			TotalRuns:     s.TotalRuns,
+			P50Ms:         s.P50Ms,
+			P95Ms:         s.P95Ms,
+			P99Ms:         s.P99Ms,
 		}
 	}
 
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// exportColumns is the header of the CSV export, and the field order of both formats
+var exportColumns = []string{
+	"date", "total", "completed", "failed", "success_rate_pct",
+	"avg_queue_wait_ms", "avg_grype_ms", "avg_dockle_ms", "avg_dive_ms",
+}
+
+// ExportDay is one day of GET /metrics/export
+type ExportDay struct {
+	Date           string  `json:"date"` // UTC, YYYY-MM-DD
+	Total          int     `json:"total"`
+	Completed      int     `json:"completed"`
+	Failed         int     `json:"failed"`
+	SuccessRate    float64 `json:"success_rate_pct"`
+	AvgQueueWaitMs int64   `json:"avg_queue_wait_ms"`
+	AvgGrypeMs     int64   `json:"avg_grype_ms"`
+	AvgDockleMs    int64   `json:"avg_dockle_ms"`
+	AvgDiveMs      int64   `json:"avg_dive_ms"`
+}
+
+// record returns d as a CSV row in exportColumns order
+func (d ExportDay) record() []string {
+	return []string{
+		d.Date,
+		strconv.Itoa(d.Total),
+		strconv.Itoa(d.Completed),
+		strconv.Itoa(d.Failed),
+		strconv.FormatFloat(d.SuccessRate, 'f', 2, 64),
+		strconv.FormatInt(d.AvgQueueWaitMs, 10),
+		strconv.FormatInt(d.AvgGrypeMs, 10),
+		strconv.FormatInt(d.AvgDockleMs, 10),
+		strconv.FormatInt(d.AvgDiveMs, 10),
+	}
+}
+
+// dayTotals sums the jobs created on one day
+type dayTotals struct {
+	day                 time.Time
+	total               int
+	completed, failed   int
+	queueWaitMs, queued int64
+	toolMs, toolRuns    map[string]int64
+}
+
+func newDayTotals(day time.Time) *dayTotals {
+	return &dayTotals{day: day, toolMs: map[string]int64{}, toolRuns: map[string]int64{}}
+}
+
+func (d *dayTotals) add(job *models.Job) {
+	d.total++
+	switch job.Status {
+	case models.JobStatusCompleted:
+		d.completed++
+	case models.JobStatusFailed:
+		d.failed++
+	}
+	if wait := job.GetQueueWaitDuration(); wait > 0 {
+		d.queueWaitMs += wait.Milliseconds()
+		d.queued++
+	}
+	if job.ToolMetrics == "" {
+		return
+	}
+	var toolMetrics map[string]struct {
+		DurationMs int64 `json:"duration_ms"`
+		Skipped    bool  `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(job.ToolMetrics), &toolMetrics); err != nil {
+		return
+	}
+	for name, m := range toolMetrics {
+		if !m.Skipped {
+			d.toolMs[name] += m.DurationMs
+			d.toolRuns[name]++
+		}
+	}
+}
+
+func (d *dayTotals) result() ExportDay {
+	avg := func(sum, n int64) int64 {
+		if n == 0 {
+			return 0
+		}
+		return sum / n
+	}
+	out := ExportDay{
+		Date:           d.day.Format(time.DateOnly),
+		Total:          d.total,
+		Completed:      d.completed,
+		Failed:         d.failed,
+		AvgQueueWaitMs: avg(d.queueWaitMs, d.queued),
+		AvgGrypeMs:     avg(d.toolMs["grype"], d.toolRuns["grype"]),
+		AvgDockleMs:    avg(d.toolMs["dockle"], d.toolRuns["dockle"]),
+		AvgDiveMs:      avg(d.toolMs["dive"], d.toolRuns["dive"]),
+	}
+	if d.total > 0 {
+		out.SuccessRate = float64(d.completed) / float64(d.total) * 100
+	}
+	return out
+}
+
+// ExportMetrics streams one row per UTC day of the period: job counts,
+// success rate, average queue wait and tool durations of the jobs created
+// that day. Days without jobs are included with zeros. Rows are written as
+// the jobs are read, so long ranges aren't buffered.
+//
+// GET /api/v1/metrics/export?range=30d&format=csv
+// GET /api/v1/metrics/export?from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z&format=ndjson&image_ref=nginx
+//
+// Response (200, text/csv):
+//
+//	date,total,completed,failed,success_rate_pct,avg_queue_wait_ms,avg_grype_ms,avg_dockle_ms,avg_dive_ms
+//	2025-01-01,42,40,2,95.24,1200,18000,2500,9100
+func (h *MetricsHandler) ExportMetrics(c *fiber.Ctx) error {
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format. Must be one of: csv, ndjson",
+		})
+	}
+	timeRange := c.Query("range", "30d")
+	if _, ok := timeRangePresets[timeRange]; !ok && c.Query("from") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid range. Must be one of: 24h, 7d, 30d (or use from/to)",
+		})
+	}
+	window, err := parseMetricsWindow(timeRange, c.Query("from"), c.Query("to"), "1d", time.Now().UTC())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// The request context is recycled once the handler returns, so the rows
+	// are read with their own
+	query := database.DB.Model(&models.Job{}).
+		Select("created_at", "status", "queued_at", "started_at", "tool_metrics").
+		Where("created_at >= ? AND created_at < ?", window.From, window.To)
+	if ref := c.Query("image_ref"); ref != "" {
+		query = query.Scopes(imageRefScope(ref))
+	}
+	rows, err := query.Order("created_at").Rows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch jobs: " + err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("metrics-%s-%s.%s", window.From.Format(time.DateOnly), window.To.Format(time.DateOnly), format)
+	if format == "csv" {
+		c.Set("Content-Type", "text/csv")
+	} else {
+		c.Set("Content-Type", "application/x-ndjson")
+	}
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+
+		var emit func(ExportDay) error
+		if format == "csv" {
+			cw := csv.NewWriter(w)
+			if err := cw.Write(exportColumns); err != nil {
+				return
+			}
+			emit = func(d ExportDay) error {
+				if err := cw.Write(d.record()); err != nil {
+					return err
+				}
+				cw.Flush()
+				return cw.Error()
+			}
+		} else {
+			enc := json.NewEncoder(w)
+			emit = func(d ExportDay) error { return enc.Encode(d) }
+		}
+
+		day := newDayTotals(window.From.Truncate(window.Bucket))
+		// next writes the current day and any empty days before until
+		next := func(until time.Time) error {
+			for ; day.day.Before(until); day = newDayTotals(day.day.Add(window.Bucket)) {
+				if err := emit(day.result()); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		}
+
+		for rows.Next() {
+			var job models.Job
+			if err := database.DB.ScanRows(rows, &job); err != nil {
+				log.Printf("[Metrics] export: failed to read job: %v", err)
+				return
+			}
+			if created := job.CreatedAt.UTC().Truncate(window.Bucket); created.After(day.day) {
+				// A failed write means the client went away
+				if err := next(created); err != nil {
+					return
+				}
+			}
+			day.add(&job)
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[Metrics] export: failed to read jobs: %v", err)
+			return
+		}
+		next(window.To)
+	})
+	return nil
+}
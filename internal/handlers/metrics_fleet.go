@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// Bounds of the ?limit= of GetFleetRisk's top lists
+const (
+	defaultFleetLimit = 10
+	maxFleetLimit     = 100
+)
+
+// FleetRiskResponse is the exposure of a user's images, from the latest
+// completed scan of each
+type FleetRiskResponse struct {
+	Images           int                         `json:"images"`          // Unique images with a completed scan
+	Vulnerabilities  scoring.VulnerabilityCounts `json:"vulnerabilities"` // Summed over the images
+	AvgSecurityScore *float64                    `json:"avg_security_score"`
+	AvgEfficiency    *float64                    `json:"avg_efficiency_pct"` // Images with dive results only
+	TopImages        []FleetImage                `json:"top_images"`         // Most Critical, then High findings first
+	TopCVEs          []FleetCVE                  `json:"top_cves"`           // Critical/High CVEs in the most images first
+}
+
+// FleetImage is the latest completed scan of one image
+type FleetImage struct {
+	ImageRef      string     `json:"image_ref"`
+	JobID         string     `json:"job_id"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	Critical      int        `json:"critical"`
+	High          int        `json:"high"`
+	Total         int        `json:"total"`
+	SecurityScore *int       `json:"security_score,omitempty"`
+	Efficiency    *float64   `json:"efficiency_pct,omitempty"`
+}
+
+// FleetCVE is a Critical or High vulnerability and how many images have it
+type FleetCVE struct {
+	Vulnerability string   `json:"vulnerability"`
+	Severity      string   `json:"severity"`
+	Images        int      `json:"images"`
+	Packages      []string `json:"packages"`
+}
+
+// GetFleetRisk aggregates the latest completed scan of every image the user
+// scanned: vulnerability totals, the images with the most findings, the
+// Critical/High CVEs shared by the most images and the average efficiency.
+//
+// GET /api/v1/metrics/fleet?limit=10
+//
+// Response:
+//
+//	{
+//	  "images": 12,
+//	  "vulnerabilities": { "critical": 4, "high": 31, "medium": 88, "low": 40, "unknown": 2, "total": 165 },
+//	  "avg_security_score": 61.5,
+//	  "avg_efficiency_pct": 93.2,
+//	  "top_images": [{ "image_ref": "nginx:1.25", "job_id": "...", "critical": 2, "high": 9, "total": 60, "security_score": 35 }],
+//	  "top_cves": [{ "vulnerability": "CVE-2024-6119", "severity": "High", "images": 7, "packages": ["libssl3"] }]
+//	}
+func (h *MetricsHandler) GetFleetRisk(c *fiber.Ctx) error {
+	ctx := c.Context()
+	userID := getUserID(c)
+
+	limit := defaultFleetLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxFleetLimit {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid limit. Must be between 1 and 100",
+			})
+		}
+		limit = n
+	}
+
+	var jobs []models.Job
+	if err := database.DB.WithContext(ctx).
+		Select("job_id", "user_id", "image_ref", "completed_at").
+		Where("user_id = ? AND status = ? AND image_ref != ''", userID, models.JobStatusCompleted).
+		Order("completed_at DESC").
+		Find(&jobs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch jobs: " + err.Error(),
+		})
+	}
+
+	response := FleetRiskResponse{TopImages: []FleetImage{}, TopCVEs: []FleetCVE{}}
+	cves := map[string]*FleetCVE{}
+	seen := map[string]bool{}
+	var scoreSum, efficiencySum float64
+	var scored, measured int
+
+	for _, job := range jobs {
+		if seen[job.ImageRef] {
+			continue // Only the latest scan of an image counts
+		}
+		seen[job.ImageRef] = true
+		loc := storage.ArtifactLocation(job.UserID, job.JobID)
+
+		image := FleetImage{ImageRef: job.ImageRef, JobID: job.JobID, CompletedAt: job.CompletedAt}
+		if data, err := storage.ReadArtifact(ctx, loc.Bucket, loc.Key("score.json")); err == nil {
+			var card scoring.ScoreCard
+			if err := json.Unmarshal(data, &card); err == nil {
+				score := card.SecurityScore
+				image.SecurityScore = &score
+				scoreSum += float64(score)
+				scored++
+				if v := card.Vulnerabilities; v != nil {
+					image.Critical, image.High, image.Total = v.Critical, v.High, v.Total
+					response.Vulnerabilities.Critical += v.Critical
+					response.Vulnerabilities.High += v.High
+					response.Vulnerabilities.Medium += v.Medium
+					response.Vulnerabilities.Low += v.Low
+					response.Vulnerabilities.Unknown += v.Unknown
+					response.Vulnerabilities.Total += v.Total
+				}
+				if card.Efficiency != nil {
+					image.Efficiency = card.Efficiency
+					efficiencySum += *card.Efficiency
+					measured++
+				}
+			}
+		}
+		response.TopImages = append(response.TopImages, image)
+
+		// Count each CVE once per image, however many packages it affects
+		inImage := map[string]bool{}
+		for _, f := range severeFindings(ctx, loc) {
+			cve := cves[f.Vulnerability]
+			if cve == nil {
+				cve = &FleetCVE{Vulnerability: f.Vulnerability, Severity: f.Severity, Packages: []string{}}
+				cves[f.Vulnerability] = cve
+			}
+			if !inImage[f.Vulnerability] {
+				inImage[f.Vulnerability] = true
+				cve.Images++
+			}
+			if !slices.Contains(cve.Packages, f.Package) {
+				cve.Packages = append(cve.Packages, f.Package)
+			}
+		}
+	}
+
+	response.Images = len(response.TopImages)
+	if scored > 0 {
+		avg := scoreSum / float64(scored)
+		response.AvgSecurityScore = &avg
+	}
+	if measured > 0 {
+		avg := efficiencySum / float64(measured)
+		response.AvgEfficiency = &avg
+	}
+
+	sort.SliceStable(response.TopImages, func(i, j int) bool {
+		a, b := response.TopImages[i], response.TopImages[j]
+		if a.Critical != b.Critical {
+			return a.Critical > b.Critical
+		}
+		if a.High != b.High {
+			return a.High > b.High
+		}
+		return a.Total > b.Total
+	})
+	response.TopImages = response.TopImages[:min(limit, len(response.TopImages))]
+
+	for _, cve := range cves {
+		response.TopCVEs = append(response.TopCVEs, *cve)
+	}
+	sort.Slice(response.TopCVEs, func(i, j int) bool {
+		a, b := response.TopCVEs[i], response.TopCVEs[j]
+		if a.Images != b.Images {
+			return a.Images > b.Images
+		}
+		if a.Severity != b.Severity {
+			return a.Severity == "Critical"
+		}
+		return a.Vulnerability < b.Vulnerability
+	})
+	response.TopCVEs = response.TopCVEs[:min(limit, len(response.TopCVEs))]
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// severeFindings returns the Critical and High findings of a job, from
+// grype.summary.json when the worker wrote one, else from grype.json. A job
+// without grype results has none.
+func severeFindings(ctx context.Context, loc storage.Location) []tools.SummaryFinding {
+	if data, err := storage.ReadArtifact(ctx, loc.Bucket, loc.Key("grype.summary.json")); err == nil {
+		var summary tools.ScanSummary
+		if err := json.Unmarshal(data, &summary); err == nil {
+			return summary.Findings
+		}
+	}
+
+	a, err := storage.OpenArtifact(ctx, loc.Bucket, loc.Key("grype.json"))
+	if err != nil {
+		return nil
+	}
+	defer a.Close()
+	// Rows are name, installed, fixed-in, type, vulnerability, severity
+	var stored struct {
+		Table *struct {
+			Rows [][]string
+		}
+	}
+	if err := json.NewDecoder(a).Decode(&stored); err != nil || stored.Table == nil {
+		return nil
+	}
+	var findings []tools.SummaryFinding
+	for _, r := range stored.Table.Rows {
+		if len(r) < 6 || (r[5] != "Critical" && r[5] != "High") {
+			continue
+		}
+		findings = append(findings, tools.SummaryFinding{Vulnerability: r[4], Package: r[0], Version: r[1], Severity: r[5]})
+	}
+	return findings
+}
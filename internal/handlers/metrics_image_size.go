@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// Bounds of the ?limit= of GetImageSizeTrend
+const (
+	defaultImageSizeLimit = 50
+	maxImageSizeLimit     = 500
+)
+
+// ImageSizeResponse is the size history of an image
+type ImageSizeResponse struct {
+	ImageRef    string           `json:"image_ref"`
+	Points      []ImageSizePoint `json:"points"`       // Oldest first
+	ChangeBytes int64            `json:"change_bytes"` // Last point's size minus the first's
+}
+
+// ImageSizePoint is the size of an image at one completed scan
+type ImageSizePoint struct {
+	JobID       string     `json:"job_id"`
+	ImageRef    string     `json:"image_ref"`
+	CompletedAt *time.Time `json:"completed_at"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Efficiency  *float64   `json:"efficiency_pct,omitempty"`
+}
+
+// GetImageSizeTrend returns the size and efficiency of an image across the
+// user's latest completed scans of it, to catch bloat creeping in over
+// releases. image_ref may be a repository (every tag) or an exact reference.
+// Only scans with dive results are included.
+//
+// GET /api/v1/metrics/image-size?image_ref=myapp&limit=50
+//
+// Response:
+//
+//	{
+//	  "image_ref": "myapp",
+//	  "points": [
+//	    { "job_id": "...", "image_ref": "myapp:1.0", "completed_at": "...", "size_bytes": 210000000, "efficiency_pct": 97.1 },
+//	    { "job_id": "...", "image_ref": "myapp:1.9", "completed_at": "...", "size_bytes": 510000000, "efficiency_pct": 88.4 }
+//	  ],
+//	  "change_bytes": 300000000
+//	}
+func (h *MetricsHandler) GetImageSizeTrend(c *fiber.Ctx) error {
+	imageRef := c.Query("image_ref")
+	if imageRef == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "image_ref is required",
+		})
+	}
+	limit := defaultImageSizeLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxImageSizeLimit {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid limit. Must be between 1 and 500",
+			})
+		}
+		limit = n
+	}
+
+	var jobs []models.Job
+	if err := database.DB.WithContext(c.Context()).
+		Select("job_id", "image_ref", "completed_at", "image_size_bytes", "efficiency").
+		Where("user_id = ? AND status = ? AND image_size_bytes IS NOT NULL", getUserID(c), models.JobStatusCompleted).
+		Scopes(imageRefScope(imageRef)).
+		Order("completed_at DESC").
+		Limit(limit).
+		Find(&jobs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch jobs: " + err.Error(),
+		})
+	}
+	slices.Reverse(jobs)
+
+	response := ImageSizeResponse{ImageRef: imageRef, Points: make([]ImageSizePoint, 0, len(jobs))}
+	for _, job := range jobs {
+		response.Points = append(response.Points, ImageSizePoint{
+			JobID:       job.JobID,
+			ImageRef:    job.ImageRef,
+			CompletedAt: job.CompletedAt,
+			SizeBytes:   *job.ImageSizeBytes,
+			Efficiency:  job.Efficiency,
+		})
+	}
+	if n := len(response.Points); n > 1 {
+		response.ChangeBytes = response.Points[n-1].SizeBytes - response.Points[0].SizeBytes
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+func TestParseMetricsWindow(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                         string
+		timeRange, from, to, granule string
+		wantFrom, wantTo             time.Time
+		wantGranularity              string
+		wantErr                      bool
+	}{
+		{name: "default preset", wantFrom: now.Add(-24 * time.Hour), wantTo: now, wantGranularity: "1h"},
+		{name: "7d preset", timeRange: "7d", wantFrom: now.Add(-7 * 24 * time.Hour), wantTo: now, wantGranularity: "1d"},
+		{name: "preset with granularity", timeRange: "24h", granule: "5m", wantFrom: now.Add(-24 * time.Hour), wantTo: now, wantGranularity: "5m"},
+		{
+			name: "custom range", from: "2025-01-01T00:00:00Z", to: "2025-01-01T06:00:00Z", granule: "15m",
+			wantFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC), wantGranularity: "15m",
+		},
+		{name: "from defaults to now", from: "2025-01-01T00:00:00Z", wantFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: now, wantGranularity: "1d"},
+		{name: "unknown preset", timeRange: "1y", wantErr: true},
+		{name: "unknown granularity", granule: "1w", wantErr: true},
+		{name: "to without from", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "from after to", from: "2025-01-02T00:00:00Z", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "too many buckets", timeRange: "30d", granule: "5m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseMetricsWindow(tt.timeRange, tt.from, tt.to, tt.granule, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", w)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !w.From.Equal(tt.wantFrom) || !w.To.Equal(tt.wantTo) || w.Granularity != tt.wantGranularity {
+				t.Errorf("got %s..%s by %s, want %s..%s by %s", w.From, w.To, w.Granularity, tt.wantFrom, tt.wantTo, tt.wantGranularity)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := make([]int64, 100)
+	for i := range values {
+		values[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name   string
+		sorted []int64
+		p      float64
+		want   int64
+	}{
+		{name: "empty", sorted: nil, p: 50, want: 0},
+		{name: "single", sorted: []int64{42}, p: 99, want: 42},
+		{name: "p50", sorted: values, p: 50, want: 50},
+		{name: "p95", sorted: values, p: 95, want: 95},
+		{name: "p99", sorted: values, p: 99, want: 99},
+		{name: "p0 is the minimum", sorted: values, p: 0, want: 1},
+		{name: "tail outlier", sorted: []int64{10, 10, 10, 10, 10000}, p: 95, want: 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v) = %d, want %d", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHistogram(t *testing.T) {
+	h := newHistogram([]int64{50, 100, 700, 20000}, []int64{100, 1000, 5000})
+
+	want := []HistogramBucket{{"0.1", 2}, {"1", 3}, {"5", 3}, {"+Inf", 4}}
+	if len(h.Buckets) != len(want) {
+		t.Fatalf("buckets = %v, want %v", h.Buckets, want)
+	}
+	for i := range want {
+		if h.Buckets[i] != want[i] {
+			t.Errorf("bucket %d = %v, want %v", i, h.Buckets[i], want[i])
+		}
+	}
+	if h.Count != 4 || h.SumMs != 20850 {
+		t.Errorf("count, sum = %d, %d, want 4, 20850", h.Count, h.SumMs)
+	}
+}
+
+func TestAggregateToolStats(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := aggregateToolStats(map[string][]int64{
+		"grype":  {300, 100, 200, 400},
+		"dockle": {50},
+		"dive":   {},
+	}, map[string]int{"grype": 3, "dockle": 1}, now)
+
+	if len(stats) != 2 {
+		t.Fatalf("got %d tools, want 2 (no row for a tool without runs): %+v", len(stats), stats)
+	}
+	if stats[0].Tool != "dockle" || stats[1].Tool != "grype" {
+		t.Fatalf("tools = %s, %s, want dockle, grype", stats[0].Tool, stats[1].Tool)
+	}
+	grype := stats[1]
+	if grype.TotalRuns != 4 || grype.Successes != 3 || grype.AvgDurationMs != 250 {
+		t.Errorf("grype runs, successes, avg = %d, %d, %d, want 4, 3, 250", grype.TotalRuns, grype.Successes, grype.AvgDurationMs)
+	}
+	if grype.P50Ms != 200 || grype.P95Ms != 400 || grype.P99Ms != 400 {
+		t.Errorf("grype p50, p95, p99 = %d, %d, %d, want 200, 400, 400", grype.P50Ms, grype.P95Ms, grype.P99Ms)
+	}
+	if !grype.ComputedAt.Equal(now) {
+		t.Errorf("computed_at = %s, want %s", grype.ComputedAt, now)
+	}
+}
+
+func TestDayTotals(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	queued := day.Add(time.Hour)
+	started := queued.Add(2 * time.Second)
+
+	d := newDayTotals(day)
+	d.add(&models.Job{Status: models.JobStatusCompleted, QueuedAt: &queued, StartedAt: &started,
+		ToolMetrics: `{"grype":{"duration_ms":1000},"dockle":{"duration_ms":0,"skipped":true}}`})
+	d.add(&models.Job{Status: models.JobStatusCompleted, ToolMetrics: `{"grype":{"duration_ms":3000}}`})
+	d.add(&models.Job{Status: models.JobStatusFailed})
+
+	want := []string{"2025-01-01", "3", "2", "1", "66.67", "2000", "2000", "0", "0"}
+	got := d.result().record()
+	if len(got) != len(exportColumns) {
+		t.Fatalf("record has %d fields, header %d", len(got), len(exportColumns))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s = %s, want %s", exportColumns[i], got[i], want[i])
+		}
+	}
+
+	if empty := newDayTotals(day).result(); empty.Total != 0 || empty.SuccessRate != 0 {
+		t.Errorf("empty day = %+v, want zeros", empty)
+	}
+}
@@ -3,8 +3,15 @@ package handlers
 import (
 	"fmt"
 	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/flows"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
 	"github.com/siddhantprateek/reefline/pkg/storage"
 )
 
@@ -16,55 +23,201 @@ func NewReportHandler() *ReportHandler {
 	return &ReportHandler{}
 }
 
-func (h *ReportHandler) streamArtifact(c *fiber.Ctx, objectName, filename, contentType string) error {
-	bucket := storage.GetConfigFromEnv().DefaultBucket
-	object, err := storage.DownloadFile(c.Context(), bucket, objectName)
+// streamArtifact streams the named artifact of the job in the :id param.
+func (h *ReportHandler) streamArtifact(c *fiber.Ctx, filename, contentType string) error {
+	jobID := c.Params("id")
+
+	var job models.Job
+	if err := database.DB.WithContext(c.Context()).Select("user_id").Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+	loc := storage.ArtifactLocation(job.UserID, jobID)
+	bucket, objectName := loc.Bucket, loc.Key(filename)
+
+	// GetObject is lazy, so check first — otherwise a missing object only
+	// surfaces mid-stream after the headers are sent
+	exists, err := storage.Exists(c.Context(), bucket, objectName)
 	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("failed to read artifact %s: %v", objectName, err),
+		})
+	}
+	if !exists {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": fmt.Sprintf("artifact not found: %s", objectName),
+			"error":  fmt.Sprintf("artifact not found: %s", objectName),
+			"status": "pending", // Not generated yet (or the job skipped this tool)
 		})
 	}
-	defer object.Close()
+
+	artifact, err := storage.OpenArtifact(c.Context(), bucket, objectName)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("failed to read artifact %s: %v", objectName, err),
+		})
+	}
+	defer artifact.Close()
 
 	c.Set("Content-Type", contentType)
 	if c.Query("download") == "true" {
 		c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	}
-	_, err = io.Copy(c.Response().BodyWriter(), object)
+
+	// Gzipped artifacts go out as stored when the client accepts gzip
+	var body io.Reader = artifact
+	if artifact.Gzipped && strings.Contains(c.Get(fiber.HeaderAcceptEncoding), "gzip") {
+		c.Set(fiber.HeaderContentEncoding, "gzip")
+		body = artifact.Raw
+	}
+	_, err = io.Copy(c.Response().BodyWriter(), body)
 	return err
 }
 
 // DownloadGrype returns the Grype vulnerability scan result.
 // GET /api/v1/jobs/:id/grype.json
 func (h *ReportHandler) DownloadGrype(c *fiber.Ctx) error {
-	jobID := c.Params("id")
-	return h.streamArtifact(c, fmt.Sprintf("%s/artifacts/grype.json", jobID), "grype.json", "application/json")
+	return h.streamArtifact(c, "grype.json", "application/json")
 }
 
 // DownloadDive returns the Dive layer efficiency analysis result.
 // GET /api/v1/jobs/:id/dive.json
 func (h *ReportHandler) DownloadDive(c *fiber.Ctx) error {
-	jobID := c.Params("id")
-	return h.streamArtifact(c, fmt.Sprintf("%s/artifacts/dive.json", jobID), "dive.json", "application/json")
+	return h.streamArtifact(c, "dive.json", "application/json")
 }
 
 // DownloadDockle returns the Dockle CIS benchmark scan result.
 // GET /api/v1/jobs/:id/dockle.json
 func (h *ReportHandler) DownloadDockle(c *fiber.Ctx) error {
-	jobID := c.Params("id")
-	return h.streamArtifact(c, fmt.Sprintf("%s/artifacts/dockle.json", jobID), "dockle.json", "application/json")
+	return h.streamArtifact(c, "dockle.json", "application/json")
+}
+
+// DownloadLicenses returns the per-package license report (permissive/copyleft/unknown, GPL/AGPL flags).
+// GET /api/v1/jobs/:id/licenses.json
+func (h *ReportHandler) DownloadLicenses(c *fiber.Ctx) error {
+	return h.streamArtifact(c, "licenses.json", "application/json")
+}
+
+// DownloadScore returns the deterministic Score Card computed from the scan results.
+// GET /api/v1/jobs/:id/score.json
+func (h *ReportHandler) DownloadScore(c *fiber.Ctx) error {
+	return h.streamArtifact(c, "score.json", "application/json")
 }
 
 // DownloadReportMD returns the final AI-generated report as Markdown.
 // GET /api/v1/jobs/:id/report.md
 func (h *ReportHandler) DownloadReportMD(c *fiber.Ctx) error {
-	jobID := c.Params("id")
-	return h.streamArtifact(c, fmt.Sprintf("%s/artifacts/report.md", jobID), "report.md", "text/markdown; charset=utf-8")
+	return h.streamArtifact(c, "report.md", "text/markdown; charset=utf-8")
 }
 
-// DownloadDraftMD returns the supervisor's first-pass draft as Markdown.
+// DownloadDraftMD returns the supervisor's latest draft as Markdown (versions are draft.vN.md).
 // GET /api/v1/jobs/:id/draft.md
 func (h *ReportHandler) DownloadDraftMD(c *fiber.Ctx) error {
+	return h.streamArtifact(c, "draft.md", "text/markdown; charset=utf-8")
+}
+
+// PreviewCost estimates the tokens and cost of generating the job's AI report
+// with its owner's provider and model, from the size of the artifacts the
+// flow reads. The model is not called. "min" assumes critique approves the
+// first draft, "max" that every allowed revision is used.
+//
+// GET /api/v1/jobs/:id/report/preview
+// Response:
+//
+//	{ "job_id": "...", "provider": "openai", "model": "gpt-4o-mini", "artifacts": {"grype.json": 52311},
+//	  "pricing_known": true, "max_revisions": 3,
+//	  "min": {"prompt_tokens": 41000, "completion_tokens": 3100, "total_tokens": 44100, "estimated_cost_usd": 0.008},
+//	  "max": {...} }
+func (h *ReportHandler) PreviewCost(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if err := database.DB.WithContext(c.Context()).Select("job_id").Where("job_id = ?", jobID).First(&models.Job{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	preview, err := flows.PreviewCost(c.Context(), jobID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(preview)
+}
+
+// artifactInfo describes a stored job artifact
+type artifactInfo struct {
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	LastModified time.Time `json:"last_modified"`
+	URL          string    `json:"url"`
+	Tool         string    `json:"tool,omitempty"`         // From the object's metadata; absent on older artifacts
+	ToolVersion  string    `json:"tool_version,omitempty"` // e.g. "v0.108.0" for grype
+}
+
+// ListArtifacts lists the artifacts stored for a job.
+//
+// GET /api/v1/jobs/:id/artifacts
+// Response:
+//
+//	{ "job_id": "...", "artifacts": [{ "name": "grype.json", "size": 1024, "content_type": "application/json",
+//	  "last_modified": "...", "url": "/api/v1/jobs/:id/artifacts/grype.json", "tool": "grype", "tool_version": "v0.108.0" }] }
+func (h *ReportHandler) ListArtifacts(c *fiber.Ctx) error {
 	jobID := c.Params("id")
-	return h.streamArtifact(c, fmt.Sprintf("%s/artifacts/draft.md", jobID), "draft.md", "text/markdown; charset=utf-8")
+
+	var job models.Job
+	if err := database.DB.WithContext(c.Context()).Select("user_id").Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+	loc := storage.ArtifactLocation(job.UserID, jobID)
+	prefix := loc.ArtifactsPrefix()
+
+	objects, err := storage.ListFiles(c.Context(), loc.Bucket, prefix)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("failed to list artifacts: %v", err),
+		})
+	}
+
+	artifacts := make([]artifactInfo, 0, len(objects))
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		meta := storage.ObjectArtifactMeta(obj)
+		artifacts = append(artifacts, artifactInfo{
+			Name:         name,
+			Size:         obj.Size,
+			ContentType:  artifactContentType(name),
+			LastModified: obj.LastModified,
+			URL:          fmt.Sprintf("/api/v1/jobs/%s/artifacts/%s", jobID, name),
+			Tool:         meta.Tool,
+			ToolVersion:  meta.ToolVersion,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":    jobID,
+		"artifacts": artifacts,
+	})
+}
+
+// DownloadArtifact returns any artifact of a job by name, e.g. sbom.json.
+//
+// GET /api/v1/jobs/:id/artifacts/:name
+func (h *ReportHandler) DownloadArtifact(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if name == "" || name != path.Base(name) || strings.HasPrefix(name, ".") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid artifact name"})
+	}
+	return h.streamArtifact(c, name, artifactContentType(name))
+}
+
+// artifactContentType guesses an artifact's content type from its extension
+func artifactContentType(name string) string {
+	switch ext := path.Ext(name); ext {
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	default:
+		if ct := mime.TypeByExtension(ext); ct != "" {
+			return ct
+		}
+		return "application/octet-stream"
+	}
 }
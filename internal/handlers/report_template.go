@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/flows"
+	"github.com/siddhantprateek/reefline/internal/flows/agents"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReportTemplateHandler manages the user's AI report template
+type ReportTemplateHandler struct{}
+
+// NewReportTemplateHandler creates a new ReportTemplateHandler instance
+func NewReportTemplateHandler() *ReportTemplateHandler {
+	return &ReportTemplateHandler{}
+}
+
+// Get returns the template used for the user's reports and whether it is
+// their own ("user") or the server default ("default").
+//
+// GET /api/v1/report-template
+func (h *ReportTemplateHandler) Get(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	source := "user"
+	err := database.DB.Where("user_id = ?", userID).First(&models.ReportTemplate{}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		source = "default"
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch report template",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source":   source,
+		"template": flows.ReportTemplateFor(userID),
+	})
+}
+
+// Update validates and saves the user's report template.
+//
+// PUT /api/v1/report-template
+// Body: agents.ReportTemplate JSON, e.g.
+//
+//	{ "sections": [{"title": "Summary", "guidance": "..."}], "base_score": 100,
+//	  "deductions": {"critical": 10, "high": 5}, "thresholds": {"green": 80, "yellow": 50} }
+func (h *ReportTemplateHandler) Update(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	tmpl, err := agents.ParseReportTemplate(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	row := models.ReportTemplate{UserID: userID, Content: string(c.Body())}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		log.Printf("Failed to save report template: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save report template",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source":   "user",
+		"template": tmpl,
+	})
+}
+
+// Reset deletes the user's template so the default applies again.
+//
+// DELETE /api/v1/report-template
+func (h *ReportTemplateHandler) Reset(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	if err := database.DB.Where("user_id = ?", userID).Delete(&models.ReportTemplate{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset report template",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source":   "default",
+		"template": flows.ReportTemplateFor(userID),
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/queue"
+	scannertools "github.com/siddhantprateek/reefline/internal/tools"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// ScannerHandler handles vulnerability scanner maintenance endpoints
+type ScannerHandler struct {
+	Queue queue.Queue
+}
+
+// NewScannerHandler creates a new ScannerHandler instance
+func NewScannerHandler(q queue.Queue) *ScannerHandler {
+	return &ScannerHandler{Queue: q}
+}
+
+// RefreshDB forces a grype vulnerability DB update without restarting the worker.
+// If the scanner runs in this process the refresh is synchronous; otherwise a
+// refresh task is enqueued for the worker.
+//
+// POST /api/v1/scanner/db/refresh
+// Response (in-process):
+//
+//	{ "status": "refreshed", "db": { "schemaVersion": "v6.0.2", "built": "2026-01-01T00:00:00Z" } }
+//
+// Response (worker):
+//
+//	{ "status": "queued", "task_id": "..." }
+func (h *ScannerHandler) RefreshDB(c *fiber.Ctx) error {
+	if tools.ImgScanner != nil {
+		status, err := scannertools.RefreshScannerDB()
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "Failed to refresh vulnerability DB: " + err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": "refreshed",
+			"db":     status,
+		})
+	}
+
+	taskID, err := h.Queue.Enqueue(c.Context(), "refresh_vuln_db", fiber.Map{})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enqueue DB refresh: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "queued",
+		"task_id": taskID,
+	})
+}
@@ -1,7 +1,15 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
 )
 
 // SSEHandler handles Server-Sent Events streaming for real-time job progress
@@ -12,9 +20,15 @@ func NewSSEHandler() *SSEHandler {
 	return &SSEHandler{}
 }
 
+// progressEvent is the data of a progress event
+type progressEvent struct {
+	models.JobEvent
+	Progress float64 `json:"progress"` // 0.0-1.0
+}
+
 // Stream establishes an SSE connection for real-time job progress updates.
-// The client receives progress events as the analysis pipeline runs through
-// its stages: intake → AI prescan → tool execution → AI proposal → finalization.
+// Each lifecycle event the job records (see GET /api/v1/jobs/:id/events) is
+// sent as a progress event, starting with those already recorded.
 //
 // GET /api/v1/jobs/:id/stream
 // Headers set:
@@ -25,8 +39,8 @@ func NewSSEHandler() *SSEHandler {
 //
 // SSE Events:
 //   - event: connected       — initial connection acknowledged
-//   - event: progress        — stage update with percentage (0.0-1.0)
-//   - event: complete        — job finished, includes report_url and score
+//   - event: progress        — a job event with the job's progress (0.0-1.0)
+//   - event: complete        — job finished, data is {"status": "COMPLETED"}
 //   - (keepalive comments every 15s to prevent proxy timeouts)
 //
 // Client usage:
@@ -34,14 +48,68 @@ func NewSSEHandler() *SSEHandler {
 //	const es = new EventSource('/api/v1/jobs/job_abc123/stream');
 //	es.addEventListener('progress', (e) => { ... });
 func (h *SSEHandler) Stream(c *fiber.Ctx) error {
-	// TODO: Extract job ID from params
-	// TODO: Verify job belongs to authenticated user
-	// TODO: Set SSE headers (Content-Type: text/event-stream, etc.)
-	// TODO: Subscribe to Redis pub/sub channel for this job (job:progress:<id>)
-	// TODO: Stream progress events using c.Context().SetBodyStreamWriter
-	// TODO: Send keepalive comments every 15s
-	// TODO: Close stream on job completion or client disconnect
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-		"message": "SSE stream endpoint not yet implemented",
+	jobID := c.Params("id")
+
+	if err := database.DB.WithContext(c.Context()).Select("job_id").Where("job_id = ? AND user_id = ?", jobID, getUserID(c)).First(&models.Job{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is recycled once the handler returns
+		ctx := context.Background()
+		ticker := time.NewTicker(LogStreamPollInterval)
+		defer ticker.Stop()
+
+		data, _ := json.Marshal(fiber.Map{"job_id": jobID})
+		fmt.Fprintf(w, "event: connected\ndata: %s\n\n", data)
+		lastWrite := time.Now()
+
+		var lastID uint
+		for {
+			var job models.Job
+			if err := database.DB.WithContext(ctx).Select("status", "progress").Where("job_id = ?", jobID).First(&job).Error; err != nil {
+				data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			}
+			// Read the events after the status, so a finished job's last
+			// events are sent before complete
+			var events []models.JobEvent
+			if err := database.DB.WithContext(ctx).Where("job_id = ? AND id > ?", jobID, lastID).Order("id").Find(&events).Error; err != nil {
+				data, _ := json.Marshal(fiber.Map{"error": err.Error()})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			}
+			for _, e := range events {
+				data, _ := json.Marshal(progressEvent{JobEvent: e, Progress: float64(job.Progress) / 100})
+				fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+				lastID = e.ID
+				lastWrite = time.Now()
+			}
+
+			if jobFinished(job.Status) {
+				data, _ := json.Marshal(fiber.Map{"status": job.Status})
+				fmt.Fprintf(w, "event: complete\ndata: %s\n\n", data)
+				w.Flush()
+				return
+			}
+			if time.Since(lastWrite) >= logStreamKeepalive {
+				fmt.Fprint(w, ": keepalive\n\n")
+				lastWrite = time.Now()
+			}
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+			<-ticker.C
+		}
 	})
+	return nil
 }
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ToolStatsInterval is how often the tool_stats table is recomputed in the
+// background. Set from TOOL_STATS_INTERVAL; 0 disables the refresh, leaving
+// GET /metrics/tools?recompute=true as the only way to update it.
+var ToolStatsInterval = 15 * time.Minute
+
+// toolStatsBatchSize is the number of jobs loaded at a time while recomputing
+const toolStatsBatchSize = 1000
+
+// RunToolStatsAggregation recomputes the tool_stats table on startup and then
+// every ToolStatsInterval until ctx is cancelled.
+func RunToolStatsAggregation(ctx context.Context) {
+	if ToolStatsInterval <= 0 {
+		return
+	}
+	for {
+		if stats, err := RecomputeToolStats(ctx); err != nil {
+			log.Printf("[Metrics] failed to recompute tool stats: %v", err)
+		} else {
+			log.Printf("[Metrics] recomputed tool stats for %d tool(s)", len(stats))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ToolStatsInterval):
+		}
+	}
+}
+
+// RecomputeToolStats scans the tool_metrics of every job and replaces the
+// tool_stats table with the result, which it returns.
+func RecomputeToolStats(ctx context.Context) ([]models.ToolStats, error) {
+	stats, err := computeToolStats(database.DB.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ToolStats{}).Error; err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			return nil
+		}
+		return tx.Create(&stats).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// computeToolStats aggregates the tool_metrics of the jobs matched by db
+func computeToolStats(db *gorm.DB) ([]models.ToolStats, error) {
+	durations := map[string][]int64{}
+	successes := map[string]int{}
+
+	var batch []models.Job
+	err := db.
+		Select("id", "tool_metrics").
+		Where("tool_metrics IS NOT NULL AND tool_metrics != ''").
+		FindInBatches(&batch, toolStatsBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, job := range batch {
+				var toolMetrics map[string]struct {
+					DurationMs int64 `json:"duration_ms"`
+					Success    bool  `json:"success"`
+					Skipped    bool  `json:"skipped"`
+				}
+				if err := json.Unmarshal([]byte(job.ToolMetrics), &toolMetrics); err != nil {
+					continue
+				}
+				for toolName, metric := range toolMetrics {
+					// Tools skipped by the job's analysis_type didn't run
+					if metric.Skipped {
+						continue
+					}
+					durations[toolName] = append(durations[toolName], metric.DurationMs)
+					if metric.Success {
+						successes[toolName]++
+					}
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return aggregateToolStats(durations, successes, time.Now()), nil
+}
+
+// aggregateToolStats builds the tool_stats rows from each tool's run
+// durations and success count, sorted by tool name
+func aggregateToolStats(durations map[string][]int64, successes map[string]int, now time.Time) []models.ToolStats {
+	stats := make([]models.ToolStats, 0, len(durations))
+	for toolName, d := range durations {
+		if len(d) == 0 {
+			continue
+		}
+		slices.Sort(d)
+		var total int64
+		for _, v := range d {
+			total += v
+		}
+		stats = append(stats, models.ToolStats{
+			Tool:          toolName,
+			TotalRuns:     len(d),
+			Successes:     successes[toolName],
+			AvgDurationMs: total / int64(len(d)),
+			P50Ms:         percentile(d, 50),
+			P95Ms:         percentile(d, 95),
+			P99Ms:         percentile(d, 99),
+			ComputedAt:    now,
+		})
+	}
+	slices.SortFunc(stats, func(a, b models.ToolStats) int {
+		return strings.Compare(a.Tool, b.Tool)
+	})
+	return stats
+}
+
+// imageRefScope limits a job query to ref: that exact reference, or every
+// tag and digest of it when ref names a repository only ("nginx")
+func imageRefScope(ref string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// A colon after the last slash is a tag; before it, a registry port
+		if strings.Contains(ref, "@") || strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/") {
+			return db.Where("image_ref = ?", ref)
+		}
+		escaped := likeEscaper.Replace(ref)
+		return db.Where("image_ref = ? OR image_ref LIKE ? OR image_ref LIKE ?", ref, escaped+":%", escaped+"@%")
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards, "_" being common in image names
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"gorm.io/gorm"
+)
+
+// StorageQuotaBytes is the artifact storage limit per user; 0 disables the
+// quota. Overridable via STORAGE_QUOTA_BYTES.
+var StorageQuotaBytes int64
+
+// errStorageQuotaExceeded is returned when a user is at or over their quota
+var errStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// UsageHandler reports per-user resource usage
+type UsageHandler struct{}
+
+// NewUsageHandler creates a new UsageHandler instance
+func NewUsageHandler() *UsageHandler {
+	return &UsageHandler{}
+}
+
+// Storage returns the artifact bytes stored for the user and their quota.
+//
+// GET /api/v1/usage/storage
+// Response:
+//
+//	{ "used_bytes": 1048576, "limit_bytes": 10737418240, "remaining_bytes": 10736369664, "exceeded": false }
+func (h *UsageHandler) Storage(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	used, err := storageUsed(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch storage usage: " + err.Error(),
+		})
+	}
+
+	resp := fiber.Map{
+		"used_bytes":  used,
+		"limit_bytes": StorageQuotaBytes, // 0 means unlimited
+		"exceeded":    StorageQuotaBytes > 0 && used >= StorageQuotaBytes,
+	}
+	if StorageQuotaBytes > 0 {
+		resp["remaining_bytes"] = max(StorageQuotaBytes-used, 0)
+	}
+	return c.JSON(resp)
+}
+
+// storageUsed returns the artifact bytes currently stored for userID
+func storageUsed(ctx context.Context, userID string) (int64, error) {
+	var usage models.UserStorage
+	err := database.DB.WithContext(ctx).Where("user_id = ?", userID).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return usage.BytesUsed, err
+}
+
+// checkStorageQuota returns errStorageQuotaExceeded if userID may not store
+// any more artifacts. Lookup failures are not treated as over quota.
+func checkStorageQuota(ctx context.Context, userID string) error {
+	if StorageQuotaBytes <= 0 {
+		return nil
+	}
+	used, err := storageUsed(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	if used >= StorageQuotaBytes {
+		return fmt.Errorf("%w: %d of %d bytes used — delete old jobs to free space", errStorageQuotaExceeded, used, StorageQuotaBytes)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	scannertools "github.com/siddhantprateek/reefline/internal/tools"
+	"github.com/siddhantprateek/reefline/pkg/version"
+)
+
+// VersionHandler reports the running build
+type VersionHandler struct{}
+
+// NewVersionHandler creates a new VersionHandler instance
+func NewVersionHandler() *VersionHandler {
+	return &VersionHandler{}
+}
+
+// VersionResponse is the build identification returned by GET /api/v1/version
+type VersionResponse struct {
+	version.Info
+	VulnerabilityDB *scannertools.DBStatus `json:"vulnerability_db,omitempty"` // Nil until the worker has loaded a DB
+}
+
+// Get returns the build version, commit, build date and Go version, plus the
+// build date of the vulnerability DB the worker scans with.
+//
+// GET /api/v1/version
+// Response:
+//
+//	{ "version": "v1.2.0", "commit": "abc1234", "build_date": "2026-01-01T00:00:00Z", "go_version": "go1.25.2",
+//	  "vulnerability_db": { "schemaVersion": "v6.0.2", "built": "2026-01-01T00:00:00Z" } }
+func (h *VersionHandler) Get(c *fiber.Ctx) error {
+	resp := VersionResponse{Info: version.Get()}
+
+	db, err := scannertools.GetDBStatus(c.Context())
+	if err != nil {
+		log.Printf("Failed to read vulnerability DB status: %v", err)
+	}
+	resp.VulnerabilityDB = db
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/siddhantprateek/reefline/internal/queue"
+	"github.com/siddhantprateek/reefline/internal/webhook"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+	"gorm.io/gorm"
+)
+
+// WatchPollInterval is how often the digests of watched images are resolved.
+// Set from WATCH_POLL_INTERVAL; 0 disables the poller.
+var WatchPollInterval = 15 * time.Minute
+
+// WatchWebhooks are the URLs notified on EventImageChanged, set from
+// WATCH_WEBHOOK_URLS (comma-separated).
+var WatchWebhooks []string
+
+// EventImageChanged is the event name sent when a watched image's digest
+// changed and a scan was started
+const EventImageChanged = "watch.image_changed"
+
+// WatchHandler manages the user's image watch-list
+type WatchHandler struct {
+	Queue queue.Queue
+}
+
+// NewWatchHandler creates a new WatchHandler instance
+func NewWatchHandler(q queue.Queue) *WatchHandler {
+	return &WatchHandler{Queue: q}
+}
+
+// List returns the user's watched images.
+//
+// GET /api/v1/watches
+func (h *WatchHandler) List(c *fiber.Ctx) error {
+	watches := []models.Watch{}
+	if err := database.DB.WithContext(c.Context()).Where("user_id = ?", getUserID(c)).Order("created_at").Find(&watches).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch watches: " + err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(watches)
+}
+
+// Create watches an image tag. Its current digest is resolved now, so the
+// first scan runs on the first change after this, not right away.
+//
+// POST /api/v1/watches
+// Body:
+//
+//	{ "image_ref": "ghcr.io/acme/api:latest", "analysis_type": "full" }
+//
+// Response (201): the models.Watch
+func (h *WatchHandler) Create(c *fiber.Ctx) error {
+	userID := getUserID(c)
+
+	var req struct {
+		ImageRef     string `json:"image_ref"`
+		AnalysisType string `json:"analysis_type"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.ImageRef == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "'image_ref' is required"})
+	}
+	analysisType, err := models.ParseAnalysisType(req.AnalysisType)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if tools.ImgInspector == nil || !tools.ImgInspector.IsEnabled() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Watches need the image inspector to resolve digests (set IMAGE_INSPECTOR_ENABLED=true)",
+		})
+	}
+
+	err = database.DB.WithContext(c.Context()).Where("user_id = ? AND image_ref = ?", userID, req.ImageRef).First(&models.Watch{}).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Image is already watched"})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to look up watches"})
+	}
+
+	res, err := inspectWatched(c.Context(), userID, req.ImageRef)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to inspect image: " + err.Error(),
+		})
+	}
+
+	now := time.Now()
+	watch := models.Watch{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		ImageRef:      req.ImageRef,
+		AnalysisType:  analysisType,
+		Digest:        res.Digest,
+		LastCheckedAt: &now,
+	}
+	if err := database.DB.WithContext(c.Context()).Create(&watch).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save watch: " + err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(watch)
+}
+
+// Delete stops watching an image. Scans it already started are kept.
+//
+// DELETE /api/v1/watches/:id
+func (h *WatchHandler) Delete(c *fiber.Ctx) error {
+	result := database.DB.WithContext(c.Context()).Where("id = ? AND user_id = ?", c.Params("id"), getUserID(c)).Delete(&models.Watch{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete watch: " + result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Watch not found"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Watch deleted successfully"})
+}
+
+// RunWatchPoller checks every watched image every WatchPollInterval (±
+// jitter) until ctx is cancelled, enqueuing scans through q.
+func RunWatchPoller(ctx context.Context, q queue.Queue) {
+	if WatchPollInterval <= 0 {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitter(WatchPollInterval)):
+		}
+
+		checked, changed, err := CheckWatches(ctx, q)
+		if err != nil {
+			log.Printf("[Watch] failed to check watched images: %v", err)
+		} else if checked > 0 {
+			log.Printf("[Watch] checked %d watched image(s), %d changed", checked, changed)
+		}
+	}
+}
+
+// CheckWatches resolves the digest of every watched image and starts a scan
+// of each whose digest changed. Returns the watches checked and changed.
+func CheckWatches(ctx context.Context, q queue.Queue) (checked, changed int, err error) {
+	var watches []models.Watch
+	if err := database.DB.WithContext(ctx).Order("last_checked_at").Find(&watches).Error; err != nil {
+		return 0, 0, err
+	}
+	for i := range watches {
+		if ctx.Err() != nil {
+			break
+		}
+		w := &watches[i]
+		ok, err := checkWatch(ctx, q, w)
+		if err != nil {
+			log.Printf("[Watch] %s (user %s): %v", w.ImageRef, w.UserID, err)
+		}
+		checked++
+		if ok {
+			changed++
+		}
+	}
+	return checked, changed, nil
+}
+
+// checkWatch resolves w's digest and, if it changed, enqueues a scan and
+// notifies WatchWebhooks. The outcome is saved on the watch; a failed scan
+// submission leaves the old digest, so the next round tries again.
+func checkWatch(ctx context.Context, q queue.Queue, w *models.Watch) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{"last_checked_at": now, "last_error": ""}
+	defer func() {
+		if err := database.DB.WithContext(context.WithoutCancel(ctx)).Model(w).Updates(updates).Error; err != nil {
+			log.Printf("[Watch] failed to update watch %s: %v", w.ID, err)
+		}
+	}()
+
+	res, err := inspectWatched(ctx, w.UserID, w.ImageRef)
+	if err != nil {
+		updates["last_error"] = err.Error()
+		return false, err
+	}
+	if res.Digest == w.Digest {
+		return false, nil
+	}
+
+	if err := checkStorageQuota(ctx, w.UserID); err != nil {
+		updates["last_error"] = err.Error()
+		return false, err
+	}
+	req := AnalysisRequest{
+		ImageRef:     w.ImageRef,
+		AnalysisType: string(w.AnalysisType),
+		// Compared with the previous automated run, which also reports new
+		// Critical/High CVEs to SCAN_DIFF_WEBHOOK_URLS
+		Scheduled: true,
+	}
+	if cred := registryCredentialFor(w.UserID, w.ImageRef); cred != nil {
+		req.RegistryIntegration = cred.IntegrationID
+	}
+	jobID, err := submitAnalysis(ctx, q, w.UserID, req, res)
+	if err != nil {
+		updates["last_error"] = err.Error()
+		return false, fmt.Errorf("starting scan: %w", err)
+	}
+
+	log.Printf("[Watch] %s changed from %s to %s, started job %s", w.ImageRef, w.Digest, res.Digest, jobID)
+	webhook.Send(WatchWebhooks, os.Getenv("WATCH_WEBHOOK_TOKEN"), EventImageChanged, map[string]interface{}{
+		"watch_id":        w.ID,
+		"user_id":         w.UserID,
+		"image_ref":       w.ImageRef,
+		"previous_digest": w.Digest,
+		"digest":          res.Digest,
+		"job_id":          jobID,
+	})
+	updates["digest"] = res.Digest
+	updates["last_changed_at"] = now
+	updates["last_job_id"] = jobID
+	return true, nil
+}
+
+// inspectWatched inspects imageRef in its registry with the user's
+// credentials. Local daemons are skipped: a stale or vanished local copy
+// would hide upstream changes or report ones that never happened.
+func inspectWatched(ctx context.Context, userID, imageRef string) (*tools.InspectResult, error) {
+	if tools.ImgInspector == nil || !tools.ImgInspector.IsEnabled() {
+		return nil, errors.New("image inspector is disabled")
+	}
+	var auth *tools.ImageAuth
+	if cred := registryCredentialFor(userID, imageRef); cred != nil {
+		auth = &cred.Auth
+	}
+	return tools.ImgInspector.InspectImage(tools.WithSources(ctx, tools.SourceRemote), imageRef, auth)
+}
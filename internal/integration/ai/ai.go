@@ -3,10 +3,20 @@ package ai
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
 )
 
 // Provider identifies which AI provider is being used
@@ -27,6 +37,38 @@ var providerBaseURLs = map[Provider]string{
 	ProviderOpenRouter: "https://openrouter.ai/api/v1",
 }
 
+// googleOpenAIPath is Google AI's OpenAI-compatible API, relative to its base URL
+const googleOpenAIPath = "/openai"
+
+// GoogleNativeChat sends Google AI chat completions to the native
+// :generateContent API instead of the OpenAI-compatible endpoint, for keys
+// that can't use the latter. Enabled with GOOGLE_AI_NATIVE_CHAT=true.
+var GoogleNativeChat = os.Getenv("GOOGLE_AI_NATIVE_CHAT") == "true"
+
+// Default OpenRouter app attribution, overridable via OPENROUTER_APP_URL and
+// OPENROUTER_APP_NAME. Some free-tier models reject requests without them.
+const (
+	defaultOpenRouterAppURL  = "https://github.com/siddhantprateek/reefline"
+	defaultOpenRouterAppName = "Reefline"
+)
+
+// OpenRouterHeaders returns the HTTP-Referer and X-Title attribution headers
+// OpenRouter uses to identify the calling app.
+func OpenRouterHeaders() map[string]string {
+	appURL := os.Getenv("OPENROUTER_APP_URL")
+	if appURL == "" {
+		appURL = defaultOpenRouterAppURL
+	}
+	appName := os.Getenv("OPENROUTER_APP_NAME")
+	if appName == "" {
+		appName = defaultOpenRouterAppName
+	}
+	return map[string]string{
+		"HTTP-Referer": appURL,
+		"X-Title":      appName,
+	}
+}
+
 // Config holds the configuration for an AI provider integration
 type Config struct {
 	Provider Provider `json:"provider"`
@@ -73,9 +115,19 @@ func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		req.Header.Set("x-api-key", t.apiKey)
 		req.Header.Set("anthropic-version", "2023-06-01")
 	case ProviderGoogleAI:
+		// The OpenAI-compatible endpoint takes a bearer token, the native API a key parameter
+		if strings.Contains(req.URL.Path, googleOpenAIPath+"/") {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.apiKey))
+			break
+		}
 		q := req.URL.Query()
 		q.Set("key", t.apiKey)
 		req.URL.RawQuery = q.Encode()
+	case ProviderOpenRouter:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.apiKey))
+		for k, v := range OpenRouterHeaders() {
+			req.Header.Set(k, v)
+		}
 	default:
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.apiKey))
 	}
@@ -103,6 +155,46 @@ type ChatCompletionRequest struct {
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Temperature float64       `json:"temperature,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	// Models is OpenRouter's fallback list: if Model is unavailable the next
+	// entry is tried. Ignored (not sent) for other providers.
+	Models []string `json:"models,omitempty"`
+	// Extra holds provider-specific parameters, e.g. {"seed": 42, "stop":
+	// ["\n\n"]}, merged into the request. Only the keys in extraParams for
+	// the provider are accepted; others fail the request.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// extraParams lists the ChatCompletionRequest.Extra keys each provider
+// accepts. Google's are those of its OpenAI-compatible endpoint.
+var extraParams = map[Provider][]string{
+	ProviderOpenAI: {"frequency_penalty", "presence_penalty", "stop", "seed", "top_p",
+		"logit_bias", "response_format", "user"},
+	ProviderOpenRouter: {"frequency_penalty", "presence_penalty", "stop", "seed", "top_p",
+		"top_k", "repetition_penalty", "min_p", "logit_bias", "response_format", "provider"},
+	ProviderAnthropic: {"top_k", "top_p", "stop_sequences", "metadata"},
+	ProviderGoogleAI:  {"frequency_penalty", "presence_penalty", "stop", "seed", "top_p"},
+}
+
+// googleNativeParams maps the Extra keys accepted with GoogleNativeChat to
+// their generationConfig names
+var googleNativeParams = map[string]string{
+	"frequency_penalty": "frequencyPenalty",
+	"presence_penalty":  "presencePenalty",
+	"stop":              "stopSequences",
+	"seed":              "seed",
+	"top_p":             "topP",
+	"top_k":             "topK",
+}
+
+// mergeExtra copies extra into payload, rejecting keys the provider doesn't accept
+func mergeExtra(payload map[string]interface{}, provider Provider, extra map[string]interface{}) error {
+	for k, v := range extra {
+		if !slices.Contains(extraParams[provider], k) {
+			return fmt.Errorf("unsupported parameter %q for %s", k, provider)
+		}
+		payload[k] = v
+	}
+	return nil
 }
 
 // ChatCompletionResponse represents the response from a chat completions API
@@ -138,7 +230,7 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return data, resp.StatusCode, nil
+	return data, resp.StatusCode, integration.CheckStatus(resp.StatusCode, data)
 }
 
 // ValidateCredentials checks if the API key is valid by making a lightweight API call.
@@ -148,14 +240,14 @@ func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
 		// GET /models — lightweight check that the key is valid
 		url := c.baseURL + "/models"
 		_, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
+		if errors.Is(err, integration.ErrUnauthorized) {
+			return "", fmt.Errorf("invalid API key: %w", err)
+		}
 		if err != nil {
 			return "", fmt.Errorf("failed to validate: %w", err)
 		}
-		if status == http.StatusUnauthorized {
-			return "", fmt.Errorf("invalid API key: 401 Unauthorized")
-		}
 		if status != http.StatusOK {
-			return "", fmt.Errorf("unexpected status %d", status)
+			return "", integration.NewStatusError(status, nil)
 		}
 		return string(c.config.Provider), nil
 
@@ -171,30 +263,34 @@ func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
 		payloadJSON, _ := json.Marshal(payload)
 		url := c.baseURL + "/messages"
 		_, status, err := c.doRequest(ctx, http.MethodPost, url, bytes.NewReader(payloadJSON))
+		if errors.Is(err, integration.ErrUnauthorized) {
+			return "", fmt.Errorf("invalid API key: %w", err)
+		}
 		if err != nil {
 			return "", fmt.Errorf("failed to validate: %w", err)
 		}
-		if status == http.StatusUnauthorized {
-			return "", fmt.Errorf("invalid API key: 401 Unauthorized")
-		}
 		// Both 200 (success) and 400 (bad model) mean the key is valid
 		if status == http.StatusOK || status == http.StatusBadRequest {
 			return string(c.config.Provider), nil
 		}
-		return "", fmt.Errorf("unexpected status %d", status)
+		return "", integration.NewStatusError(status, nil)
 
 	case ProviderGoogleAI:
 		// GET /models — with API key in query param (handled by transport)
 		url := c.baseURL + "/models"
 		_, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
+		if errors.Is(err, integration.ErrUnauthorized) {
+			return "", fmt.Errorf("invalid API key: %w", err)
+		}
 		if err != nil {
 			return "", fmt.Errorf("failed to validate: %w", err)
 		}
-		if status == http.StatusBadRequest || status == http.StatusForbidden {
-			return "", fmt.Errorf("invalid API key: %d", status)
+		// Google reports a malformed key as 400 rather than 401/403
+		if status == http.StatusBadRequest {
+			return "", fmt.Errorf("invalid API key: %w", &integration.StatusError{StatusCode: status, Err: integration.ErrUnauthorized})
 		}
 		if status != http.StatusOK {
-			return "", fmt.Errorf("unexpected status %d", status)
+			return "", integration.NewStatusError(status, nil)
 		}
 		return string(c.config.Provider), nil
 
@@ -221,7 +317,7 @@ func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
 			return nil, err
 		}
 		if status != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+			return nil, integration.NewStatusError(status, data)
 		}
 
 		var response struct {
@@ -248,22 +344,96 @@ func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
 	}
 }
 
+// ErrUnknownModel is returned by ValidateModel for a model the provider doesn't offer
+var ErrUnknownModel = errors.New("unknown model")
+
+// modelListTTL is how long ValidateModel reuses a provider's model list
+const modelListTTL = 10 * time.Minute
+
+// modelLists caches model IDs per provider and API key (keys can differ in
+// the models they may use)
+var modelLists = struct {
+	sync.Mutex
+	entries map[string]modelList
+}{entries: make(map[string]modelList)}
+
+type modelList struct {
+	ids       map[string]bool
+	fetchedAt time.Time
+}
+
+// ValidateModel checks modelID against the provider's /models list, cached
+// for modelListTTL, and returns ErrUnknownModel if it isn't offered. Anthropic
+// has no model listing here and accepts any ID. Other errors mean the list
+// couldn't be fetched.
+func (c *Client) ValidateModel(ctx context.Context, modelID string) error {
+	if c.config.Provider == ProviderAnthropic {
+		return nil
+	}
+	modelID = strings.TrimPrefix(modelID, "models/") // Google lists "models/<id>"
+
+	sum := sha256.Sum256([]byte(c.config.APIKey))
+	key := string(c.config.Provider) + ":" + hex.EncodeToString(sum[:])
+	modelLists.Lock()
+	list, ok := modelLists.entries[key]
+	modelLists.Unlock()
+
+	if !ok || time.Since(list.fetchedAt) > modelListTTL {
+		models, err := c.ListModels(ctx)
+		if err != nil {
+			return fmt.Errorf("listing models: %w", err)
+		}
+		list = modelList{ids: make(map[string]bool, len(models)), fetchedAt: time.Now()}
+		for _, m := range models {
+			list.ids[strings.TrimPrefix(m.ID, "models/")] = true
+		}
+		modelLists.Lock()
+		modelLists.entries[key] = list
+		modelLists.Unlock()
+	}
+
+	if !list.ids[modelID] {
+		return fmt.Errorf("%w %q: %s doesn't list it", ErrUnknownModel, modelID, c.config.Provider)
+	}
+	return nil
+}
+
 // ChatCompletion sends a chat completion request (non-streaming).
 func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
 	switch c.config.Provider {
 	case ProviderAnthropic:
 		return c.anthropicChatCompletion(ctx, req)
 	case ProviderGoogleAI:
-		return c.googleChatCompletion(ctx, req)
+		req.Model = strings.TrimPrefix(req.Model, "models/") // ListModels returns native names
+		if GoogleNativeChat {
+			return c.googleChatCompletion(ctx, req)
+		}
+		return c.openAIChatCompletion(ctx, req)
 	default:
 		return c.openAIChatCompletion(ctx, req)
 	}
 }
 
-// openAIChatCompletion handles OpenAI/OpenRouter format
+// openAIChatCompletion handles OpenAI/OpenRouter format, and Google AI's
+// OpenAI-compatible endpoint
 func (c *Client) openAIChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	// OpenAI rejects unknown parameters
+	if c.config.Provider != ProviderOpenRouter {
+		req.Models = nil
+	}
 	payloadJSON, _ := json.Marshal(req)
+	if len(req.Extra) > 0 {
+		var payload map[string]interface{}
+		_ = json.Unmarshal(payloadJSON, &payload)
+		if err := mergeExtra(payload, c.config.Provider, req.Extra); err != nil {
+			return nil, err
+		}
+		payloadJSON, _ = json.Marshal(payload)
+	}
 	url := c.baseURL + "/chat/completions"
+	if c.config.Provider == ProviderGoogleAI {
+		url = c.baseURL + googleOpenAIPath + "/chat/completions"
+	}
 
 	data, status, err := c.doRequest(ctx, http.MethodPost, url, bytes.NewReader(payloadJSON))
 	if err != nil {
@@ -327,6 +497,9 @@ func (c *Client) anthropicChatCompletion(ctx context.Context, req ChatCompletion
 	if req.Temperature > 0 {
 		payload["temperature"] = req.Temperature
 	}
+	if err := mergeExtra(payload, ProviderAnthropic, req.Extra); err != nil {
+		return nil, err
+	}
 
 	payloadJSON, _ := json.Marshal(payload)
 	url := c.baseURL + "/messages"
@@ -371,13 +544,19 @@ func (c *Client) anthropicChatCompletion(ctx context.Context, req ChatCompletion
 	}, nil
 }
 
-// googleChatCompletion handles Google AI's generateContent format
+// googleChatCompletion handles Google AI's native generateContent format
+// (see GoogleNativeChat)
 func (c *Client) googleChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	// Convert messages to Google's format
+	// Convert messages to Google's format — the system prompt goes separately
+	var systemPrompt string
 	var parts []map[string]interface{}
 	for _, m := range req.Messages {
 		role := m.Role
-		if role == "assistant" {
+		switch role {
+		case "system":
+			systemPrompt = m.Content
+			continue
+		case "assistant":
 			role = "model"
 		}
 		parts = append(parts, map[string]interface{}{
@@ -389,6 +568,28 @@ func (c *Client) googleChatCompletion(ctx context.Context, req ChatCompletionReq
 	payload := map[string]interface{}{
 		"contents": parts,
 	}
+	if systemPrompt != "" {
+		payload["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]string{{"text": systemPrompt}},
+		}
+	}
+	generationConfig := map[string]interface{}{}
+	if req.MaxTokens > 0 {
+		generationConfig["maxOutputTokens"] = req.MaxTokens
+	}
+	if req.Temperature > 0 {
+		generationConfig["temperature"] = req.Temperature
+	}
+	for k, v := range req.Extra {
+		name, ok := googleNativeParams[k]
+		if !ok {
+			return nil, fmt.Errorf("unsupported parameter %q for %s", k, ProviderGoogleAI)
+		}
+		generationConfig[name] = v
+	}
+	if len(generationConfig) > 0 {
+		payload["generationConfig"] = generationConfig
+	}
 	payloadJSON, _ := json.Marshal(payload)
 
 	url := fmt.Sprintf("%s/models/%s:generateContent", c.baseURL, req.Model)
@@ -408,6 +609,11 @@ func (c *Client) googleChatCompletion(ctx context.Context, req ChatCompletionReq
 				} `json:"parts"`
 			} `json:"content"`
 		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
 	}
 	if err := json.Unmarshal(data, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
@@ -421,6 +627,11 @@ func (c *Client) googleChatCompletion(ctx context.Context, req ChatCompletionReq
 	return &ChatCompletionResponse{
 		Model:   req.Model,
 		Content: content,
+		Usage: Usage{
+			PromptTokens:     response.UsageMetadata.PromptTokenCount,
+			CompletionTokens: response.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      response.UsageMetadata.TotalTokenCount,
+		},
 	}, nil
 }
 
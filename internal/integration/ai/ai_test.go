@@ -3,9 +3,12 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
 )
 
 func TestValidateCredentials(t *testing.T) {
@@ -222,3 +225,213 @@ func TestChatCompletion_Anthropic(t *testing.T) {
 		t.Errorf("expected 'Hello world', got '%s'", resp.Content)
 	}
 }
+
+func TestErrorClassification(t *testing.T) {
+	originalBaseURLs := make(map[Provider]string)
+	for k, v := range providerBaseURLs {
+		originalBaseURLs[k] = v
+	}
+	defer func() {
+		providerBaseURLs = originalBaseURLs
+	}()
+
+	tests := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusUnauthorized, integration.ErrUnauthorized},
+		{http.StatusNotFound, integration.ErrNotFound},
+		{http.StatusTooManyRequests, integration.ErrRateLimited},
+		{http.StatusBadGateway, integration.ErrServerError},
+	}
+
+	for _, tc := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		providerBaseURLs[ProviderOpenAI] = server.URL
+
+		client := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test"})
+		_, err := client.ListModels(context.Background())
+		server.Close()
+
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("status %d: expected %v, got %v", tc.status, tc.expected, err)
+		}
+	}
+}
+
+func TestChatCompletion_OpenRouterAttribution(t *testing.T) {
+	originalBaseURLs := make(map[Provider]string)
+	for k, v := range providerBaseURLs {
+		originalBaseURLs[k] = v
+	}
+	defer func() {
+		providerBaseURLs = originalBaseURLs
+	}()
+
+	t.Setenv("OPENROUTER_APP_NAME", "Reefline Test")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("HTTP-Referer") != defaultOpenRouterAppURL {
+			t.Errorf("expected HTTP-Referer %s, got %s", defaultOpenRouterAppURL, r.Header.Get("HTTP-Referer"))
+		}
+		if r.Header.Get("X-Title") != "Reefline Test" {
+			t.Errorf("expected X-Title 'Reefline Test', got %s", r.Header.Get("X-Title"))
+		}
+
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if models, ok := payload["models"].([]interface{}); !ok || len(models) != 2 {
+			t.Errorf("expected 2 fallback models, got %v", payload["models"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "gen_1", "model": "openai/gpt-4o-mini", "choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	providerBaseURLs[ProviderOpenRouter] = server.URL
+
+	client := NewClient(Config{Provider: ProviderOpenRouter, APIKey: "test"})
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "openai/gpt-4o",
+		Models:   []string{"openai/gpt-4o-mini", "google/gemini-2.0-flash-001"},
+		Messages: []ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if resp.Model != "openai/gpt-4o-mini" {
+		t.Errorf("expected fallback model openai/gpt-4o-mini, got %s", resp.Model)
+	}
+}
+
+func TestGoogleChatCompletionUsesOpenAIEndpoint(t *testing.T) {
+	originalBaseURLs := make(map[Provider]string)
+	for k, v := range providerBaseURLs {
+		originalBaseURLs[k] = v
+	}
+	defer func() {
+		providerBaseURLs = originalBaseURLs
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/chat/completions" {
+			t.Errorf("expected path /openai/chat/completions, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-google-key" || r.URL.Query().Get("key") != "" {
+			t.Errorf("expected bearer auth only, got header %q and key %q", r.Header.Get("Authorization"), r.URL.Query().Get("key"))
+		}
+
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["model"] != "gemini-2.0-flash" {
+			t.Errorf("expected model gemini-2.0-flash, got %v", payload["model"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "g1", "model": "gemini-2.0-flash", "choices": [{"message": {"content": "ok"}}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`))
+	}))
+	defer server.Close()
+
+	providerBaseURLs[ProviderGoogleAI] = server.URL
+
+	client := NewClient(Config{Provider: ProviderGoogleAI, APIKey: "test-google-key"})
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "models/gemini-2.0-flash",
+		Messages: []ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if resp.Content != "ok" || resp.Usage.TotalTokens != 15 {
+		t.Errorf("expected content ok with 15 tokens, got %q with %d", resp.Content, resp.Usage.TotalTokens)
+	}
+}
+
+func TestValidateModel(t *testing.T) {
+	originalBaseURLs := make(map[Provider]string)
+	for k, v := range providerBaseURLs {
+		originalBaseURLs[k] = v
+	}
+	defer func() {
+		providerBaseURLs = originalBaseURLs
+	}()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}, {"id": "gpt-4o-mini"}]}`))
+	}))
+	defer server.Close()
+
+	providerBaseURLs[ProviderOpenAI] = server.URL
+
+	client := NewClient(Config{Provider: ProviderOpenAI, APIKey: "validate-model-key"})
+	if err := client.ValidateModel(context.Background(), "gpt-4o-mini"); err != nil {
+		t.Errorf("listed model rejected: %v", err)
+	}
+	if err := client.ValidateModel(context.Background(), "gpt-4o-mnii"); !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("expected ErrUnknownModel for a typo, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the model list to be fetched once, got %d calls", calls)
+	}
+}
+
+func TestChatCompletionExtraParams(t *testing.T) {
+	originalBaseURLs := make(map[Provider]string)
+	for k, v := range providerBaseURLs {
+		originalBaseURLs[k] = v
+	}
+	defer func() {
+		providerBaseURLs = originalBaseURLs
+	}()
+
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id": "1", "model": "m", "choices": [{"message": {"content": "ok"}}], "content": [{"text": "ok"}]}`))
+	}))
+	defer server.Close()
+	providerBaseURLs[ProviderOpenAI] = server.URL
+	providerBaseURLs[ProviderAnthropic] = server.URL
+
+	openai := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test"})
+	_, err := openai.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []ChatMessage{{Role: "user", Content: "hi"}},
+		Extra:    map[string]interface{}{"seed": 42, "stop": []string{"END"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if payload["seed"] != float64(42) || payload["model"] != "gpt-4o" {
+		t.Errorf("expected seed 42 merged into the request, got %v", payload)
+	}
+
+	if _, err := openai.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Model: "gpt-4o",
+		Extra: map[string]interface{}{"top_k": 5},
+	}); err == nil {
+		t.Error("expected top_k to be rejected for openai")
+	}
+
+	anthropic := NewClient(Config{Provider: ProviderAnthropic, APIKey: "test"})
+	if _, err := anthropic.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 10,
+		Messages:  []ChatMessage{{Role: "user", Content: "hi"}},
+		Extra:     map[string]interface{}{"top_k": 5},
+	}); err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if payload["top_k"] != float64(5) {
+		t.Errorf("expected top_k 5 for anthropic, got %v", payload["top_k"])
+	}
+}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache holds serialized provider responses for a short time, so repeated
+// browsing of repositories, projects and tags doesn't hit the upstream API
+// (and its rate limit) on every call. Failures are logged and read as misses.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// memoryCacheSweepSize is the entry count above which Set drops expired entries
+const memoryCacheSweepSize = 1024
+
+// memoryCache is a process-local Cache, used when Redis isn't configured
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryCache returns an in-process Cache
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+func (m *memoryCache) Get(_ context.Context, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (m *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if len(m.entries) >= memoryCacheSweepSize {
+		for k, e := range m.entries {
+			if now.After(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	m.entries[key] = memoryCacheEntry{value: value, expiresAt: now.Add(ttl)}
+}
+
+// redisCache is a Cache shared by all API server replicas
+type redisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache returns a Cache backed by the Redis server at addr
+func NewRedisCache(addr, password string) Cache {
+	return &redisCache{client: redis.NewClient(&redis.Options{Addr: addr, Password: password})}
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Warning: cache read of %s failed: %v", key, err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if err := r.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		log.Printf("Warning: cache write of %s failed: %v", key, err)
+	}
+}
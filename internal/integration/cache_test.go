@@ -0,0 +1,25 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+
+	if _, ok := c.Get(ctx, "repos"); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	c.Set(ctx, "repos", []byte(`[{"name":"api"}]`), time.Minute)
+	if v, ok := c.Get(ctx, "repos"); !ok || string(v) != `[{"name":"api"}]` {
+		t.Errorf("Get = %q, %v; want the stored value", v, ok)
+	}
+
+	c.Set(ctx, "tags", []byte(`[]`), -time.Second)
+	if _, ok := c.Get(ctx, "tags"); ok {
+		t.Error("expired entry returned")
+	}
+}
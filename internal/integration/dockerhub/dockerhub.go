@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"sync"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
 )
 
 const (
@@ -102,7 +104,7 @@ func (c *Client) login(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("login failed (status %d): %s", resp.StatusCode, string(body))
+		return fmt.Errorf("login failed: %w", integration.NewStatusError(resp.StatusCode, body))
 	}
 
 	var response struct {
@@ -152,7 +154,7 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return data, resp.StatusCode, nil
+	return data, resp.StatusCode, integration.CheckStatus(resp.StatusCode, data)
 }
 
 // ValidateCredentials checks if the PAT and username are valid by attempting to login.
@@ -172,7 +174,7 @@ func (c *Client) ListRepositories(ctx context.Context, page, pageSize int) ([]Do
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var response struct {
@@ -192,7 +194,7 @@ func (c *Client) GetRepository(ctx context.Context, namespace, repo string) (*Do
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var repository DockerRepository
@@ -210,7 +212,7 @@ func (c *Client) ListTags(ctx context.Context, namespace, repo string, page, pag
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var response struct {
@@ -230,7 +232,7 @@ func (c *Client) GetTag(ctx context.Context, namespace, repo, tag string) (*Imag
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var imageTag ImageTag
@@ -257,7 +259,7 @@ func (c *Client) SearchImages(ctx context.Context, query string, page, pageSize
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	// Docker Hub search structure is slightly different, but let's assume standard pagination for now
@@ -0,0 +1,83 @@
+// Package integration holds types shared by the external service clients
+// (github, dockerhub, harbor, ai).
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for common upstream failures. Clients wrap them in a
+// *StatusError so callers can use errors.Is instead of matching strings.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServerError  = errors.New("upstream server error")
+)
+
+// StatusError is returned when a provider responds with a non-success status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	Err        error // One of the sentinels above, or nil for unclassified statuses
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// NewStatusError builds a *StatusError for status, classifying it into one of
+// the sentinel errors where possible.
+func NewStatusError(status int, body []byte) *StatusError {
+	e := &StatusError{StatusCode: status, Body: string(body)}
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		e.Err = ErrUnauthorized
+	case status == http.StatusNotFound:
+		e.Err = ErrNotFound
+	case status == http.StatusTooManyRequests:
+		e.Err = ErrRateLimited
+	case status >= http.StatusInternalServerError:
+		e.Err = ErrServerError
+	}
+	return e
+}
+
+// CheckStatus returns a *StatusError when status falls into one of the
+// classified failure classes (auth, not found, rate limit, 5xx) and nil
+// otherwise. Any other status is left for the caller to interpret.
+func CheckStatus(status int, body []byte) error {
+	if e := NewStatusError(status, body); e.Err != nil {
+		return e
+	}
+	return nil
+}
+
+// HTTPStatus maps an integration error to the status code an API handler
+// should return, falling back to fallback for unclassified errors.
+func HTTPStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrServerError):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return fallback
+	}
+}
@@ -1,11 +1,19 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
 )
 
 const (
@@ -15,6 +23,9 @@ const (
 	GHCRBaseURL = "ghcr.io"
 )
 
+// apiBaseURL is the GitHub API the client talks to; overridden in tests
+var apiBaseURL = GitHubAPIBaseURL
+
 // Config holds the configuration for a GitHub integration
 type Config struct {
 	// PersonalAccessToken (PAT) for authenticating with GitHub API and GHCR
@@ -70,6 +81,7 @@ type ContainerImage struct {
 	ID          int64    `json:"id"`
 	Name        string   `json:"name"`
 	PackageType string   `json:"package_type"`
+	Visibility  string   `json:"visibility"`
 	HTMLURL     string   `json:"html_url"`
 	Tags        []string `json:"tags"`
 }
@@ -92,12 +104,16 @@ type Issue struct {
 	HTMLURL string `json:"html_url"`
 }
 
-// doRequest is a helper that executes an HTTP request and returns the response body.
+// doRequest is a helper that executes an HTTP request and returns the response body
+// and status code. A non-nil body is sent as JSON.
 func (c *Client) doRequest(ctx context.Context, method, url string, body io.Reader) ([]byte, int, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -110,22 +126,21 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return data, resp.StatusCode, nil
+	return data, resp.StatusCode, integration.CheckStatus(resp.StatusCode, data)
 }
 
 // ValidateCredentials checks if the PAT is valid by calling the /user endpoint.
 // Returns the authenticated username or an error.
 func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
-	data, status, err := c.doRequest(ctx, http.MethodGet, GitHubAPIBaseURL+"/user", nil)
+	data, status, err := c.doRequest(ctx, http.MethodGet, apiBaseURL+"/user", nil)
+	if errors.Is(err, integration.ErrUnauthorized) {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to validate: %w", err)
 	}
-
-	if status == http.StatusUnauthorized {
-		return "", fmt.Errorf("invalid token: 401 Unauthorized")
-	}
 	if status != http.StatusOK {
-		return "", fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return "", integration.NewStatusError(status, data)
 	}
 
 	var user struct {
@@ -141,13 +156,13 @@ func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
 // ListRepositories returns repositories accessible to the authenticated user.
 // Supports pagination via page and perPage parameters.
 func (c *Client) ListRepositories(ctx context.Context, page, perPage int) ([]Repository, error) {
-	url := fmt.Sprintf("%s/user/repos?page=%d&per_page=%d&sort=updated", GitHubAPIBaseURL, page, perPage)
+	url := fmt.Sprintf("%s/user/repos?page=%d&per_page=%d&sort=updated", apiBaseURL, page, perPage)
 	data, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var repos []Repository
@@ -159,13 +174,13 @@ func (c *Client) ListRepositories(ctx context.Context, page, perPage int) ([]Rep
 
 // GetRepository returns details of a specific repository.
 func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*Repository, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s", GitHubAPIBaseURL, owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s", apiBaseURL, owner, repo)
 	data, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var repository Repository
@@ -177,20 +192,20 @@ func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*Reposi
 
 // GetFileContent retrieves a file's content from a repository.
 func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) (*FileContent, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", GitHubAPIBaseURL, owner, repo, path)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", apiBaseURL, owner, repo, path)
 	if ref != "" {
 		url += "?ref=" + ref
 	}
 
 	data, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
+	if errors.Is(err, integration.ErrNotFound) {
+		return nil, fmt.Errorf("file not found: %s: %w", path, err)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if status == http.StatusNotFound {
-		return nil, fmt.Errorf("file not found: %s", path)
-	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var fc FileContent
@@ -208,42 +223,126 @@ func (c *Client) GetDockerfile(ctx context.Context, owner, repo, path, ref strin
 		paths = []string{"Dockerfile", "docker/Dockerfile", ".docker/Dockerfile"}
 	}
 
+	var lastErr error
 	for _, p := range paths {
 		fc, err := c.GetFileContent(ctx, owner, repo, p, ref)
 		if err == nil {
 			return fc.Content, nil
 		}
+		lastErr = err
 	}
-	return "", fmt.Errorf("no Dockerfile found in repository %s/%s", owner, repo)
+	return "", fmt.Errorf("no Dockerfile found in repository %s/%s: %w", owner, repo, lastErr)
 }
 
-// ListContainerImages lists container images published to GHCR for a user/org.
+// ListContainerImages lists container images published to GHCR for a user.
 func (c *Client) ListContainerImages(ctx context.Context, owner string, page, perPage int) ([]ContainerImage, error) {
-	url := fmt.Sprintf("%s/users/%s/packages?package_type=container&page=%d&per_page=%d", GitHubAPIBaseURL, owner, page, perPage)
-	data, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
+	return c.ListContainerImagesFiltered(ctx, owner, ImageListOptions{Page: page, PerPage: perPage})
+}
+
+// ImageListOptions filters and paginates GHCR image listings
+type ImageListOptions struct {
+	Org        bool   // owner is an organization (/orgs/:org/packages) rather than a user
+	Visibility string // "public", "private" or "internal"; empty lists all
+	NamePrefix string // Only images whose name starts with this
+	Page       int
+	PerPage    int
+}
+
+// tagLookupConcurrency bounds parallel version lookups in ListContainerImagesWithTags
+const tagLookupConcurrency = 4
+
+// packagesPath returns the API path owning owner's packages
+func packagesPath(owner string, org bool) string {
+	if org {
+		return fmt.Sprintf("%s/orgs/%s/packages", apiBaseURL, url.PathEscape(owner))
+	}
+	return fmt.Sprintf("%s/users/%s/packages", apiBaseURL, url.PathEscape(owner))
+}
+
+// ListContainerImagesFiltered lists owner's GHCR images with opts applied.
+// The name prefix filter is applied to the returned page.
+func (c *Client) ListContainerImagesFiltered(ctx context.Context, owner string, opts ImageListOptions) ([]ContainerImage, error) {
+	query := url.Values{}
+	query.Set("package_type", "container")
+	query.Set("page", strconv.Itoa(max(opts.Page, 1)))
+	query.Set("per_page", strconv.Itoa(max(opts.PerPage, 1)))
+	if opts.Visibility != "" {
+		query.Set("visibility", opts.Visibility)
+	}
+
+	data, status, err := c.doRequest(ctx, http.MethodGet, packagesPath(owner, opts.Org)+"?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var images []ContainerImage
 	if err := json.Unmarshal(data, &images); err != nil {
 		return nil, fmt.Errorf("failed to parse images: %w", err)
 	}
+	if opts.NamePrefix != "" {
+		filtered := images[:0]
+		for _, img := range images {
+			if strings.HasPrefix(img.Name, opts.NamePrefix) {
+				filtered = append(filtered, img)
+			}
+		}
+		images = filtered
+	}
+	return images, nil
+}
+
+// ListContainerImagesWithTags is ListContainerImagesFiltered with each image's
+// tags filled in, fetched with bounded concurrency instead of one request per
+// image from the caller. Images whose tags can't be fetched keep Tags empty;
+// a cancelled ctx returns its error.
+func (c *Client) ListContainerImagesWithTags(ctx context.Context, owner string, opts ImageListOptions) ([]ContainerImage, error) {
+	images, err := c.ListContainerImagesFiltered(ctx, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, tagLookupConcurrency)
+	for i := range images {
+		wg.Add(1)
+		go func(img *ContainerImage) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if tags, err := c.containerImageTags(ctx, owner, img.Name, opts.Org); err == nil {
+				img.Tags = tags
+			}
+		}(&images[i])
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return images, nil
 }
 
-// GetContainerImageTags returns available tags for a GHCR image.
+// GetContainerImageTags returns available tags for a user's GHCR image.
 func (c *Client) GetContainerImageTags(ctx context.Context, owner, imageName string) ([]string, error) {
-	url := fmt.Sprintf("%s/users/%s/packages/container/%s/versions", GitHubAPIBaseURL, owner, imageName)
-	data, status, err := c.doRequest(ctx, http.MethodGet, url, nil)
+	return c.containerImageTags(ctx, owner, imageName, false)
+}
+
+// containerImageTags returns the tags of a GHCR image owned by a user or org.
+func (c *Client) containerImageTags(ctx context.Context, owner, imageName string, org bool) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/container/%s/versions", packagesPath(owner, org), url.PathEscape(imageName))
+	data, status, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var versions []struct {
@@ -280,29 +379,18 @@ func (c *Client) CreateIssue(ctx context.Context, owner, repo, title, body strin
 		"body":   body,
 		"labels": labels,
 	}
-	payloadJSON, _ := json.Marshal(payload)
-
-	url := fmt.Sprintf("%s/repos/%s/%s/issues", GitHubAPIBaseURL, owner, repo)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, io.NopCloser(
-		io.Reader(
-			jsonReader(payloadJSON),
-		),
-	))
+	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to encode issue: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	url := fmt.Sprintf("%s/repos/%s/%s/issues", apiBaseURL, owner, repo)
+	data, status, err := c.doRequest(ctx, http.MethodPost, url, bytes.NewReader(payloadJSON))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create issue: %w", err)
 	}
-	defer resp.Body.Close()
-
-	data, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create issue (status %d): %s", resp.StatusCode, string(data))
+	if status != http.StatusCreated {
+		return nil, fmt.Errorf("failed to create issue: %w", integration.NewStatusError(status, data))
 	}
 
 	var issue Issue
@@ -325,28 +413,3 @@ func (c *Client) CreateOptimizationIssue(ctx context.Context, owner, repo string
 
 	return c.CreateIssue(ctx, owner, repo, "Container Image Optimization Report", body, []string{"optimization"})
 }
-
-// jsonReader is a helper to create an io.Reader from a byte slice
-func jsonReader(data []byte) io.Reader {
-	return io.NopCloser(
-		readerFromBytes(data),
-	)
-}
-
-type bytesReader struct {
-	data []byte
-	pos  int
-}
-
-func readerFromBytes(data []byte) *bytesReader {
-	return &bytesReader{data: data}
-}
-
-func (r *bytesReader) Read(p []byte) (n int, err error) {
-	if r.pos >= len(r.data) {
-		return 0, io.EOF
-	}
-	n = copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
-}
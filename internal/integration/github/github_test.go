@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
+)
+
+func TestCreateIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/app/issues" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-pat" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected Content-Type %q", r.Header.Get("Content-Type"))
+		}
+
+		var payload struct {
+			Title  string   `json:"title"`
+			Body   string   `json:"body"`
+			Labels []string `json:"labels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		if payload.Title != "Slim the image" || payload.Body != "Use a distroless base" ||
+			!reflect.DeepEqual(payload.Labels, []string{"optimization"}) {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number": 42, "title": "Slim the image", "html_url": "https://github.com/acme/app/issues/42"}`))
+	}))
+	defer server.Close()
+
+	defer func(orig string) { apiBaseURL = orig }(apiBaseURL)
+	apiBaseURL = server.URL
+
+	client := NewClient(Config{PersonalAccessToken: "test-pat"})
+	issue, err := client.CreateIssue(context.Background(), "acme", "app", "Slim the image", "Use a distroless base", []string{"optimization"})
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	if issue.Number != 42 || issue.HTMLURL != "https://github.com/acme/app/issues/42" {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+}
+
+func TestCreateIssueForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "Resource not accessible by personal access token"}`))
+	}))
+	defer server.Close()
+
+	defer func(orig string) { apiBaseURL = orig }(apiBaseURL)
+	apiBaseURL = server.URL
+
+	client := NewClient(Config{PersonalAccessToken: "test-pat"})
+	_, err := client.CreateIssue(context.Background(), "acme", "app", "title", "body", nil)
+	if !errors.Is(err, integration.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+}
@@ -3,10 +3,13 @@ package harbor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/siddhantprateek/reefline/internal/integration"
 )
 
 // Config holds the configuration for a Harbor integration.
@@ -95,6 +98,13 @@ type Artifact struct {
 	ExtraAttrs   map[string]interface{} `json:"extra_attrs"`
 }
 
+// ArtifactRef identifies an artifact within a Harbor instance
+type ArtifactRef struct {
+	Project    string `json:"project"`
+	Repository string `json:"repository"`
+	Reference  string `json:"reference"` // Tag or digest
+}
+
 // Tag represents a tag attached to an artifact
 type Tag struct {
 	ID         int64  `json:"id"`
@@ -114,12 +124,73 @@ type VulnerabilitySummary struct {
 	ScanStatus string `json:"scan_status"`
 }
 
+// VulnerabilityReport is Harbor's detailed scan report for an artifact
+// (the "additions/vulnerabilities" addition), as produced by its scanner.
+type VulnerabilityReport struct {
+	GeneratedAt     string               `json:"generated_at"`
+	Scanner         ReportScanner        `json:"scanner"`
+	Severity        string               `json:"severity"` // Highest severity found
+	Vulnerabilities []VulnerabilityEntry `json:"vulnerabilities"`
+}
+
+// ReportScanner identifies the scanner that produced a VulnerabilityReport
+type ReportScanner struct {
+	Name    string `json:"name"`
+	Vendor  string `json:"vendor"`
+	Version string `json:"version"`
+}
+
+// VulnerabilityEntry is a single finding in a VulnerabilityReport
+type VulnerabilityEntry struct {
+	ID          string   `json:"id"`
+	Package     string   `json:"package"`
+	Version     string   `json:"version"`
+	FixVersion  string   `json:"fix_version"`
+	Severity    string   `json:"severity"` // Critical, High, Medium, Low, Negligible, Unknown
+	Description string   `json:"description"`
+	Links       []string `json:"links"`
+}
+
+// Summary counts the report's findings per severity.
+func (r *VulnerabilityReport) Summary() VulnerabilitySummary {
+	sum := VulnerabilitySummary{Total: len(r.Vulnerabilities), ScanStatus: "Success"}
+	for _, v := range r.Vulnerabilities {
+		switch strings.ToLower(v.Severity) {
+		case "critical":
+			sum.Critical++
+		case "high":
+			sum.High++
+		case "medium":
+			sum.Medium++
+		case "low":
+			sum.Low++
+		case "negligible":
+			sum.Negligible++
+		}
+	}
+	return sum
+}
+
+// vulnerabilityReportMimeType is the report format requested from Harbor
+const vulnerabilityReportMimeType = "application/vnd.security.vulnerability.report; version=1.1"
+
+// ErrNoScanReport is returned when Harbor has not scanned an artifact
+var ErrNoScanReport = errors.New("no Harbor scan report available for this artifact")
+
 // doRequest is a helper that executes an HTTP request and returns the response body.
 func (c *Client) doRequest(ctx context.Context, method, url string, body io.Reader) ([]byte, int, error) {
+	return c.doRequestWithHeaders(ctx, method, url, body, nil)
+}
+
+// doRequestWithHeaders is doRequest with extra request headers.
+func (c *Client) doRequestWithHeaders(ctx context.Context, method, url string, body io.Reader, headers map[string]string) ([]byte, int, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -132,7 +203,7 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return data, resp.StatusCode, nil
+	return data, resp.StatusCode, integration.CheckStatus(resp.StatusCode, data)
 }
 
 // ValidateCredentials checks if the Harbor URL, username, and password are valid.
@@ -141,15 +212,18 @@ func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
 	// Check credentials against a protected endpoint (401 if invalid)
 	permURL := fmt.Sprintf("%s/api/v2.0/users/current/permissions", c.baseURL)
 	_, status, err := c.doRequest(ctx, http.MethodGet, permURL, nil)
+	if errors.Is(err, integration.ErrUnauthorized) {
+		return "", fmt.Errorf("invalid credentials: %w", err)
+	}
+	var statusErr *integration.StatusError
+	if errors.As(err, &statusErr) {
+		return "", fmt.Errorf("checking permissions: %w", err)
+	}
 	if err != nil {
 		return "", fmt.Errorf("cannot reach Harbor at %s: %w", c.baseURL, err)
 	}
-
-	if status == http.StatusUnauthorized {
-		return "", fmt.Errorf("invalid credentials: 401 Unauthorized")
-	}
 	if status != http.StatusOK {
-		return "", fmt.Errorf("unexpected status checking permissions: %d", status)
+		return "", fmt.Errorf("checking permissions: %w", integration.NewStatusError(status, nil))
 	}
 
 	// Get version info via systeminfo (which might be public, but we already validated auth)
@@ -160,7 +234,7 @@ func (c *Client) ValidateCredentials(ctx context.Context) (string, error) {
 	}
 	// We expect 200 here too
 	if status != http.StatusOK {
-		return "", fmt.Errorf("unexpected status fetch system info: %d", status)
+		return "", fmt.Errorf("fetching system info: %w", integration.NewStatusError(status, nil))
 	}
 
 	var info struct {
@@ -181,7 +255,7 @@ func (c *Client) ListProjects(ctx context.Context, page, pageSize int) ([]Projec
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var projects []Project
@@ -199,7 +273,7 @@ func (c *Client) GetProject(ctx context.Context, projectName string) (*Project,
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var projects []Project
@@ -220,7 +294,7 @@ func (c *Client) ListRepositories(ctx context.Context, projectName string, page,
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var repos []HarborRepository
@@ -239,7 +313,7 @@ func (c *Client) ListArtifacts(ctx context.Context, projectName, repoName string
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var artifacts []Artifact
@@ -258,7 +332,7 @@ func (c *Client) GetArtifact(ctx context.Context, projectName, repoName, referen
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	var artifact Artifact
@@ -277,7 +351,7 @@ func (c *Client) GetVulnerabilitySummary(ctx context.Context, projectName, repoN
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(data))
+		return nil, integration.NewStatusError(status, data)
 	}
 
 	// The scan overview is nested inside the artifact response
@@ -300,6 +374,37 @@ func (c *Client) GetVulnerabilitySummary(ctx context.Context, projectName, repoN
 	return nil, fmt.Errorf("no scan results available for this artifact")
 }
 
+// GetVulnerabilityReport returns Harbor's detailed vulnerability report for an
+// artifact. Returns ErrNoScanReport if the artifact has not been scanned.
+func (c *Client) GetVulnerabilityReport(ctx context.Context, projectName, repoName, reference string) (*VulnerabilityReport, error) {
+	url := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts/%s/additions/vulnerabilities",
+		c.baseURL, projectName, repoName, reference)
+	data, status, err := c.doRequestWithHeaders(ctx, http.MethodGet, url, nil, map[string]string{
+		"X-Accept-Vulnerabilities": vulnerabilityReportMimeType,
+	})
+	if errors.Is(err, integration.ErrNotFound) {
+		return nil, ErrNoScanReport
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, integration.NewStatusError(status, data)
+	}
+
+	// Reports are keyed by MIME type; an unscanned artifact returns {}
+	var reports map[string]*VulnerabilityReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("failed to parse vulnerability report: %w", err)
+	}
+	for _, report := range reports {
+		if report != nil && report.GeneratedAt != "" {
+			return report, nil
+		}
+	}
+	return nil, ErrNoScanReport
+}
+
 // TriggerScan triggers a vulnerability scan for an artifact.
 func (c *Client) TriggerScan(ctx context.Context, projectName, repoName, reference string) error {
 	url := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts/%s/scan",
@@ -315,7 +420,8 @@ func (c *Client) TriggerScan(ctx context.Context, projectName, repoName, referen
 }
 
 // ImageRef returns the full Harbor image reference.
-// Format: {harbor_host}/{project}/{repo}:{tag}
+// Format: {harbor_host}/{project}/{repo}:{tag}, or {harbor_host}/{project}/{repo}@{digest}
+// when tag is a "sha256:..." digest.
 func (c *Client) ImageRef(projectName, repoName, tag string) string {
 	if tag == "" {
 		tag = "latest"
@@ -326,5 +432,8 @@ func (c *Client) ImageRef(projectName, repoName, tag string) string {
 	} else if len(host) > 7 && host[:7] == "http://" {
 		host = host[7:]
 	}
+	if strings.HasPrefix(tag, "sha256:") {
+		return fmt.Sprintf("%s/%s/%s@%s", host, projectName, repoName, tag)
+	}
 	return fmt.Sprintf("%s/%s/%s:%s", host, projectName, repoName, tag)
 }
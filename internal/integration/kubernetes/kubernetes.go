@@ -22,13 +22,15 @@ type ContainerImage struct {
 	ContainerName string `json:"container_name"`
 	// IsInit indicates whether this is an init container
 	IsInit bool `json:"is_init"`
+	// Labels are the labels of the pod, used for scan exclusion rules
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // ClusterInfo holds metadata about the connected cluster.
 type ClusterInfo struct {
-	ServerVersion string `json:"server_version"`
-	NodeCount     int    `json:"node_count"`
-	NamespaceCount int   `json:"namespace_count"`
+	ServerVersion  string `json:"server_version"`
+	NodeCount      int    `json:"node_count"`
+	NamespaceCount int    `json:"namespace_count"`
 }
 
 // Client wraps the Kubernetes client-go clientset and operates via in-cluster config.
@@ -111,6 +113,7 @@ func (c *Client) ListContainerImages(ctx context.Context, namespace string) ([]C
 					Namespace:     pod.Namespace,
 					ContainerName: c.Name,
 					IsInit:        false,
+					Labels:        pod.Labels,
 				})
 			}
 		}
@@ -124,6 +127,7 @@ func (c *Client) ListContainerImages(ctx context.Context, namespace string) ([]C
 					Namespace:     pod.Namespace,
 					ContainerName: c.Name,
 					IsInit:        true,
+					Labels:        pod.Labels,
 				})
 			}
 		}
@@ -0,0 +1,122 @@
+// Package registry maps image references to the user's connected container
+// registry integrations (Docker Hub, GHCR, Harbor) so private images can be
+// pulled with the stored credentials.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/siddhantprateek/reefline/pkg/crypto"
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// registryIntegrations lists the integration IDs that hold registry credentials
+var registryIntegrations = []string{"harbor", "github", "docker"}
+
+// Credential is a connected registry integration resolved to pull credentials
+type Credential struct {
+	IntegrationID string
+	Host          string // Registry domain, e.g. "ghcr.io"
+	URL           string // API URL as configured (Harbor only)
+	Auth          tools.ImageAuth
+}
+
+// LoadCredentials returns pull credentials for every connected registry
+// integration of userID. Integrations that fail to decrypt are skipped.
+func LoadCredentials(userID string) ([]Credential, error) {
+	var stored []models.Integration
+	if err := database.DB.
+		Where("user_id = ? AND integration_id IN ? AND status = ?", userID, registryIntegrations, "connected").
+		Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("fetching registry integrations: %w", err)
+	}
+
+	creds := make([]Credential, 0, len(stored))
+	for _, integration := range stored {
+		cred, err := toCredential(integration)
+		if err != nil {
+			continue
+		}
+		creds = append(creds, *cred)
+	}
+	return creds, nil
+}
+
+// Lookup returns the pull credentials of a single connected integration
+func Lookup(userID, integrationID string) (*Credential, error) {
+	var integration models.Integration
+	if err := database.DB.
+		Where("user_id = ? AND integration_id = ? AND status = ?", userID, integrationID, "connected").
+		First(&integration).Error; err != nil {
+		return nil, fmt.Errorf("%s is not connected", integrationID)
+	}
+	return toCredential(integration)
+}
+
+// Match returns the credential whose registry hosts imageRef, or nil
+func Match(creds []Credential, imageRef string) *Credential {
+	host := tools.RegistryHost(imageRef)
+	if host == "" {
+		return nil
+	}
+	for i := range creds {
+		if strings.EqualFold(creds[i].Host, host) {
+			return &creds[i]
+		}
+	}
+	return nil
+}
+
+// toCredential decrypts a stored integration into pull credentials
+func toCredential(integration models.Integration) (*Credential, error) {
+	raw, err := crypto.Decrypt(integration.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting credentials for %s: %w", integration.IntegrationID, err)
+	}
+	var stored map[string]string
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, fmt.Errorf("parsing credentials for %s: %w", integration.IntegrationID, err)
+	}
+
+	cred := &Credential{IntegrationID: integration.IntegrationID}
+	switch integration.IntegrationID {
+	case "docker":
+		cred.Host = "docker.io"
+		cred.Auth = tools.ImageAuth{Username: stored["username"], Password: stored["patToken"]}
+	case "github":
+		// GHCR accepts any non-empty username alongside a PAT
+		username := "x-access-token"
+		var meta map[string]interface{}
+		if json.Unmarshal([]byte(integration.Metadata), &meta) == nil {
+			if u, ok := meta["username"].(string); ok && u != "" {
+				username = u
+			}
+		}
+		cred.Host = "ghcr.io"
+		cred.Auth = tools.ImageAuth{Username: username, Password: stored["patToken"]}
+	case "harbor":
+		cred.Host = hostOf(stored["url"])
+		cred.URL = stored["url"]
+		cred.Auth = tools.ImageAuth{Username: stored["username"], Password: stored["password"]}
+	default:
+		return nil, fmt.Errorf("%s is not a registry integration", integration.IntegrationID)
+	}
+	return cred, nil
+}
+
+// hostOf extracts host[:port] from a registry URL that may lack a scheme
+func hostOf(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
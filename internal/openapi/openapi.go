@@ -0,0 +1,325 @@
+// Package openapi builds an OpenAPI 3 document from a table of operations.
+// Request and response schemas are generated from Go types by reflection,
+// following their json tags, so the spec stays in step with the handlers'
+// structs.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version of documents built by Build
+const Version = "3.0.3"
+
+// Schema is a raw JSON Schema object, for payloads without a Go type
+// (e.g. handlers that respond with fiber.Map)
+type Schema map[string]interface{}
+
+// Object is a shorthand Schema for an object with the given properties
+func Object(props map[string]interface{}) Schema {
+	return Schema{"type": "object", "properties": props}
+}
+
+// ArrayOf is a shorthand Schema for an array of v (a Go value or Schema)
+func ArrayOf(v interface{}) Schema {
+	return Schema{"type": "array", "items": v}
+}
+
+// Common property schemas for Object
+var (
+	String  = Schema{"type": "string"}
+	Integer = Schema{"type": "integer"}
+	Number  = Schema{"type": "number"}
+	Boolean = Schema{"type": "boolean"}
+	Any     = Schema{}
+)
+
+// Param is a query or header parameter
+type Param struct {
+	Name        string
+	Type        string // "string" (default), "integer" or "boolean"
+	Description string
+	Required    bool
+}
+
+// Operation describes one route
+type Operation struct {
+	Method      string // HTTP method, e.g. http.MethodGet
+	Path        string // Fiber-style path, e.g. /api/v1/jobs/:id
+	Summary     string
+	Tag         string
+	Query       []Param
+	Headers     []Param
+	Body        interface{} // Request body: a Go value whose type is reflected, or a Schema
+	BodyType    string      // Request body type; defaults to application/json
+	Response    interface{} // Success response body, as for Body; nil for no body
+	ContentType string      // Success response type; defaults to application/json
+	Status      int         // Success status; defaults to 200
+}
+
+// Info is the document's title and version
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Build returns the OpenAPI document for ops.
+func Build(info Info, ops []Operation) map[string]interface{} {
+	g := &generator{components: map[string]interface{}{}}
+	paths := map[string]map[string]interface{}{}
+
+	for _, op := range ops {
+		p, params := templatePath(op.Path)
+		if paths[p] == nil {
+			paths[p] = map[string]interface{}{}
+		}
+
+		var parameters []interface{}
+		for _, name := range params {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "path", "required": true, "schema": String,
+			})
+		}
+		for _, q := range op.Query {
+			parameters = append(parameters, paramObject(q, "query"))
+		}
+		for _, h := range op.Headers {
+			parameters = append(parameters, paramObject(h, "header"))
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := map[string]interface{}{"description": http.StatusText(status)}
+		if op.Response != nil {
+			contentType := op.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			resp["content"] = map[string]interface{}{
+				contentType: map[string]interface{}{"schema": g.schema(op.Response)},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"summary":     op.Summary,
+			"operationId": operationID(op.Method, p),
+			"responses": map[string]interface{}{
+				itoa(status): resp,
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+					},
+				},
+			},
+		}
+		if op.Tag != "" {
+			operation["tags"] = []string{op.Tag}
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Body != nil {
+			bodyType := op.BodyType
+			if bodyType == "" {
+				bodyType = "application/json"
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					bodyType: map[string]interface{}{"schema": g.schema(op.Body)},
+				},
+			}
+		}
+		paths[p][strings.ToLower(op.Method)] = operation
+	}
+
+	g.components["Error"] = Object(map[string]interface{}{"error": String, "code": Integer})
+
+	return map[string]interface{}{
+		"openapi":    Version,
+		"info":       info,
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.components},
+	}
+}
+
+// templatePath converts a Fiber path (/jobs/:id) to an OpenAPI template
+// (/jobs/{id}) and returns the parameter names. Trailing slashes are dropped.
+func templatePath(p string) (string, []string) {
+	var params []string
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			name := strings.TrimSuffix(s[1:], "?")
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	out := strings.Join(segments, "/")
+	if len(out) > 1 {
+		out = strings.TrimSuffix(out, "/")
+	}
+	return out, params
+}
+
+// operationID derives a stable operationId such as get_api_v1_jobs_id
+func operationID(method, p string) string {
+	r := strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_", "-", "_")
+	return strings.ToLower(method) + strings.TrimSuffix(r.Replace(p), "_")
+}
+
+func paramObject(p Param, in string) map[string]interface{} {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	obj := map[string]interface{}{
+		"name":   p.Name,
+		"in":     in,
+		"schema": Schema{"type": typ},
+	}
+	if p.Description != "" {
+		obj["description"] = p.Description
+	}
+	if p.Required {
+		obj["required"] = true
+	}
+	return obj
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+// generator reflects Go types into schemas, collecting named structs as components
+type generator struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schema returns the schema for v: a Schema is used as-is (with nested Go
+// values reflected), anything else is reflected from its type.
+func (g *generator) schema(v interface{}) interface{} {
+	if s, ok := v.(Schema); ok {
+		out := Schema{}
+		for k, val := range s {
+			switch k {
+			case "items":
+				out[k] = g.schema(val)
+			case "properties":
+				props := map[string]interface{}{}
+				for name, p := range val.(map[string]interface{}) {
+					props[name] = g.schema(p)
+				}
+				out[k] = props
+			default:
+				out[k] = val
+			}
+		}
+		return out
+	}
+	return g.typeSchema(reflect.TypeOf(v))
+}
+
+func (g *generator) typeSchema(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return Any
+	case t.Name() == "DeletedAt" && t.PkgPath() == "gorm.io/gorm":
+		return Schema{"type": "string", "format": "date-time", "nullable": true}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer
+	case reflect.Float32, reflect.Float64:
+		return Number
+	case reflect.String:
+		return String
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return ArrayOf(g.typeSchema(t.Elem()))
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = Schema{} // Placeholder for recursive types
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return Any
+}
+
+// structSchema reflects a struct's JSON-visible fields, flattening embedded structs
+func (g *generator) structSchema(t reflect.Type) Schema {
+	props := map[string]interface{}{}
+	var required []string
+	g.addFields(t, props, &required)
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *generator) addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
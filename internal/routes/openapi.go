@@ -0,0 +1,443 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/flows"
+	"github.com/siddhantprateek/reefline/internal/flows/agents"
+	"github.com/siddhantprateek/reefline/internal/handlers"
+	"github.com/siddhantprateek/reefline/internal/integration/dockerhub"
+	"github.com/siddhantprateek/reefline/internal/integration/github"
+	"github.com/siddhantprateek/reefline/internal/integration/harbor"
+	k8s "github.com/siddhantprateek/reefline/internal/integration/kubernetes"
+	"github.com/siddhantprateek/reefline/internal/openapi"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+	"github.com/siddhantprateek/reefline/pkg/version"
+)
+
+// apiInfo is the info block of the served OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "Reefline API",
+	Version:     version.Version,
+	Description: "Container image and Dockerfile analysis.",
+}
+
+// Response shapes for handlers that reply with fiber.Map
+var (
+	healthResponse = openapi.Object(map[string]interface{}{
+		"status":  openapi.String,
+		"service": openapi.String,
+	})
+	imageInfoSchema = openapi.Object(map[string]interface{}{
+		"digest": openapi.String,
+		"size":   openapi.Integer,
+		"arch":   openapi.String,
+		"os":     openapi.String,
+		"layers": openapi.Integer,
+	})
+	submittedJobResponse = openapi.Object(map[string]interface{}{
+		"job_id":     openapi.String,
+		"status":     openapi.String,
+		"image_ref":  openapi.String,
+		"stream_url": openapi.String,
+		"image_info": imageInfoSchema,
+		"dry_run":    openapi.Boolean,
+	})
+	registryScanBody = openapi.Object(map[string]interface{}{
+		"analysis_type":   openapi.String,
+		"timeout_seconds": openapi.Integer,
+		"match_profile":   openapi.String,
+		"app_context":     openapi.String,
+		"use_harbor_scan": openapi.Boolean,
+		"scheduled":       openapi.Boolean,
+	})
+	messageResponse = openapi.Object(map[string]interface{}{
+		"message":     openapi.String,
+		"job_id":      openapi.String,
+		"purge_after": openapi.String,
+	})
+	artifactListResponse = openapi.Object(map[string]interface{}{
+		"job_id": openapi.String,
+		"artifacts": openapi.ArrayOf(openapi.Object(map[string]interface{}{
+			"name":          openapi.String,
+			"size":          openapi.Integer,
+			"content_type":  openapi.String,
+			"last_modified": openapi.Schema{"type": "string", "format": "date-time"},
+			"url":           openapi.String,
+			"tool":          openapi.String,
+			"tool_version":  openapi.String,
+		})),
+	})
+	integrationStatus = openapi.Object(map[string]interface{}{
+		"id":              openapi.String,
+		"status":          openapi.String,
+		"connected_at":    openapi.Schema{"type": "string", "format": "date-time"},
+		"metadata":        openapi.Schema{"type": "object", "additionalProperties": openapi.String},
+		"last_tested_at":  openapi.Schema{"type": "string", "format": "date-time"},
+		"last_latency_ms": openapi.Integer,
+		"test_history":    openapi.ArrayOf(models.IntegrationTest{}),
+		"latency_ms":      openapi.Integer,
+		"error":           openapi.String,
+	})
+	usageTotals = openapi.Object(map[string]interface{}{
+		"requests":           openapi.Integer,
+		"prompt_tokens":      openapi.Integer,
+		"completion_tokens":  openapi.Integer,
+		"total_tokens":       openapi.Integer,
+		"estimated_cost_usd": openapi.Number,
+	})
+	clusterImage = openapi.Object(map[string]interface{}{
+		"image":       openapi.String,
+		"registry":    openapi.String,
+		"integration": openapi.String,
+	})
+	reportTemplateResponse = openapi.Object(map[string]interface{}{
+		"source":   openapi.String,
+		"template": agents.ReportTemplate{},
+	})
+	flowPreferencesResponse = openapi.Object(map[string]interface{}{
+		"source":            openapi.String,
+		"provider_priority": openapi.ArrayOf(openapi.String),
+	})
+)
+
+// operations documents every route registered by Setup. TestOpenAPIMatchesRoutes
+// fails if a route is added or removed without updating this table.
+var operations = []openapi.Operation{
+	// Meta
+	{Method: http.MethodGet, Path: "/api/v1/openapi.json", Tag: "meta", Summary: "This OpenAPI document",
+		Response: openapi.Schema{"type": "object"}},
+
+	// Health
+	{Method: http.MethodGet, Path: "/api/v1/health", Tag: "health", Summary: "Service health", Response: healthResponse},
+	{Method: http.MethodGet, Path: "/api/v1/health/ready", Tag: "health", Summary: "Readiness probe", Response: healthResponse},
+	{Method: http.MethodGet, Path: "/api/v1/health/live", Tag: "health", Summary: "Liveness probe", Response: healthResponse},
+
+	// Version
+	{Method: http.MethodGet, Path: "/api/v1/version", Tag: "meta", Summary: "Build and vulnerability DB version",
+		Response: handlers.VersionResponse{}},
+
+	// Analyze
+	{Method: http.MethodPost, Path: "/api/v1/analyze", Tag: "analyze",
+		Summary: "Submit a Dockerfile and/or image reference for analysis",
+		Headers: []openapi.Param{{Name: "Idempotency-Key", Description: "Return the existing job for a retried submission"}},
+		Body:    handlers.AnalysisRequest{}, Response: submittedJobResponse, Status: http.StatusAccepted},
+	{Method: http.MethodPost, Path: "/api/v1/analyze/source", Tag: "analyze",
+		Summary: "Upload a source tree tarball to scan its dependencies",
+		Body: openapi.Object(map[string]interface{}{
+			"source":          openapi.Schema{"type": "string", "format": "binary"},
+			"name":            openapi.String,
+			"app_context":     openapi.String,
+			"timeout_seconds": openapi.Integer,
+			"match_profile":   openapi.String,
+		}),
+		BodyType: "multipart/form-data", Response: submittedJobResponse, Status: http.StatusAccepted},
+
+	// Jobs
+	{Method: http.MethodGet, Path: "/api/v1/jobs", Tag: "jobs", Summary: "List jobs",
+		Query: []openapi.Param{
+			{Name: "page", Type: "integer"},
+			{Name: "limit", Type: "integer"},
+			{Name: "status", Description: "QUEUED | RUNNING | COMPLETED | FAILED"},
+			{Name: "sort", Description: "created_at (default) | completed_at | security_score | critical_count | high_count | dockle_fatal_count | image_size_bytes | efficiency"},
+			{Name: "order", Description: "asc | desc (default); jobs without the sorted value come last"},
+		},
+		Response: openapi.ArrayOf(handlers.JobListResponse{})},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id", Tag: "jobs", Summary: "Job status and report availability",
+		Response: handlers.JobReportResponse{}},
+	{Method: http.MethodDelete, Path: "/api/v1/jobs/:id", Tag: "jobs", Summary: "Soft-delete a job, or purge it with ?purge=true",
+		Query:    []openapi.Param{{Name: "purge", Type: "boolean"}},
+		Response: messageResponse},
+	{Method: http.MethodPost, Path: "/api/v1/jobs/:id/restore", Tag: "jobs", Summary: "Undelete a soft-deleted job",
+		Response: messageResponse},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/events", Tag: "jobs", Summary: "Lifecycle history of the job, oldest first",
+		Response: handlers.JobEventsResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/stream", Tag: "jobs", Summary: "Real-time progress (Server-Sent Events)",
+		Response: openapi.String, ContentType: "text/event-stream"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/logs", Tag: "jobs", Summary: "Worker log of the job, one JSON object per line",
+		Response: openapi.String, ContentType: "application/x-ndjson"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/logs/stream", Tag: "jobs", Summary: "Tail of the worker log until the job finishes (Server-Sent Events)",
+		Response: openapi.String, ContentType: "text/event-stream"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/artifacts", Tag: "jobs", Summary: "List stored artifacts",
+		Response: artifactListResponse},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/artifacts/:name", Tag: "jobs", Summary: "Download an artifact by name",
+		Response: openapi.Schema{"type": "string", "format": "binary"}, ContentType: "application/octet-stream"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/grype.json", Tag: "jobs", Summary: "Grype vulnerability scan result",
+		Response: openapi.Any},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/dive.json", Tag: "jobs", Summary: "Dive layer efficiency analysis",
+		Response: openapi.Any},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/dockle.json", Tag: "jobs", Summary: "Dockle CIS benchmark result",
+		Response: openapi.Any},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/licenses.json", Tag: "jobs", Summary: "Package license summary",
+		Response: openapi.Any},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/score.json", Tag: "jobs", Summary: "Deterministic score card",
+		Response: scoring.ScoreCard{}},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/report.md", Tag: "jobs", Summary: "Final report (Markdown)",
+		Response: openapi.String, ContentType: "text/markdown"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/draft.md", Tag: "jobs", Summary: "Supervisor draft (Markdown)",
+		Response: openapi.String, ContentType: "text/markdown"},
+	{Method: http.MethodGet, Path: "/api/v1/jobs/:id/report/preview", Tag: "jobs", Summary: "Estimated tokens and cost of generating the report, without calling the model",
+		Response: flows.CostPreview{}},
+
+	// Images
+	{Method: http.MethodGet, Path: "/api/v1/images/manifest", Tag: "images", Summary: "Raw image manifest as served by the registry",
+		Query:    []openapi.Param{{Name: "ref", Description: "Image reference, e.g. nginx:1.25", Required: true}},
+		Response: openapi.Any, ContentType: "application/vnd.oci.image.manifest.v1+json"},
+	{Method: http.MethodGet, Path: "/api/v1/images/config", Tag: "images", Summary: "Raw image config blob",
+		Query:    []openapi.Param{{Name: "ref", Description: "Image reference, e.g. nginx:1.25", Required: true}},
+		Response: openapi.Any, ContentType: "application/vnd.oci.image.config.v1+json"},
+	{Method: http.MethodGet, Path: "/api/v1/images/referrers", Tag: "images", Summary: "Artifacts attached to an image (OCI referrers)",
+		Query:    []openapi.Param{{Name: "ref", Description: "Image reference, e.g. nginx:1.25", Required: true}},
+		Response: []tools.Referrer{}},
+
+	// Compare
+	{Method: http.MethodPost, Path: "/api/v1/compare", Tag: "compare", Summary: "Compare two completed jobs",
+		Body: openapi.Object(map[string]interface{}{
+			"job_id_a": openapi.String,
+			"job_id_b": openapi.String,
+		}),
+		Response: openapi.Object(map[string]interface{}{"comparison": openapi.Schema{"type": "object"}})},
+
+	// Integrations
+	{Method: http.MethodGet, Path: "/api/v1/integrations", Tag: "integrations", Summary: "List integrations with status",
+		Response: openapi.Object(map[string]interface{}{"integrations": openapi.ArrayOf(integrationStatus)})},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/:id", Tag: "integrations", Summary: "Integration details",
+		Response: integrationStatus},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/:id/connect", Tag: "integrations", Summary: "Save and validate credentials",
+		Body: openapi.Object(map[string]interface{}{
+			"data":      openapi.Schema{"type": "string", "format": "byte", "description": "Base64-encoded credentials JSON"},
+			"test_only": openapi.Boolean,
+			"force":     openapi.Schema{"type": "boolean", "description": "Save an AI model the provider doesn't list"},
+		}),
+		Response: integrationStatus},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/:id/disconnect", Tag: "integrations", Summary: "Remove credentials",
+		Response: integrationStatus},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/:id/test", Tag: "integrations", Summary: "Re-validate stored credentials",
+		Response: integrationStatus},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/:id/usage", Tag: "integrations", Summary: "Daily AI provider token usage",
+		Query: []openapi.Param{{Name: "range", Description: "e.g. 7d or 30d (max 365d)"}},
+		Response: openapi.Object(map[string]interface{}{
+			"provider": openapi.String,
+			"range":    openapi.String,
+			"daily":    openapi.ArrayOf(models.ProviderUsage{}),
+			"totals":   usageTotals,
+		})},
+
+	{Method: http.MethodGet, Path: "/api/v1/integrations/github/repos", Tag: "github", Summary: "List GitHub repositories",
+		Response: []github.Repository{}},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/github/repos/:owner/:repo/dockerfile", Tag: "github",
+		Summary: "Fetch a repository's Dockerfile",
+		Query:   []openapi.Param{{Name: "path"}, {Name: "ref"}},
+		Response: openapi.Object(map[string]interface{}{
+			"content": openapi.String,
+			"path":    openapi.String,
+		})},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/github/images", Tag: "github", Summary: "List GHCR container images",
+		Query: []openapi.Param{
+			{Name: "org", Description: "List an organization's packages"},
+			{Name: "visibility", Description: "public | private | internal"},
+			{Name: "prefix"},
+			{Name: "with_tags", Type: "boolean"},
+		},
+		Response: []github.ContainerImage{}},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/github/repos/:owner/:repo/issues", Tag: "github",
+		Summary: "Create an optimization issue",
+		Body: openapi.Object(map[string]interface{}{
+			"job_id": openapi.String,
+			"title":  openapi.String,
+			"labels": openapi.ArrayOf(openapi.String),
+		}),
+		Response: github.Issue{}},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/github/images/:owner/:image/tags/:tag/scan", Tag: "github",
+		Summary: "Analyze a GHCR image with the stored PAT",
+		Body:    registryScanBody, Response: submittedJobResponse, Status: http.StatusAccepted},
+
+	{Method: http.MethodGet, Path: "/api/v1/integrations/docker/repos", Tag: "docker", Summary: "List Docker Hub repositories",
+		Response: []dockerhub.DockerRepository{}},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/docker/repos/:namespace/:repo/tags", Tag: "docker",
+		Summary: "List a repository's tags", Response: []dockerhub.ImageTag{}},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/docker/repos/:namespace/:repo/tags/:tag/scan", Tag: "docker",
+		Summary: "Analyze a Docker Hub tag with the stored credentials",
+		Body:    registryScanBody, Response: submittedJobResponse, Status: http.StatusAccepted},
+
+	{Method: http.MethodGet, Path: "/api/v1/integrations/kubernetes/status", Tag: "kubernetes", Summary: "In-cluster availability",
+		Response: openapi.Object(map[string]interface{}{
+			"id":        openapi.String,
+			"status":    openapi.String,
+			"available": openapi.Boolean,
+			"metadata":  k8s.ClusterInfo{},
+			"message":   openapi.String,
+			"error":     openapi.String,
+		})},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/kubernetes/images", Tag: "kubernetes",
+		Summary: "List container images running in the cluster",
+		Query: []openapi.Param{
+			{Name: "namespace"},
+			{Name: "include_excluded", Type: "boolean"},
+		},
+		Response: []k8s.ContainerImage{}},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/kubernetes/scan", Tag: "kubernetes",
+		Summary: "Enqueue analyses for the cluster's images",
+		Body: openapi.Object(map[string]interface{}{
+			"namespace":        openapi.String,
+			"include_excluded": openapi.Boolean,
+			"dry_run":          openapi.Boolean,
+			"max_concurrent":   openapi.Integer,
+			"timeout_seconds":  openapi.Integer,
+		}),
+		Response: openapi.Object(map[string]interface{}{
+			"dry_run": openapi.Boolean,
+			"images":  openapi.ArrayOf(clusterImage),
+			"jobs": openapi.ArrayOf(openapi.Object(map[string]interface{}{
+				"image":        openapi.String,
+				"digest":       openapi.String,
+				"status":       openapi.String,
+				"job_id":       openapi.String,
+				"duplicate_of": openapi.String,
+				"error":        openapi.String,
+			})),
+			"summary": openapi.Object(map[string]interface{}{
+				"enqueued":              openapi.Integer,
+				"duplicates":            openapi.Integer,
+				"failed":                openapi.Integer,
+				"timed_out":             openapi.Integer,
+				"excluded":              openapi.Integer,
+				"unmatched_credentials": openapi.Integer,
+			}),
+			"registries": openapi.Schema{"type": "object"},
+			"excluded":   openapi.Integer,
+		}),
+		Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/kubernetes/exclusions", Tag: "kubernetes",
+		Summary: "Cluster scan exclusions", Response: tools.Exclusions{}},
+	{Method: http.MethodPut, Path: "/api/v1/integrations/kubernetes/exclusions", Tag: "kubernetes",
+		Summary: "Replace cluster scan exclusions", Body: tools.Exclusions{}, Response: tools.Exclusions{}},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/kubernetes/namespaces", Tag: "kubernetes", Summary: "List namespaces",
+		Response: openapi.Object(map[string]interface{}{"namespaces": openapi.ArrayOf(openapi.String)})},
+
+	{Method: http.MethodGet, Path: "/api/v1/integrations/harbor/projects", Tag: "harbor", Summary: "List Harbor projects",
+		Response: []harbor.Project{}},
+	{Method: http.MethodGet, Path: "/api/v1/integrations/harbor/projects/:project/repos/:repo/artifacts", Tag: "harbor",
+		Summary: "List a repository's artifacts", Response: []harbor.Artifact{}},
+	{Method: http.MethodPost, Path: "/api/v1/integrations/harbor/projects/:project/repos/:repo/artifacts/:ref/scan", Tag: "harbor",
+		Summary: "Analyze an artifact (tag or digest), optionally importing Harbor's scan",
+		Body:    registryScanBody, Response: submittedJobResponse, Status: http.StatusAccepted},
+
+	// Metrics
+	{Method: http.MethodGet, Path: "/api/v1/metrics/queue", Tag: "metrics", Summary: "Real-time queue statistics",
+		Query:    []openapi.Param{{Name: "window", Description: "Period throughput_per_hour is averaged over: 1h (default) | 6h | 24h"}},
+		Response: handlers.QueueStatsResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/metrics/jobs", Tag: "metrics", Summary: "Job metrics and trends",
+		Query: []openapi.Param{
+			{Name: "time_range", Description: "24h | 7d | 30d (default 24h), ending now; ignored when from is set"},
+			{Name: "from", Description: "Start of a custom range (RFC 3339)"},
+			{Name: "to", Description: "End of a custom range (RFC 3339, default now)"},
+			{Name: "granularity", Description: "Time series bucket: 5m | 15m | 1h | 6h | 1d (default 1h up to two days, else 1d); at most 1000 buckets"},
+			{Name: "image_ref", Description: "Only jobs of this image: a repository (any tag or digest) or an exact reference"},
+		},
+		Response: handlers.JobMetricsResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/metrics/tools", Tag: "metrics", Summary: "Tool performance",
+		Query: []openapi.Param{
+			{Name: "recompute", Description: "true to recompute the stats from all jobs before answering"},
+			{Name: "image_ref", Description: "Only jobs of this image: a repository (any tag or digest) or an exact reference; computed on request"},
+		},
+		Response: handlers.ToolPerformanceResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/metrics/fleet", Tag: "metrics", Summary: "Fleet exposure from the latest completed scan of each image",
+		Query:    []openapi.Param{{Name: "limit", Description: "Length of the top images and CVEs lists, 1-100 (default 10)"}},
+		Response: handlers.FleetRiskResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/metrics/image-size", Tag: "metrics", Summary: "Size and efficiency of an image across its completed scans",
+		Query: []openapi.Param{
+			{Name: "image_ref", Description: "A repository (every tag) or an exact reference", Required: true},
+			{Name: "limit", Description: "Latest scans to include, 1-500 (default 50)"},
+		},
+		Response: handlers.ImageSizeResponse{}},
+	{Method: http.MethodGet, Path: "/api/v1/metrics/export", Tag: "metrics", Summary: "Per-day job counts, success rate, queue wait and tool durations as CSV or NDJSON",
+		Query: []openapi.Param{
+			{Name: "range", Description: "24h | 7d | 30d (default 30d), ending now; ignored when from is set"},
+			{Name: "from", Description: "Start of a custom range (RFC 3339)"},
+			{Name: "to", Description: "End of a custom range (RFC 3339, default now)"},
+			{Name: "format", Description: "csv (default) | ndjson"},
+			{Name: "image_ref", Description: "Only jobs of this image: a repository (any tag or digest) or an exact reference"},
+		},
+		Response: openapi.String, ContentType: "text/csv"},
+
+	// Scanner
+	{Method: http.MethodPost, Path: "/api/v1/scanner/db/refresh", Tag: "scanner", Summary: "Force a grype vulnerability DB update",
+		Response: openapi.Object(map[string]interface{}{
+			"status":  openapi.String,
+			"db":      openapi.Schema{"type": "object"},
+			"task_id": openapi.String,
+		})},
+
+	// Usage
+	{Method: http.MethodGet, Path: "/api/v1/usage/storage", Tag: "usage", Summary: "Artifact storage used vs. quota",
+		Response: openapi.Object(map[string]interface{}{
+			"used_bytes":      openapi.Integer,
+			"limit_bytes":     openapi.Integer,
+			"remaining_bytes": openapi.Integer,
+			"exceeded":        openapi.Boolean,
+		})},
+
+	// Report template
+	{Method: http.MethodGet, Path: "/api/v1/report-template", Tag: "report-template", Summary: "Effective report template",
+		Response: reportTemplateResponse},
+	{Method: http.MethodPut, Path: "/api/v1/report-template", Tag: "report-template", Summary: "Save a custom report template",
+		Body: agents.ReportTemplate{}, Response: reportTemplateResponse},
+	{Method: http.MethodDelete, Path: "/api/v1/report-template", Tag: "report-template", Summary: "Revert to the default template",
+		Response: reportTemplateResponse},
+
+	// Flow preferences
+	{Method: http.MethodGet, Path: "/api/v1/flow-preferences", Tag: "flow-preferences", Summary: "Effective AI provider order",
+		Response: flowPreferencesResponse},
+	{Method: http.MethodPut, Path: "/api/v1/flow-preferences", Tag: "flow-preferences", Summary: "Save the AI provider order (empty reverts to the default)",
+		Body: openapi.Object(map[string]interface{}{"provider_priority": openapi.ArrayOf(openapi.String)}), Response: flowPreferencesResponse},
+
+	// Watches
+	{Method: http.MethodGet, Path: "/api/v1/watches", Tag: "watches", Summary: "Watched images",
+		Response: openapi.ArrayOf(models.Watch{})},
+	{Method: http.MethodPost, Path: "/api/v1/watches", Tag: "watches", Summary: "Rescan an image whenever its digest changes",
+		Body: openapi.Object(map[string]interface{}{
+			"image_ref":     openapi.String,
+			"analysis_type": openapi.String,
+		}),
+		Response: models.Watch{}, Status: http.StatusCreated},
+	{Method: http.MethodDelete, Path: "/api/v1/watches/:id", Tag: "watches", Summary: "Stop watching an image",
+		Response: messageResponse},
+
+	// Admin
+	{Method: http.MethodGet, Path: "/api/v1/admin/integrations", Tag: "admin", Summary: "All users' integrations",
+		Headers: []openapi.Param{{Name: "X-Admin-Token", Required: true}},
+		Query: []openapi.Param{
+			{Name: "status"},
+			{Name: "integration_id"},
+			{Name: "user_id"},
+			{Name: "page", Type: "integer"},
+			{Name: "limit", Type: "integer"},
+		},
+		Response: openapi.Object(map[string]interface{}{
+			"total": openapi.Integer,
+			"page":  openapi.Integer,
+			"limit": openapi.Integer,
+			"integrations": openapi.ArrayOf(openapi.Object(map[string]interface{}{
+				"id":             openapi.Integer,
+				"user_id":        openapi.String,
+				"integration_id": openapi.String,
+				"status":         openapi.String,
+				"connected_at":   openapi.Schema{"type": "string", "format": "date-time"},
+				"updated_at":     openapi.Schema{"type": "string", "format": "date-time"},
+			})),
+		})},
+}
+
+// setupOpenAPIRoutes serves the OpenAPI document for the routes above
+func setupOpenAPIRoutes(api fiber.Router) {
+	spec := openapi.Build(apiInfo, operations)
+
+	// GET /api/v1/openapi.json — OpenAPI 3 description of this API
+	api.Get("/openapi.json", func(c *fiber.Ctx) error {
+		return c.JSON(spec)
+	})
+}
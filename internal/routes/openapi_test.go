@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/siddhantprateek/reefline/internal/queue"
+)
+
+// TestOpenAPIMatchesRoutes checks that the served spec documents exactly the
+// routes registered by Setup.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	app := fiber.New()
+	Setup(app, queue.NewInMemoryQueue(1))
+
+	registered := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == http.MethodHead || r.Method == "USE" || !strings.HasPrefix(r.Path, "/api/v1/") {
+			continue
+		}
+		path := r.Path
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+		registered[r.Method+" "+path] = true
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("GET /api/v1/openapi.json: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/v1/openapi.json status = %d, want 200", resp.StatusCode)
+	}
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("decoding spec: %v", err)
+	}
+	if spec.OpenAPI == "" {
+		t.Error("spec has no openapi version")
+	}
+
+	documented := map[string]bool{}
+	for path, methods := range spec.Paths {
+		// Back to Fiber syntax: {id} -> :id
+		fiberPath := strings.NewReplacer("{", ":", "}", "").Replace(path)
+		for method := range methods {
+			documented[strings.ToUpper(method)+" "+fiberPath] = true
+		}
+	}
+
+	for _, route := range sortedKeys(registered) {
+		if !documented[route] {
+			t.Errorf("route %s is registered but missing from the OpenAPI spec", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !registered[route] {
+			t.Errorf("route %s is in the OpenAPI spec but not registered", route)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -11,11 +11,20 @@ func Setup(app *fiber.App, q queue.Queue) {
 	api := app.Group("/api/v1")
 
 	setupHealthRoutes(api)
+	setupVersionRoutes(api)
 	setupAnalyzeRoutes(api, q)
 	setupJobRoutes(api, q)
 	setupCompareRoutes(api)
-	setupIntegrationRoutes(api)
+	setupImageRoutes(api)
+	setupIntegrationRoutes(api, q)
 	setupMetricsRoutes(api, q)
+	setupScannerRoutes(api, q)
+	setupUsageRoutes(api)
+	setupReportTemplateRoutes(api)
+	setupFlowPreferencesRoutes(api)
+	setupWatchRoutes(api, q)
+	setupAdminRoutes(api)
+	setupOpenAPIRoutes(api)
 }
 
 // setupHealthRoutes configures health check endpoints
@@ -28,12 +37,22 @@ func setupHealthRoutes(api fiber.Router) {
 	health.Get("/live", healthHandler.Live)
 }
 
+// setupVersionRoutes configures the build information endpoint
+func setupVersionRoutes(api fiber.Router) {
+	versionHandler := handlers.NewVersionHandler()
+
+	// GET /api/v1/version — Build version, commit, Go version and vulnerability DB build date
+	api.Get("/version", versionHandler.Get)
+}
+
 // setupAnalyzeRoutes configures the analysis submission endpoint
 func setupAnalyzeRoutes(api fiber.Router, q queue.Queue) {
 	analyzeHandler := handlers.NewAnalyzeHandler(q)
 
-	// POST /api/v1/analyze — Submit Dockerfile and/or image ref for analysis
+	// POST /api/v1/analyze        — Submit Dockerfile and/or image ref for analysis
+	// POST /api/v1/analyze/source — Upload a source tree tarball to scan its dependencies
 	api.Post("/analyze", analyzeHandler.Handle)
+	api.Post("/analyze/source", analyzeHandler.HandleSource)
 }
 
 // setupJobRoutes configures job management and artifact download endpoints
@@ -49,24 +68,46 @@ func setupJobRoutes(api fiber.Router, q queue.Queue) {
 	// GET  /api/v1/jobs         — List user's jobs
 	jobs.Get("/", jobsHandler.List)
 
-	// GET    /api/v1/jobs/:id   — Get job status + report
-	// DELETE /api/v1/jobs/:id   — Delete job + artifacts
+	// GET    /api/v1/jobs/:id             — Get job status + report
+	// DELETE /api/v1/jobs/:id             — Soft-delete job (artifacts kept for the grace period)
+	// DELETE /api/v1/jobs/:id?purge=true  — Delete job + artifacts permanently
+	// POST   /api/v1/jobs/:id/restore     — Undelete a soft-deleted job
 	jobs.Get("/:id", jobsHandler.Get)
 	jobs.Delete("/:id", jobsHandler.Delete)
+	jobs.Post("/:id/restore", jobsHandler.Restore)
 
+	// GET /api/v1/jobs/:id/events     — Lifecycle history (queued, started, tool runs, completed/failed)
 	// GET /api/v1/jobs/:id/stream     — SSE real-time progress
+	jobs.Get("/:id/events", jobsHandler.Events)
 	jobs.Get("/:id/stream", sseHandler.Stream)
 
-	// GET /api/v1/jobs/:id/grype.json  — Grype vulnerability scan result
-	// GET /api/v1/jobs/:id/dive.json   — Dive layer efficiency analysis
-	// GET /api/v1/jobs/:id/dockle.json — Dockle CIS benchmark scan result
-	// GET /api/v1/jobs/:id/report.md   — AI-generated final report (Markdown)
-	// GET /api/v1/jobs/:id/draft.md    — Supervisor first-pass draft (Markdown)
+	// GET /api/v1/jobs/:id/logs        — Worker log of the job (JSON lines)
+	// GET /api/v1/jobs/:id/logs/stream — SSE tail of the worker log until the job finishes
+	jobs.Get("/:id/logs", reportHandler.DownloadLogs)
+	jobs.Get("/:id/logs/stream", reportHandler.StreamLogs)
+
+	// GET /api/v1/jobs/:id/artifacts       — List stored artifacts (name, size, type, URL)
+	// GET /api/v1/jobs/:id/artifacts/:name — Download any artifact by name
+	jobs.Get("/:id/artifacts", reportHandler.ListArtifacts)
+	jobs.Get("/:id/artifacts/:name", reportHandler.DownloadArtifact)
+
+	// GET /api/v1/jobs/:id/grype.json    — Grype vulnerability scan result
+	// GET /api/v1/jobs/:id/dive.json     — Dive layer efficiency analysis
+	// GET /api/v1/jobs/:id/dockle.json   — Dockle CIS benchmark scan result
+	// GET /api/v1/jobs/:id/licenses.json — Package license summary (GPL/AGPL flagged)
+	// GET /api/v1/jobs/:id/score.json    — Deterministic Score Card
+	// GET /api/v1/jobs/:id/report.md     — AI-generated final report (Markdown)
+	// GET /api/v1/jobs/:id/draft.md      — Supervisor's latest draft (Markdown)
 	jobs.Get("/:id/grype.json", reportHandler.DownloadGrype)
 	jobs.Get("/:id/dive.json", reportHandler.DownloadDive)
 	jobs.Get("/:id/dockle.json", reportHandler.DownloadDockle)
+	jobs.Get("/:id/licenses.json", reportHandler.DownloadLicenses)
+	jobs.Get("/:id/score.json", reportHandler.DownloadScore)
 	jobs.Get("/:id/report.md", reportHandler.DownloadReportMD)
 	jobs.Get("/:id/draft.md", reportHandler.DownloadDraftMD)
+
+	// GET /api/v1/jobs/:id/report/preview — Estimated token usage and cost of the report flow
+	jobs.Get("/:id/report/preview", reportHandler.PreviewCost)
 }
 
 // setupCompareRoutes configures the comparison endpoint
@@ -77,9 +118,22 @@ func setupCompareRoutes(api fiber.Router) {
 	api.Post("/compare", compareHandler.Handle)
 }
 
+// setupImageRoutes configures raw image metadata endpoints
+func setupImageRoutes(api fiber.Router) {
+	imagesHandler := handlers.NewImagesHandler()
+	images := api.Group("/images")
+
+	// GET /api/v1/images/manifest?ref= — Raw manifest as served by the registry
+	// GET /api/v1/images/config?ref=   — Raw config blob (history, env, labels, ...)
+	// GET /api/v1/images/referrers?ref= — Attached SBOMs, signatures and attestations
+	images.Get("/manifest", imagesHandler.Manifest)
+	images.Get("/config", imagesHandler.Config)
+	images.Get("/referrers", imagesHandler.Referrers)
+}
+
 // setupIntegrationRoutes configures integration management and provider-specific endpoints
-func setupIntegrationRoutes(api fiber.Router) {
-	integrationHandler := handlers.NewIntegrationHandler()
+func setupIntegrationRoutes(api fiber.Router, q queue.Queue) {
+	integrationHandler := handlers.NewIntegrationHandler(q)
 
 	integrations := api.Group("/integrations")
 
@@ -96,6 +150,12 @@ func setupIntegrationRoutes(api fiber.Router) {
 	integrations.Post("/:id/disconnect", integrationHandler.Disconnect)
 	integrations.Post("/:id/test", integrationHandler.TestConnection)
 
+	// GET  /api/v1/integrations/:id/usage?range=30d — Daily token usage and estimated cost (AI providers)
+	integrations.Get("/:id/usage", integrationHandler.Usage)
+
+	// Repository, image, project and tag listings below are cached for
+	// LISTING_CACHE_TTL; ?refresh=true bypasses the cache
+
 	// === GitHub-specific endpoints ===
 	gh := integrations.Group("/github")
 
@@ -105,12 +165,15 @@ func setupIntegrationRoutes(api fiber.Router) {
 	// GET  /api/v1/integrations/github/repos/:owner/:repo/dockerfile — Fetch Dockerfile from repo
 	gh.Get("/repos/:owner/:repo/dockerfile", integrationHandler.GetGitHubDockerfile)
 
-	// GET  /api/v1/integrations/github/images          — List GHCR container images
+	// GET  /api/v1/integrations/github/images          — List GHCR container images (?org=true, ?visibility=, ?prefix=, ?with_tags=true)
 	gh.Get("/images", integrationHandler.ListGitHubContainerImages)
 
 	// POST /api/v1/integrations/github/repos/:owner/:repo/issues — Create optimization issue
 	gh.Post("/repos/:owner/:repo/issues", integrationHandler.CreateGitHubIssue)
 
+	// POST /api/v1/integrations/github/images/:owner/:image/tags/:tag/scan — Analyze a GHCR image with the stored PAT
+	gh.Post("/images/:owner/:image/tags/:tag/scan", integrationHandler.ScanGitHubContainerImage)
+
 	// === Docker Hub-specific endpoints ===
 	docker := integrations.Group("/docker")
 
@@ -120,15 +183,26 @@ func setupIntegrationRoutes(api fiber.Router) {
 	// GET /api/v1/integrations/docker/repos/:namespace/:repo/tags   — List tags for a repo
 	docker.Get("/repos/:namespace/:repo/tags", integrationHandler.ListDockerHubTags)
 
+	// POST /api/v1/integrations/docker/repos/:namespace/:repo/tags/:tag/scan — Analyze a tag with the stored credentials
+	docker.Post("/repos/:namespace/:repo/tags/:tag/scan", integrationHandler.ScanDockerHubTag)
+
 	// === Kubernetes-specific endpoints ===
 	k8s := integrations.Group("/kubernetes")
 
 	// GET /api/v1/integrations/kubernetes/status     — In-cluster availability + cluster info
 	k8s.Get("/status", integrationHandler.GetKubernetesStatus)
 
-	// GET /api/v1/integrations/kubernetes/images     — List all container images in the cluster
+	// GET /api/v1/integrations/kubernetes/images     — List container images in the cluster (?include_excluded=true to skip exclusions)
 	k8s.Get("/images", integrationHandler.ListKubernetesImages)
 
+	// POST /api/v1/integrations/kubernetes/scan      — Enqueue analysis jobs for all non-excluded cluster images
+	k8s.Post("/scan", integrationHandler.ScanKubernetesImages)
+
+	// GET /api/v1/integrations/kubernetes/exclusions — Namespace/label exclusions applied to cluster scans
+	// PUT /api/v1/integrations/kubernetes/exclusions — Replace the exclusions
+	k8s.Get("/exclusions", integrationHandler.GetKubernetesExclusions)
+	k8s.Put("/exclusions", integrationHandler.UpdateKubernetesExclusions)
+
 	// GET /api/v1/integrations/kubernetes/namespaces — List all namespaces
 	k8s.Get("/namespaces", integrationHandler.ListKubernetesNamespaces)
 
@@ -140,6 +214,9 @@ func setupIntegrationRoutes(api fiber.Router) {
 
 	// GET /api/v1/integrations/harbor/projects/:project/repos/:repo/artifacts         — List artifacts
 	harbor.Get("/projects/:project/repos/:repo/artifacts", integrationHandler.ListHarborArtifacts)
+
+	// POST /api/v1/integrations/harbor/projects/:project/repos/:repo/artifacts/:ref/scan — Analyze an artifact (tag or digest)
+	harbor.Post("/projects/:project/repos/:repo/artifacts/:ref/scan", integrationHandler.ScanHarborArtifact)
 }
 
 // setupMetricsRoutes configures analytics and metrics endpoints
@@ -148,12 +225,87 @@ func setupMetricsRoutes(api fiber.Router, q queue.Queue) {
 
 	metrics := api.Group("/metrics")
 
-	// GET /api/v1/metrics/queue — Real-time queue statistics
+	// GET /api/v1/metrics/queue?window=1h|6h|24h — Real-time queue statistics
 	metrics.Get("/queue", metricsHandler.GetQueueStats)
 
 	// GET /api/v1/metrics/jobs?time_range=24h|7d|30d — Job metrics and trends
 	metrics.Get("/jobs", metricsHandler.GetJobMetrics)
 
-	// GET /api/v1/metrics/tools — Tool performance metrics
+	// GET /api/v1/metrics/tools?recompute=true — Tool performance metrics (materialized)
 	metrics.Get("/tools", metricsHandler.GetToolPerformance)
+
+	// GET /api/v1/metrics/fleet?limit=10 — Exposure across the latest scan of each image
+	metrics.Get("/fleet", metricsHandler.GetFleetRisk)
+
+	// GET /api/v1/metrics/image-size?image_ref=myapp — Size and efficiency of an image over its scans
+	metrics.Get("/image-size", metricsHandler.GetImageSizeTrend)
+
+	// GET /api/v1/metrics/export?range=30d&format=csv|ndjson — Per-day trends for reporting
+	metrics.Get("/export", metricsHandler.ExportMetrics)
+}
+
+// setupScannerRoutes configures vulnerability scanner maintenance endpoints
+func setupScannerRoutes(api fiber.Router, q queue.Queue) {
+	scannerHandler := handlers.NewScannerHandler(q)
+
+	scanner := api.Group("/scanner")
+
+	// POST /api/v1/scanner/db/refresh — Force a grype vulnerability DB update
+	scanner.Post("/db/refresh", scannerHandler.RefreshDB)
+}
+
+// setupUsageRoutes configures per-user resource usage endpoints
+func setupUsageRoutes(api fiber.Router) {
+	usageHandler := handlers.NewUsageHandler()
+
+	usage := api.Group("/usage")
+
+	// GET /api/v1/usage/storage — Artifact bytes stored vs. quota
+	usage.Get("/storage", usageHandler.Storage)
+}
+
+// setupReportTemplateRoutes configures the per-user AI report template endpoints
+func setupReportTemplateRoutes(api fiber.Router) {
+	templateHandler := handlers.NewReportTemplateHandler()
+
+	// GET    /api/v1/report-template — Effective template (user's own or default)
+	// PUT    /api/v1/report-template — Save a custom template
+	// DELETE /api/v1/report-template — Revert to the default template
+	api.Get("/report-template", templateHandler.Get)
+	api.Put("/report-template", templateHandler.Update)
+	api.Delete("/report-template", templateHandler.Reset)
+}
+
+// setupFlowPreferencesRoutes configures the per-user AI report flow settings
+func setupFlowPreferencesRoutes(api fiber.Router) {
+	prefsHandler := handlers.NewFlowPreferencesHandler()
+
+	// GET /api/v1/flow-preferences — Effective settings (user's own or default)
+	// PUT /api/v1/flow-preferences — Save settings (empty provider_priority reverts)
+	api.Get("/flow-preferences", prefsHandler.Get)
+	api.Put("/flow-preferences", prefsHandler.Update)
+}
+
+// setupWatchRoutes configures the image watch-list endpoints
+func setupWatchRoutes(api fiber.Router, q queue.Queue) {
+	watchHandler := handlers.NewWatchHandler(q)
+
+	watches := api.Group("/watches")
+
+	// GET    /api/v1/watches     — Watched images with their last digest and check
+	// POST   /api/v1/watches     — Watch an image; a digest change starts a scan
+	// DELETE /api/v1/watches/:id — Stop watching
+	watches.Get("/", watchHandler.List)
+	watches.Post("/", watchHandler.Create)
+	watches.Delete("/:id", watchHandler.Delete)
+}
+
+// setupAdminRoutes configures operator-only endpoints (X-Admin-Token required)
+func setupAdminRoutes(api fiber.Router) {
+	adminHandler := handlers.NewAdminHandler()
+
+	admin := api.Group("/admin", handlers.RequireAdmin)
+
+	// GET /api/v1/admin/integrations — All users' integrations (filter by status, integration_id, user_id)
+	admin.Get("/integrations", adminHandler.ListIntegrations)
 }
@@ -2,9 +2,12 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/siddhantprateek/reefline/pkg/storage"
 	"github.com/siddhantprateek/reefline/pkg/tools"
 )
 
@@ -16,9 +19,31 @@ type ScannerStatus struct {
 
 // ScanRequest represents a request to scan images
 type ScanRequest struct {
-	Images []string `json:"images" binding:"required"`
+	Images       []string `json:"images" binding:"required"`
+	Sort         string   `json:"sort,omitempty"`          // "severity" (default), "epss" or "cvss"
+	PackageTypes []string `json:"package_types,omitempty"` // e.g. "os", "python", "javascript", "java", "golang", "gem"; empty means all
+	SummaryOnly  bool     `json:"summary_only,omitempty"`  // Return only the summary counts and status
 }
 
+// Options returns the result options of the request
+func (r ScanRequest) Options() ScanOptions {
+	return ScanOptions{Sort: r.Sort, PackageTypes: r.PackageTypes, SummaryOnly: r.SummaryOnly}
+}
+
+// ScanOptions shape the vulnerabilities returned for a completed scan
+type ScanOptions struct {
+	Sort         string   // See SortSeverity (the default when empty), SortEPSS and SortCVSS
+	PackageTypes []string // Only findings in these ecosystems or syft package types, see tools.PackageTypeFilter
+	SummaryOnly  bool     // Skip the vulnerability list, e.g. for badges and overview screens
+}
+
+// Orders for the vulnerabilities of a scan result
+const (
+	SortSeverity = "severity" // Most severe first; within a severity, fix available first
+	SortEPSS     = "epss"     // Most likely to be exploited first
+	SortCVSS     = "cvss"     // Highest CVSS base score first
+)
+
 // ScanResult represents the result of a vulnerability scan
 type ScanResult struct {
 	Image           string          `json:"image"`
@@ -35,6 +60,8 @@ type Vulnerability struct {
 	PackageName            string                  `json:"packageName"`
 	Version                string                  `json:"version"`
 	FixVersion             string                  `json:"fixVersion"`
+	HasFix                 bool                    `json:"hasFix"`   // A fixed version is available
+	FixState               string                  `json:"fixState"` // "fixed", "not-fixed", "wont-fix" or "unknown"
 	PackageType            string                  `json:"packageType"`
 	DataSource             string                  `json:"dataSource,omitempty"`
 	Description            string                  `json:"description,omitempty"`
@@ -42,6 +69,8 @@ type Vulnerability struct {
 	LastModifiedDate       string                  `json:"lastModifiedDate,omitempty"`
 	CVSSScore              *float64                `json:"cvssScore,omitempty"`
 	CVSSVector             string                  `json:"cvssVector,omitempty"`
+	EPSSScore              *float64                `json:"epssScore,omitempty"`      // Probability of exploitation in the next 30 days (0-1)
+	EPSSPercentile         *float64                `json:"epssPercentile,omitempty"` // Rank of EPSSScore among all scored CVEs (0-1)
 	CWEIDs                 []string                `json:"cweIds,omitempty"`
 	Namespace              string                  `json:"namespace,omitempty"`
 	PURL                   string                  `json:"purl,omitempty"`
@@ -83,15 +112,101 @@ func GetScannerStatus() ScannerStatus {
 
 	return ScannerStatus{
 		Available:   true,
-		Initialized: tools.ImgScanner.IsEnabled(),
+		Initialized: tools.ImgScanner.IsInitialized(),
+	}
+}
+
+// DBStatus describes the currently loaded vulnerability database
+type DBStatus struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Built         string `json:"built"`
+}
+
+// RefreshScannerDB forces a vulnerability DB update on the in-process scanner
+func RefreshScannerDB() (*DBStatus, error) {
+	if tools.ImgScanner == nil {
+		return nil, fmt.Errorf("vulnerability scanner not initialized")
+	}
+
+	built, err := tools.ImgScanner.RefreshDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DBStatus{
+		SchemaVersion: tools.ImgScanner.DBStatus().SchemaVersion,
+		Built:         built.Format(time.RFC3339),
+	}, nil
+}
+
+// dbStatusObject is where the worker publishes its vulnerability DB status in
+// the default bucket, for the API server, which doesn't load the DB itself
+const dbStatusObject = ".system/vulndb-status.json"
+
+// currentDBStatus returns the in-process scanner's DB status, or nil if no DB is loaded
+func currentDBStatus() *DBStatus {
+	if tools.ImgScanner == nil {
+		return nil
+	}
+	status := tools.ImgScanner.DBStatus()
+	if status.Built.IsZero() {
+		return nil
 	}
+	return &DBStatus{
+		SchemaVersion: status.SchemaVersion,
+		Built:         status.Built.Format(time.RFC3339),
+	}
+}
+
+// PublishDBStatus stores the in-process scanner's DB status so GetDBStatus
+// can report it from other processes. A no-op until a DB is loaded.
+func PublishDBStatus(ctx context.Context) error {
+	status := currentDBStatus()
+	if status == nil {
+		return nil
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	ctx = storage.WithArtifactMeta(ctx, storage.ArtifactMeta{Tool: "grype", ToolVersion: tools.ToolVersion("grype")})
+	_, err = storage.PutArtifact(ctx, storage.GetConfigFromEnv().DefaultBucket, dbStatusObject, data, "application/json")
+	return err
+}
+
+// GetDBStatus returns the vulnerability DB status of the in-process scanner,
+// or else the one last published by the worker. Returns nil if neither is known.
+func GetDBStatus(ctx context.Context) (*DBStatus, error) {
+	if status := currentDBStatus(); status != nil {
+		return status, nil
+	}
+
+	bucket := storage.GetConfigFromEnv().DefaultBucket
+	exists, err := storage.Exists(ctx, bucket, dbStatusObject)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := storage.ReadArtifact(ctx, bucket, dbStatusObject)
+	if err != nil {
+		return nil, err
+	}
+	var status DBStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("decoding vulnerability DB status: %w", err)
+	}
+	return &status, nil
 }
 
-// ScanImages initiates vulnerability scans for the provided images
-func ScanImages(ctx context.Context, images []string) ([]ScanResult, error) {
+// ScanImages initiates vulnerability scans for the provided images. opts
+// orders and filters the vulnerabilities of completed scans.
+func ScanImages(ctx context.Context, images []string, opts ScanOptions) ([]ScanResult, error) {
 	if tools.ImgScanner == nil {
 		return nil, fmt.Errorf("vulnerability scanner not initialized")
 	}
+	match, err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
 
 	// Enqueue images for scanning (non-blocking)
 	tools.ImgScanner.Enqueue(ctx, images...)
@@ -102,21 +217,7 @@ func ScanImages(ctx context.Context, images []string) ([]ScanResult, error) {
 	for _, img := range images {
 		scan, found := tools.ImgScanner.GetScan(img)
 		if found && scan != nil {
-			result := ScanResult{
-				Image:           img,
-				Vulnerabilities: convertVulnerabilities(scan),
-				Summary: Summary{
-					Critical: scan.Tally.Critical,
-					High:     scan.Tally.High,
-					Medium:   scan.Tally.Medium,
-					Low:      scan.Tally.Low,
-					Unknown:  scan.Tally.Unknown,
-					Total:    scan.Tally.Total,
-				},
-				ScanTime: time.Now().Format(time.RFC3339),
-				Status:   "completed",
-			}
-			results = append(results, result)
+			results = append(results, completedResult(img, scan, opts, match))
 		} else {
 			// Scan is queued/in progress
 			result := ScanResult{
@@ -131,11 +232,16 @@ func ScanImages(ctx context.Context, images []string) ([]ScanResult, error) {
 	return results, nil
 }
 
-// GetImageScanResults retrieves scan results for a specific image
-func GetImageScanResults(image string) (*ScanResult, error) {
+// GetImageScanResults retrieves scan results for a specific image, with the
+// vulnerabilities ordered and filtered by opts as in ScanImages
+func GetImageScanResults(image string, opts ScanOptions) (*ScanResult, error) {
 	if tools.ImgScanner == nil {
 		return nil, fmt.Errorf("vulnerability scanner not initialized")
 	}
+	match, err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
 
 	scan, found := tools.ImgScanner.GetScan(image)
 	if !found {
@@ -146,36 +252,25 @@ func GetImageScanResults(image string) (*ScanResult, error) {
 		}, nil
 	}
 
-	result := &ScanResult{
-		Image:           image,
-		Vulnerabilities: convertVulnerabilities(scan),
-		Summary: Summary{
-			Critical: scan.Tally.Critical,
-			High:     scan.Tally.High,
-			Medium:   scan.Tally.Medium,
-			Low:      scan.Tally.Low,
-			Unknown:  scan.Tally.Unknown,
-			Total:    scan.Tally.Total,
-		},
-		ScanTime: time.Now().Format(time.RFC3339),
-		Status:   "completed",
-	}
-
-	return result, nil
+	result := completedResult(image, scan, opts, match)
+	return &result, nil
 }
 
-// convertVulnerabilities converts internal scan results to API format
-func convertVulnerabilities(scan *tools.Scan) []Vulnerability {
+// convertVulnerabilities converts internal scan results to API format, keeping
+// the findings whose package type matches and ordering them by sortBy
+func convertVulnerabilities(scan *tools.Scan, sortBy string, match func(packageType string) bool) []Vulnerability {
 	var vulns []Vulnerability
 
 	for i, row := range scan.Table.Rows {
-		if len(row) >= 6 {
+		if len(row) >= 6 && match(row.Type()) {
 			vuln := Vulnerability{
 				ID:          row.Vulnerability(),
 				Severity:    row.Severity(),
 				PackageName: row.Name(),
 				Version:     row.Version(),
 				FixVersion:  row.Fix(),
+				HasFix:      row.HasFix(),
+				FixState:    row.FixState(),
 				PackageType: row.Type(),
 			}
 
@@ -206,9 +301,13 @@ func convertVulnerabilities(scan *tools.Scan) []Vulnerability {
 						vuln.PublishedDate = meta.VulnMetadata.KnownExploited[0].DateAdded.Format("2006-01-02T15:04:05Z")
 					}
 
-					// Add EPSS date if available
+					// Add EPSS score and date if available
 					if len(meta.VulnMetadata.EPSS) > 0 {
-						vuln.LastModifiedDate = meta.VulnMetadata.EPSS[0].Date.Format("2006-01-02T15:04:05Z")
+						epss := meta.VulnMetadata.EPSS[0]
+						score, percentile := epss.EPSS, epss.Percentile
+						vuln.EPSSScore = &score
+						vuln.EPSSPercentile = &percentile
+						vuln.LastModifiedDate = epss.Date.Format("2006-01-02T15:04:05Z")
 					}
 				}
 
@@ -240,5 +339,128 @@ func convertVulnerabilities(scan *tools.Scan) []Vulnerability {
 		}
 	}
 
+	switch sortBy {
+	case SortEPSS:
+		sortByScore(vulns, func(v Vulnerability) *float64 { return v.EPSSScore })
+	case SortCVSS:
+		sortByScore(vulns, func(v Vulnerability) *float64 { return v.CVSSScore })
+	default:
+		prioritizeVulnerabilities(vulns)
+	}
 	return vulns
 }
+
+// completedResult builds the result of a completed scan of image. With
+// opts.SummaryOnly only the counts are computed, without converting findings.
+func completedResult(image string, scan *tools.Scan, opts ScanOptions, match func(packageType string) bool) ScanResult {
+	result := ScanResult{
+		Image:    image,
+		Summary:  summarize(scan, opts, match),
+		ScanTime: time.Now().Format(time.RFC3339),
+		Status:   "completed",
+	}
+	if !opts.SummaryOnly {
+		result.Vulnerabilities = convertVulnerabilities(scan, opts.Sort, match)
+	}
+	return result
+}
+
+// summarize counts the findings of scan whose package type matches, by
+// severity. Without a package-type filter the scan's own tally is used.
+func summarize(scan *tools.Scan, opts ScanOptions, match func(packageType string) bool) Summary {
+	if len(opts.PackageTypes) == 0 {
+		return Summary{
+			Critical: scan.Tally.Critical,
+			High:     scan.Tally.High,
+			Medium:   scan.Tally.Medium,
+			Low:      scan.Tally.Low,
+			Unknown:  scan.Tally.Unknown,
+			Total:    scan.Tally.Total,
+		}
+	}
+
+	var s Summary
+	for _, row := range scan.Table.Rows {
+		if len(row) < 6 || !match(row.Type()) {
+			continue
+		}
+		switch row.Severity() {
+		case "Critical":
+			s.Critical++
+		case "High":
+			s.High++
+		case "Medium":
+			s.Medium++
+		case "Low":
+			s.Low++
+		default:
+			s.Unknown++
+		}
+		s.Total++
+	}
+	return s
+}
+
+// validate checks opts, returning the package-type predicate for convertVulnerabilities
+func (opts ScanOptions) validate() (func(packageType string) bool, error) {
+	if err := validateSort(opts.Sort); err != nil {
+		return nil, err
+	}
+	return tools.PackageTypeFilter(opts.PackageTypes)
+}
+
+// validateSort checks a vulnerability sort order; empty means SortSeverity
+func validateSort(sortBy string) error {
+	switch sortBy {
+	case "", SortSeverity, SortEPSS, SortCVSS:
+		return nil
+	}
+	return fmt.Errorf("invalid sort %q: want %s, %s or %s", sortBy, SortSeverity, SortEPSS, SortCVSS)
+}
+
+// severityOrder ranks grype severities, most severe first
+var severityOrder = map[string]int{
+	"Critical":   0,
+	"High":       1,
+	"Medium":     2,
+	"Low":        3,
+	"Negligible": 4,
+	"Unknown":    5,
+}
+
+// severityRank returns the sort rank of severity; unrecognized ones sort last
+func severityRank(severity string) int {
+	if rank, ok := severityOrder[severity]; ok {
+		return rank
+	}
+	return len(severityOrder)
+}
+
+// prioritizeVulnerabilities orders vulns by severity and, within a severity,
+// puts those with a fix available first: they are the ones to act on first.
+func prioritizeVulnerabilities(vulns []Vulnerability) {
+	sort.SliceStable(vulns, func(i, j int) bool {
+		ri, rj := severityRank(vulns[i].Severity), severityRank(vulns[j].Severity)
+		if ri != rj {
+			return ri < rj
+		}
+		return vulns[i].HasFix && !vulns[j].HasFix
+	})
+}
+
+// sortByScore orders vulns by score, highest first. Vulnerabilities without
+// a score sort last; ties keep the severity order.
+func sortByScore(vulns []Vulnerability, score func(Vulnerability) *float64) {
+	prioritizeVulnerabilities(vulns)
+	sort.SliceStable(vulns, func(i, j int) bool {
+		si, sj := score(vulns[i]), score(vulns[j])
+		switch {
+		case si == nil:
+			return false
+		case sj == nil:
+			return true
+		default:
+			return *si > *sj
+		}
+	})
+}
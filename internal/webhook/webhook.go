@@ -0,0 +1,82 @@
+// Package webhook delivers JSON event notifications to operator-configured URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout bounds each delivery
+const Timeout = 10 * time.Second
+
+// ParseURLs splits a comma-separated URL list, dropping blanks
+func ParseURLs(v string) []string {
+	var urls []string
+	for _, u := range strings.Split(v, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Send POSTs {"event": event, "timestamp": ..., ...payload} to every URL.
+// A non-empty token is sent as a Bearer Authorization header. Failures are
+// logged, not returned, so a broken receiver never blocks the caller.
+func Send(urls []string, token, event string, payload map[string]interface{}) {
+	if len(urls) == 0 {
+		return
+	}
+	body := map[string]interface{}{
+		"event":     event,
+		"timestamp": time.Now().UTC(),
+	}
+	for k, v := range payload {
+		body[k] = v
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("[Webhook] failed to encode %s event: %v", event, err)
+		return
+	}
+	for _, url := range urls {
+		if err := post(url, token, raw); err != nil {
+			log.Printf("[Webhook] %s delivery to %s failed: %v", event, url, err)
+		}
+	}
+}
+
+// post delivers a JSON body to url
+func post(url, token string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "reefline")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// baselineCandidates bounds how many earlier jobs are checked for a baseline
+const baselineCandidates = 20
+
+// BaselineDelta compares a job with the most recent earlier completed job for
+// the same image repository. It is stored as baseline.json for the report.
+type BaselineDelta struct {
+	BaselineJobID       string                 `json:"baseline_job_id"`
+	BaselineImageRef    string                 `json:"baseline_image_ref"`
+	BaselineCompletedAt time.Time              `json:"baseline_completed_at"`
+	NewCVEs             []tools.SummaryFinding `json:"new_cves"`             // In this scan, not the baseline
+	FixedCVEs           []tools.SummaryFinding `json:"fixed_cves"`           // In the baseline, not this scan
+	CVEsCompared        bool                   `json:"cves_compared"`        // False when either side has no grype result
+	SizeBytes           *BaselineSize          `json:"size_bytes,omitempty"` // Nil when either size is unknown
+}
+
+// BaselineSize is the image size of both jobs
+type BaselineSize struct {
+	Baseline int64 `json:"baseline"`
+	Current  int64 `json:"current"`
+	Delta    int64 `json:"delta"`
+}
+
+// imageRepository strips the tag and digest from an image reference, so all
+// builds of one image ("digest family") compare against each other.
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	// A colon after the last slash is a tag; before it, a registry port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// findBaseline returns the most recent completed job of the same user for
+// the same image repository as job, or nil if there is none. A scheduled job
+// compares against the previous scheduled run only.
+func findBaseline(ctx context.Context, job *models.Job) (*models.Job, error) {
+	repo := imageRepository(job.ImageRef)
+	if repo == "" {
+		return nil, nil
+	}
+
+	// LIKE narrows the candidates; imageRepository decides, since "_" in
+	// image names is a LIKE wildcard
+	q := database.DB.WithContext(ctx).
+		Where("user_id = ? AND job_id <> ? AND status = ? AND completed_at IS NOT NULL", job.UserID, job.JobID, models.JobStatusCompleted).
+		Where("image_ref = ? OR image_ref LIKE ? OR image_ref LIKE ?", repo, repo+":%", repo+"@%")
+	if job.Scheduled {
+		q = q.Where("scheduled = ?", true)
+	}
+	var candidates []models.Job
+	if err := q.Order("completed_at DESC").
+		Limit(baselineCandidates).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if imageRepository(candidates[i].ImageRef) == repo {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// storedScan is the part of a stored grype.json the baseline compares
+type storedScan struct {
+	Table *struct {
+		Rows [][]string
+	}
+}
+
+// findingKey identifies a finding across scans
+func findingKey(f tools.SummaryFinding) string {
+	return f.Vulnerability + "|" + f.Package
+}
+
+// scanFindings lists every finding of a grype scan
+func scanFindings(scan *tools.Scan) []tools.SummaryFinding {
+	if scan == nil || scan.Table == nil {
+		return nil
+	}
+	findings := make([]tools.SummaryFinding, 0, len(scan.Table.Rows))
+	for _, r := range scan.Table.Rows {
+		findings = append(findings, tools.SummaryFinding{
+			Vulnerability: r.Vulnerability(),
+			Package:       r.Name(),
+			Version:       r.Version(),
+			Fix:           r.Fix(),
+			Type:          r.Type(),
+			Severity:      r.Severity(),
+			HasFix:        r.HasFix(),
+		})
+	}
+	return findings
+}
+
+// loadBaselineFindings reads the findings of the baseline job's grype.json.
+// Returns nil, nil if the baseline has no grype result.
+func loadBaselineFindings(ctx context.Context, baseline *models.Job) ([]tools.SummaryFinding, error) {
+	loc := storage.ArtifactLocation(baseline.UserID, baseline.JobID)
+	exists, err := storage.Exists(ctx, loc.Bucket, loc.Key("grype.json"))
+	if err != nil || !exists {
+		return nil, err
+	}
+	a, err := storage.OpenArtifact(ctx, loc.Bucket, loc.Key("grype.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	var stored storedScan
+	if err := json.NewDecoder(a).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("decoding baseline grype.json: %w", err)
+	}
+	if stored.Table == nil {
+		return []tools.SummaryFinding{}, nil
+	}
+	findings := make([]tools.SummaryFinding, 0, len(stored.Table.Rows))
+	for _, r := range stored.Table.Rows {
+		findings = append(findings, tools.SummaryFinding{
+			Vulnerability: at(r, 4),
+			Package:       at(r, 0),
+			Version:       at(r, 1),
+			Fix:           at(r, 2),
+			Type:          at(r, 3),
+			Severity:      at(r, 5),
+			HasFix:        tools.FixAvailable(at(r, 2)),
+		})
+	}
+	return findings, nil
+}
+
+// at returns r[i], or "" when r is too short
+func at(r []string, i int) string {
+	if i < len(r) {
+		return r[i]
+	}
+	return ""
+}
+
+// diffFindings returns the findings only in current and those only in baseline
+func diffFindings(current, baseline []tools.SummaryFinding) (added, fixed []tools.SummaryFinding) {
+	inCurrent := make(map[string]bool, len(current))
+	for _, f := range current {
+		inCurrent[findingKey(f)] = true
+	}
+	inBaseline := make(map[string]bool, len(baseline))
+	for _, f := range baseline {
+		inBaseline[findingKey(f)] = true
+	}
+
+	added, fixed = []tools.SummaryFinding{}, []tools.SummaryFinding{}
+	for _, f := range current {
+		if !inBaseline[findingKey(f)] {
+			added = append(added, f)
+		}
+	}
+	for _, f := range baseline {
+		if !inCurrent[findingKey(f)] {
+			fixed = append(fixed, f)
+		}
+	}
+	return added, fixed
+}
+
+// metadataImageSize sums the layer sizes of a job's stored inspection
+func metadataImageSize(metadata string) (int64, bool) {
+	if metadata == "" {
+		return 0, false
+	}
+	var inspect tools.InspectResult
+	if err := json.Unmarshal([]byte(metadata), &inspect); err != nil || len(inspect.Layers) == 0 {
+		return 0, false
+	}
+	var size int64
+	for _, l := range inspect.Layers {
+		size += l.Size
+	}
+	return size, true
+}
+
+// compareWithBaseline finds the job's baseline, writes the delta as
+// baseline.json and records the baseline on the job. scan is this job's grype
+// result (nil if grype didn't run). Returns the bytes stored; 0 with a nil
+// error when the image was never scanned before.
+func compareWithBaseline(ctx context.Context, loc storage.Location, jobID string, scan *tools.Scan) (int64, error) {
+	var job models.Job
+	if err := database.DB.WithContext(ctx).Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return 0, err
+	}
+	baseline, err := findBaseline(ctx, &job)
+	if err != nil || baseline == nil {
+		return 0, err
+	}
+
+	delta := BaselineDelta{
+		BaselineJobID:    baseline.JobID,
+		BaselineImageRef: baseline.ImageRef,
+		NewCVEs:          []tools.SummaryFinding{},
+		FixedCVEs:        []tools.SummaryFinding{},
+	}
+	if baseline.CompletedAt != nil {
+		delta.BaselineCompletedAt = *baseline.CompletedAt
+	}
+
+	if scan != nil {
+		baselineFindings, err := loadBaselineFindings(ctx, baseline)
+		if err != nil {
+			jobErrorf(ctx, "[Worker] Failed to read baseline %s grype result: %v", baseline.JobID, err)
+		} else if baselineFindings != nil {
+			delta.NewCVEs, delta.FixedCVEs = diffFindings(scanFindings(scan), baselineFindings)
+			delta.CVEsCompared = true
+		}
+	}
+
+	current, okCurrent := metadataImageSize(job.Metadata)
+	previous, okPrevious := metadataImageSize(baseline.Metadata)
+	if okCurrent && okPrevious {
+		delta.SizeBytes = &BaselineSize{Baseline: previous, Current: current, Delta: current - previous}
+	}
+
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		return 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("baseline.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, deltaJSON, "application/json")
+	if err != nil {
+		return 0, err
+	}
+	if err := database.DB.WithContext(ctx).Model(&models.Job{}).Where("job_id = ?", job.JobID).
+		Update("baseline_job_id", baseline.JobID).Error; err != nil {
+		jobErrorf(ctx, "[Worker] Failed to record baseline for job %s: %v", job.JobID, err)
+	}
+
+	jobLogf(ctx, "[Worker] Uploaded baseline.json to %s/%s (vs %s: %d new, %d fixed CVEs)",
+		bucket, objectName, baseline.JobID, len(delta.NewCVEs), len(delta.FixedCVEs))
+
+	if job.Scheduled && delta.CVEsCompared {
+		notifyNewVulnerabilities(&job, &delta)
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	scannertools "github.com/siddhantprateek/reefline/internal/tools"
+	"github.com/siddhantprateek/reefline/internal/webhook"
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// EventDBUpdated is the event name sent when a newer vulnerability DB is installed
+const EventDBUpdated = "vulnerability_db.updated"
+
+// EventNewVulnerabilities is the event name sent when a scheduled rescan finds
+// Critical or High CVEs its image's previous scheduled run didn't have
+const EventNewVulnerabilities = "scan.new_vulnerabilities"
+
+// DBUpdateWebhooks are the URLs notified on EventDBUpdated, read from
+// VULN_DB_WEBHOOK_URLS (comma-separated) by the worker on startup.
+var DBUpdateWebhooks []string
+
+// ScanDiffWebhooks are the URLs notified on EventNewVulnerabilities, read from
+// SCAN_DIFF_WEBHOOK_URLS (comma-separated) by the worker on startup.
+var ScanDiffWebhooks []string
+
+// HandleDBUpdate is registered with the image scanner. It logs the update and
+// POSTs it to every configured webhook so scheduled rescans can be triggered.
+func HandleDBUpdate(ev tools.DBUpdateEvent) {
+	log.Printf("[Worker] Vulnerability DB updated: %s -> %s",
+		ev.PreviousBuilt.Format(time.RFC3339), ev.Built.Format(time.RFC3339))
+
+	// Keep the DB build date reported by GET /api/v1/version current
+	if err := scannertools.PublishDBStatus(context.Background()); err != nil {
+		log.Printf("[Worker] Failed to publish vulnerability DB status: %v", err)
+	}
+
+	webhook.Send(DBUpdateWebhooks, os.Getenv("VULN_DB_WEBHOOK_TOKEN"), EventDBUpdated, map[string]interface{}{
+		"previous_built": ev.PreviousBuilt,
+		"built":          ev.Built,
+		"schema_version": ev.SchemaVersion,
+	})
+}
+
+// notifyNewVulnerabilities POSTs the Critical and High findings a scheduled
+// job added over its baseline to ScanDiffWebhooks. Nothing is sent when there
+// are none, so receivers are only paged when an image becomes newly vulnerable.
+func notifyNewVulnerabilities(job *models.Job, delta *BaselineDelta) {
+	newCVEs := []tools.SummaryFinding{}
+	for _, f := range delta.NewCVEs {
+		if f.Severity == "Critical" || f.Severity == "High" {
+			newCVEs = append(newCVEs, f)
+		}
+	}
+	if len(newCVEs) == 0 {
+		log.Printf("[Worker] Scheduled job %s: no new Critical/High CVEs since %s", job.JobID, delta.BaselineJobID)
+		return
+	}
+	log.Printf("[Worker] Scheduled job %s: %d new Critical/High CVEs since %s", job.JobID, len(newCVEs), delta.BaselineJobID)
+
+	webhook.Send(ScanDiffWebhooks, os.Getenv("SCAN_DIFF_WEBHOOK_TOKEN"), EventNewVulnerabilities, map[string]interface{}{
+		"job_id":                job.JobID,
+		"image_ref":             job.ImageRef,
+		"baseline_job_id":       delta.BaselineJobID,
+		"baseline_image_ref":    delta.BaselineImageRef,
+		"baseline_completed_at": delta.BaselineCompletedAt,
+		"new_cves":              newCVEs,
+		"fixed_cves":            len(delta.FixedCVEs),
+	})
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// failureMarkers map error text to a failure category, checked in order. The
+// scanners wrap registry and network errors as text, so matching the message
+// is the only way to tell them apart.
+var failureMarkers = []struct {
+	category string
+	markers  []string
+}{
+	{models.FailureRegistryAuth, []string{"unauthorized", "authentication required", "denied", "forbidden", "401", "403"}},
+	{models.FailureImageNotFound, []string{"manifest unknown", "name unknown", "not found", "no such image", "404"}},
+	{models.FailureScanTimeout, []string{"deadline exceeded", "timed out", "timeout"}},
+	{models.FailureNetwork, []string{"connection refused", "connection reset", "no such host", "network is unreachable", "eof"}},
+}
+
+// classifyFailure maps a tool error to one of the models.Failure* categories.
+// A nil error has none.
+func classifyFailure(err error) string {
+	if err == nil {
+		return ""
+	}
+	var timeout *tools.TimeoutError
+	if errors.As(err, &timeout) || errors.Is(err, context.DeadlineExceeded) {
+		return models.FailureScanTimeout
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range failureMarkers {
+		for _, marker := range m.markers {
+			if strings.Contains(msg, marker) {
+				return m.category
+			}
+		}
+	}
+	return models.FailureUnknown
+}
@@ -4,26 +4,41 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/minio/minio-go/v7"
+	"github.com/siddhantprateek/reefline/internal/flows"
+	"github.com/siddhantprateek/reefline/internal/integration/harbor"
+	"github.com/siddhantprateek/reefline/internal/integration/registry"
+	scannertools "github.com/siddhantprateek/reefline/internal/tools"
 	"github.com/siddhantprateek/reefline/pkg/database"
 	"github.com/siddhantprateek/reefline/pkg/models"
+	"github.com/siddhantprateek/reefline/pkg/scoring"
 	"github.com/siddhantprateek/reefline/pkg/storage"
 	"github.com/siddhantprateek/reefline/pkg/tools"
+	"github.com/siddhantprateek/reefline/pkg/version"
 )
 
 type AnalyzeJobPayload struct {
-	JobID      string      `json:"job_id"`
-	Dockerfile string      `json:"dockerfile"`
-	ImageRef   string      `json:"image_ref"`
-	AppContext string      `json:"app_context"`
-	SkopeoMeta interface{} `json:"skopeo_meta,omitempty"` // Keep as interface{} to avoid circular dep if tools not wanted here, or use tools.InspectResult
+	JobID         string              `json:"job_id"`
+	Dockerfile    string              `json:"dockerfile"`
+	ImageRef      string              `json:"image_ref"`
+	AppContext    string              `json:"app_context"`
+	AnalysisType  models.AnalysisType `json:"analysis_type"`                  // Empty means full
+	Tools         map[string]bool     `json:"tools,omitempty"`                // Per-tool override of the analysis type
+	TimeoutSecs   int                 `json:"timeout_seconds,omitempty"`      // Per-tool timeout override
+	MatchProfile  string              `json:"match_profile,omitempty"`        // Grype CPE matching profile; empty means default
+	RegistryID    string              `json:"registry_integration,omitempty"` // Connected registry to pull with
+	HarborScan    *harbor.ArtifactRef `json:"harbor_scan,omitempty"`          // Import Harbor's scan report instead of running grype
+	SourceArchive string              `json:"source_archive,omitempty"`       // Uploaded source tree to scan instead of the image
+	AllPlatforms  bool                `json:"all_platforms,omitempty"`        // Also scan the other platforms of a manifest list
+	SkopeoMeta    interface{}         `json:"skopeo_meta,omitempty"`          // Keep as interface{} to avoid circular dep if tools not wanted here, or use tools.InspectResult
 }
 
 // ProcessAnalyzeJob handles the image analysis workflow
@@ -47,7 +62,43 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 		return nil
 	}
 
-	log.Printf("[Worker] Starting analysis job %s for image: %s", data.JobID, target)
+	ctx = tools.WithTimeout(ctx, time.Duration(data.TimeoutSecs)*time.Second)
+	ctx = tools.WithMatchProfile(ctx, data.MatchProfile)
+	// Tag every artifact with the job and image; the tool sections below narrow
+	// the tool, and what's left (score, baseline, ...) is Reefline's own output
+	ctx = storage.WithArtifactMeta(ctx, storage.ArtifactMeta{
+		JobID:       data.JobID,
+		Tool:        "reefline",
+		ToolVersion: version.Version,
+		Image:       target,
+	})
+	// Lines logged with ctx from here on are also kept in the job's logs.jsonl
+	ctx, jl := withJobLog(ctx)
+
+	// The owner determines the artifact location and registry credentials;
+	// the metadata carries the API server's inspection (signature check)
+	var job models.Job
+	if err := database.DB.Select("user_id", "metadata", "started_at").Where("job_id = ?", data.JobID).First(&job).Error; err != nil {
+		jobErrorf(ctx, "[Worker] Failed to load owner of job %s: %v", data.JobID, err)
+	}
+
+	// Pull private images with the owner's connected registry credentials
+	var registryCred *registry.Credential
+	if data.RegistryID != "" {
+		if cred, err := registry.Lookup(job.UserID, data.RegistryID); err != nil {
+			jobLogf(ctx, "[Worker] Registry credentials unavailable for job %s: %v — pulling anonymously", data.JobID, err)
+		} else {
+			registryCred = cred
+			ctx = tools.WithImageAuth(ctx, &cred.Auth)
+		}
+	}
+
+	analysisType := data.AnalysisType
+	if analysisType == "" {
+		analysisType = models.AnalysisTypeFull
+	}
+
+	jobLogf(ctx, "[Worker] Starting %s analysis job %s for image: %s", analysisType, data.JobID, target)
 
 	// Update Job status to RUNNING and set StartedAt timestamp
 	startedAt := time.Now()
@@ -56,11 +107,46 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 		"progress":   0,
 		"started_at": startedAt,
 	}).Error; err != nil {
-		log.Printf("[Worker] Failed to update job status to RUNNING: %v", err)
+		jobErrorf(ctx, "[Worker] Failed to update job status to RUNNING: %v", err)
 	}
+	// A job that already started was redelivered after its worker went away
+	if job.StartedAt != nil {
+		jobEvent(ctx, data.JobID, models.JobEventRetried, fmt.Sprintf("previous attempt started %s", job.StartedAt.UTC().Format(time.RFC3339)))
+	}
+	jobEvent(ctx, data.JobID, models.JobEventStarted, string(analysisType))
 
-	bucket := storage.GetConfigFromEnv().DefaultBucket
-	hasErrors := false
+	loc := storage.ArtifactLocation(job.UserID, data.JobID)
+	bucket := loc.Bucket
+	if err := storage.EnsureBucket(ctx, bucket); err != nil {
+		jobErrorf(ctx, "[Worker] Failed to prepare bucket %s: %v", bucket, err)
+	}
+	jl.start(ctx, loc)
+	defer jl.stop(ctx)
+
+	// Each scan removes its own temp files; sweep what crashed or abandoned
+	// scans left behind and log how full the scratch disk is
+	if tools.Scratch != nil {
+		defer func() {
+			tools.Scratch.Sweep()
+			tools.Scratch.LogUsage("job " + data.JobID)
+		}()
+	}
+
+	// Tools that ran but failed (or whose result couldn't be stored). One failing
+	// tool only degrades the job; the report is still generated from the rest.
+	var failedTools []string
+	succeeded := 0
+	var storedBytes int64 // Counted against the owner's storage quota
+	var scoreInput scoring.Input
+	scoreInput.Signature = inspectedSignature(job.Metadata)
+
+	// The build history from the image config shows the commands behind each
+	// layer, which the report uses when the job has no Dockerfile
+	if n, err := uploadHistory(toolContext(ctx, "skopeo"), loc, job.Metadata); err != nil {
+		jobErrorf(ctx, "[Worker] Failed to upload history.json: %v", err)
+	} else {
+		storedBytes += n
+	}
 
 	// Initialize tool metrics map
 	type ToolMetric struct {
@@ -68,19 +154,139 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 		CompletedAt string `json:"completed_at"`
 		DurationMs  int64  `json:"duration_ms"`
 		Success     bool   `json:"success"`
+		Skipped     bool   `json:"skipped,omitempty"`     // Didn't run, see SkipReason
+		SkipReason  string `json:"skip_reason,omitempty"` // models.SkipAnalysisType, SkipRequest, SkipDisabled or SkipImported
 		Error       string `json:"error,omitempty"`
+		// ErrorCategory classifies Error, e.g. models.FailureRegistryAuth
+		ErrorCategory string `json:"error_category,omitempty"`
 	}
 	toolMetrics := make(map[string]ToolMetric)
 
+	// The first failure is the job's error_message and failure_category
+	var failureMessage, failureCategory string
+	fail := func(tool string, err error, category string) {
+		failedTools = append(failedTools, tool)
+		if failureMessage == "" {
+			failureMessage = fmt.Sprintf("%s: %v", tool, err)
+			failureCategory = category
+		}
+	}
+
+	runs := func(tool string) bool {
+		return analysisType.RunsWith(tool, data.Tools) && toolEnabled(tool)
+	}
+	for _, tool := range models.AnalysisTools {
+		switch {
+		case !analysisType.RunsWith(tool, data.Tools):
+			reason := models.SkipAnalysisType
+			if _, ok := data.Tools[tool]; ok {
+				reason = models.SkipRequest
+			}
+			toolMetrics[tool] = ToolMetric{Skipped: true, SkipReason: reason}
+		case !toolEnabled(tool):
+			toolMetrics[tool] = ToolMetric{Skipped: true, SkipReason: models.SkipDisabled}
+		}
+	}
+
+	// 0. Generate SBOM only (syft cataloging, no vulnerability matching)
+	if runs("sbom") {
+		ctx := toolContext(ctx, "sbom")
+		jobLogf(ctx, "[Worker] Generating SBOM for %s...", target)
+		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 10)
+
+		jobEvent(ctx, data.JobID, models.JobEventToolStarted, "sbom")
+		sbomStart := time.Now()
+		sbomJSON, licenses, err := tools.ImgScanner.GenerateSBOM(ctx, target)
+		sbomEnd := time.Now()
+		toolCompleted(ctx, data.JobID, "sbom", sbomEnd.Sub(sbomStart), err)
+
+		toolMetrics["sbom"] = ToolMetric{
+			StartedAt:   sbomStart.Format(time.RFC3339),
+			CompletedAt: sbomEnd.Format(time.RFC3339),
+			DurationMs:  sbomEnd.Sub(sbomStart).Milliseconds(),
+			Success:     err == nil,
+			Error: func() string {
+				if err != nil {
+					return err.Error()
+				}
+				return ""
+			}(),
+			ErrorCategory: classifyFailure(err),
+		}
+
+		if err != nil {
+			jobErrorf(ctx, "[Worker] SBOM generation failed: %v", err)
+			fail("sbom", err, classifyFailure(err))
+		} else {
+			objectName := loc.Key("sbom.json")
+
+			n, err := storage.PutArtifact(ctx, bucket, objectName, sbomJSON, "application/json")
+			if err != nil {
+				jobErrorf(ctx, "[Worker] Failed to upload sbom.json: %v", err)
+				fail("sbom", err, models.FailureStorage)
+			} else {
+				jobLogf(ctx, "[Worker] Uploaded sbom.json to %s/%s", bucket, objectName)
+				succeeded++
+				storedBytes += n
+			}
+			if n, err := uploadLicenses(ctx, loc, licenses); err != nil {
+				jobErrorf(ctx, "[Worker] Failed to upload licenses.json: %v", err)
+			} else {
+				storedBytes += n
+			}
+		}
+		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 95)
+	}
+
+	// 1a. Import Harbor's own scan report when requested, skipping grype
+	runGrype := runs("grype")
+	if runGrype && data.HarborScan != nil {
+		if registryCred == nil || registryCred.IntegrationID != "harbor" {
+			jobLogf(ctx, "[Worker] Harbor credentials unavailable for job %s, running grype instead", data.JobID)
+		} else {
+			importStart := time.Now()
+			n, imported, err := importHarborScan(ctx, loc, registryCred, data.HarborScan)
+			switch {
+			case errors.Is(err, harbor.ErrNoScanReport):
+				jobLogf(ctx, "[Worker] Harbor has no scan report for %s, running grype instead", target)
+			case err != nil:
+				jobErrorf(ctx, "[Worker] Failed to import Harbor scan for %s: %v — running grype instead", target, err)
+			default:
+				importEnd := time.Now()
+				toolMetrics["harbor_scan"] = ToolMetric{
+					StartedAt:   importStart.Format(time.RFC3339),
+					CompletedAt: importEnd.Format(time.RFC3339),
+					DurationMs:  importEnd.Sub(importStart).Milliseconds(),
+					Success:     true,
+				}
+				toolMetrics["grype"] = ToolMetric{Skipped: true, SkipReason: models.SkipImported}
+				succeeded++
+				storedBytes += n
+				scoreInput.Imported = imported
+				runGrype = false
+				database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 35)
+			}
+		}
+	}
+
 	// 1. Run Grype Scan
-	if tools.ImgScanner != nil && tools.ImgScanner.IsEnabled() {
-		log.Printf("[Worker] Running Grype scan for %s...", target)
+	if runGrype {
+		ctx := toolContext(ctx, "grype")
+		jobLogf(ctx, "[Worker] Running Grype scan for %s...", target)
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 10)
 
+		jobEvent(ctx, data.JobID, models.JobEventToolStarted, "grype")
 		grypeStart := time.Now()
-		scanResult, err := tools.ImgScanner.ScanImage(ctx, target)
+		var scanResult *tools.Scan
+		var err error
+		if data.SourceArchive != "" {
+			scanResult, err = scanSource(ctx, bucket, data.SourceArchive, target)
+		} else {
+			scanResult, err = tools.ImgScanner.ScanImage(ctx, target)
+		}
 		grypeEnd := time.Now()
 		grypeDuration := grypeEnd.Sub(grypeStart)
+		toolCompleted(ctx, data.JobID, "grype", grypeDuration, err)
 
 		toolMetrics["grype"] = ToolMetric{
 			StartedAt:   grypeStart.Format(time.RFC3339),
@@ -93,39 +299,66 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 				}
 				return ""
 			}(),
+			ErrorCategory: classifyFailure(err),
 		}
 
 		if err != nil {
-			log.Printf("[Worker] Grype scan failed: %v", err)
-			hasErrors = true
+			jobErrorf(ctx, "[Worker] Grype scan failed: %v", err)
+			fail("grype", err, classifyFailure(err))
 		} else {
-			// Upload Grype result (processed in next step or by LLM)
-			resultJSON, _ := json.Marshal(scanResult)
-			reader := bytes.NewReader(resultJSON)
-			objectName := fmt.Sprintf("%s/artifacts/grype.json", data.JobID)
-
-			_, err := storage.Client.PutObject(ctx, bucket, objectName, reader, int64(len(resultJSON)), minio.PutObjectOptions{
-				ContentType: "application/json",
-			})
+			// Upload Grype result (processed in next step or by LLM), streamed
+			// since results for big images can run to hundreds of MB
+			objectName := loc.Key("grype.json")
+
+			n, size, err := storage.PutJSONArtifact(ctx, bucket, objectName, scanResult)
 			if err != nil {
-				log.Printf("[Worker] Failed to upload grype.json: %v", err)
-				hasErrors = true
+				jobErrorf(ctx, "[Worker] Failed to upload grype.json: %v", err)
+				fail("grype", err, models.FailureStorage)
+			} else {
+				jobLogf(ctx, "[Worker] Uploaded grype.json to %s/%s", bucket, objectName)
+				succeeded++
+				scoreInput.Grype = scanResult
+				storedBytes += n
+
+				// Large results get a condensed copy so the report doesn't page through MBs of JSON
+				if GrypeSummaryThreshold > 0 && size > GrypeSummaryThreshold {
+					if n, err := uploadGrypeSummary(ctx, loc, scanResult); err != nil {
+						jobErrorf(ctx, "[Worker] Failed to upload grype.summary.json: %v", err)
+					} else {
+						storedBytes += n
+					}
+				}
+			}
+			if n, err := uploadLicenses(ctx, loc, scanResult.Licenses); err != nil {
+				jobErrorf(ctx, "[Worker] Failed to upload licenses.json: %v", err)
 			} else {
-				log.Printf("[Worker] Uploaded grype.json to %s/%s", bucket, objectName)
+				storedBytes += n
+			}
+			// The other platforms only add platforms.json; the score stays on
+			// the default platform's result
+			if data.AllPlatforms && data.SourceArchive == "" {
+				n, err := scanPlatforms(ctx, loc, target, scanResult)
+				if err != nil {
+					jobErrorf(ctx, "[Worker] Multi-platform scan failed: %v", err)
+				}
+				storedBytes += n
 			}
 		}
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 35)
 	}
 
 	// 2. Run Dockle Scan
-	if tools.DockleScn != nil && tools.DockleScn.IsEnabled() {
-		log.Printf("[Worker] Running Dockle scan for %s...", target)
+	if runs("dockle") {
+		ctx := toolContext(ctx, "dockle")
+		jobLogf(ctx, "[Worker] Running Dockle scan for %s...", target)
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 40)
 
+		jobEvent(ctx, data.JobID, models.JobEventToolStarted, "dockle")
 		dockleStart := time.Now()
 		dockleResult, err := tools.DockleScn.ScanImage(ctx, target)
 		dockleEnd := time.Now()
 		dockleDuration := dockleEnd.Sub(dockleStart)
+		toolCompleted(ctx, data.JobID, "dockle", dockleDuration, err)
 
 		toolMetrics["dockle"] = ToolMetric{
 			StartedAt:   dockleStart.Format(time.RFC3339),
@@ -138,39 +371,50 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 				}
 				return ""
 			}(),
+			ErrorCategory: classifyFailure(err),
 		}
 
 		if err != nil {
-			log.Printf("[Worker] Dockle scan failed: %v", err)
-			hasErrors = true
+			jobErrorf(ctx, "[Worker] Dockle scan failed: %v", err)
+			fail("dockle", err, classifyFailure(err))
 		} else {
 			// Upload Dockle result
-			resultJSON, _ := json.Marshal(dockleResult)
-			reader := bytes.NewReader(resultJSON)
-			objectName := fmt.Sprintf("%s/artifacts/dockle.json", data.JobID)
+			objectName := loc.Key("dockle.json")
 
-			_, err := storage.Client.PutObject(ctx, bucket, objectName, reader, int64(len(resultJSON)), minio.PutObjectOptions{
-				ContentType: "application/json",
-			})
+			n, _, err := storage.PutJSONArtifact(ctx, bucket, objectName, dockleResult)
 			if err != nil {
-				log.Printf("[Worker] Failed to upload dockle.json: %v", err)
-				hasErrors = true
+				jobErrorf(ctx, "[Worker] Failed to upload dockle.json: %v", err)
+				fail("dockle", err, models.FailureStorage)
 			} else {
-				log.Printf("[Worker] Uploaded dockle.json to %s/%s", bucket, objectName)
+				jobLogf(ctx, "[Worker] Uploaded dockle.json to %s/%s", bucket, objectName)
+				succeeded++
+				scoreInput.Dockle = dockleResult
+				storedBytes += n
 			}
 		}
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 65)
 	}
 
 	// 3. Run Dive Analysis
-	if tools.DiveAnalyzer != nil && tools.DiveAnalyzer.IsEnabled() {
-		log.Printf("[Worker] Running Dive analysis for %s...", target)
+	if runs("dive") {
+		ctx := toolContext(ctx, "dive")
+		jobLogf(ctx, "[Worker] Running Dive analysis for %s...", target)
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 70)
 
+		// Hand the server's inspection to dive so a remote pull reuses its manifest/config
+		var inspectMeta *tools.InspectResult
+		if data.SkopeoMeta != nil {
+			if raw, err := json.Marshal(data.SkopeoMeta); err == nil {
+				_ = json.Unmarshal(raw, &inspectMeta)
+			}
+		}
+
+		jobEvent(ctx, data.JobID, models.JobEventToolStarted, "dive")
 		diveStart := time.Now()
-		diveResult, err := tools.DiveAnalyzer.AnalyzeImage(ctx, target)
+		diveResult, err := tools.DiveAnalyzer.AnalyzeImageWithMeta(ctx, target, inspectMeta)
 		diveEnd := time.Now()
 		diveDuration := diveEnd.Sub(diveStart)
+		toolCompleted(ctx, data.JobID, "dive", diveDuration, err)
 
 		toolMetrics["dive"] = ToolMetric{
 			StartedAt:   diveStart.Format(time.RFC3339),
@@ -183,76 +427,370 @@ func ProcessAnalyzeJob(ctx context.Context, payload []byte) error {
 				}
 				return ""
 			}(),
+			ErrorCategory: classifyFailure(err),
 		}
 
 		if err != nil {
-			log.Printf("[Worker] Dive analysis failed: %v", err)
-			hasErrors = true
+			jobErrorf(ctx, "[Worker] Dive analysis failed: %v", err)
+			fail("dive", err, classifyFailure(err))
 		} else {
 			// Upload Dive result
-			resultJSON, _ := json.Marshal(diveResult)
-			reader := bytes.NewReader(resultJSON)
-			objectName := fmt.Sprintf("%s/artifacts/dive.json", data.JobID)
+			objectName := loc.Key("dive.json")
 
-			_, err := storage.Client.PutObject(ctx, bucket, objectName, reader, int64(len(resultJSON)), minio.PutObjectOptions{
-				ContentType: "application/json",
-			})
+			n, _, err := storage.PutJSONArtifact(ctx, bucket, objectName, diveResult)
 			if err != nil {
-				log.Printf("[Worker] Failed to upload dive.json: %v", err)
-				hasErrors = true
+				jobErrorf(ctx, "[Worker] Failed to upload dive.json: %v", err)
+				fail("dive", err, models.FailureStorage)
 			} else {
-				log.Printf("[Worker] Uploaded dive.json to %s/%s", bucket, objectName)
+				jobLogf(ctx, "[Worker] Uploaded dive.json to %s/%s", bucket, objectName)
+				succeeded++
+				scoreInput.Dive = diveResult
+				storedBytes += n
 			}
 		}
 		database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Update("progress", 95)
 	}
 
-	// 4. Trigger flow service to generate AI report
-	flowURL := os.Getenv("FLOW_SERVICE_URL")
-	if flowURL == "" {
-		flowURL = "http://localhost:8000"
+	// With both a Dockerfile and an image, map the instructions onto the layers
+	// they produced so the report can point at specific lines
+	if data.Dockerfile != "" {
+		if n, err := uploadDockerfileLayerMap(ctx, loc, data.Dockerfile, job.Metadata, scoreInput.Dive); err != nil {
+			jobErrorf(ctx, "[Worker] Failed to upload dockerfile-layer-map.json: %v", err)
+		} else {
+			storedBytes += n
+		}
+	}
+
+	// Compute the Score Card here so the report quotes fixed numbers instead of LLM arithmetic
+	var card *scoring.ScoreCard
+	if analysisType != models.AnalysisTypeSBOM && succeeded > 0 {
+		var n int64
+		var err error
+		if card, n, err = uploadScoreCard(ctx, loc, scoreInput, job.UserID); err != nil {
+			jobErrorf(ctx, "[Worker] Failed to upload score.json: %v", err)
+		} else {
+			storedBytes += n
+		}
+	}
+
+	// Compare with the previous scan of the same image, if there is one
+	if analysisType != models.AnalysisTypeSBOM && succeeded > 0 {
+		if n, err := compareWithBaseline(ctx, loc, data.JobID, scoreInput.Grype); err != nil {
+			jobErrorf(ctx, "[Worker] Failed to compare job %s with its baseline: %v", data.JobID, err)
+		} else {
+			storedBytes += n
+		}
 	}
-	flowProvider := os.Getenv("FLOW_PROVIDER")
-	if flowProvider == "" {
-		flowProvider = "openai"
+
+	if err := models.AddUserStorage(database.DB, job.UserID, storedBytes); err != nil {
+		jobErrorf(ctx, "[Worker] Failed to record storage usage for job %s: %v", data.JobID, err)
+	}
+
+	// 4. Trigger flow service to generate AI report from whatever artifacts exist
+	// (an SBOM has nothing to report on). Without a connected AI provider the
+	// job completes scans-only, with a note on how to get a report.
+	reportNote := ""
+	wantsReport := analysisType != models.AnalysisTypeSBOM && succeeded > 0
+	if wantsReport {
+		if ok, err := flows.HasProvider(job.UserID); err != nil {
+			jobErrorf(ctx, "[Worker] Failed to look up AI providers for job %s: %v", data.JobID, err)
+		} else if !ok {
+			jobLogf(ctx, "[Worker] No AI provider connected for user %s — job %s completes with scan artifacts only", job.UserID, data.JobID)
+			reportNote = flows.NoProviderNote
+			wantsReport = false
+		}
 	}
-	if err := triggerFlowReport(ctx, flowURL, data.JobID, flowProvider); err != nil {
-		log.Printf("[Worker] Flow report generation failed for job %s: %v", data.JobID, err)
-		// Non-fatal — scans are still stored
+	if wantsReport {
+		flowURL := os.Getenv("FLOW_SERVICE_URL")
+		if flowURL == "" {
+			flowURL = "http://localhost:8000"
+		}
+		flowProvider := os.Getenv("FLOW_PROVIDER")
+		if flowProvider == "" {
+			flowProvider = "openai"
+		}
+		weights := flows.ReportTemplateFor(job.UserID).Weights()
+		if err := triggerFlowReport(ctx, flowURL, data.JobID, flowProvider, failedTools, weights); err != nil {
+			jobErrorf(ctx, "[Worker] Flow report generation failed for job %s: %v", data.JobID, err)
+			// Non-fatal — scans are still stored
+			if failureMessage == "" {
+				failureMessage, failureCategory = fmt.Sprintf("report: %v", err), models.FailureFlow
+			}
+		} else if err := flows.WriteStructuredReport(ctx, loc, data.JobID); err != nil {
+			// report.md is already published; only report.json is missing
+			jobErrorf(ctx, "[Worker] Failed to write report.json for job %s: %v", data.JobID, err)
+		}
 	}
 
 	// Update final job status
 	completedAt := time.Now()
 	finalStatus := models.JobStatusCompleted
-	if hasErrors {
-		finalStatus = models.JobStatusFailed
+	degraded := false
+	if len(failedTools) > 0 {
+		if succeeded == 0 {
+			finalStatus = models.JobStatusFailed
+		} else {
+			degraded = true
+		}
 	}
 
 	// Serialize tool metrics to JSON
 	toolMetricsJSON, err := json.Marshal(toolMetrics)
 	if err != nil {
-		log.Printf("[Worker] Failed to marshal tool metrics: %v", err)
+		jobErrorf(ctx, "[Worker] Failed to marshal tool metrics: %v", err)
 		toolMetricsJSON = []byte("{}")
 	}
 
-	if err := database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Updates(map[string]interface{}{
+	// Recorded before the final status, so whoever sees the job finished
+	// also sees its last event
+	switch {
+	case finalStatus == models.JobStatusFailed:
+		jobEvent(ctx, data.JobID, models.JobEventFailed, failureMessage)
+	case degraded:
+		jobEvent(ctx, data.JobID, models.JobEventCompleted, "degraded, failed tools: "+strings.Join(failedTools, ", "))
+	default:
+		jobEvent(ctx, data.JobID, models.JobEventCompleted, "")
+	}
+
+	final := map[string]interface{}{
 		"status":       finalStatus,
+		"degraded":     degraded,
+		"failed_tools": strings.Join(failedTools, ","),
 		"progress":     100,
 		"completed_at": completedAt,
 		"tool_metrics": string(toolMetricsJSON),
-	}).Error; err != nil {
-		log.Printf("[Worker] Failed to update job final status: %v", err)
+		"report_note":  reportNote,
+		// Set when a tool or the report failed, for degraded and failed jobs alike
+		"error_message":    failureMessage,
+		"failure_category": failureCategory,
+	}
+	// Kept on the job so size trends don't read every dive.json
+	if scoreInput.Dive != nil {
+		final["image_size_bytes"] = int64(scoreInput.Dive.SizeBytes)
+		final["efficiency"] = scoreInput.Dive.Efficiency
+	}
+	if card != nil {
+		final["security_score"] = card.SecurityScore
+		if card.Vulnerabilities != nil {
+			final["critical_count"] = card.Vulnerabilities.Critical
+			final["high_count"] = card.Vulnerabilities.High
+		}
+		if card.CIS != nil {
+			final["dockle_fatal_count"] = card.CIS.Fatal
+		}
+	}
+	if err := database.DB.Model(&models.Job{}).Where("job_id = ?", data.JobID).Updates(final).Error; err != nil {
+		jobErrorf(ctx, "[Worker] Failed to update job final status: %v", err)
 	}
 
-	log.Printf("[Worker] Finished analysis job %s for: %s with status: %s", data.JobID, target, finalStatus)
+	if degraded {
+		jobLogf(ctx, "[Worker] Finished analysis job %s for: %s with status: %s (degraded, failed tools: %s)", data.JobID, target, finalStatus, strings.Join(failedTools, ", "))
+	} else {
+		jobLogf(ctx, "[Worker] Finished analysis job %s for: %s with status: %s", data.JobID, target, finalStatus)
+	}
 	return nil
 }
 
+// toolContext narrows ctx so the artifacts written with it are tagged with
+// tool and the version of its library
+func toolContext(ctx context.Context, tool string) context.Context {
+	return storage.WithArtifactMeta(ctx, storage.ArtifactMeta{Tool: tool, ToolVersion: tools.ToolVersion(tool)})
+}
+
+// toolEnabled reports whether tool is enabled on this worker
+func toolEnabled(tool string) bool {
+	switch tool {
+	case "sbom", "grype":
+		return tools.ImgScanner != nil && tools.ImgScanner.IsEnabled()
+	case "dockle":
+		return tools.DockleScn != nil && tools.DockleScn.IsEnabled()
+	case "dive":
+		return tools.DiveAnalyzer != nil && tools.DiveAnalyzer.IsEnabled()
+	}
+	return false
+}
+
+// uploadLicenses stores the license report for a job as licenses.json and
+// returns the bytes written. A nil report (e.g. a cached scan from before
+// licenses were extracted) is skipped.
+func uploadLicenses(ctx context.Context, loc storage.Location, report *tools.LicenseReport) (int64, error) {
+	if report == nil {
+		return 0, nil
+	}
+	resultJSON, err := json.Marshal(report)
+	if err != nil {
+		return 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("licenses.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, resultJSON, "application/json")
+	if err != nil {
+		return 0, err
+	}
+	jobLogf(ctx, "[Worker] Uploaded licenses.json to %s/%s (gpl=%t agpl=%t)", bucket, objectName, report.HasGPL, report.HasAGPL)
+	return n, nil
+}
+
+// inspectedSignature returns the cosign signature check stored with the job's
+// inspection, or nil if it didn't run
+func inspectedSignature(metadata string) *tools.SignatureStatus {
+	if metadata == "" {
+		return nil
+	}
+	var inspect tools.InspectResult
+	if err := json.Unmarshal([]byte(metadata), &inspect); err != nil {
+		return nil
+	}
+	return inspect.Signature
+}
+
+// uploadHistory stores the build history of the job's stored inspection as
+// history.json. Nothing is stored when the inspection has no history.
+func uploadHistory(ctx context.Context, loc storage.Location, metadata string) (int64, error) {
+	if metadata == "" {
+		return 0, nil
+	}
+	var inspect tools.InspectResult
+	if err := json.Unmarshal([]byte(metadata), &inspect); err != nil || len(inspect.History) == 0 {
+		return 0, nil
+	}
+	historyJSON, err := json.Marshal(inspect.History)
+	if err != nil {
+		return 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("history.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, historyJSON, "application/json")
+	if err != nil {
+		return 0, err
+	}
+	jobLogf(ctx, "[Worker] Uploaded history.json to %s/%s (%d steps)", bucket, objectName, len(inspect.History))
+	return n, nil
+}
+
+// uploadDockerfileLayerMap correlates dockerfile with the image history of the
+// job's stored inspection and stores it as dockerfile-layer-map.json. Nothing
+// is stored when the inspection has no history.
+func uploadDockerfileLayerMap(ctx context.Context, loc storage.Location, dockerfile, metadata string, dive *tools.DiveAnalysis) (int64, error) {
+	if metadata == "" {
+		return 0, nil
+	}
+	var inspect tools.InspectResult
+	if err := json.Unmarshal([]byte(metadata), &inspect); err != nil {
+		return 0, nil
+	}
+	layerMap := tools.MapDockerfileLayers(dockerfile, &inspect, dive)
+	if layerMap == nil {
+		return 0, nil
+	}
+	mapJSON, err := json.Marshal(layerMap)
+	if err != nil {
+		return 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("dockerfile-layer-map.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, mapJSON, "application/json")
+	if err != nil {
+		return 0, err
+	}
+	jobLogf(ctx, "[Worker] Uploaded dockerfile-layer-map.json to %s/%s (%d/%d instructions matched, %d findings)",
+		bucket, objectName, layerMap.Matched, layerMap.Matched+layerMap.Unmatched, len(layerMap.Findings))
+	return n, nil
+}
+
+// uploadScoreCard computes the deterministic Score Card with the owner's report
+// template weights and stores it as score.json. The card is returned even
+// when storing it failed.
+func uploadScoreCard(ctx context.Context, loc storage.Location, in scoring.Input, userID string) (*scoring.ScoreCard, int64, error) {
+	card := scoring.Compute(in, flows.ReportTemplateFor(userID).Weights())
+	cardJSON, err := json.Marshal(card)
+	if err != nil {
+		return card, 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("score.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, cardJSON, "application/json")
+	if err != nil {
+		return card, 0, err
+	}
+	jobLogf(ctx, "[Worker] Uploaded score.json to %s/%s (security score %d)", bucket, objectName, card.SecurityScore)
+	return card, n, nil
+}
+
+// GrypeSummaryThreshold is the grype.json size above which a condensed
+// grype.summary.json is also written for the report flow. 0 disables it.
+// Set from GRYPE_SUMMARY_THRESHOLD_BYTES by the worker on startup.
+var GrypeSummaryThreshold int64 = 256 << 10
+
+// uploadGrypeSummary stores the Critical/High-only summary of scan as grype.summary.json.
+func uploadGrypeSummary(ctx context.Context, loc storage.Location, scan *tools.Scan) (int64, error) {
+	summaryJSON, err := json.Marshal(scan.Summary())
+	if err != nil {
+		return 0, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("grype.summary.json")
+	n, err := storage.PutArtifact(ctx, bucket, objectName, summaryJSON, "application/json")
+	if err != nil {
+		return 0, err
+	}
+	jobLogf(ctx, "[Worker] Uploaded grype.summary.json to %s/%s (%d bytes)", bucket, objectName, len(summaryJSON))
+	return n, nil
+}
+
+// importHarborScan fetches Harbor's vulnerability report for artifact and
+// stores it as harbor-scan.json. Returns the bytes stored and the severity
+// counts for the score card, or harbor.ErrNoScanReport if Harbor has not
+// scanned the artifact.
+func importHarborScan(ctx context.Context, loc storage.Location, cred *registry.Credential, artifact *harbor.ArtifactRef) (int64, *scoring.ImportedScan, error) {
+	client := harbor.NewClient(harbor.Config{
+		URL:      cred.URL,
+		Username: cred.Auth.Username,
+		Password: cred.Auth.Password,
+	})
+	report, err := client.GetVulnerabilityReport(ctx, artifact.Project, artifact.Repository, artifact.Reference)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return 0, nil, err
+	}
+	bucket, objectName := loc.Bucket, loc.Key("harbor-scan.json")
+	scanner := strings.ToLower(report.Scanner.Name)
+	if scanner == "" {
+		scanner = "harbor"
+	}
+	ctx = storage.WithArtifactMeta(ctx, storage.ArtifactMeta{Tool: scanner, ToolVersion: report.Scanner.Version})
+	n, err := storage.PutArtifact(ctx, bucket, objectName, reportJSON, "application/json")
+	if err != nil {
+		return 0, nil, err
+	}
+	jobLogf(ctx, "[Worker] Imported Harbor scan (%s %s, %d findings) to %s/%s",
+		report.Scanner.Name, report.Scanner.Version, len(report.Vulnerabilities), bucket, objectName)
+
+	sum := report.Summary()
+	return n, &scoring.ImportedScan{
+		Source: "harbor",
+		Counts: scoring.VulnerabilityCounts{
+			Critical: sum.Critical,
+			High:     sum.High,
+			Medium:   sum.Medium,
+			Low:      sum.Low + sum.Negligible,
+			Unknown:  sum.Total - sum.Critical - sum.High - sum.Medium - sum.Low - sum.Negligible,
+			Total:    sum.Total,
+		},
+	}, nil
+}
+
 // triggerFlowReport calls the Python flow service to generate an AI report for the job.
-func triggerFlowReport(ctx context.Context, baseURL, jobID, provider string) error {
-	body, _ := json.Marshal(map[string]string{
-		"job_id":   jobID,
-		"provider": provider,
+// unavailableTools lists tools whose artifacts are missing so the report can say so.
+// weights are the owner's scoring, which the report uses when score.json is missing.
+func triggerFlowReport(ctx context.Context, baseURL, jobID, provider string, unavailableTools []string, weights scoring.Weights) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"job_id":            jobID,
+		"provider":          provider,
+		"unavailable_tools": unavailableTools,
+		"scoring": map[string]interface{}{
+			"base_score": weights.BaseScore,
+			"deductions": weights.Deductions,
+		},
 	})
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/report", bytes.NewReader(body))
@@ -271,6 +809,26 @@ func triggerFlowReport(ctx context.Context, baseURL, jobID, provider string) err
 	if resp.StatusCode >= 300 {
 		return fmt.Errorf("flow service returned %d", resp.StatusCode)
 	}
-	log.Printf("[Worker] Flow service generated report for job %s", jobID)
+	jobLogf(ctx, "[Worker] Flow service generated report for job %s", jobID)
+	return nil
+}
+
+// ProcessRefreshDBJob forces a vulnerability DB update on the worker's scanner
+func ProcessRefreshDBJob(ctx context.Context, payload []byte) error {
+	if tools.ImgScanner == nil || !tools.ImgScanner.IsEnabled() {
+		log.Printf("[Worker] Vulnerability scanner disabled, ignoring DB refresh")
+		return nil
+	}
+
+	built, err := tools.ImgScanner.RefreshDB()
+	if err != nil {
+		log.Printf("[Worker] Vulnerability DB refresh failed: %v", err)
+		return err
+	}
+
+	log.Printf("[Worker] Vulnerability DB refreshed (built %s)", built.Format(time.RFC3339))
+	if err := scannertools.PublishDBStatus(ctx); err != nil {
+		log.Printf("[Worker] Failed to publish vulnerability DB status: %v", err)
+	}
 	return nil
 }
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/database"
+	"github.com/siddhantprateek/reefline/pkg/models"
+)
+
+// jobEvent records a lifecycle event of jobID. A failed write is logged but
+// never fails the job.
+func jobEvent(ctx context.Context, jobID string, t models.JobEventType, detail string) {
+	if err := models.RecordJobEvent(database.DB.WithContext(context.WithoutCancel(ctx)), jobID, t, detail); err != nil {
+		jobErrorf(ctx, "[Worker] Failed to record %s event for job %s: %v", t, jobID, err)
+	}
+}
+
+// toolCompleted records the outcome of a tool run that took d
+func toolCompleted(ctx context.Context, jobID, tool string, d time.Duration, err error) {
+	detail := fmt.Sprintf("%s succeeded in %s", tool, d.Round(time.Millisecond))
+	if err != nil {
+		detail = fmt.Sprintf("%s failed after %s: %v", tool, d.Round(time.Millisecond), err)
+	}
+	jobEvent(ctx, jobID, models.JobEventToolCompleted, detail)
+}
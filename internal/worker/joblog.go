@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddhantprateek/reefline/pkg/storage"
+)
+
+// JobLogArtifact is the artifact holding a job's worker log, one JSON object
+// per line: {"time": "...", "level": "info|error", "message": "..."}
+const JobLogArtifact = "logs.jsonl"
+
+// JobLogFlushInterval is how often a running job's log is written to
+// storage, i.e. how far GET /api/v1/jobs/:id/logs lags behind the worker
+var JobLogFlushInterval = 2 * time.Second
+
+// jobLogMaxBytes caps a job's log; later lines only go to the worker log
+const jobLogMaxBytes = 1 << 20
+
+// JobLogEntry is one line of a job's logs.jsonl
+type JobLogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// jobLog collects the worker log lines of one job and mirrors them to the
+// job's logs.jsonl artifact while it runs
+type jobLog struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	dirty     bool
+	truncated bool
+	loc       *storage.Location
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+type jobLogKey struct{}
+
+// withJobLog returns a context whose jobLogf and jobErrorf lines are
+// collected for the job. Nothing is stored until start.
+func withJobLog(ctx context.Context) (context.Context, *jobLog) {
+	l := &jobLog{}
+	return context.WithValue(ctx, jobLogKey{}, l), l
+}
+
+// jobLogf logs to the worker log and, if ctx carries one, the job's log
+func jobLogf(ctx context.Context, format string, args ...interface{}) {
+	logTo(ctx, "info", format, args...)
+}
+
+// jobErrorf is jobLogf for failures, which the job log marks as errors
+func jobErrorf(ctx context.Context, format string, args ...interface{}) {
+	logTo(ctx, "error", format, args...)
+}
+
+func logTo(ctx context.Context, level, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	if l, ok := ctx.Value(jobLogKey{}).(*jobLog); ok {
+		l.add(level, strings.TrimPrefix(msg, "[Worker] "))
+	}
+}
+
+func (l *jobLog) add(level, msg string) {
+	line, err := json.Marshal(JobLogEntry{Time: time.Now().UTC(), Level: level, Message: msg})
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buf.Len()+len(line) >= jobLogMaxBytes {
+		if !l.truncated {
+			l.truncated = true
+			l.dirty = true
+			note, _ := json.Marshal(JobLogEntry{Time: time.Now().UTC(), Level: "error", Message: "log truncated; see the worker log for the rest"})
+			l.buf.Write(append(note, '\n'))
+		}
+		return
+	}
+	l.buf.Write(append(line, '\n'))
+	l.dirty = true
+}
+
+// start writes the log to loc every JobLogFlushInterval until stop
+func (l *jobLog) start(ctx context.Context, loc storage.Location) {
+	l.mu.Lock()
+	l.loc = &loc
+	l.mu.Unlock()
+	l.done = make(chan struct{})
+	l.stopped = make(chan struct{})
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(JobLogFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.flush(ctx)
+			case <-l.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the periodic writes and stores the complete log. The write
+// doesn't depend on ctx being live, so a cancelled job keeps its log.
+func (l *jobLog) stop(ctx context.Context) {
+	if l.done == nil {
+		return
+	}
+	close(l.done)
+	<-l.stopped
+	l.flush(context.WithoutCancel(ctx))
+}
+
+// flush stores the log if lines were added since the last write
+func (l *jobLog) flush(ctx context.Context) {
+	l.mu.Lock()
+	if !l.dirty || l.loc == nil {
+		l.mu.Unlock()
+		return
+	}
+	data := bytes.Clone(l.buf.Bytes())
+	loc := *l.loc
+	l.dirty = false
+	l.mu.Unlock()
+
+	if _, err := storage.PutArtifact(ctx, loc.Bucket, loc.Key(JobLogArtifact), data, "application/x-ndjson"); err != nil {
+		log.Printf("[Worker] Failed to upload %s: %v", JobLogArtifact, err)
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/siddhantprateek/reefline/pkg/scoring"
+	"github.com/siddhantprateek/reefline/pkg/storage"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// PlatformsArtifact is the combined result of scanning every platform of a
+// multi-platform image
+const PlatformsArtifact = "platforms.json"
+
+// PlatformReport compares the grype results of the platforms of one image,
+// stored as platforms.json for the report
+type PlatformReport struct {
+	Platforms        []PlatformResult  `json:"platforms"`
+	PlatformSpecific []PlatformFinding `json:"platform_specific"` // Critical/High findings missing from some scanned platforms
+	Common           int               `json:"common"`            // Critical/High findings on every scanned platform
+}
+
+// PlatformResult is the grype result of one platform
+type PlatformResult struct {
+	Platform        string                       `json:"platform"`
+	Artifact        string                       `json:"artifact,omitempty"` // Full grype result
+	Vulnerabilities *scoring.VulnerabilityCounts `json:"vulnerabilities,omitempty"`
+	Error           string                       `json:"error,omitempty"` // The scan failed; not compared
+}
+
+// PlatformFinding is a Critical or High finding and the platforms that have it
+type PlatformFinding struct {
+	tools.SummaryFinding
+	Platforms []string `json:"platforms"`
+}
+
+// platformScan is the outcome of scanning one platform
+type platformScan struct {
+	platform string
+	artifact string
+	scan     *tools.Scan
+	err      error
+}
+
+// scanPlatforms scans every platform of target's manifest list other than
+// the default one, whose result (primary, already stored as grype.json) it
+// joins. Each platform's result is stored as grype.<os>-<arch>.json and the
+// comparison as platforms.json. Single-platform and local images are left
+// alone. Returns the bytes stored.
+func scanPlatforms(ctx context.Context, loc storage.Location, target string, primary *tools.Scan) (int64, error) {
+	if in, err := tools.ParseImageInput(target, ""); err == nil && in.IsLocal() {
+		jobLogf(ctx, "[Worker] %s is a local image, scanning its default platform only", target)
+		return 0, nil
+	}
+	if tools.ImgInspector == nil || !tools.ImgInspector.IsEnabled() {
+		return 0, errors.New("listing platforms needs the image inspector (IMAGE_INSPECTOR_ENABLED=true)")
+	}
+	platforms, err := tools.ImgInspector.ListPlatforms(ctx, target, nil)
+	if err != nil {
+		return 0, fmt.Errorf("listing platforms of %s: %w", target, err)
+	}
+	if len(platforms) == 0 {
+		jobLogf(ctx, "[Worker] %s is a single-platform image", target)
+		return 0, nil
+	}
+
+	var scans []platformScan
+	var stored int64
+	for _, p := range platforms {
+		if p.String() == tools.DefaultPlatform {
+			scans = append(scans, platformScan{platform: p.String(), artifact: "grype.json", scan: primary})
+			continue
+		}
+		if ctx.Err() != nil {
+			return stored, ctx.Err()
+		}
+
+		jobLogf(ctx, "[Worker] Running Grype scan of %s for %s...", target, p)
+		ps := platformScan{platform: p.String()}
+		ps.scan, ps.err = tools.ImgScanner.ScanImage(tools.WithPlatform(ctx, p.String()), target)
+		if ps.err != nil {
+			jobErrorf(ctx, "[Worker] Grype scan for %s failed: %v", p, ps.err)
+			scans = append(scans, ps)
+			continue
+		}
+
+		objectName := loc.Key("grype." + p.Slug() + ".json")
+		n, _, err := storage.PutJSONArtifact(ctx, loc.Bucket, objectName, ps.scan)
+		if err != nil {
+			jobErrorf(ctx, "[Worker] Failed to upload %s: %v", objectName, err)
+		} else {
+			jobLogf(ctx, "[Worker] Uploaded grype.%s.json to %s/%s", p.Slug(), loc.Bucket, objectName)
+			ps.artifact = "grype." + p.Slug() + ".json"
+			stored += n
+		}
+		scans = append(scans, ps)
+	}
+
+	n, _, err := storage.PutJSONArtifact(ctx, loc.Bucket, loc.Key(PlatformsArtifact), comparePlatforms(scans))
+	if err != nil {
+		return stored, fmt.Errorf("uploading %s: %w", PlatformsArtifact, err)
+	}
+	jobLogf(ctx, "[Worker] Uploaded %s for %d platform(s)", PlatformsArtifact, len(scans))
+	return stored + n, nil
+}
+
+// comparePlatforms builds the platform report of scans. Findings are matched
+// by vulnerability and package; failed scans are listed but not compared, so
+// they don't make every finding look platform-specific.
+func comparePlatforms(scans []platformScan) PlatformReport {
+	report := PlatformReport{Platforms: []PlatformResult{}, PlatformSpecific: []PlatformFinding{}}
+
+	type key struct{ vulnerability, pkg string }
+	findings := map[key]*PlatformFinding{}
+	var order []key
+	compared := 0
+	for _, s := range scans {
+		result := PlatformResult{Platform: s.platform, Artifact: s.artifact}
+		if s.err != nil || s.scan == nil {
+			if s.err != nil {
+				result.Error = s.err.Error()
+			}
+			report.Platforms = append(report.Platforms, result)
+			continue
+		}
+		t := s.scan.Tally
+		result.Vulnerabilities = &scoring.VulnerabilityCounts{
+			Critical: t.Critical, High: t.High, Medium: t.Medium,
+			Low: t.Low, Unknown: t.Unknown, Total: t.Total,
+		}
+		report.Platforms = append(report.Platforms, result)
+		compared++
+
+		for _, f := range s.scan.Summary().Findings {
+			k := key{f.Vulnerability, f.Package}
+			pf := findings[k]
+			if pf == nil {
+				pf = &PlatformFinding{SummaryFinding: f}
+				findings[k] = pf
+				order = append(order, k)
+			}
+			if !slices.Contains(pf.Platforms, s.platform) {
+				pf.Platforms = append(pf.Platforms, s.platform)
+			}
+		}
+	}
+
+	for _, k := range order {
+		pf := findings[k]
+		if len(pf.Platforms) == compared {
+			report.Common++
+			continue
+		}
+		report.PlatformSpecific = append(report.PlatformSpecific, *pf)
+	}
+	return report
+}
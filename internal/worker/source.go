@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/siddhantprateek/reefline/pkg/storage"
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+// MaxSourceBytes caps the extracted size of an uploaded source tree.
+// Overridable via SOURCE_MAX_EXTRACT_BYTES.
+var MaxSourceBytes int64 = 2 << 30
+
+// scanSource downloads the uploaded source archive at bucket/objectName,
+// extracts it to a temporary directory and scans it with grype. The result
+// is named after the job's image_ref rather than the temporary path.
+func scanSource(ctx context.Context, bucket, objectName, name string) (*tools.Scan, error) {
+	obj, err := storage.DownloadFile(ctx, bucket, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download source archive: %w", err)
+	}
+	defer obj.Close()
+
+	dir, err := os.MkdirTemp("", "reefline-source-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := tools.ExtractSourceArchive(obj, dir, MaxSourceBytes); err != nil {
+		return nil, fmt.Errorf("failed to extract source archive: %w", err)
+	}
+	jobLogf(ctx, "[Worker] Extracted source archive %s to %s", objectName, dir)
+
+	scan, err := tools.ImgScanner.ScanDirectory(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	scan.ID = name
+	return scan, nil
+}
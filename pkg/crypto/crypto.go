@@ -9,13 +9,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"sync"
 )
 
+// ErrDecrypt is returned by Decrypt when the ciphertext does not authenticate
+// under the current key — typically because ENCRYPTION_KEY was rotated.
+var ErrDecrypt = errors.New("decryption failed (wrong key or corrupted data)")
+
 var (
-	gcm     cipher.AEAD
-	once    sync.Once
-	initErr error
+	gcm      cipher.AEAD
+	previous []cipher.AEAD // Retired keys, only used to decrypt
+	once     sync.Once
+	initErr  error
 )
 
 // Init loads the encryption key from the ENCRYPTION_KEY environment variable
@@ -23,6 +29,10 @@ var (
 //
 // The key must be exactly 32 bytes (256 bits) encoded as base64.
 // Generate one with: openssl rand -base64 32
+//
+// During a key rotation, ENCRYPTION_KEY_PREVIOUS may list the retired keys
+// (comma-separated, same format). They are tried when the active key fails
+// so existing data keeps working until it is re-encrypted.
 func Init() error {
 	once.Do(func() {
 		keyB64 := os.Getenv("ENCRYPTION_KEY")
@@ -31,33 +41,50 @@ func Init() error {
 			return
 		}
 
-		key, err := base64.StdEncoding.DecodeString(keyB64)
-		if err != nil {
-			initErr = fmt.Errorf("ENCRYPTION_KEY is not valid base64: %w", err)
-			return
-		}
-
-		if len(key) != 32 {
-			initErr = fmt.Errorf("ENCRYPTION_KEY must be 32 bytes (got %d) — generate with: openssl rand -base64 32", len(key))
+		gcm, initErr = newGCM("ENCRYPTION_KEY", keyB64)
+		if initErr != nil {
 			return
 		}
 
-		block, err := aes.NewCipher(key)
-		if err != nil {
-			initErr = fmt.Errorf("failed to create AES cipher: %w", err)
-			return
-		}
-
-		gcm, err = cipher.NewGCM(block)
-		if err != nil {
-			initErr = fmt.Errorf("failed to create GCM: %w", err)
-			return
+		for _, old := range strings.Split(os.Getenv("ENCRYPTION_KEY_PREVIOUS"), ",") {
+			if old = strings.TrimSpace(old); old == "" {
+				continue
+			}
+			aead, err := newGCM("ENCRYPTION_KEY_PREVIOUS", old)
+			if err != nil {
+				initErr = err
+				return
+			}
+			previous = append(previous, aead)
 		}
 	})
 
 	return initErr
 }
 
+// newGCM builds an AES-256-GCM cipher from a base64 key read from env var name
+func newGCM(name, keyB64 string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", name, err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes (got %d) — generate with: openssl rand -base64 32", name, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
 // Encrypt encrypts plaintext using AES-256-GCM.
 //
 // Returns a base64-encoded string containing: nonce (12 bytes) || ciphertext+tag.
@@ -80,32 +107,63 @@ func Encrypt(plaintext []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt decrypts a base64-encoded ciphertext produced by Encrypt.
+// Decrypt decrypts a base64-encoded ciphertext produced by Encrypt, trying
+// the active key first and then any ENCRYPTION_KEY_PREVIOUS keys.
 //
 // Expects the format: base64(nonce || ciphertext+tag)
 func Decrypt(encoded string) ([]byte, error) {
+	plaintext, _, err := decrypt(encoded)
+	return plaintext, err
+}
+
+// Reencrypt returns encoded re-encrypted under the active key. Data already
+// using the active key is returned unchanged with migrated=false, so it is
+// safe to run repeatedly.
+func Reencrypt(encoded string) (out string, migrated bool, err error) {
+	plaintext, active, err := decrypt(encoded)
+	if err != nil {
+		return "", false, err
+	}
+	if active {
+		return encoded, false, nil
+	}
+	out, err = Encrypt(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return out, true, nil
+}
+
+// decrypt opens encoded with the first key that authenticates it and reports
+// whether that was the active key.
+func decrypt(encoded string) (plaintext []byte, active bool, err error) {
 	if gcm == nil {
-		return nil, errors.New("crypto not initialised — call crypto.Init() first")
+		return nil, false, errors.New("crypto not initialised — call crypto.Init() first")
 	}
 
 	data, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64: %w", err)
+		return nil, false, fmt.Errorf("failed to decode base64: %w", err)
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
-		return nil, errors.New("ciphertext too short")
+		return nil, false, errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("decryption failed (wrong key or corrupted data): %w", err)
+	plaintext, err = gcm.Open(nil, nonce, ciphertext, nil)
+	if err == nil {
+		return plaintext, true, nil
+	}
+	for _, aead := range previous {
+		if plaintext, perr := aead.Open(nil, nonce, ciphertext, nil); perr == nil {
+			return plaintext, false, nil
+		}
 	}
 
-	return plaintext, nil
+	return nil, false, fmt.Errorf("%w: %v", ErrDecrypt, err)
 }
 
 // EncryptString is a convenience wrapper that encrypts a string.
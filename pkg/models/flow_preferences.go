@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FlowPreferences stores a user's AI report flow settings. Users without a
+// row get the server defaults.
+type FlowPreferences struct {
+	UserID           string    `json:"user_id" gorm:"primaryKey"`
+	ProviderPriority string    `json:"provider_priority"` // Comma-separated provider IDs, most preferred first
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default GORM table name
+func (FlowPreferences) TableName() string {
+	return "flow_preferences"
+}
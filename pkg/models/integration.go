@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Integration represents a user's connection to an external service
 // (GitHub, Docker Hub, Harbor, AI providers).
@@ -9,10 +12,13 @@ type Integration struct {
 	ID            uint       `json:"id" gorm:"primaryKey"`
 	UserID        string     `json:"user_id" gorm:"index;not null"`
 	IntegrationID string     `json:"integration_id" gorm:"not null"`      // e.g. "github", "docker", "harbor", "openai"
-	Status        string     `json:"status" gorm:"default:disconnected"`  // "connected", "disconnected", "error"
+	Status        string     `json:"status" gorm:"default:disconnected"`  // "connected", "disconnected", "error", "reauth_required"
 	Credentials   string     `json:"-" gorm:"type:text"`                  // encrypted JSON blob — never exposed in API responses
 	Metadata      string     `json:"metadata,omitempty" gorm:"type:text"` // JSON — public info like username, provider version
 	ConnectedAt   *time.Time `json:"connected_at,omitempty"`
+	LastTestedAt  *time.Time `json:"last_tested_at,omitempty"`
+	LastLatencyMs *int64     `json:"last_latency_ms,omitempty"`
+	TestHistory   string     `json:"-" gorm:"type:text"` // JSON []IntegrationTest, newest last, capped at MaxTestHistory
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 }
@@ -21,3 +27,36 @@ type Integration struct {
 func (Integration) TableName() string {
 	return "integrations"
 }
+
+// MaxTestHistory is how many connection tests are kept per integration
+const MaxTestHistory = 20
+
+// IntegrationTest is one recorded credential validation against the provider
+type IntegrationTest struct {
+	At        time.Time `json:"at"`
+	Success   bool      `json:"success"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Tests returns the recorded test history, oldest first.
+func (i *Integration) Tests() []IntegrationTest {
+	var tests []IntegrationTest
+	if i.TestHistory != "" {
+		_ = json.Unmarshal([]byte(i.TestHistory), &tests)
+	}
+	return tests
+}
+
+// RecordTest appends t to the history (dropping the oldest beyond
+// MaxTestHistory) and updates LastTestedAt/LastLatencyMs. The caller saves.
+func (i *Integration) RecordTest(t IntegrationTest) {
+	tests := append(i.Tests(), t)
+	if len(tests) > MaxTestHistory {
+		tests = tests[len(tests)-MaxTestHistory:]
+	}
+	raw, _ := json.Marshal(tests)
+	i.TestHistory = string(raw)
+	i.LastTestedAt = &t.At
+	i.LastLatencyMs = &t.LatencyMs
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,25 +23,144 @@ const (
 	JobStatusUnknown   JobStatus = "UNKNOWN"
 )
 
+// AnalysisType selects which tools the worker runs for a job
+type AnalysisType string
+
+const (
+	AnalysisTypeFull    AnalysisType = "full"    // grype + dockle + dive + AI report
+	AnalysisTypeSBOM    AnalysisType = "sbom"    // syft package inventory only (sbom.json)
+	AnalysisTypeVuln    AnalysisType = "vuln"    // grype only
+	AnalysisTypeHygiene AnalysisType = "hygiene" // dockle + dive
+)
+
+// analysisTools lists the tools each analysis type runs
+var analysisTools = map[AnalysisType][]string{
+	AnalysisTypeFull:    {"grype", "dockle", "dive"},
+	AnalysisTypeSBOM:    {"sbom"},
+	AnalysisTypeVuln:    {"grype"},
+	AnalysisTypeHygiene: {"dockle", "dive"},
+}
+
+// ParseAnalysisType validates s, defaulting to AnalysisTypeFull when empty
+func ParseAnalysisType(s string) (AnalysisType, error) {
+	if s == "" {
+		return AnalysisTypeFull, nil
+	}
+	t := AnalysisType(s)
+	if _, ok := analysisTools[t]; !ok {
+		return "", fmt.Errorf("invalid analysis_type %q (expected full, sbom, vuln or hygiene)", s)
+	}
+	return t, nil
+}
+
+// Runs reports whether tool is part of this analysis type.
+// Unknown or empty types behave like AnalysisTypeFull.
+func (t AnalysisType) Runs(tool string) bool {
+	tools, ok := analysisTools[t]
+	if !ok {
+		tools = analysisTools[AnalysisTypeFull]
+	}
+	for _, name := range tools {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalysisTools are the tools a job can run, in order
+var AnalysisTools = []string{"sbom", "grype", "dockle", "dive"}
+
+// Skip reasons recorded in tool_metrics for tools that didn't run
+const (
+	SkipAnalysisType = "analysis_type" // Not part of the job's analysis_type
+	SkipRequest      = "request"       // Turned off by the request's tools override
+	SkipDisabled     = "disabled"      // Not enabled on the worker
+	SkipImported     = "imported"      // Replaced by an imported scan report (Harbor)
+)
+
+// Failure categories recorded as a job's failure_category and a failed tool's
+// error_category, so failures can be counted by cause
+const (
+	FailureRegistryAuth  = "registry_auth"   // The registry refused the credentials (or anonymous pull)
+	FailureImageNotFound = "image_not_found" // No such repository, tag or digest
+	FailureScanTimeout   = "scan_timeout"    // A tool exceeded its timeout
+	FailureNetwork       = "network"         // The registry or a database was unreachable
+	FailureStorage       = "storage"         // A result couldn't be written to object storage
+	FailureFlow          = "flow_error"      // The AI report couldn't be generated
+	FailureUnknown       = "unknown"
+)
+
+// ValidateToolOverrides checks the tool names of a request's tools override,
+// e.g. {"dockle": false}
+func ValidateToolOverrides(overrides map[string]bool) error {
+	for tool := range overrides {
+		if !slices.Contains(AnalysisTools, tool) {
+			return fmt.Errorf("invalid tool %q in tools (expected %s)", tool, strings.Join(AnalysisTools, ", "))
+		}
+	}
+	return nil
+}
+
+// RunsWith reports whether tool runs for this analysis type once a request's
+// tools override is applied: an explicit entry wins, otherwise Runs decides.
+func (t AnalysisType) RunsWith(tool string, overrides map[string]bool) bool {
+	if on, ok := overrides[tool]; ok {
+		return on
+	}
+	return t.Runs(tool)
+}
+
 // Job represents an analysis task
 type Job struct {
-	ID           string         `json:"id" gorm:"primaryKey"`
-	JobID        string         `json:"job_id" gorm:"uniqueIndex"`
-	UserID       string         `json:"user_id" gorm:"index"`
-	ImageRef     string         `json:"image_ref"`
-	Dockerfile   string         `json:"dockerfile" gorm:"type:text"`
-	Status       JobStatus      `json:"status" gorm:"index"`
-	Scenario     string         `json:"scenario"`                  // "dockerfile", "image", "both"
-	Metadata     string         `json:"metadata" gorm:"type:text"` // JSON string of Skopeo results, etc.
-	ErrorMessage string         `json:"error_message" gorm:"type:text"`
-	Progress     int            `json:"progress"` // 0-100
-	QueuedAt     *time.Time     `json:"queued_at"`
-	StartedAt    *time.Time     `json:"started_at" gorm:"index:idx_timing"`
-	CompletedAt  *time.Time     `json:"completed_at"`
-	ToolMetrics  string         `json:"tool_metrics" gorm:"type:text"` // JSON string of per-tool timing data
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID              string         `json:"id" gorm:"primaryKey"`
+	JobID           string         `json:"job_id" gorm:"uniqueIndex"`
+	UserID          string         `json:"user_id" gorm:"index;uniqueIndex:idx_job_idempotency,priority:1,where:idempotency_key <> ''"`
+	ImageRef        string         `json:"image_ref"`
+	Dockerfile      string         `json:"dockerfile" gorm:"type:text"`
+	Status          JobStatus      `json:"status" gorm:"index"`
+	Scenario        string         `json:"scenario"`                                                                    // "dockerfile", "image", "both", "source"
+	IdempotencyKey  string         `json:"idempotency_key,omitempty" gorm:"uniqueIndex:idx_job_idempotency,priority:2"` // Client-supplied Idempotency-Key header, unique per user
+	AnalysisType    AnalysisType   `json:"analysis_type" gorm:"default:full"`                                           // "full", "sbom", "vuln", "hygiene"
+	Scheduled       bool           `json:"scheduled" gorm:"index"`                                                      // Submitted by a schedule (e.g. a cron rescan), not a user
+	Metadata        string         `json:"metadata" gorm:"type:text"`                                                   // JSON string of Skopeo results, etc.
+	ErrorMessage    string         `json:"error_message" gorm:"type:text"`
+	FailureCategory string         `json:"failure_category,omitempty" gorm:"index"` // Cause of ErrorMessage, e.g. FailureRegistryAuth
+	Degraded        bool           `json:"degraded"`                                // Completed, but some tools failed
+	FailedTools     string         `json:"failed_tools,omitempty"`                  // Comma-separated tool names, e.g. "dive"
+	BaselineJobID   string         `json:"baseline_job_id,omitempty" gorm:"index"`  // Previous completed job for the same image, compared in baseline.json
+	ReportNote      string         `json:"report_note,omitempty" gorm:"type:text"`  // Why the job has no AI report, e.g. no provider connected
+	Progress        int            `json:"progress"`                                // 0-100
+	QueuedAt        *time.Time     `json:"queued_at"`
+	StartedAt       *time.Time     `json:"started_at" gorm:"index:idx_timing"`
+	CompletedAt     *time.Time     `json:"completed_at"`
+	ToolMetrics     string         `json:"tool_metrics" gorm:"type:text"`                                 // JSON string of per-tool timing data
+	ImageSizeBytes  *int64         `json:"image_size_bytes,omitempty"`                                    // From dive, for size trends without reading dive.json
+	Efficiency      *float64       `json:"efficiency_pct,omitempty"`                                      // From dive, 0-100
+	SecurityScore   *int           `json:"security_score,omitempty" gorm:"index"`                         // From score.json, so lists can sort and filter in SQL
+	CriticalCount   *int           `json:"critical_count,omitempty" gorm:"index"`                         // From score.json; nil without grype results
+	HighCount       *int           `json:"high_count,omitempty"`                                          // From score.json; nil without grype results
+	DockleFatal     *int           `json:"dockle_fatal_count,omitempty" gorm:"column:dockle_fatal_count"` // From score.json; nil without dockle results
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// DedupeIdempotencyKeys clears the Idempotency-Key of every job but the newest
+// that shares one with another job of the same user, so the unique index on
+// (user_id, idempotency_key) can be created over rows written before it existed.
+// Run it before migrating Job; it does nothing on databases that predate the
+// idempotency_key column, whose rows cannot share a key.
+func DedupeIdempotencyKeys(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Job{}) || !db.Migrator().HasColumn(&Job{}, "IdempotencyKey") {
+		return nil
+	}
+	return db.Exec(`UPDATE jobs SET idempotency_key = ''
+		WHERE idempotency_key <> '' AND EXISTS (
+			SELECT 1 FROM jobs newer
+			WHERE newer.user_id = jobs.user_id AND newer.idempotency_key = jobs.idempotency_key
+			AND (newer.created_at > jobs.created_at OR (newer.created_at = jobs.created_at AND newer.id > jobs.id))
+		)`).Error
 }
 
 // BeforeCreate hooks into GORM to set UUID if needed
@@ -47,6 +169,14 @@ func (j *Job) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// FailedToolList returns the names of the tools that failed for a degraded job
+func (j *Job) FailedToolList() []string {
+	if j.FailedTools == "" {
+		return nil
+	}
+	return strings.Split(j.FailedTools, ",")
+}
+
 // GetQueueWaitDuration returns the time spent waiting in queue before processing started
 // Returns 0 if QueuedAt or StartedAt is nil
 func (j *Job) GetQueueWaitDuration() time.Duration {
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobEventType is a lifecycle transition of a job
+type JobEventType string
+
+const (
+	JobEventQueued        JobEventType = "queued"         // Stored and enqueued by the API server
+	JobEventStarted       JobEventType = "started"        // Picked up by a worker
+	JobEventRetried       JobEventType = "retried"        // Picked up again after an earlier attempt was interrupted
+	JobEventToolStarted   JobEventType = "tool_started"   // Detail is the tool
+	JobEventToolCompleted JobEventType = "tool_completed" // Detail is the tool and its outcome
+	JobEventCompleted     JobEventType = "completed"      // Detail lists the failed tools of a degraded job
+	JobEventFailed        JobEventType = "failed"         // Detail is the error message
+)
+
+// JobEvent is one entry of a job's lifecycle history, for timelines that
+// don't depend on the worker log
+type JobEvent struct {
+	ID        uint         `json:"-" gorm:"primaryKey"`
+	JobID     string       `json:"job_id" gorm:"not null;index:idx_job_events_job"`
+	EventType JobEventType `json:"event_type" gorm:"not null"`
+	Timestamp time.Time    `json:"timestamp" gorm:"not null;index:idx_job_events_job"`
+	Detail    string       `json:"detail,omitempty"`
+}
+
+// RecordJobEvent appends an event of type t to jobID's history
+func RecordJobEvent(db *gorm.DB, jobID string, t JobEventType, detail string) error {
+	return db.Create(&JobEvent{JobID: jobID, EventType: t, Timestamp: time.Now().UTC(), Detail: detail}).Error
+}
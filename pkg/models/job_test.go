@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/reefline.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDedupeIdempotencyKeysBeforeColumnExists(t *testing.T) {
+	db := openTestDB(t)
+	// The jobs table as created before idempotency keys existed
+	if err := db.Exec("CREATE TABLE jobs (id text PRIMARY KEY, job_id text, user_id text, created_at datetime)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DedupeIdempotencyKeys(db); err != nil {
+		t.Fatalf("dedupe on a table without idempotency_key: %v", err)
+	}
+	if err := db.AutoMigrate(&Job{}); err != nil {
+		t.Fatalf("migrate after dedupe: %v", err)
+	}
+}
+
+func TestDedupeIdempotencyKeys(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec("CREATE TABLE jobs (id text PRIMARY KEY, job_id text, user_id text, idempotency_key text, created_at datetime)").Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rows := []struct {
+		id, user, key string
+		created       time.Time
+	}{
+		{"old", "u1", "k", now.Add(-time.Hour)},
+		{"new", "u1", "k", now},
+		{"other-user", "u2", "k", now.Add(-time.Hour)},
+		{"no-key-1", "u1", "", now},
+		{"no-key-2", "u1", "", now},
+	}
+	for _, r := range rows {
+		if err := db.Exec("INSERT INTO jobs (id, job_id, user_id, idempotency_key, created_at) VALUES (?, ?, ?, ?, ?)",
+			r.id, r.id, r.user, r.key, r.created).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DedupeIdempotencyKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	var keyed []string
+	if err := db.Raw("SELECT id FROM jobs WHERE idempotency_key <> '' ORDER BY id").Scan(&keyed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(keyed) != 2 || keyed[0] != "new" || keyed[1] != "other-user" {
+		t.Errorf("jobs keeping their key = %v, want [new other-user]", keyed)
+	}
+	if err := db.AutoMigrate(&Job{}); err != nil {
+		t.Fatalf("unique index not creatable after dedupe: %v", err)
+	}
+}
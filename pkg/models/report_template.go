@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ReportTemplate stores a user's custom AI report structure and scoring
+// weights as JSON (see agents.ReportTemplate). Users without a row get the
+// REPORT_TEMPLATE_FILE template, or the built-in default.
+type ReportTemplate struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	Content   string    `json:"-" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default GORM table name
+func (ReportTemplate) TableName() string {
+	return "report_templates"
+}
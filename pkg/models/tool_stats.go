@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ToolStats is the materialized run statistics of one scanner tool over all
+// jobs, refreshed periodically so GET /metrics/tools doesn't scan every job.
+type ToolStats struct {
+	Tool          string    `json:"tool" gorm:"primaryKey"` // e.g. "grype", "dockle", "dive"
+	TotalRuns     int       `json:"total_runs"`
+	Successes     int       `json:"successes"`
+	AvgDurationMs int64     `json:"avg_duration_ms"`
+	P50Ms         int64     `json:"p50_ms"`
+	P95Ms         int64     `json:"p95_ms"`
+	P99Ms         int64     `json:"p99_ms"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+// TableName overrides the default GORM table name
+func (ToolStats) TableName() string {
+	return "tool_stats"
+}
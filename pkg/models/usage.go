@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProviderUsage accumulates AI token usage per user, provider and UTC day.
+// Rows are upserted after each completion so BYOK users can see their key spend.
+type ProviderUsage struct {
+	ID               uint      `json:"-" gorm:"primaryKey"`
+	UserID           string    `json:"user_id" gorm:"not null;uniqueIndex:idx_provider_usage_day"`
+	Provider         string    `json:"provider" gorm:"not null;uniqueIndex:idx_provider_usage_day"` // e.g. "openai", "anthropic"
+	Day              time.Time `json:"day" gorm:"type:date;not null;uniqueIndex:idx_provider_usage_day"`
+	Requests         int64     `json:"requests"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	EstimatedCostUSD float64   `json:"estimated_cost_usd"` // Based on list prices; unknown models count as 0
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default GORM table name
+func (ProviderUsage) TableName() string {
+	return "provider_usage"
+}
+
+// UserStorage tracks the artifact bytes stored for a user, for quota enforcement.
+// The worker adds to it on upload; purging a job subtracts its artifacts.
+type UserStorage struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	BytesUsed int64     `json:"bytes_used"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the default GORM table name
+func (UserStorage) TableName() string {
+	return "user_storage"
+}
+
+// AddUserStorage adjusts userID's stored bytes by delta (negative to free),
+// never dropping below zero.
+func AddUserStorage(db *gorm.DB, userID string, delta int64) error {
+	if userID == "" || delta == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"bytes_used": gorm.Expr("GREATEST(user_storage.bytes_used + ?, 0)", delta),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&UserStorage{UserID: userID, BytesUsed: max(delta, 0)}).Error
+}
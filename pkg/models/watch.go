@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Watch is an image tag a user wants rescanned whenever its digest changes
+// upstream. Unlike a scheduled rescan, nothing runs while the image stays the
+// same.
+type Watch struct {
+	ID            string       `json:"id" gorm:"primaryKey"`
+	UserID        string       `json:"user_id" gorm:"not null;uniqueIndex:idx_watch_image"`
+	ImageRef      string       `json:"image_ref" gorm:"not null;uniqueIndex:idx_watch_image"`
+	AnalysisType  AnalysisType `json:"analysis_type" gorm:"default:full"`
+	Digest        string       `json:"digest"`                // Last digest seen; a different one triggers a scan
+	LastCheckedAt *time.Time   `json:"last_checked_at"`       // Last time the digest was resolved
+	LastChangedAt *time.Time   `json:"last_changed_at"`       // Last time the digest changed
+	LastJobID     string       `json:"last_job_id,omitempty"` // Scan started by the last change
+	LastError     string       `json:"last_error,omitempty"`  // Why the last check failed, empty when it succeeded
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// TableName overrides the default GORM table name
+func (Watch) TableName() string {
+	return "watches"
+}
@@ -0,0 +1,284 @@
+// Package scoring computes the report Score Card deterministically from the
+// grype, dockle and dive results, so the numbers in a report never depend on
+// the LLM doing arithmetic.
+package scoring
+
+import "github.com/siddhantprateek/reefline/pkg/tools"
+
+// Metric statuses, rendered as 🟢/🟡/🔴 in reports
+const (
+	StatusGreen  = "green"
+	StatusYellow = "yellow"
+	StatusRed    = "red"
+)
+
+// maxQuickWins caps the quick wins listed in a score card
+const maxQuickWins = 20
+
+// Image efficiency (dive, 0-100%) thresholds
+const (
+	efficiencyGreen  = 90.0
+	efficiencyYellow = 75.0
+)
+
+// Deductions are the points removed from the base score per finding
+type Deductions struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Fatal    int `json:"fatal"` // dockle FATAL
+	Warn     int `json:"warn"`  // dockle WARN
+	Info     int `json:"info"`  // dockle INFO
+}
+
+// Thresholds map a security score to a status: >= Green is green, >= Yellow is yellow, else red
+type Thresholds struct {
+	Green  int `json:"green"`
+	Yellow int `json:"yellow"`
+}
+
+// Weights configure the security score
+type Weights struct {
+	BaseScore  int
+	Deductions Deductions
+	Thresholds Thresholds
+}
+
+// DefaultWeights returns the built-in scoring: start at 100, Critical=-10,
+// High=-5, FATAL=-8, WARN=-3 (INFO free); green at 80+, yellow at 50+.
+func DefaultWeights() Weights {
+	return Weights{
+		BaseScore:  100,
+		Deductions: Deductions{Critical: 10, High: 5, Fatal: 8, Warn: 3},
+		Thresholds: Thresholds{Green: 80, Yellow: 50},
+	}
+}
+
+// Input holds the tool results to score. Any of them may be nil if the tool
+// did not run or failed; its metrics are then omitted.
+type Input struct {
+	Grype  *tools.Scan
+	Dockle *tools.DockleScan
+	Dive   *tools.DiveAnalysis
+
+	// Imported vulnerability counts from another scanner (e.g. Harbor's),
+	// used in place of Grype when it did not run
+	Imported *ImportedScan
+
+	// Cosign signature check from the image inspection, if it ran
+	Signature *tools.SignatureStatus
+}
+
+// ImportedScan is a vulnerability result taken from an external scanner
+type ImportedScan struct {
+	Source string // e.g. "harbor"
+	Counts VulnerabilityCounts
+}
+
+// ScoreCard is the authoritative set of numbers for a report, stored as score.json
+type ScoreCard struct {
+	SecurityScore  int            `json:"security_score"`
+	SecurityStatus string         `json:"security_status"`
+	BaseScore      int            `json:"base_score"`
+	Deducted       map[string]int `json:"deducted"` // Points removed per finding kind
+
+	Vulnerabilities     *VulnerabilityCounts `json:"vulnerabilities,omitempty"`
+	VulnerabilitySource string               `json:"vulnerability_source,omitempty"` // "grype" or the imported scanner
+	CriticalCVEs        *int                 `json:"critical_cves,omitempty"`
+	CriticalStatus      string               `json:"critical_status,omitempty"`
+
+	// Critical/High findings remediable by a version bump (grype only), Critical first
+	FixAvailable *FixCounts             `json:"fix_available,omitempty"`
+	QuickWins    []tools.SummaryFinding `json:"quick_wins,omitempty"` // At most maxQuickWins
+
+	CIS       *CISCounts `json:"cis,omitempty"`
+	CISStatus string     `json:"cis_status,omitempty"`
+
+	Efficiency       *float64 `json:"efficiency,omitempty"` // 0-100%
+	EfficiencyStatus string   `json:"efficiency_status,omitempty"`
+	WastedBytes      *uint64  `json:"wasted_bytes,omitempty"`
+
+	// Supply chain: verified signature green, signed but unverified or not
+	// checked (local source) yellow, unsigned red. Reported only; it doesn't change the security score.
+	Signature       *tools.SignatureStatus `json:"signature,omitempty"`
+	SignatureStatus string                 `json:"signature_status,omitempty"`
+
+	Missing []string `json:"missing,omitempty"` // Tools whose results were unavailable
+}
+
+// VulnerabilityCounts are the grype findings per severity
+type VulnerabilityCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Unknown  int `json:"unknown"`
+	Total    int `json:"total"`
+}
+
+// FixCounts are the Critical and High findings with a fix available
+type FixCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+}
+
+// CISCounts are the dockle checks per level. Passed/Checked excludes skipped checks.
+type CISCounts struct {
+	Fatal   int `json:"fatal"`
+	Warn    int `json:"warn"`
+	Info    int `json:"info"`
+	Passed  int `json:"passed"`
+	Checked int `json:"checked"`
+}
+
+// Compute builds the score card for in. The score is clamped to [0, BaseScore].
+func Compute(in Input, w Weights) *ScoreCard {
+	card := &ScoreCard{
+		BaseScore: w.BaseScore,
+		Deducted:  map[string]int{},
+	}
+	d := w.Deductions
+
+	switch {
+	case in.Grype != nil:
+		t := in.Grype.Tally
+		card.Vulnerabilities = &VulnerabilityCounts{
+			Critical: t.Critical, High: t.High, Medium: t.Medium,
+			Low: t.Low, Unknown: t.Unknown, Total: t.Total,
+		}
+		card.VulnerabilitySource = "grype"
+		card.FixAvailable, card.QuickWins = quickWins(in.Grype)
+	case in.Imported != nil:
+		counts := in.Imported.Counts
+		card.Vulnerabilities = &counts
+		card.VulnerabilitySource = in.Imported.Source
+	}
+
+	if v := card.Vulnerabilities; v != nil {
+		card.deduct("critical", v.Critical*d.Critical)
+		card.deduct("high", v.High*d.High)
+		card.deduct("medium", v.Medium*d.Medium)
+		card.deduct("low", v.Low*d.Low)
+
+		critical := v.Critical
+		card.CriticalCVEs = &critical
+		card.CriticalStatus = StatusGreen
+		if critical > 0 {
+			card.CriticalStatus = StatusRed
+		}
+	} else {
+		card.Missing = append(card.Missing, "grype")
+	}
+
+	if in.Dockle != nil {
+		s := in.Dockle.Summary
+		card.CIS = &CISCounts{
+			Fatal: s.Fatal, Warn: s.Warn, Info: s.Info,
+			Passed: s.Pass, Checked: s.Total - s.Skip,
+		}
+		card.deduct("fatal", s.Fatal*d.Fatal)
+		card.deduct("warn", s.Warn*d.Warn)
+		card.deduct("info", s.Info*d.Info)
+
+		switch {
+		case s.Fatal > 0:
+			card.CISStatus = StatusRed
+		case s.Warn > 0:
+			card.CISStatus = StatusYellow
+		default:
+			card.CISStatus = StatusGreen
+		}
+	} else {
+		card.Missing = append(card.Missing, "dockle")
+	}
+
+	if in.Dive != nil {
+		eff, wasted := in.Dive.Efficiency, in.Dive.WastedBytes
+		card.Efficiency = &eff
+		card.WastedBytes = &wasted
+		switch {
+		case eff >= efficiencyGreen:
+			card.EfficiencyStatus = StatusGreen
+		case eff >= efficiencyYellow:
+			card.EfficiencyStatus = StatusYellow
+		default:
+			card.EfficiencyStatus = StatusRed
+		}
+	} else {
+		card.Missing = append(card.Missing, "dive")
+	}
+
+	if s := in.Signature; s != nil {
+		card.Signature = s
+		switch {
+		case s.Verified:
+			card.SignatureStatus = StatusGreen
+		case s.Signed, s.Skipped != "":
+			card.SignatureStatus = StatusYellow
+		default:
+			card.SignatureStatus = StatusRed
+		}
+	}
+
+	score := w.BaseScore
+	for _, pts := range card.Deducted {
+		score -= pts
+	}
+	card.SecurityScore = max(score, 0)
+	card.SecurityStatus = w.Thresholds.status(card.SecurityScore)
+	return card
+}
+
+// quickWins counts the Critical/High findings with a fix available and lists
+// the first maxQuickWins of them
+func quickWins(scan *tools.Scan) (*FixCounts, []tools.SummaryFinding) {
+	counts := &FixCounts{}
+	wins := []tools.SummaryFinding{}
+	for _, f := range scan.Summary().Findings {
+		if !f.HasFix {
+			continue
+		}
+		if f.Severity == "Critical" {
+			counts.Critical++
+		} else {
+			counts.High++
+		}
+		if len(wins) < maxQuickWins {
+			wins = append(wins, f)
+		}
+	}
+	return counts, wins
+}
+
+// deduct records pts against kind, skipping zero deductions
+func (c *ScoreCard) deduct(kind string, pts int) {
+	if pts > 0 {
+		c.Deducted[kind] = pts
+	}
+}
+
+// status maps a score to a status
+func (t Thresholds) status(score int) string {
+	switch {
+	case score >= t.Green:
+		return StatusGreen
+	case score >= t.Yellow:
+		return StatusYellow
+	default:
+		return StatusRed
+	}
+}
+
+// Emoji returns the report marker for a status
+func Emoji(status string) string {
+	switch status {
+	case StatusGreen:
+		return "🟢"
+	case StatusYellow:
+		return "🟡"
+	case StatusRed:
+		return "🔴"
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/siddhantprateek/reefline/pkg/tools"
+)
+
+func TestCompute(t *testing.T) {
+	grype := &tools.Scan{}
+	grype.Tally.Critical, grype.Tally.High, grype.Tally.Total = 2, 3, 5
+	dockle := &tools.DockleScan{Summary: tools.DockleSummary{Warn: 1, Pass: 10, Skip: 2, Total: 13}}
+
+	card := Compute(Input{Grype: grype, Dockle: dockle}, DefaultWeights())
+
+	// 100 - 2*10 - 3*5 - 1*3
+	if card.SecurityScore != 62 || card.SecurityStatus != StatusYellow {
+		t.Errorf("score = %d (%s), want 62 (yellow)", card.SecurityScore, card.SecurityStatus)
+	}
+	if card.CIS.Checked != 11 || card.CISStatus != StatusYellow {
+		t.Errorf("cis = %+v (%s), want 11 checked (yellow)", card.CIS, card.CISStatus)
+	}
+	if *card.CriticalCVEs != 2 || card.CriticalStatus != StatusRed {
+		t.Errorf("critical = %d (%s), want 2 (red)", *card.CriticalCVEs, card.CriticalStatus)
+	}
+	if len(card.Missing) != 1 || card.Missing[0] != "dive" {
+		t.Errorf("missing = %v, want [dive]", card.Missing)
+	}
+}
+
+func TestComputeDockleWeights(t *testing.T) {
+	dockle := &tools.DockleScan{Summary: tools.DockleSummary{Fatal: 1, Warn: 2, Info: 4, Total: 7}}
+	w := DefaultWeights()
+	w.Deductions.Fatal, w.Deductions.Warn, w.Deductions.Info = 20, 1, 2
+
+	card := Compute(Input{Dockle: dockle}, w)
+
+	// 100 - 1*20 - 2*1 - 4*2
+	if card.SecurityScore != 70 {
+		t.Errorf("score = %d, want 70", card.SecurityScore)
+	}
+	if card.Deducted["fatal"] != 20 || card.Deducted["warn"] != 2 || card.Deducted["info"] != 8 {
+		t.Errorf("deducted = %v, want fatal=20 warn=2 info=8", card.Deducted)
+	}
+}
+
+func TestComputeClampsAtZero(t *testing.T) {
+	grype := &tools.Scan{}
+	grype.Tally.Critical = 50
+
+	card := Compute(Input{Grype: grype}, DefaultWeights())
+	if card.SecurityScore != 0 || card.SecurityStatus != StatusRed {
+		t.Errorf("score = %d (%s), want 0 (red)", card.SecurityScore, card.SecurityStatus)
+	}
+}
+
+func TestComputeSignature(t *testing.T) {
+	tests := []struct {
+		status *tools.SignatureStatus
+		want   string
+	}{
+		{&tools.SignatureStatus{Signed: true, Verified: true}, StatusGreen},
+		{&tools.SignatureStatus{Signed: true}, StatusYellow},
+		{&tools.SignatureStatus{Skipped: "not verified (local docker source)"}, StatusYellow},
+		{&tools.SignatureStatus{}, StatusRed},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		card := Compute(Input{Signature: tt.status}, DefaultWeights())
+		if card.SignatureStatus != tt.want || card.SecurityScore != 100 {
+			t.Errorf("signature %+v: status = %q, score = %d; want %q, 100", tt.status, card.SignatureStatus, card.SecurityScore, tt.want)
+		}
+	}
+}
+
+func TestComputeImported(t *testing.T) {
+	imported := &ImportedScan{Source: "harbor", Counts: VulnerabilityCounts{High: 2, Total: 2}}
+
+	card := Compute(Input{Imported: imported}, DefaultWeights())
+	if card.SecurityScore != 90 || card.VulnerabilitySource != "harbor" {
+		t.Errorf("score = %d (%s), want 90 (harbor)", card.SecurityScore, card.VulnerabilitySource)
+	}
+	for _, m := range card.Missing {
+		if m == "grype" {
+			t.Errorf("missing = %v, imported scan should count as vulnerability data", card.Missing)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// CompressArtifacts gzips artifacts written with PutArtifact. Enabled unless
+// ARTIFACT_COMPRESSION=false.
+var CompressArtifacts = os.Getenv("ARTIFACT_COMPRESSION") != "false"
+
+// minCompressSize is the size below which compression isn't worth it
+const minCompressSize = 1 << 10
+
+// gzipMagic prefixes every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// PutArtifact stores data as objectName, gzipped (Content-Encoding: gzip) when
+// compression is enabled and actually saves space. Returns the bytes stored.
+func PutArtifact(ctx context.Context, bucket, objectName string, data []byte, contentType string) (int64, error) {
+	body, encoding := data, ""
+	if CompressArtifacts && len(data) >= minCompressSize {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return 0, fmt.Errorf("failed to compress %s: %w", objectName, err)
+		}
+		if err := zw.Close(); err != nil {
+			return 0, fmt.Errorf("failed to compress %s: %w", objectName, err)
+		}
+		if buf.Len() < len(data) {
+			body, encoding = buf.Bytes(), "gzip"
+			log.Printf("Compressed %s: %d -> %d bytes (%.1fx)", objectName, len(data), buf.Len(), float64(len(data))/float64(buf.Len()))
+		}
+	}
+
+	_, err := Client.PutObject(ctx, bucket, objectName, bytes.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+		ContentType:     contentType,
+		ContentEncoding: encoding,
+		UserMetadata:    userMetadata(ctx),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload file: %w", err)
+	}
+	return int64(len(body)), nil
+}
+
+// streamPartSize is the multipart part size for streamed uploads. With an
+// unknown length minio-go would otherwise buffer parts sized for a 5 TiB
+// object; this bounds a streamed upload's memory to one part.
+const streamPartSize = 16 << 20
+
+// PutJSONArtifact encodes v as JSON straight into objectName through an
+// io.Pipe (gzipped when compression is enabled), so the serialized artifact
+// is never held in memory in full. Returns the bytes stored and the size of
+// the uncompressed JSON.
+func PutJSONArtifact(ctx context.Context, bucket, objectName string, v interface{}) (stored, size int64, err error) {
+	pr, pw := io.Pipe()
+	encoded := make(chan int64, 1)
+	go func() {
+		var w io.Writer = pw
+		var zw *gzip.Writer
+		if CompressArtifacts {
+			zw = gzip.NewWriter(pw)
+			w = zw
+		}
+		cw := &countingWriter{w: w}
+		err := json.NewEncoder(cw).Encode(v)
+		if zw != nil && err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err) // A nil error ends the upload with EOF
+		encoded <- cw.n
+	}()
+
+	encoding := ""
+	if CompressArtifacts {
+		encoding = "gzip"
+	}
+	info, err := Client.PutObject(ctx, bucket, objectName, pr, -1, minio.PutObjectOptions{
+		ContentType:     "application/json",
+		ContentEncoding: encoding,
+		UserMetadata:    userMetadata(ctx),
+		PartSize:        streamPartSize,
+	})
+	// Unblock the encoder if the upload gave up before reading everything
+	pr.CloseWithError(err)
+	size = <-encoded
+	if err != nil {
+		return 0, size, fmt.Errorf("failed to upload %s: %w", objectName, err)
+	}
+	return info.Size, size, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Artifact is an open stored artifact. Read returns the decompressed content;
+// Raw exposes the stored bytes for passing gzip through to HTTP clients.
+type Artifact struct {
+	io.Reader
+	Gzipped bool
+	Raw     io.Reader
+	object  *minio.Object
+}
+
+// Close releases the underlying object
+func (a *Artifact) Close() error {
+	return a.object.Close()
+}
+
+// OpenArtifact opens objectName, transparently decompressing gzipped
+// artifacts. Objects stored before compression was enabled read as-is.
+func OpenArtifact(ctx context.Context, bucket, objectName string) (*Artifact, error) {
+	object, err := DownloadFile(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := bufio.NewReader(object)
+	head, err := raw.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		object.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", objectName, err)
+	}
+
+	a := &Artifact{Reader: raw, Raw: raw, object: object}
+	if bytes.Equal(head, gzipMagic) {
+		zr, err := gzip.NewReader(raw)
+		if err != nil {
+			object.Close()
+			return nil, fmt.Errorf("failed to decompress %s: %w", objectName, err)
+		}
+		a.Reader, a.Gzipped = zr, true
+	}
+	return a, nil
+}
+
+// ReadArtifact returns the full decompressed content of objectName.
+func ReadArtifact(ctx context.Context, bucket, objectName string) ([]byte, error) {
+	a, err := OpenArtifact(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	return io.ReadAll(a)
+}
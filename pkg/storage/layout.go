@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Artifact layouts, selected with ARTIFACT_LAYOUT
+const (
+	LayoutJob    = "job"    // {bucket}/{jobID}/artifacts/{name} (default)
+	LayoutUser   = "user"   // {bucket}/{userID}/{jobID}/artifacts/{name}
+	LayoutBucket = "bucket" // {bucket}-{userID}/{jobID}/artifacts/{name}
+)
+
+// Location is where a job's artifacts are stored
+type Location struct {
+	Bucket string
+	Prefix string // Job root, always ending in "/"
+}
+
+// Key returns the object name of an artifact, e.g. "grype.json"
+func (l Location) Key(name string) string {
+	return l.ArtifactsPrefix() + name
+}
+
+// ArtifactsPrefix returns the prefix all of the job's artifacts share
+func (l Location) ArtifactsPrefix() string {
+	return l.Prefix + "artifacts/"
+}
+
+// ArtifactLayout returns the configured layout, defaulting to LayoutJob
+func ArtifactLayout() string {
+	switch layout := strings.ToLower(os.Getenv("ARTIFACT_LAYOUT")); layout {
+	case LayoutUser, LayoutBucket:
+		return layout
+	default:
+		return LayoutJob
+	}
+}
+
+// ArtifactLocation returns where the artifacts of jobID, owned by userID, live
+// under the configured layout. Isolating by user keeps per-tenant retention
+// and quotas a matter of prefix or bucket policies.
+func ArtifactLocation(userID, jobID string) Location {
+	bucket := GetConfigFromEnv().DefaultBucket
+	if userID == "" {
+		return Location{Bucket: bucket, Prefix: jobID + "/"}
+	}
+
+	switch ArtifactLayout() {
+	case LayoutUser:
+		return Location{Bucket: bucket, Prefix: fmt.Sprintf("%s/%s/", sanitizeSegment(userID), jobID)}
+	case LayoutBucket:
+		return Location{Bucket: userBucket(bucket, userID), Prefix: jobID + "/"}
+	default:
+		return Location{Bucket: bucket, Prefix: jobID + "/"}
+	}
+}
+
+// EnsureBucket creates bucket if it does not exist yet (per-user buckets are
+// created lazily on first upload). With MINIO_AUTO_CREATE_BUCKET=false a
+// missing bucket is an error instead, since the credentials are not expected
+// to be allowed to create one.
+func EnsureBucket(ctx context.Context, bucket string) error {
+	exists, err := Client.BucketExists(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check existence of bucket %s: %w", bucket, err)
+	}
+	if exists {
+		return nil
+	}
+	if !autoCreate {
+		return fmt.Errorf("bucket %s does not exist and MINIO_AUTO_CREATE_BUCKET=false: create it (or point MINIO_DEFAULT_BUCKET at an existing one)", bucket)
+	}
+	if err := Client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+	}
+	log.Printf("Created bucket: %s", bucket)
+	return nil
+}
+
+var invalidBucketChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// userBucket derives a valid S3 bucket name (3-63 chars of [a-z0-9-]) for userID
+func userBucket(base, userID string) string {
+	name := base + "-" + invalidBucketChars.ReplaceAllString(strings.ToLower(userID), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "-")
+}
+
+// sanitizeSegment keeps a user ID from escaping its prefix
+func sanitizeSegment(s string) string {
+	s = strings.NewReplacer("/", "_", "\\", "_").Replace(s)
+	if s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ArtifactMeta describes what produced an artifact. It's stored on the object
+// as user metadata (x-amz-meta-job-id, -tool, -tool-version, -image), so the
+// bucket can be listed and accounted for without the database.
+type ArtifactMeta struct {
+	JobID       string `json:"job_id,omitempty"`
+	Tool        string `json:"tool,omitempty"`
+	ToolVersion string `json:"tool_version,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// User metadata keys; minio-go adds the x-amz-meta- prefix
+const (
+	metaJobID       = "job-id"
+	metaTool        = "tool"
+	metaToolVersion = "tool-version"
+	metaImage       = "image"
+)
+
+type artifactMetaKey struct{}
+
+// WithArtifactMeta returns a context whose uploads (PutArtifact,
+// PutJSONArtifact, UploadFile) carry meta. Empty fields keep the value
+// already set on ctx, so a job-wide context can be narrowed per tool.
+func WithArtifactMeta(ctx context.Context, meta ArtifactMeta) context.Context {
+	prev := artifactMetaFor(ctx)
+	if meta.JobID == "" {
+		meta.JobID = prev.JobID
+	}
+	if meta.Tool == "" {
+		meta.Tool = prev.Tool
+	}
+	if meta.ToolVersion == "" {
+		meta.ToolVersion = prev.ToolVersion
+	}
+	if meta.Image == "" {
+		meta.Image = prev.Image
+	}
+	return context.WithValue(ctx, artifactMetaKey{}, meta)
+}
+
+// artifactMetaFor returns the artifact metadata carried by ctx
+func artifactMetaFor(ctx context.Context) ArtifactMeta {
+	meta, _ := ctx.Value(artifactMetaKey{}).(ArtifactMeta)
+	return meta
+}
+
+// userMetadata returns the metadata carried by ctx as minio user metadata,
+// or nil when there is none
+func userMetadata(ctx context.Context) map[string]string {
+	meta := artifactMetaFor(ctx)
+	m := map[string]string{}
+	for key, value := range map[string]string{
+		metaJobID:       meta.JobID,
+		metaTool:        meta.Tool,
+		metaToolVersion: meta.ToolVersion,
+		metaImage:       meta.Image,
+	} {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// ObjectArtifactMeta reads the artifact metadata back from a listed or
+// stat'ed object. Objects stored before metadata was added return a zero value.
+func ObjectArtifactMeta(info minio.ObjectInfo) ArtifactMeta {
+	var meta ArtifactMeta
+	for key, value := range info.UserMetadata {
+		switch strings.TrimPrefix(strings.ToLower(key), "x-amz-meta-") {
+		case metaJobID:
+			meta.JobID = value
+		case metaTool:
+			meta.Tool = value
+		case metaToolVersion:
+			meta.ToolVersion = value
+		case metaImage:
+			meta.Image = value
+		}
+	}
+	return meta
+}
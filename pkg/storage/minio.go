@@ -19,49 +19,69 @@ type Config struct {
 	Endpoint        string
 	AccessKeyID     string
 	SecretAccessKey string
+	SessionToken    string // For temporary (STS) credentials
 	UseSSL          bool
 	DefaultBucket   string
+	Region          string // Required by some S3-compatible backends (Wasabi, Backblaze)
+	BucketLookup    minio.BucketLookupType
+	AutoCreate      bool // Create missing buckets; disable when they are pre-provisioned
 }
 
 // GetConfigFromEnv loads MinIO configuration from environment variables
 func GetConfigFromEnv() *Config {
 	useSSL := os.Getenv("MINIO_USE_SSL") == "true"
+
+	// MINIO_PATH_STYLE=true forces path-style (host/bucket) addressing, false
+	// forces virtual-host style (bucket.host); unset lets minio-go pick
+	lookup := minio.BucketLookupAuto
+	switch os.Getenv("MINIO_PATH_STYLE") {
+	case "true":
+		lookup = minio.BucketLookupPath
+	case "false":
+		lookup = minio.BucketLookupDNS
+	}
+
 	return &Config{
 		Endpoint:        getEnv("MINIO_ENDPOINT", "localhost:9000"),
 		AccessKeyID:     getEnv("MINIO_ACCESS_KEY", "minioadmin"),
 		SecretAccessKey: getEnv("MINIO_SECRET_KEY", "minioadmin"),
+		SessionToken:    os.Getenv("MINIO_SESSION_TOKEN"),
 		UseSSL:          useSSL,
 		DefaultBucket:   getEnv("MINIO_DEFAULT_BUCKET", "reefline"),
+		Region:          os.Getenv("MINIO_REGION"),
+		BucketLookup:    lookup,
+		AutoCreate:      os.Getenv("MINIO_AUTO_CREATE_BUCKET") != "false",
 	}
 }
 
+// Bucket creation settings from the Config passed to Initialize
+var (
+	region     string // Region new buckets are created in
+	autoCreate = true
+)
+
 // Initialize creates a new MinIO client and ensures the default bucket exists
 func Initialize(config *Config) (*minio.Client, error) {
 	client, err := minio.New(config.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
-		Secure: config.UseSSL,
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, config.SessionToken),
+		Secure:       config.UseSSL,
+		Region:       config.Region,
+		BucketLookup: config.BucketLookup,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
 
 	Client = client
+	region = config.Region
+	autoCreate = config.AutoCreate
 
-	// Create default bucket if it doesn't exist
+	// Create default bucket if it doesn't exist (or just verify it when auto-creation is off)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	exists, err := client.BucketExists(ctx, config.DefaultBucket)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check bucket existence: %w", err)
-	}
-
-	if !exists {
-		err = client.MakeBucket(ctx, config.DefaultBucket, minio.MakeBucketOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create default bucket: %w", err)
-		}
-		log.Printf("Created default bucket: %s", config.DefaultBucket)
+	if err := EnsureBucket(ctx, config.DefaultBucket); err != nil {
+		return nil, err
 	}
 
 	log.Println("Successfully connected to MinIO storage")
@@ -71,7 +91,8 @@ func Initialize(config *Config) (*minio.Client, error) {
 // UploadFile uploads a file to the specified bucket
 func UploadFile(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) (*minio.UploadInfo, error) {
 	info, err := Client.PutObject(ctx, bucket, objectName, reader, size, minio.PutObjectOptions{
-		ContentType: contentType,
+		ContentType:  contentType,
+		UserMetadata: userMetadata(ctx),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload file: %w", err)
@@ -88,6 +109,20 @@ func DownloadFile(ctx context.Context, bucket, objectName string) (*minio.Object
 	return object, nil
 }
 
+// Exists reports whether an object exists in the specified bucket.
+// A missing object returns (false, nil); any other failure is returned as an error.
+func Exists(ctx context.Context, bucket, objectName string) (bool, error) {
+	_, err := Client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchObject":
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to stat file: %w", err)
+}
+
 // DeleteFile removes a file from the specified bucket
 func DeleteFile(ctx context.Context, bucket, objectName string) error {
 	err := Client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{})
@@ -110,8 +145,9 @@ func GetPresignedURL(ctx context.Context, bucket, objectName string, expiry time
 func ListFiles(ctx context.Context, bucket, prefix string) ([]minio.ObjectInfo, error) {
 	var objects []minio.ObjectInfo
 	objectCh := Client.ListObjects(ctx, bucket, minio.ListObjectsOptions{
-		Prefix:    prefix,
-		Recursive: true,
+		Prefix:       prefix,
+		Recursive:    true,
+		WithMetadata: true, // MinIO returns user metadata (see ObjectArtifactMeta)
 	})
 
 	for object := range objectCh {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/siddhantprateek/reefline/pkg/version"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
@@ -91,7 +92,7 @@ func GetConfigFromEnv() TelemetryConfig {
 
 	serviceVersion := os.Getenv("OTEL_SERVICE_VERSION")
 	if serviceVersion == "" {
-		serviceVersion = "1.0.0"
+		serviceVersion = version.Version
 	}
 
 	environment := os.Getenv("ENVIRONMENT")
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+)
+
+type imageAuthKey struct{}
+
+// WithImageAuth returns a context carrying registry credentials for the image
+// being analyzed. The inspector, grype, dockle and dive all pick them up, so
+// private images can be pulled without a pre-configured docker login.
+func WithImageAuth(ctx context.Context, auth *ImageAuth) context.Context {
+	if auth == nil || auth.Username == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, imageAuthKey{}, auth)
+}
+
+// imageAuthFor returns the registry credentials carried by ctx, or nil
+func imageAuthFor(ctx context.Context) *ImageAuth {
+	auth, _ := ctx.Value(imageAuthKey{}).(*ImageAuth)
+	return auth
+}
+
+// RegistryHost returns the registry domain of an image reference, e.g.
+// "ghcr.io" for "ghcr.io/org/app:1.0" and "docker.io" for "nginx".
+// Returns "" for local docker-archive/OCI inputs and unparsable refs.
+func RegistryHost(imageName string) string {
+	if in, err := ParseImageInput(imageName, ""); err == nil {
+		if in.Transport != SourceRemote {
+			return ""
+		}
+		imageName = in.Ref
+	}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+// dockerAuthConfig converts the credentials carried by ctx for containers/image
+func dockerAuthConfig(ctx context.Context) *types.DockerAuthConfig {
+	auth := imageAuthFor(ctx)
+	if auth == nil {
+		return nil
+	}
+	return &types.DockerAuthConfig{Username: auth.Username, Password: auth.Password}
+}
+
+// registryCredentials converts the credentials carried by ctx for syft/stereoscope
+func registryCredentials(ctx context.Context, imageName string) []image.RegistryCredentials {
+	auth := imageAuthFor(ctx)
+	if auth == nil {
+		return nil
+	}
+	authority := RegistryHost(imageName)
+	if authority == "docker.io" {
+		// go-containerregistry names Docker Hub by its index host
+		authority = "index.docker.io"
+	}
+	return []image.RegistryCredentials{{
+		Authority: authority,
+		Username:  auth.Username,
+		Password:  auth.Password,
+	}}
+}
@@ -0,0 +1,42 @@
+package tools
+
+import "testing"
+
+func TestRegistryHost(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                          "docker.io",
+		"library/alpine:3.19":            "docker.io",
+		"ghcr.io/org/app:1.0":            "ghcr.io",
+		"harbor.example.com:8443/p/r:v2": "harbor.example.com:8443",
+		"docker://quay.io/prom/node":     "quay.io",
+		"docker-archive:/tmp/image.tar":  "",
+	}
+
+	for ref, want := range cases {
+		if got := RegistryHost(ref); got != want {
+			t.Errorf("RegistryHost(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestInsecureRegistry(t *testing.T) {
+	defer func(prev []string) { InsecureRegistries = prev }(InsecureRegistries)
+	InsecureRegistries = ParseRegistryPatterns(" Harbor.Internal , *.corp.example,registry.lan:5000,")
+
+	cases := map[string]bool{
+		"harbor.internal/p/app:1":       true,
+		"harbor.internal:8443/p/app:1":  true,
+		"build.corp.example/app":        true,
+		"corp.example/app":              false,
+		"registry.lan:5000/app":         true,
+		"registry.lan:5001/app":         false,
+		"nginx":                         false,
+		"ghcr.io/org/app:1.0":           false,
+		"docker-archive:/tmp/image.tar": false,
+	}
+	for ref, want := range cases {
+		if got := insecureRegistry(ref); got != want {
+			t.Errorf("insecureRegistry(%q) = %t, want %t", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	defaultBlobCacheMaxBytes = 10 << 30 // 10 GiB
+)
+
+// Global layer/blob cache shared by the inspector and dive analyzer.
+// Nil disables caching.
+var LayerCache *BlobCache
+
+// BlobCacheConfig holds configuration for the on-disk blob cache
+type BlobCacheConfig struct {
+	Dir      string `json:"dir"`
+	MaxBytes int64  `json:"maxBytes"` // Oldest blobs are evicted once exceeded
+}
+
+// BlobCache is a content-addressed on-disk cache for image layers and configs.
+// Blobs are stored as {Dir}/{algorithm}/{hex} and verified against their digest on write.
+type BlobCache struct {
+	mx      sync.Mutex
+	config  BlobCacheConfig
+	log     *slog.Logger
+	entries []blobEntry // Cached blobs, least recently written first
+	total   int64       // Sum of entries' sizes
+
+	hits       atomic.Int64
+	misses     atomic.Int64
+	bytesSaved atomic.Int64
+}
+
+// blobEntry is one cached blob as tracked for eviction
+type blobEntry struct {
+	path string
+	size int64
+}
+
+// BlobCacheStats reports cache effectiveness since startup
+type BlobCacheStats struct {
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	BytesSaved int64 `json:"bytesSaved"`
+}
+
+// NewBlobCache creates a blob cache rooted at cfg.Dir
+func NewBlobCache(cfg BlobCacheConfig, l *slog.Logger) (*BlobCache, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "reefline-blobs")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultBlobCacheMaxBytes
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob cache dir: %w", err)
+	}
+	c := &BlobCache{
+		config: cfg,
+		log:    l.With("subsys", "blob-cache"),
+	}
+	c.load()
+	return c, nil
+}
+
+// load indexes the blobs already on disk, oldest first, so the running total
+// starts from what a previous run left behind
+func (c *BlobCache) load() {
+	type found struct {
+		blobEntry
+		mod int64
+	}
+	var blobs []found
+	_ = filepath.Walk(c.config.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Base(p)[0] == '.' {
+			return nil
+		}
+		blobs = append(blobs, found{blobEntry{path: p, size: info.Size()}, info.ModTime().UnixNano()})
+		return nil
+	})
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].mod < blobs[j].mod })
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for _, b := range blobs {
+		c.entries = append(c.entries, b.blobEntry)
+		c.total += b.size
+	}
+	c.prune("")
+}
+
+func (c *BlobCache) path(d digest.Digest) string {
+	return filepath.Join(c.config.Dir, d.Algorithm().String(), d.Encoded())
+}
+
+// Open returns a reader for a cached blob and its size, recording a hit.
+// ok is false when the blob is not cached.
+func (c *BlobCache) Open(d digest.Digest) (rc io.ReadCloser, size int64, ok bool) {
+	rc, size, ok = c.open(d)
+	if ok {
+		c.hits.Add(1)
+		c.bytesSaved.Add(size)
+	} else {
+		c.misses.Add(1)
+	}
+	return rc, size, ok
+}
+
+// open returns a reader for a cached blob without touching the counters
+func (c *BlobCache) open(d digest.Digest) (io.ReadCloser, int64, bool) {
+	if d.Validate() != nil {
+		return nil, 0, false
+	}
+	f, err := os.Open(c.path(d))
+	if err != nil {
+		return nil, 0, false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, false
+	}
+	return f, info.Size(), true
+}
+
+// Put stores the content of r under d, verifying that it matches the digest,
+// and returns an open reader for it with its size. The blob just written is
+// never evicted to make room, and one larger than MaxBytes is handed back
+// without being cached.
+func (c *BlobCache) Put(d digest.Digest, r io.Reader) (io.ReadCloser, int64, error) {
+	if err := d.Validate(); err != nil {
+		return nil, 0, fmt.Errorf("invalid digest %q: %w", d, err)
+	}
+	dst := c.path(d)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return nil, 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".partial-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	// The open handle outlives the name on unlink, and rename keeps it valid
+	defer os.Remove(tmp.Name())
+
+	verifier := d.Verifier()
+	size, err := io.Copy(io.MultiWriter(tmp, verifier), r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, 0, fmt.Errorf("failed to write blob %s: %w", d, err)
+	}
+	if !verifier.Verified() {
+		tmp.Close()
+		return nil, 0, fmt.Errorf("blob %s failed digest verification", d)
+	}
+	if size > c.config.MaxBytes {
+		c.log.Debug("Blob exceeds cache size, not caching", "digest", d, "size", size)
+		return tmp, size, nil
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		tmp.Close()
+		return nil, 0, err
+	}
+	c.forget(dst)
+	c.entries = append(c.entries, blobEntry{path: dst, size: size})
+	c.total += size
+	c.prune(dst)
+	return tmp, size, nil
+}
+
+// Stats returns cache hit/miss counters and total bytes served from disk
+func (c *BlobCache) Stats() BlobCacheStats {
+	return BlobCacheStats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		BytesSaved: c.bytesSaved.Load(),
+	}
+}
+
+// forget drops path from the index if it is there. Callers hold c.mx.
+func (c *BlobCache) forget(path string) {
+	c.entries = slices.DeleteFunc(c.entries, func(e blobEntry) bool {
+		if e.path == path {
+			c.total -= e.size
+			return true
+		}
+		return false
+	})
+}
+
+// prune evicts the least recently written blobs until the cache fits
+// MaxBytes, sparing pinned (the blob being inserted). Callers hold c.mx.
+func (c *BlobCache) prune(pinned string) {
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if c.total <= c.config.MaxBytes || e.path == pinned {
+			kept = append(kept, e)
+			continue
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			kept = append(kept, e)
+			continue
+		}
+		c.total -= e.size
+		c.log.Debug("Evicted blob", "path", e.path, "size", e.size)
+	}
+	c.entries = kept
+}
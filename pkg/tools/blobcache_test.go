@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func putBlob(t *testing.T, c *BlobCache, content string) digest.Digest {
+	t.Helper()
+	d := digest.FromString(content)
+	rc, size, err := c.Put(d, bytes.NewBufferString(content))
+	if err != nil {
+		t.Fatalf("Put(%q): %v", content, err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content || size != int64(len(content)) {
+		t.Errorf("Put(%q) returned %q (%d bytes)", content, got, size)
+	}
+	return d
+}
+
+func TestBlobCachePutKeepsNewestBlob(t *testing.T) {
+	c, err := NewBlobCache(BlobCacheConfig{Dir: t.TempDir(), MaxBytes: 10}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := putBlob(t, c, "aaaaaa")
+	second := putBlob(t, c, "bbbbbb")
+	if _, _, ok := c.open(first); ok {
+		t.Error("oldest blob not evicted")
+	}
+	if _, _, ok := c.open(second); !ok {
+		t.Error("blob just written was evicted")
+	}
+	if c.total != 6 {
+		t.Errorf("running total = %d, want 6", c.total)
+	}
+
+	// Larger than the whole cache: served but not cached
+	big := putBlob(t, c, "cccccccccccc")
+	if _, _, ok := c.open(big); ok {
+		t.Error("blob larger than MaxBytes was cached")
+	}
+	if _, _, ok := c.open(second); !ok {
+		t.Error("oversized blob evicted the cache")
+	}
+
+	// A new cache picks up what is already on disk
+	reopened, err := NewBlobCache(c.config, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.total != 6 || len(reopened.entries) != 1 {
+		t.Errorf("reloaded %d blobs totalling %d, want 1 totalling 6", len(reopened.entries), reopened.total)
+	}
+}
+
+func TestBlobCachePutRejectsBadDigest(t *testing.T) {
+	c, err := NewBlobCache(BlobCacheConfig{Dir: t.TempDir()}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Put(digest.FromString("expected"), bytes.NewBufferString("other")); err == nil {
+		t.Fatal("mismatched content accepted")
+	}
+	if c.total != 0 || len(c.entries) != 0 {
+		t.Error("rejected blob was indexed")
+	}
+}
@@ -0,0 +1,542 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// Cosign attachment media types and annotations
+const (
+	cosignSignatureType   = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignAttestationType = "application/vnd.dsse.envelope.v1+json"
+	cosignSignatureAnn    = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnn  = "dev.sigstore.cosign/certificate"
+	cosignChainAnn        = "dev.sigstore.cosign/chain"
+	cosignBundleAnn       = "dev.sigstore.cosign/bundle"
+	inTotoPayloadType     = "application/vnd.in-toto+json"
+
+	// maxAttachmentBlob bounds the signature/attestation blobs read per layer
+	maxAttachmentBlob = 4 << 20
+)
+
+// Fulcio certificate extensions naming the OIDC issuer of the signer
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidFulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+)
+
+// SignatureConfig configures cosign signature and attestation verification.
+// A signature verifies against any of PublicKeys, or keylessly against a
+// Fulcio certificate whose identity matches Identity (and OIDCIssuer, if set),
+// logged in Rekor.
+type SignatureConfig struct {
+	PublicKeys     []crypto.PublicKey
+	FulcioRoots    *x509.CertPool
+	Identity       *regexp.Regexp
+	OIDCIssuer     string
+	RekorPublicKey crypto.PublicKey
+}
+
+// SignatureStatus is the outcome of checking an image's cosign signatures and
+// SLSA provenance. An unsigned image is a finding, not an error.
+type SignatureStatus struct {
+	Signed     bool              `json:"signed"`               // At least one cosign signature is attached
+	Verified   bool              `json:"verified"`             // A signature verified against the configured key or identity
+	Method     string            `json:"method,omitempty"`     // "key" or "keyless"
+	Signer     string            `json:"signer,omitempty"`     // Keyless certificate identity (email or URI)
+	Issuer     string            `json:"issuer,omitempty"`     // Keyless OIDC issuer
+	Signatures int               `json:"signatures"`           // Signatures attached
+	Provenance *ProvenanceStatus `json:"provenance,omitempty"` // Nil when no SLSA provenance is attached
+	Error      string            `json:"error,omitempty"`      // Why nothing verified, or why the lookup failed
+	Skipped    string            `json:"skipped,omitempty"`    // Why no check ran, e.g. the image came from a local daemon
+}
+
+// ProvenanceStatus describes an SLSA provenance attestation
+type ProvenanceStatus struct {
+	PredicateType string `json:"predicateType"`
+	Verified      bool   `json:"verified"`
+	BuilderID     string `json:"builderId,omitempty"`
+}
+
+// SignatureConfigFromEnv builds the verification config. Returns nil unless
+// IMAGE_SIGNATURE_CHECK_ENABLED=true. Without COSIGN_PUBLIC_KEY or
+// COSIGN_CERTIFICATE_IDENTITY_REGEXP, images are only checked for the presence
+// of signatures.
+//
+//   - COSIGN_PUBLIC_KEY: PEM public key, or a path to one
+//   - COSIGN_CERTIFICATE_IDENTITY_REGEXP: keyless signer identity (email or URI)
+//   - COSIGN_CERTIFICATE_OIDC_ISSUER: keyless OIDC issuer, e.g. https://token.actions.githubusercontent.com
+//   - COSIGN_FULCIO_ROOTS / COSIGN_REKOR_PUBLIC_KEY: PEM (or paths) of the Fulcio CA and Rekor key, required for keyless
+func SignatureConfigFromEnv() (*SignatureConfig, error) {
+	if os.Getenv("IMAGE_SIGNATURE_CHECK_ENABLED") != "true" {
+		return nil, nil
+	}
+	cfg := &SignatureConfig{OIDCIssuer: os.Getenv("COSIGN_CERTIFICATE_OIDC_ISSUER")}
+
+	if v := os.Getenv("COSIGN_PUBLIC_KEY"); v != "" {
+		keys, err := parsePublicKeys(pemValue(v))
+		if err != nil {
+			return nil, fmt.Errorf("COSIGN_PUBLIC_KEY: %w", err)
+		}
+		cfg.PublicKeys = keys
+	}
+
+	if v := os.Getenv("COSIGN_CERTIFICATE_IDENTITY_REGEXP"); v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("COSIGN_CERTIFICATE_IDENTITY_REGEXP: %w", err)
+		}
+		cfg.Identity = re
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pemValue(os.Getenv("COSIGN_FULCIO_ROOTS"))) {
+			return nil, fmt.Errorf("COSIGN_FULCIO_ROOTS: keyless verification needs the Fulcio root certificates")
+		}
+		cfg.FulcioRoots = roots
+
+		keys, err := parsePublicKeys(pemValue(os.Getenv("COSIGN_REKOR_PUBLIC_KEY")))
+		if err != nil || len(keys) == 0 {
+			return nil, fmt.Errorf("COSIGN_REKOR_PUBLIC_KEY: keyless verification needs the Rekor public key")
+		}
+		cfg.RekorPublicKey = keys[0]
+	}
+	return cfg, nil
+}
+
+// pemValue returns v if it holds PEM, else the contents of the file it names
+func pemValue(v string) []byte {
+	if v == "" || strings.Contains(v, "-----BEGIN") {
+		return []byte(v)
+	}
+	data, err := os.ReadFile(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// parsePublicKeys decodes every PUBLIC KEY block in data
+func parsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM public key found")
+	}
+	return keys, nil
+}
+
+// attachmentLayer is one layer of a cosign .sig or .att attachment
+type attachmentLayer struct {
+	MediaType   string
+	Annotations map[string]string
+	Blob        []byte
+}
+
+// VerifySignatures looks up the cosign signatures (<repo>:sha256-<hex>.sig)
+// and attestations (.att) attached to the image with manifest digest
+// imageDigest, and verifies them. Registry failures are reported in the
+// status' Error rather than returned.
+func (cfg *SignatureConfig) VerifySignatures(ctx context.Context, imageName, imageDigest string, sysCtx *types.SystemContext) *SignatureStatus {
+	dgst, err := digest.Parse(imageDigest)
+	if err != nil {
+		return &SignatureStatus{Error: fmt.Sprintf("invalid image digest %q", imageDigest)}
+	}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return &SignatureStatus{Error: fmt.Sprintf("invalid image reference %q", imageName)}
+	}
+	repo := reference.TrimNamed(named).String()
+	tag := fmt.Sprintf("%s-%s", dgst.Algorithm(), dgst.Encoded())
+
+	signatures, err := fetchAttachment(ctx, sysCtx, repo+":"+tag+".sig")
+	if err != nil {
+		return &SignatureStatus{Error: "failed to fetch signatures: " + err.Error()}
+	}
+	attestations, err := fetchAttachment(ctx, sysCtx, repo+":"+tag+".att")
+	if err != nil {
+		return &SignatureStatus{Error: "failed to fetch attestations: " + err.Error()}
+	}
+	return cfg.evaluate(imageDigest, signatures, attestations)
+}
+
+// fetchAttachment reads the layers of a cosign attachment manifest. Returns
+// nil, nil when the attachment doesn't exist.
+func fetchAttachment(ctx context.Context, sysCtx *types.SystemContext, ref string) ([]attachmentLayer, error) {
+	imgRef, err := docker.ParseReference("//" + ref)
+	if err != nil {
+		return nil, err
+	}
+	src, err := imgRef.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer src.Close()
+
+	raw, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m, err := manifest.OCI1FromManifest(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ref, err)
+	}
+
+	var layers []attachmentLayer
+	for _, l := range m.Layers {
+		if l.MediaType != cosignSignatureType && l.MediaType != cosignAttestationType {
+			continue
+		}
+		rc, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: l.Digest, Size: l.Size}, none.NoCache)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s layer %s: %w", ref, l.Digest, err)
+		}
+		blob, err := io.ReadAll(io.LimitReader(rc, maxAttachmentBlob))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if l.Digest.Validate() == nil && l.Digest.Algorithm().FromBytes(blob) != l.Digest {
+			return nil, fmt.Errorf("%s layer %s: digest mismatch", ref, l.Digest)
+		}
+		layers = append(layers, attachmentLayer{MediaType: l.MediaType, Annotations: l.Annotations, Blob: blob})
+	}
+	return layers, nil
+}
+
+// isNotFound reports whether err is a registry "manifest unknown"
+func isNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "manifest unknown") || strings.Contains(msg, "not found") || strings.Contains(msg, "404")
+}
+
+// evaluate verifies the fetched signature and attestation layers
+func (cfg *SignatureConfig) evaluate(imageDigest string, signatures, attestations []attachmentLayer) *SignatureStatus {
+	status := &SignatureStatus{}
+	var reasons []string
+	for _, l := range signatures {
+		if l.MediaType != cosignSignatureType {
+			continue
+		}
+		status.Signatures++
+		status.Signed = true
+		if status.Verified {
+			continue
+		}
+		v, err := cfg.verifySignature(imageDigest, l)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		status.Verified, status.Method, status.Signer, status.Issuer = true, v.method, v.signer, v.issuer
+	}
+
+	for _, l := range attestations {
+		if l.MediaType != cosignAttestationType {
+			continue
+		}
+		provenance, err := cfg.verifyProvenance(imageDigest, l)
+		if provenance == nil {
+			continue
+		}
+		if err != nil {
+			reasons = append(reasons, "provenance: "+err.Error())
+		}
+		if status.Provenance == nil || (!status.Provenance.Verified && provenance.Verified) {
+			status.Provenance = provenance
+		}
+	}
+
+	switch {
+	case !status.Signed:
+		// Unsigned is a finding for the report, not a failure
+	case !status.Verified && len(cfg.PublicKeys) == 0 && cfg.Identity == nil:
+		status.Error = "no public key or keyless identity configured"
+	case !status.Verified:
+		status.Error = strings.Join(dedupe(reasons), "; ")
+	}
+	return status
+}
+
+// verified is a signature that verified, and how
+type verified struct {
+	method, signer, issuer string
+}
+
+// verifySignature verifies a simple-signing signature layer for imageDigest
+func (cfg *SignatureConfig) verifySignature(imageDigest string, l attachmentLayer) (*verified, error) {
+	var payload struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(l.Blob, &payload); err != nil {
+		return nil, fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if payload.Critical.Image.DockerManifestDigest != imageDigest {
+		return nil, fmt.Errorf("signature is for %s", payload.Critical.Image.DockerManifestDigest)
+	}
+	sig, err := base64.StdEncoding.DecodeString(l.Annotations[cosignSignatureAnn])
+	if err != nil || len(sig) == 0 {
+		return nil, fmt.Errorf("missing signature annotation")
+	}
+	return cfg.verifyBlob(l.Blob, sig, l.Annotations)
+}
+
+// verifyBlob verifies sig over message with a configured key, else keylessly
+// with the certificate and Rekor bundle in annotations
+func (cfg *SignatureConfig) verifyBlob(message, sig []byte, annotations map[string]string) (*verified, error) {
+	for _, key := range cfg.PublicKeys {
+		if verifyWithKey(key, message, sig) {
+			return &verified{method: "key"}, nil
+		}
+	}
+	if cfg.Identity == nil || annotations[cosignCertificateAnn] == "" {
+		if len(cfg.PublicKeys) > 0 {
+			return nil, fmt.Errorf("signature does not match the configured public key")
+		}
+		return nil, fmt.Errorf("no public key or keyless identity configured")
+	}
+	return cfg.verifyKeyless(message, sig, annotations)
+}
+
+// verifyWithKey verifies sig over message (SHA-256 for ECDSA and RSA, as cosign signs)
+func verifyWithKey(key crypto.PublicKey, message, sig []byte) bool {
+	hashed := sha256.Sum256(message)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, hashed[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], sig) == nil ||
+			rsa.VerifyPSS(k, crypto.SHA256, hashed[:], sig, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, sig)
+	}
+	return false
+}
+
+// rekorBundle is cosign's offline proof that a signature was logged in Rekor
+type rekorBundle struct {
+	SignedEntryTimestamp []byte
+	Payload              rekorPayload
+}
+
+// rekorPayload fields are in canonical (sorted) JSON order: the Rekor
+// SignedEntryTimestamp signs their json.Marshal encoding
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// verifyKeyless verifies sig with the Fulcio certificate in annotations: the
+// certificate must chain to the configured roots at the time Rekor logged it,
+// name the configured identity and issuer, and appear in the logged entry.
+func (cfg *SignatureConfig) verifyKeyless(message, sig []byte, annotations map[string]string) (*verified, error) {
+	certPEM := annotations[cosignCertificateAnn]
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(annotations[cosignBundleAnn]), &bundle); err != nil || len(bundle.SignedEntryTimestamp) == 0 {
+		return nil, fmt.Errorf("keyless signature has no Rekor bundle")
+	}
+	canonical, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyWithKey(cfg.RekorPublicKey, canonical, bundle.SignedEntryTimestamp) {
+		return nil, fmt.Errorf("rekor bundle signature is invalid")
+	}
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rekor entry: %w", err)
+	}
+	encodedCert := base64.StdEncoding.EncodeToString([]byte(certPEM))
+	if !bytes.Contains(body, []byte(encodedCert)) &&
+		!bytes.Contains(body, []byte(base64.StdEncoding.EncodeToString([]byte(encodedCert)))) {
+		return nil, fmt.Errorf("rekor entry is for a different certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM([]byte(annotations[cosignChainAnn]))
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         cfg.FulcioRoots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(bundle.Payload.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, fmt.Errorf("signing certificate is not trusted: %w", err)
+	}
+
+	signer, issuer := certIdentity(cert)
+	if !cfg.Identity.MatchString(signer) {
+		return nil, fmt.Errorf("signed by %q, which does not match the configured identity", signer)
+	}
+	if cfg.OIDCIssuer != "" && issuer != cfg.OIDCIssuer {
+		return nil, fmt.Errorf("signer issuer %q is not %q", issuer, cfg.OIDCIssuer)
+	}
+	if !verifyWithKey(cert.PublicKey, message, sig) {
+		return nil, fmt.Errorf("signature does not match the signing certificate")
+	}
+	return &verified{method: "keyless", signer: signer, issuer: issuer}, nil
+}
+
+// certIdentity returns a Fulcio certificate's subject (email or URI SAN) and OIDC issuer
+func certIdentity(cert *x509.Certificate) (signer, issuer string) {
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		signer = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		signer = cert.URIs[0].String()
+	}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuer):
+			var s string
+			if _, err := asn1.Unmarshal(ext.Value, &s); err == nil {
+				issuer = s
+			}
+		case ext.Id.Equal(oidFulcioIssuerV1) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	return signer, issuer
+}
+
+// dsseEnvelope is a DSSE-signed attestation
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     []byte `json:"payload"`
+	Signatures  []struct {
+		Sig []byte `json:"sig"`
+	} `json:"signatures"`
+}
+
+// inTotoStatement is the attested statement of an attestation
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"` // SLSA v0.2
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"` // SLSA v1
+	} `json:"predicate"`
+}
+
+// verifyProvenance verifies an attestation layer if it is SLSA provenance for
+// imageDigest. Returns nil for other attestations.
+func (cfg *SignatureConfig) verifyProvenance(imageDigest string, l attachmentLayer) (*ProvenanceStatus, error) {
+	var env dsseEnvelope
+	if err := json.Unmarshal(l.Blob, &env); err != nil || env.PayloadType != inTotoPayloadType {
+		return nil, nil
+	}
+	var stmt inTotoStatement
+	if err := json.Unmarshal(env.Payload, &stmt); err != nil || !strings.HasPrefix(stmt.PredicateType, "https://slsa.dev/provenance/") {
+		return nil, nil
+	}
+
+	provenance := &ProvenanceStatus{PredicateType: stmt.PredicateType, BuilderID: stmt.Predicate.Builder.ID}
+	if provenance.BuilderID == "" {
+		provenance.BuilderID = stmt.Predicate.RunDetails.Builder.ID
+	}
+
+	dgst, err := digest.Parse(imageDigest)
+	if err != nil {
+		return provenance, err
+	}
+	matches := false
+	for _, s := range stmt.Subject {
+		if s.Digest[dgst.Algorithm().String()] == dgst.Encoded() {
+			matches = true
+		}
+	}
+	if !matches {
+		return provenance, fmt.Errorf("attestation subject is a different image")
+	}
+
+	pae := dssePAE(env.PayloadType, env.Payload)
+	var lastErr error = fmt.Errorf("attestation is not signed")
+	for _, s := range env.Signatures {
+		if _, err := cfg.verifyBlob(pae, s.Sig, l.Annotations); err != nil {
+			lastErr = err
+			continue
+		}
+		provenance.Verified = true
+		return provenance, nil
+	}
+	return provenance, lastErr
+}
+
+// dssePAE is the DSSE pre-authentication encoding that envelope signatures sign
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// dedupe drops repeated strings, keeping the first occurrence
+func dedupe(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	var out []string
+	for _, s := range items {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDigest = "sha256:3f1a2b0c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708"
+
+func testKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, message []byte) []byte {
+	t.Helper()
+	hashed := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// signatureLayer builds a cosign simple-signing layer for imageDigest
+func signatureLayer(t *testing.T, key *ecdsa.PrivateKey, imageDigest string, annotations map[string]string) attachmentLayer {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry.example/app"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, imageDigest))
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[cosignSignatureAnn] = base64.StdEncoding.EncodeToString(sign(t, key, payload))
+	return attachmentLayer{MediaType: cosignSignatureType, Annotations: annotations, Blob: payload}
+}
+
+// provenanceLayer builds a DSSE-signed SLSA v1 provenance layer for imageDigest
+func provenanceLayer(t *testing.T, key *ecdsa.PrivateKey, imageDigest string) attachmentLayer {
+	t.Helper()
+	hex := strings.TrimPrefix(imageDigest, "sha256:")
+	statement := []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"registry.example/app","digest":{"sha256":%q}}],"predicateType":"https://slsa.dev/provenance/v1","predicate":{"runDetails":{"builder":{"id":"https://github.com/actions/runner"}}}}`, hex))
+	env, err := json.Marshal(map[string]interface{}{
+		"payloadType": inTotoPayloadType,
+		"payload":     statement,
+		"signatures":  []map[string][]byte{{"sig": sign(t, key, dssePAE(inTotoPayloadType, statement))}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attachmentLayer{MediaType: cosignAttestationType, Annotations: map[string]string{}, Blob: env}
+}
+
+func TestEvaluateWithKey(t *testing.T) {
+	key, other := testKey(t), testKey(t)
+	cfg := &SignatureConfig{PublicKeys: []crypto.PublicKey{&key.PublicKey}}
+
+	status := cfg.evaluate(testDigest,
+		[]attachmentLayer{signatureLayer(t, key, testDigest, nil)},
+		[]attachmentLayer{provenanceLayer(t, key, testDigest)})
+	if !status.Signed || !status.Verified || status.Method != "key" || status.Signatures != 1 {
+		t.Errorf("signed by the configured key: got %+v", status)
+	}
+	if p := status.Provenance; p == nil || !p.Verified || p.BuilderID != "https://github.com/actions/runner" {
+		t.Errorf("provenance = %+v, want verified with builder", p)
+	}
+
+	status = cfg.evaluate(testDigest, []attachmentLayer{signatureLayer(t, other, testDigest, nil)}, nil)
+	if !status.Signed || status.Verified || status.Error == "" {
+		t.Errorf("signed by another key: got %+v", status)
+	}
+
+	otherDigest := "sha256:" + strings.Repeat("0", 64)
+	status = cfg.evaluate(testDigest, []attachmentLayer{signatureLayer(t, key, otherDigest, nil)}, nil)
+	if status.Verified {
+		t.Errorf("signature for another image verified: %+v", status)
+	}
+
+	status = cfg.evaluate(testDigest, nil, nil)
+	if status.Signed || status.Verified || status.Error != "" {
+		t.Errorf("unsigned image: got %+v, want a finding without error", status)
+	}
+}
+
+func TestEvaluateKeyless(t *testing.T) {
+	// Fulcio-like CA and a short-lived signing certificate
+	caKey, signerKey, rekorKey := testKey(t), testKey(t), testKey(t)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issuer, _ := asn1.Marshal("https://token.actions.githubusercontent.com")
+	signedAt := time.Now()
+	certDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-time.Minute),
+		NotAfter:        signedAt.Add(10 * time.Minute),
+		EmailAddresses:  []string{"release@example.com"},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuer, Value: issuer}},
+	}, ca, &signerKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	// Rekor entry naming the certificate, with its signed entry timestamp
+	entry := fmt.Sprintf(`{"kind":"hashedrekord","spec":{"signature":{"publicKey":{"content":%q}}}}`, base64.StdEncoding.EncodeToString([]byte(certPEM)))
+	payload := rekorPayload{Body: base64.StdEncoding.EncodeToString([]byte(entry)), IntegratedTime: signedAt.Unix(), LogID: "test", LogIndex: 7}
+	canonical, _ := json.Marshal(payload)
+	bundle, _ := json.Marshal(rekorBundle{SignedEntryTimestamp: sign(t, rekorKey, canonical), Payload: payload})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	cfg := &SignatureConfig{
+		FulcioRoots:    roots,
+		Identity:       regexp.MustCompile(`^release@example\.com$`),
+		OIDCIssuer:     "https://token.actions.githubusercontent.com",
+		RekorPublicKey: &rekorKey.PublicKey,
+	}
+	layer := signatureLayer(t, signerKey, testDigest, map[string]string{
+		cosignCertificateAnn: certPEM,
+		cosignBundleAnn:      string(bundle),
+	})
+
+	status := cfg.evaluate(testDigest, []attachmentLayer{layer}, nil)
+	if !status.Verified || status.Method != "keyless" || status.Signer != "release@example.com" ||
+		status.Issuer != "https://token.actions.githubusercontent.com" {
+		t.Errorf("keyless signature: got %+v", status)
+	}
+
+	cfg.Identity = regexp.MustCompile(`^someone-else@example\.com$`)
+	if status := cfg.evaluate(testDigest, []attachmentLayer{layer}, nil); status.Verified {
+		t.Errorf("signature by another identity verified: %+v", status)
+	}
+}
@@ -2,11 +2,15 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/containers/image/v5/types"
 	"github.com/wagoodman/dive/dive"
 	"github.com/wagoodman/dive/dive/image"
 )
@@ -22,7 +26,8 @@ var DiveAnalyzer *diveAnalyzer
 type DiveConfig struct {
 	Enable       bool          `json:"enable"`
 	Timeout      time.Duration `json:"timeout"`
-	Source       string        `json:"source"` // "docker", "podman", "docker-archive"
+	Source       string        `json:"source"`            // Deprecated: single source; use Sources
+	Sources      []string      `json:"sources,omitempty"` // Ordered fallback: "docker", "podman", "remote"
 	DockerHost   string        `json:"dockerHost,omitempty"`
 	IgnoreErrors bool          `json:"ignoreErrors"`
 }
@@ -47,7 +52,8 @@ type DiveAnalysis struct {
 	WastedUserPercent float64            `json:"wastedUserPercent"` // % of wasted in user layers
 	Inefficiencies    []DiveInefficiency `json:"inefficiencies"`
 	AnalysisTime      time.Time          `json:"analysisTime"`
-	Status            string             `json:"status"` // "completed", "error"
+	Source            string             `json:"source,omitempty"` // Pull source that succeeded
+	Status            string             `json:"status"`           // "completed", "error"
 	Error             string             `json:"error,omitempty"`
 }
 
@@ -73,8 +79,12 @@ func NewDiveAnalyzer(cfg DiveConfig, l *slog.Logger) *diveAnalyzer {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = diveAnalysisTimeout
 	}
-	if cfg.Source == "" {
-		cfg.Source = "docker"
+	if len(cfg.Sources) == 0 {
+		if cfg.Source != "" {
+			cfg.Sources = []string{cfg.Source}
+		} else {
+			cfg.Sources = append([]string(nil), DefaultSourceOrder...)
+		}
 	}
 	return &diveAnalyzer{
 		config: cfg,
@@ -96,7 +106,7 @@ func (a *diveAnalyzer) Init() {
 	}
 
 	a.initialized = true
-	a.log.Info("Dive analyzer initialized", "source", a.config.Source)
+	a.log.Info("Dive analyzer initialized", "sources", a.config.Sources)
 }
 
 // IsEnabled returns whether the analyzer is enabled
@@ -127,6 +137,12 @@ func (a *diveAnalyzer) setAnalysis(img string, analysis *DiveAnalysis) {
 
 // AnalyzeImage analyzes a Docker image from the Docker daemon
 func (a *diveAnalyzer) AnalyzeImage(ctx context.Context, imageName string) (*DiveAnalysis, error) {
+	return a.AnalyzeImageWithMeta(ctx, imageName, nil)
+}
+
+// AnalyzeImageWithMeta analyzes an image, reusing the manifest/config from a
+// prior inspection (if any) so a remote pull doesn't fetch them again.
+func (a *diveAnalyzer) AnalyzeImageWithMeta(ctx context.Context, imageName string, meta *InspectResult) (*DiveAnalysis, error) {
 	if !a.IsInitialized() {
 		return nil, fmt.Errorf("dive analyzer not initialized")
 	}
@@ -140,10 +156,116 @@ func (a *diveAnalyzer) AnalyzeImage(ctx context.Context, imageName string) (*Div
 		return analysis, nil
 	}
 
-	// Parse image source
-	source := dive.ParseImageSource(a.config.Source)
+	return runWithTimeout(ctx, "dive", imageName, timeoutFor(ctx, a.config.Timeout), func(ctx context.Context) (*DiveAnalysis, error) {
+		return a.analyze(ctx, imageName, meta)
+	})
+}
+
+// analyze resolves imageName and runs the analysis against a local archive or
+// each configured pull source in turn. Pulled and converted archives are
+// written to a workspace that is removed when the analysis ends, however it
+// ends.
+func (a *diveAnalyzer) analyze(ctx context.Context, imageName string, meta *InspectResult) (*DiveAnalysis, error) {
+	in, err := ParseImageInput(imageName, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cleanup, err := withWorkspace(ctx, "dive")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if in.IsLocal() {
+		return a.analyzeLocal(ctx, imageName, in)
+	}
+
+	// Try each configured source in order, keeping the first that works
+	var errs []string
+	for _, src := range a.config.Sources {
+		analysis, err := a.analyzeFromSource(ctx, in.Ref, src, meta)
+		if err == nil && analysis.Status != "error" {
+			analysis.Source = src
+			return analysis, nil
+		}
+		if err == nil {
+			err = errors.New(analysis.Error)
+		}
+		a.log.Warn("Dive source failed, trying next", "image", imageName, "source", src, "error", err)
+		errs = append(errs, fmt.Sprintf("%s: %v", src, err))
+	}
 
-	return a.doAnalyze(ctx, imageName, "", source)
+	analysis := &DiveAnalysis{
+		Image:        imageName,
+		AnalysisTime: time.Now(),
+		Status:       "error",
+		Error:        fmt.Sprintf("all image sources failed (%s)", strings.Join(errs, "; ")),
+	}
+	a.setAnalysis(imageName, analysis)
+	return analysis, fmt.Errorf("failed to analyze image %s: %s", imageName, analysis.Error)
+}
+
+// analyzeFromSource runs a single analysis attempt against one pull source.
+// The "remote" source pulls the image daemonlessly into a temporary archive.
+func (a *diveAnalyzer) analyzeFromSource(ctx context.Context, imageName, src string, meta *InspectResult) (*DiveAnalysis, error) {
+	if src != SourceRemote {
+		return a.doAnalyze(ctx, imageName, "", dive.ParseImageSource(src))
+	}
+
+	archivePath, stats, err := pullToDockerArchive(ctx, imageName, &types.SystemContext{
+		OSChoice:                    "linux",
+		ArchitectureChoice:          "amd64",
+		DockerAuthConfig:            dockerAuthConfig(ctx),
+		DockerInsecureSkipTLSVerify: insecureTLS(imageName),
+	}, meta)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archivePath)
+
+	a.log.Info("Remote pull completed",
+		"image", imageName,
+		"bytes_fetched", stats.BytesFetched,
+		"bytes_saved", stats.BytesReused,
+	)
+
+	return a.analyzeArchiveAs(ctx, archivePath, imageName)
+}
+
+// analyzeLocal analyzes a docker-archive tarball or OCI layout on disk.
+// OCI layouts are first converted to a temporary docker-archive.
+func (a *diveAnalyzer) analyzeLocal(ctx context.Context, imageName string, in ImageInput) (*DiveAnalysis, error) {
+	archivePath := in.Path()
+	if in.Transport == SourceOCI {
+		ref, err := in.containersRef()
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCI layout %s: %w", in.Ref, err)
+		}
+		archivePath, _, err = writeDockerArchive(ctx, ref, imageName, &types.SystemContext{}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCI layout %s: %w", in.Ref, err)
+		}
+		defer os.Remove(archivePath)
+	}
+
+	analysis, err := a.analyzeArchiveAs(ctx, archivePath, imageName)
+	if analysis != nil {
+		analysis.Source = in.Transport
+	}
+	return analysis, err
+}
+
+// analyzeArchiveAs analyzes a docker-archive and stores the result under
+// imageName rather than the (possibly temporary) archive path.
+func (a *diveAnalyzer) analyzeArchiveAs(ctx context.Context, archivePath, imageName string) (*DiveAnalysis, error) {
+	analysis, err := a.doAnalyze(ctx, "", archivePath, dive.SourceDockerArchive)
+	if analysis != nil && archivePath != imageName {
+		a.mx.Lock()
+		delete(a.scans, archivePath)
+		a.mx.Unlock()
+		analysis.Image = imageName
+		a.setAnalysis(imageName, analysis)
+	}
+	return analysis, err
 }
 
 // AnalyzeImageFromArchive analyzes an image from a tar archive
@@ -155,7 +277,9 @@ func (a *diveAnalyzer) AnalyzeImageFromArchive(ctx context.Context, archivePath
 		return nil, fmt.Errorf("archive path is required")
 	}
 
-	return a.doAnalyze(ctx, "", archivePath, dive.SourceDockerArchive)
+	return runWithTimeout(ctx, "dive", archivePath, timeoutFor(ctx, a.config.Timeout), func(ctx context.Context) (*DiveAnalysis, error) {
+		return a.doAnalyze(ctx, "", archivePath, dive.SourceDockerArchive)
+	})
 }
 
 func (a *diveAnalyzer) doAnalyze(ctx context.Context, imageName, archivePath string, source dive.ImageSource) (analysis *DiveAnalysis, err error) {
@@ -167,7 +291,8 @@ func (a *diveAnalyzer) doAnalyze(ctx context.Context, imageName, archivePath str
 	start := time.Now()
 	a.log.Info("Starting dive analysis", "image", scanID, "source", source)
 
-	// Handle panics gracefully
+	// Handle panics gracefully. The recovered analysis returns normally, so
+	// the caller's workspace cleanup (see analyze) still removes the archive.
 	defer func() {
 		if r := recover(); r != nil {
 			recErr := fmt.Errorf("panic in dive analysis: %v", r)
@@ -186,10 +311,6 @@ func (a *diveAnalyzer) doAnalyze(ctx context.Context, imageName, archivePath str
 		}
 	}()
 
-	// Add timeout to context
-	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
-	defer cancel()
-
 	// Get image resolver based on source
 	resolver, err := dive.GetImageResolver(source)
 	if err != nil {
@@ -0,0 +1,323 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dockerfile/layer drift findings
+const (
+	DriftDifferentDockerfile = "different_dockerfile" // The image history doesn't follow the submitted Dockerfile
+	DriftLargeLayer          = "large_layer"          // A single instruction produced an oversized layer
+	DriftCacheBusting        = "cache_busting"        // Copying the whole build context before installing dependencies
+)
+
+// LargeLayerBytes is the layer size above which an instruction is flagged
+const LargeLayerBytes = 100 << 20
+
+// historySearchWindow is how many unexplained history entries the alignment
+// skips looking for an instruction (e.g. layers buildkit adds for WORKDIR)
+const historySearchWindow = 3
+
+var (
+	// dependencyInstall matches RUN commands that install packages or dependencies
+	dependencyInstall = regexp.MustCompile(`\b(apt-get install|apt install|apk add|yum install|dnf install|microdnf install|pip3? install|npm (ci|install)|yarn( install)?\b|pnpm install|go mod download|go build|bundle install|composer install|mvn|gradle|cargo build)\b`)
+	// packageCacheCleanup matches the usual ways of not keeping package caches in a layer
+	packageCacheCleanup = regexp.MustCompile(`rm -rf /var/lib/apt/lists|--no-cache|apt-get clean|yum clean|dnf clean|npm cache clean|rm -rf /root/\.cache`)
+)
+
+// DockerfileLayerMap correlates the final stage of a submitted Dockerfile with
+// the layers of the built image, to flag where the two disagree
+type DockerfileLayerMap struct {
+	BaseImage string              `json:"base_image"` // FROM of the final stage
+	Steps     []DockerfileStep    `json:"steps"`
+	Matched   int                 `json:"matched"`   // RUN/COPY/ADD instructions found in the image history
+	Unmatched int                 `json:"unmatched"` // RUN/COPY/ADD instructions missing from it
+	Findings  []DockerfileFinding `json:"findings"`
+}
+
+// DockerfileStep is one instruction of the final stage and the history entry
+// (and layer) it produced, if any
+type DockerfileStep struct {
+	Line        int    `json:"line"`
+	Instruction string `json:"instruction"` // e.g. "RUN apt-get update && apt-get install -y curl"
+	Matched     bool   `json:"matched"`
+	CreatedBy   string `json:"created_by,omitempty"` // The matching history entry
+	Layer       string `json:"layer,omitempty"`      // Digest of the produced layer; empty for metadata-only steps
+	LayerIndex  *int   `json:"layer_index,omitempty"`
+	SizeBytes   uint64 `json:"size_bytes,omitempty"` // Uncompressed size from dive, else the compressed size
+}
+
+// DockerfileFinding is a drift between the Dockerfile and the image, tied to a
+// Dockerfile line where there is one
+type DockerfileFinding struct {
+	Type       string `json:"type"` // DriftDifferentDockerfile, DriftLargeLayer or DriftCacheBusting
+	Line       int    `json:"line,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// dockerfileInstruction is a parsed Dockerfile instruction
+type dockerfileInstruction struct {
+	line    int
+	keyword string // Upper-cased, e.g. "RUN"
+	args    string
+}
+
+// MapDockerfileLayers aligns the final stage of dockerfile with the build
+// history of inspect, sizing each layer from dive (when it ran) or the
+// inspection. It returns nil when the image has no history to compare with.
+func MapDockerfileLayers(dockerfile string, inspect *InspectResult, dive *DiveAnalysis) *DockerfileLayerMap {
+	if inspect == nil || len(inspect.History) == 0 {
+		return nil
+	}
+	base, instructions := finalStage(parseDockerfile(dockerfile))
+	m := &DockerfileLayerMap{BaseImage: base, Steps: []DockerfileStep{}, Findings: []DockerfileFinding{}}
+
+	// Layer index of each history entry that produced a layer
+	layerIndex := make([]int, len(inspect.History))
+	for i, n := 0, 0; i < len(inspect.History); i++ {
+		layerIndex[i] = -1
+		if !inspect.History[i].EmptyLayer {
+			layerIndex[i] = n
+			n++
+		}
+	}
+
+	// Walk both backwards: the final stage's steps are the newest history
+	// entries, after whatever the base image brought
+	steps := make([]DockerfileStep, len(instructions))
+	next := len(inspect.History) - 1
+	for i := len(instructions) - 1; i >= 0; i-- {
+		in := instructions[i]
+		steps[i] = DockerfileStep{Line: in.line, Instruction: in.keyword + " " + in.args}
+		for k := next; k >= 0 && k >= next-historySearchWindow; k-- {
+			if !historyMatches(in, inspect.History[k].CreatedBy) {
+				continue
+			}
+			h := inspect.History[k]
+			steps[i].Matched = true
+			steps[i].CreatedBy = h.CreatedBy
+			if idx := layerIndex[k]; idx >= 0 {
+				steps[i].Layer = h.Layer
+				steps[i].LayerIndex = &idx
+				steps[i].SizeBytes = layerSize(idx, inspect, dive)
+			}
+			next = k - 1
+			break
+		}
+		if producesLayer(in.keyword) {
+			if steps[i].Matched {
+				m.Matched++
+			} else {
+				m.Unmatched++
+			}
+		}
+	}
+	m.Steps = steps
+
+	if total := m.Matched + m.Unmatched; total > 0 && m.Matched*2 < total {
+		m.Findings = append(m.Findings, DockerfileFinding{
+			Type:       DriftDifferentDockerfile,
+			Message:    fmt.Sprintf("Only %d of %d RUN/COPY/ADD instructions of the final stage appear in the image history; the image was likely built from a different Dockerfile or stage", m.Matched, total),
+			Suggestion: "Rebuild the image from the submitted Dockerfile, or submit the Dockerfile it was built from",
+		})
+	}
+	for _, s := range steps {
+		if s.SizeBytes >= LargeLayerBytes {
+			m.Findings = append(m.Findings, DockerfileFinding{
+				Type:       DriftLargeLayer,
+				Line:       s.Line,
+				Message:    fmt.Sprintf("%s produced a %.1f MB layer", truncateInstruction(s.Instruction), float64(s.SizeBytes)/(1<<20)),
+				Suggestion: largeLayerSuggestion(s.Instruction),
+			})
+		}
+	}
+	m.Findings = append(m.Findings, cacheBusting(instructions)...)
+	return m
+}
+
+// parseDockerfile splits a Dockerfile into instructions, joining continuation
+// lines and dropping comments
+func parseDockerfile(dockerfile string) []dockerfileInstruction {
+	var (
+		out     []dockerfileInstruction
+		current strings.Builder
+		start   int
+	)
+	for i, raw := range strings.Split(dockerfile, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current.Len() == 0 {
+			start = i + 1
+		}
+		if cont, ok := strings.CutSuffix(line, "\\"); ok {
+			current.WriteString(cont)
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		keyword, args, _ := strings.Cut(current.String(), " ")
+		out = append(out, dockerfileInstruction{line: start, keyword: strings.ToUpper(keyword), args: normalizeCommand(args)})
+		current.Reset()
+	}
+	return out
+}
+
+// finalStage returns the base image and the instructions of the last stage,
+// without the ARGs, which leave no history
+func finalStage(instructions []dockerfileInstruction) (string, []dockerfileInstruction) {
+	var (
+		base  string
+		stage []dockerfileInstruction
+	)
+	for _, in := range instructions {
+		switch in.keyword {
+		case "FROM":
+			base, _, _ = strings.Cut(in.args, " ")
+			stage = nil
+		case "ARG":
+		default:
+			stage = append(stage, in)
+		}
+	}
+	return base, stage
+}
+
+// historyInstruction recovers the instruction behind a history entry's
+// created_by, as recorded by buildkit ("RUN /bin/sh -c ... # buildkit") or the
+// legacy builder ("/bin/sh -c #(nop)  CMD [...]", "/bin/sh -c apk add ...")
+func historyInstruction(createdBy string) (string, string) {
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(createdBy), "# buildkit"))
+	if rest, ok := strings.CutPrefix(s, "/bin/sh -c #(nop)"); ok {
+		s = strings.TrimSpace(rest)
+	} else if strings.HasPrefix(s, "/bin/sh -c ") || strings.HasPrefix(s, "|") {
+		s = "RUN " + s
+	}
+	keyword, args, _ := strings.Cut(s, " ")
+	keyword = strings.ToUpper(keyword)
+	if keyword == "RUN" {
+		args = stripBuildArgs(args)
+		args = strings.TrimPrefix(args, "/bin/sh -c ")
+	}
+	return keyword, normalizeCommand(args)
+}
+
+// stripBuildArgs drops the "|N KEY=value ..." build args builders prefix to
+// RUN commands that had ARGs in scope
+func stripBuildArgs(args string) string {
+	if !strings.HasPrefix(args, "|") {
+		return args
+	}
+	fields := strings.Fields(args)
+	n, err := strconv.Atoi(strings.TrimPrefix(fields[0], "|"))
+	if err != nil || n+1 > len(fields) {
+		return args
+	}
+	return strings.Join(fields[n+1:], " ")
+}
+
+// historyMatches reports whether a history entry could have come from in.
+// RUN commands must match; COPY/ADD and metadata instructions are recorded
+// too differently between builders to compare more than the keyword.
+func historyMatches(in dockerfileInstruction, createdBy string) bool {
+	keyword, args := historyInstruction(createdBy)
+	if keyword != in.keyword {
+		return false
+	}
+	return keyword != "RUN" || args == in.args
+}
+
+// normalizeCommand collapses whitespace and turns exec form into a command line
+func normalizeCommand(args string) string {
+	if strings.HasPrefix(strings.TrimSpace(args), "[") {
+		var argv []string
+		if err := json.Unmarshal([]byte(args), &argv); err == nil {
+			args = strings.Join(argv, " ")
+		}
+	}
+	return strings.Join(strings.Fields(args), " ")
+}
+
+// producesLayer reports whether instructions with keyword add filesystem content
+func producesLayer(keyword string) bool {
+	return keyword == "RUN" || keyword == "COPY" || keyword == "ADD"
+}
+
+// layerSize returns the size of layer idx, preferring dive's uncompressed size
+func layerSize(idx int, inspect *InspectResult, dive *DiveAnalysis) uint64 {
+	if dive != nil && idx < len(dive.Layers) {
+		return dive.Layers[idx].SizeBytes
+	}
+	if idx < len(inspect.Layers) && inspect.Layers[idx].Size > 0 {
+		return uint64(inspect.Layers[idx].Size)
+	}
+	return 0
+}
+
+// largeLayerSuggestion is the remediation for an oversized layer from instruction
+func largeLayerSuggestion(instruction string) string {
+	keyword, args, _ := strings.Cut(instruction, " ")
+	switch {
+	case keyword == "RUN" && dependencyInstall.MatchString(args) && !packageCacheCleanup.MatchString(args):
+		return "Clean package manager caches in the same RUN (e.g. rm -rf /var/lib/apt/lists/*, apk add --no-cache, pip install --no-cache-dir), or build in a separate stage"
+	case (keyword == "COPY" || keyword == "ADD") && copiesContext(args):
+		return "Add a .dockerignore or copy only the files the image needs"
+	}
+	return "Split or trim this step; check dive's inefficiencies for files added here and removed later"
+}
+
+// cacheBusting flags a copy of the whole build context followed by a
+// dependency install, which reruns the install on every source change
+func cacheBusting(instructions []dockerfileInstruction) []DockerfileFinding {
+	var findings []DockerfileFinding
+	for i, in := range instructions {
+		if (in.keyword != "COPY" && in.keyword != "ADD") || !copiesContext(in.args) {
+			continue
+		}
+		for _, later := range instructions[i+1:] {
+			if later.keyword == "RUN" && dependencyInstall.MatchString(later.args) {
+				findings = append(findings, DockerfileFinding{
+					Type:       DriftCacheBusting,
+					Line:       in.line,
+					Message:    fmt.Sprintf("%s at line %d copies the whole build context before the dependency install at line %d, so any source change reruns it", in.keyword, in.line, later.line),
+					Suggestion: "Copy the dependency manifests (package.json, go.mod, requirements.txt, ...) and install first, then copy the rest of the source",
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// copiesContext reports whether COPY/ADD args copy the whole build context
+func copiesContext(args string) bool {
+	var srcs []string
+	for _, f := range strings.Fields(args) {
+		if !strings.HasPrefix(f, "--") {
+			srcs = append(srcs, f)
+		}
+	}
+	if len(srcs) < 2 {
+		return false
+	}
+	for _, src := range srcs[:len(srcs)-1] {
+		if src == "." || src == "./" {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateInstruction shortens an instruction for a finding message
+func truncateInstruction(s string) string {
+	if len(s) > 80 {
+		return s[:77] + "..."
+	}
+	return s
+}
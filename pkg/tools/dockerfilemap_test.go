@@ -0,0 +1,76 @@
+package tools
+
+import "testing"
+
+func TestMapDockerfileLayers(t *testing.T) {
+	dockerfile := `# syntax=docker/dockerfile:1
+FROM golang:1.22 AS build
+RUN go build ./...
+
+FROM node:20-slim
+ARG NODE_ENV=production
+WORKDIR /app
+COPY . .
+RUN apt-get update && \
+    apt-get install -y curl
+RUN ["npm", "ci"]
+CMD ["node", "server.js"]
+`
+	inspect := &InspectResult{
+		History: []HistoryEntry{
+			{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in / "},
+			{CreatedBy: `/bin/sh -c #(nop)  CMD ["bash"]`, EmptyLayer: true},
+			{CreatedBy: "WORKDIR /app", EmptyLayer: true, Comment: "buildkit.dockerfile.v0"},
+			{CreatedBy: "COPY . . # buildkit"},
+			{CreatedBy: "RUN |1 NODE_ENV=production /bin/sh -c apt-get update &&     apt-get install -y curl # buildkit"},
+			{CreatedBy: "RUN |1 NODE_ENV=production npm ci # buildkit"},
+			{CreatedBy: `CMD ["node" "server.js"]`, EmptyLayer: true},
+		},
+		Layers: []InspectLayerInfo{{Digest: "sha256:base"}, {Digest: "sha256:copy"}, {Digest: "sha256:apt"}, {Digest: "sha256:npm"}},
+	}
+	for i, n := 0, 0; i < len(inspect.History); i++ {
+		if !inspect.History[i].EmptyLayer {
+			inspect.History[i].Layer = inspect.Layers[n].Digest
+			n++
+		}
+	}
+	dive := &DiveAnalysis{Layers: []DiveLayer{{SizeBytes: 80 << 20}, {SizeBytes: 1 << 20}, {SizeBytes: 150 << 20}, {SizeBytes: 30 << 20}}}
+
+	m := MapDockerfileLayers(dockerfile, inspect, dive)
+	if m == nil {
+		t.Fatal("no map for an image with history")
+	}
+	if m.BaseImage != "node:20-slim" || len(m.Steps) != 5 {
+		t.Fatalf("base %q with %d steps, want node:20-slim with 5", m.BaseImage, len(m.Steps))
+	}
+	if m.Matched != 3 || m.Unmatched != 0 {
+		t.Errorf("matched %d, unmatched %d; want 3, 0", m.Matched, m.Unmatched)
+	}
+	apt := m.Steps[2]
+	if apt.Line != 9 || apt.Layer != "sha256:apt" || apt.SizeBytes != 150<<20 {
+		t.Errorf("apt step = %+v, want line 9, layer sha256:apt, 150 MB", apt)
+	}
+
+	found := map[string]int{}
+	for _, f := range m.Findings {
+		found[f.Type] = f.Line
+	}
+	if found[DriftLargeLayer] != 9 {
+		t.Errorf("large layer finding at line %d, want 9 (findings %+v)", found[DriftLargeLayer], m.Findings)
+	}
+	if found[DriftCacheBusting] != 8 {
+		t.Errorf("cache busting finding at line %d, want 8", found[DriftCacheBusting])
+	}
+	if _, ok := found[DriftDifferentDockerfile]; ok {
+		t.Error("matching Dockerfile flagged as different")
+	}
+
+	other := MapDockerfileLayers("FROM alpine\nRUN apk add --no-cache python3\nADD app.tar.gz /srv/\n", inspect, dive)
+	if other.Matched != 0 || other.Unmatched != 2 || len(other.Findings) == 0 || other.Findings[0].Type != DriftDifferentDockerfile {
+		t.Errorf("different Dockerfile: matched %d, unmatched %d, findings %+v", other.Matched, other.Unmatched, other.Findings)
+	}
+
+	if MapDockerfileLayers(dockerfile, &InspectResult{}, dive) != nil {
+		t.Error("map built without image history")
+	}
+}
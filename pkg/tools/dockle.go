@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	imagetypes "github.com/containers/image/v5/types"
 	deckodertypes "github.com/goodwithtech/deckoder/types"
 	"github.com/goodwithtech/dockle/config"
 	"github.com/goodwithtech/dockle/pkg/assessor/credential"
@@ -160,7 +161,54 @@ func (s *DockleScanner) ScanImage(ctx context.Context, imageName string) (*Dockl
 	if imageName == "" {
 		return nil, fmt.Errorf("image name is required")
 	}
-	return s.doScan(ctx, imageName, "")
+
+	return runWithTimeout(ctx, "dockle", imageName, timeoutFor(ctx, s.config.Timeout), func(ctx context.Context) (*DockleScan, error) {
+		return s.scanImage(ctx, imageName)
+	})
+}
+
+// scanImage resolves imageName and scans it, converting OCI layouts to a
+// docker-archive first.
+func (s *DockleScanner) scanImage(ctx context.Context, imageName string) (*DockleScan, error) {
+	in, err := ParseImageInput(imageName, "")
+	if err != nil {
+		return nil, err
+	}
+	switch in.Transport {
+	case SourceDockerArchive:
+		return s.scanArchiveAs(ctx, in.Path(), imageName)
+	case SourceOCI:
+		// dockle only reads docker-archives; convert the layout first
+		ref, err := in.containersRef()
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCI layout %s: %w", in.Ref, err)
+		}
+		ctx, cleanup, err := withWorkspace(ctx, "dockle")
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		archivePath, _, err := writeDockerArchive(ctx, ref, imageName, &imagetypes.SystemContext{}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCI layout %s: %w", in.Ref, err)
+		}
+		return s.scanArchiveAs(ctx, archivePath, imageName)
+	}
+	return s.doScan(ctx, in.Ref, "")
+}
+
+// scanArchiveAs scans a docker-archive and stores the result under imageName
+// rather than the (possibly temporary) archive path.
+func (s *DockleScanner) scanArchiveAs(ctx context.Context, archivePath, imageName string) (*DockleScan, error) {
+	scan, err := s.doScan(ctx, "", archivePath)
+	if scan != nil && archivePath != imageName {
+		s.mx.Lock()
+		delete(s.scans, archivePath)
+		s.mx.Unlock()
+		scan.Image = imageName
+		s.setScan(imageName, scan)
+	}
+	return scan, err
 }
 
 // ScanImageFromFile scans a container image from a local tar archive
@@ -171,7 +219,9 @@ func (s *DockleScanner) ScanImageFromFile(ctx context.Context, filePath string)
 	if filePath == "" {
 		return nil, fmt.Errorf("file path is required")
 	}
-	return s.doScan(ctx, "", filePath)
+	return runWithTimeout(ctx, "dockle", filePath, timeoutFor(ctx, s.config.Timeout), func(ctx context.Context) (*DockleScan, error) {
+		return s.doScan(ctx, "", filePath)
+	})
 }
 
 func (s *DockleScanner) doScan(ctx context.Context, imageName, filePath string) (scan *DockleScan, err error) {
@@ -204,12 +254,15 @@ func (s *DockleScanner) doScan(ctx context.Context, imageName, filePath string)
 		}
 	}()
 
-	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
-	defer cancel()
-
+	timeout := timeoutFor(ctx, s.config.Timeout)
 	dockerOption := deckodertypes.DockerOption{
-		Timeout:  s.config.Timeout,
-		SkipPing: true,
+		Timeout:               timeout,
+		SkipPing:              true,
+		InsecureSkipTLSVerify: insecureRegistry(imageName),
+	}
+	if auth := imageAuthFor(ctx); auth != nil {
+		dockerOption.UserName = auth.Username
+		dockerOption.Password = auth.Password
 	}
 
 	// Call dockle's scanner
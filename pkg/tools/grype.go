@@ -1,12 +1,16 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anchore/clio"
@@ -28,12 +32,22 @@ import (
 	"github.com/anchore/grype/grype/vulnerability"
 	"github.com/anchore/syft/syft"
 	"github.com/anchore/syft/syft/cataloging"
+	"github.com/anchore/syft/syft/format"
+	"github.com/anchore/syft/syft/format/syftjson"
+	"github.com/anchore/syft/syft/sbom"
 )
 
 const (
 	imgScanTimeout = 5 * time.Minute
 	wontFix        = "(won't fix)"
 	naValue        = "N/A"
+
+	// Vulnerability DB load retry policy (attempts and initial backoff are
+	// the ImageScans defaults)
+	dbLoadMaxAttempts    = 5
+	dbLoadInitialBackoff = 2 * time.Second
+	dbLoadMaxBackoff     = 1 * time.Minute
+	dbRetryInterval      = 5 * time.Minute
 )
 
 // Global scanner instance
@@ -41,8 +55,17 @@ var ImgScanner *imageScanner
 
 // ImageScans configuration similar to K9s
 type ImageScans struct {
-	Enable     bool       `json:"enable"`
-	Exclusions Exclusions `json:"exclusions"`
+	Enable     bool          `json:"enable"`
+	Timeout    time.Duration `json:"timeout"` // Per-image scan timeout (default 5m)
+	Exclusions Exclusions    `json:"exclusions"`
+	// AttachedSBOMs uses an SBOM attached to a registry image (OCI referrers)
+	// instead of cataloging the image with syft
+	AttachedSBOMs bool `json:"attachedSboms"`
+	// DBLoadAttempts and DBLoadBackoff bound Init's vulnerability DB load:
+	// the attempts made before it falls back to background retries, and the
+	// initial wait between them, doubled after each failure (default 5, 2s)
+	DBLoadAttempts int           `json:"dbLoadAttempts"`
+	DBLoadBackoff  time.Duration `json:"dbLoadBackoff"`
 }
 
 type Exclusions struct {
@@ -50,9 +73,30 @@ type Exclusions struct {
 	Labels     map[string][]string `json:"labels"`
 }
 
+// DefaultExcludedNamespaces are Kubernetes system namespaces skipped unless configured otherwise
+var DefaultExcludedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// ExclusionsFromEnv builds scan exclusions from SCAN_EXCLUDED_NAMESPACES
+// (comma-separated). Falls back to DefaultExcludedNamespaces when unset.
+func ExclusionsFromEnv() Exclusions {
+	namespaces := DefaultExcludedNamespaces
+	if v, ok := os.LookupEnv("SCAN_EXCLUDED_NAMESPACES"); ok {
+		namespaces = []string{}
+		for _, ns := range strings.Split(v, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	return Exclusions{
+		Namespaces: append([]string(nil), namespaces...),
+		Labels:     map[string][]string{},
+	}
+}
+
 // imageScanner follows K9s architecture
 type imageScanner struct {
-	vulnProvider vulnerability.Provider
+	vulnProvider *sharedProvider
 	dbStatus     *vulnerability.ProviderStatus
 	opts         *options.Grype
 	scans        Scans
@@ -60,14 +104,48 @@ type imageScanner struct {
 	initialized  bool
 	config       ImageScans
 	log          *slog.Logger
+	id           clio.Identification
+	refreshMx    sync.Mutex // serializes DB loads/refreshes
+	onDBUpdate   []func(DBUpdateEvent)
+	stopCh       chan struct{}
+	stopOnce     sync.Once
+}
+
+// sharedProvider is a vulnerability provider shared by in-flight scans. The
+// scanner holds one reference while the provider is current and each scan
+// holds one until it finishes, so a provider swapped out by a DB refresh is
+// closed only once its last scan is done.
+type sharedProvider struct {
+	vulnerability.Provider
+	refs atomic.Int64
+	log  *slog.Logger
+}
+
+func newSharedProvider(p vulnerability.Provider, l *slog.Logger) *sharedProvider {
+	sp := &sharedProvider{Provider: p, log: l}
+	sp.refs.Store(1)
+	return sp
+}
+
+func (p *sharedProvider) acquire() { p.refs.Add(1) }
+
+// release drops a reference, closing the provider on the last one
+func (p *sharedProvider) release() {
+	if p.refs.Add(-1) != 0 {
+		return
+	}
+	if err := p.Provider.Close(); err != nil {
+		p.log.Warn("Failed to close previous vulnerability db", "error", err)
+	}
 }
 
 type Scans map[string]*Scan
 
 type Scan struct {
-	ID    string
-	Table *table
-	Tally tally
+	ID       string
+	Table    *table
+	Tally    tally
+	Licenses *LicenseReport `json:"-"` // Uploaded separately as licenses.json
 }
 
 type table struct {
@@ -93,60 +171,217 @@ type tally struct {
 
 // NewImageScanner creates a new image scanner like K9s
 func NewImageScanner(cfg ImageScans, l *slog.Logger) *imageScanner {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = imgScanTimeout
+	}
+	if cfg.DBLoadAttempts <= 0 {
+		cfg.DBLoadAttempts = dbLoadMaxAttempts
+	}
+	if cfg.DBLoadBackoff <= 0 {
+		cfg.DBLoadBackoff = dbLoadInitialBackoff
+	}
 	return &imageScanner{
 		scans:  make(Scans),
 		config: cfg,
 		log:    l.With("subsys", "vul"),
+		stopCh: make(chan struct{}),
 	}
 }
 
-// Init initializes the scanner exactly like K9s does
+// Init initializes the scanner exactly like K9s does.
+// If the vulnerability DB cannot be loaded after a few attempts, a background
+// goroutine keeps retrying and flips the scanner to initialized once it succeeds.
 func (s *imageScanner) Init(name, version string) {
+	id := clio.Identification{Name: name, Version: version}
+	opts := options.DefaultGrype(id)
+	opts.GenerateMissingCPEs = true
+
 	s.mx.Lock()
-	defer s.mx.Unlock()
+	s.id = id
+	s.opts = opts
+	s.mx.Unlock()
 
-	id := clio.Identification{Name: name, Version: version}
-	s.opts = options.DefaultGrype(id)
-	s.opts.GenerateMissingCPEs = true
+	if err := s.loadDBWithRetry(); err != nil {
+		s.log.Error("VulDb load failed, retrying in background",
+			"error", err,
+			"interval", dbRetryInterval,
+		)
+		go s.retryLoop()
+		return
+	}
 
-	var err error
-	s.vulnProvider, s.dbStatus, err = grype.LoadVulnerabilityDB(
+	s.log.Info("Vulnerability scanner initialized successfully")
+}
+
+// loadDB loads (downloading if needed) and validates the vulnerability DB,
+// then swaps the new provider in under the lock. When force is set, the
+// update-check frequency is ignored so a newer DB is always looked for.
+// Scans already in flight keep the provider they started with; the previous
+// provider is closed when the last of them finishes.
+func (s *imageScanner) loadDB(force bool) error {
+	s.refreshMx.Lock()
+	defer s.refreshMx.Unlock()
+
+	s.mx.RLock()
+	id, opts := s.id, s.opts
+	s.mx.RUnlock()
+	if opts == nil {
+		return fmt.Errorf("vulnerability scanner not initialized")
+	}
+
+	updateCheckFrequency := opts.DB.MaxUpdateCheckFrequency
+	if force {
+		updateCheckFrequency = 0
+	}
+
+	provider, status, err := grype.LoadVulnerabilityDB(
 		v6dist.Config{
 			ID:                 id,
-			LatestURL:          s.opts.DB.UpdateURL,
-			CACert:             s.opts.DB.CACert,
-			RequireUpdateCheck: s.opts.DB.RequireUpdateCheck,
-			CheckTimeout:       s.opts.DB.UpdateAvailableTimeout,
-			UpdateTimeout:      s.opts.DB.UpdateDownloadTimeout,
+			LatestURL:          opts.DB.UpdateURL,
+			CACert:             opts.DB.CACert,
+			RequireUpdateCheck: opts.DB.RequireUpdateCheck || force,
+			CheckTimeout:       opts.DB.UpdateAvailableTimeout,
+			UpdateTimeout:      opts.DB.UpdateDownloadTimeout,
 		},
 		v6inst.Config{
-			DBRootDir:               s.opts.DB.Dir,
-			ValidateAge:             s.opts.DB.ValidateAge,
-			MaxAllowedBuiltAge:      s.opts.DB.MaxAllowedBuiltAge,
-			UpdateCheckMaxFrequency: s.opts.DB.MaxUpdateCheckFrequency,
+			DBRootDir:               opts.DB.Dir,
+			ValidateAge:             opts.DB.ValidateAge,
+			MaxAllowedBuiltAge:      opts.DB.MaxAllowedBuiltAge,
+			UpdateCheckMaxFrequency: updateCheckFrequency,
 		},
-		s.opts.DB.AutoUpdate,
+		opts.DB.AutoUpdate || force,
 	)
-	if err != nil {
-		s.log.Error("VulDb load failed", "error", err)
-		return
+	if e := validateDBLoad(err, status); e != nil {
+		return e
 	}
 
-	if e := validateDBLoad(err, s.dbStatus); e != nil {
-		s.log.Error("VulDb validate failed", "error", e)
-		return
+	s.mx.Lock()
+	old, oldStatus := s.vulnProvider, s.dbStatus
+	s.vulnProvider = newSharedProvider(provider, s.log)
+	s.dbStatus = status
+	s.initialized = true
+	listeners := slices.Clone(s.onDBUpdate)
+	s.mx.Unlock()
+
+	if ev, ok := dbUpdateEvent(oldStatus, status); ok {
+		s.log.Info("Vulnerability DB updated", "previous", ev.PreviousBuilt, "built", ev.Built)
+		for _, fn := range listeners {
+			go fn(ev)
+		}
 	}
 
-	s.initialized = true
-	s.log.Info("Vulnerability scanner initialized successfully")
+	if old != nil {
+		old.release()
+	}
+	return nil
+}
+
+// DBUpdateEvent is emitted when a vulnerability DB with a newer build date
+// replaces the one in use.
+type DBUpdateEvent struct {
+	PreviousBuilt time.Time `json:"previous_built"`
+	Built         time.Time `json:"built"`
+	SchemaVersion string    `json:"schema_version"`
+}
+
+// OnDBUpdate registers fn to be called (in its own goroutine) whenever a newer
+// vulnerability DB is installed. The initial load is not reported.
+func (s *imageScanner) OnDBUpdate(fn func(DBUpdateEvent)) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.onDBUpdate = append(s.onDBUpdate, fn)
+}
+
+// dbUpdateEvent reports whether next is a newer DB build than prev
+func dbUpdateEvent(prev, next *vulnerability.ProviderStatus) (DBUpdateEvent, bool) {
+	if prev == nil || next == nil || prev.Built.IsZero() || !next.Built.After(prev.Built) {
+		return DBUpdateEvent{}, false
+	}
+	return DBUpdateEvent{
+		PreviousBuilt: prev.Built,
+		Built:         next.Built,
+		SchemaVersion: next.SchemaVersion,
+	}, true
+}
+
+// RefreshDB forces a vulnerability DB update check and swaps in the new
+// provider. It is safe to call while scans are in flight. Returns the build
+// date of the DB now in use.
+func (s *imageScanner) RefreshDB() (time.Time, error) {
+	s.log.Info("Refreshing vulnerability DB")
+	if err := s.loadDB(true); err != nil {
+		s.log.Error("VulDb refresh failed", "error", err)
+		return time.Time{}, err
+	}
+
+	status := s.DBStatus()
+	s.log.Info("Vulnerability DB refreshed", "built", status.Built, "schema", status.SchemaVersion)
+	return status.Built, nil
+}
+
+// DBStatus returns the status of the currently loaded vulnerability DB
+func (s *imageScanner) DBStatus() vulnerability.ProviderStatus {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.dbStatus == nil {
+		return vulnerability.ProviderStatus{}
+	}
+	return *s.dbStatus
+}
+
+// loadDBWithRetry attempts to load the vulnerability DB with exponential backoff.
+func (s *imageScanner) loadDBWithRetry() error {
+	backoff := s.config.DBLoadBackoff
+	var err error
+	for attempt := 1; attempt <= s.config.DBLoadAttempts; attempt++ {
+		s.log.Info("Loading vulnerability DB", "attempt", attempt, "maxAttempts", s.config.DBLoadAttempts)
+		if err = s.loadDB(false); err == nil {
+			return nil
+		}
+		s.log.Warn("VulDb load attempt failed", "attempt", attempt, "error", err)
+		if attempt == s.config.DBLoadAttempts {
+			break
+		}
+
+		select {
+		case <-s.stopCh:
+			return fmt.Errorf("scanner stopped: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, dbLoadMaxBackoff)
+	}
+	return fmt.Errorf("vulnerability db load failed after %d attempts: %w", s.config.DBLoadAttempts, err)
+}
+
+// retryLoop periodically retries the DB load until it succeeds or the scanner is stopped.
+func (s *imageScanner) retryLoop() {
+	ticker := time.NewTicker(dbRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if err := s.loadDBWithRetry(); err != nil {
+				s.log.Error("VulDb background load failed", "error", err)
+				continue
+			}
+			s.log.Info("Vulnerability scanner initialized successfully (background retry)")
+			return
+		}
+	}
 }
 
 // Stop closes scan database like K9s
 func (s *imageScanner) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
 	if s.vulnProvider != nil {
+		s.vulnProvider.release()
 		s.vulnProvider = nil
 	}
 }
@@ -192,37 +427,51 @@ func (s *imageScanner) isInitialized() bool {
 	return s.initialized
 }
 
+// IsInitialized returns whether the vulnerability DB has been loaded
+func (s *imageScanner) IsInitialized() bool {
+	return s.isInitialized()
+}
+
 // Enqueue images for scanning like K9s
 func (s *imageScanner) Enqueue(ctx context.Context, images ...string) {
 	if !s.isInitialized() {
 		return
 	}
-	ctx, cancel := context.WithTimeout(ctx, imgScanTimeout)
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
 	defer cancel()
 
 	for _, img := range images {
-		if _, ok := s.GetScan(img); ok {
+		if _, ok := s.GetScan(scanKey(img, matchProfileFor(ctx), platformFor(ctx))); ok {
 			continue
 		}
 		go s.scanWorker(ctx, img)
 	}
 }
 
-// ScanImage performs a synchronous vulnerability scan
+// ScanImage performs a synchronous vulnerability scan, with the match profile
+// carried by ctx (see WithMatchProfile)
 func (s *imageScanner) ScanImage(ctx context.Context, img string) (*Scan, error) {
 	if !s.isInitialized() {
 		return nil, fmt.Errorf("vulnerability scanner not initialized")
 	}
 
 	// Check cache first
-	if sc, ok := s.GetScan(img); ok {
+	key := scanKey(img, matchProfileFor(ctx), platformFor(ctx))
+	if sc, ok := s.GetScan(key); ok {
 		return sc, nil
 	}
 
 	sc := newScan(img)
-	s.setScan(img, sc)
+	s.setScan(key, sc)
 
-	if err := s.scan(ctx, img, sc); err != nil {
+	_, err := runWithTimeout(ctx, "grype", img, timeoutFor(ctx, s.config.Timeout), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, s.scan(ctx, img, sc)
+	})
+	if err != nil {
+		// Don't serve a partial result from cache on the next attempt
+		s.mx.Lock()
+		delete(s.scans, key)
+		s.mx.Unlock()
 		return nil, err
 	}
 
@@ -235,7 +484,7 @@ func (s *imageScanner) scanWorker(ctx context.Context, img string) {
 
 	s.log.Info("ScanWorker processing image", "image", img)
 	sc := newScan(img)
-	s.setScan(img, sc)
+	s.setScan(scanKey(img, matchProfileFor(ctx), platformFor(ctx)), sc)
 	if err := s.scan(ctx, img, sc); err != nil {
 		s.log.Error("Scan failed for image",
 			"image", img,
@@ -246,8 +495,37 @@ func (s *imageScanner) scanWorker(ctx context.Context, img string) {
 	}
 }
 
+// ScanDirectory scans a directory, e.g. an extracted source checkout, for
+// vulnerable dependencies through syft's dir: source. The result has the same
+// format as an image scan; it is not cached.
+func (s *imageScanner) ScanDirectory(ctx context.Context, path string) (*Scan, error) {
+	if !s.isInitialized() {
+		return nil, fmt.Errorf("vulnerability scanner not initialized")
+	}
+
+	sc := newScan(path)
+	_, err := runWithTimeout(ctx, "grype", path, timeoutFor(ctx, s.config.Timeout), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, s.scanInput(ctx, path, "dir:"+path, sc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
 // scan performs the actual vulnerability scanning like K9s
-func (s *imageScanner) scan(_ context.Context, img string, sc *Scan) error {
+func (s *imageScanner) scan(ctx context.Context, img string, sc *Scan) error {
+	// Local docker-archive/OCI inputs need syft's scheme prefix
+	input := img
+	if in, err := ParseImageInput(img, ""); err == nil {
+		input = in.SyftInput()
+	}
+	return s.scanInput(ctx, img, input, sc)
+}
+
+// scanInput scans the syft source input (an image, archive or dir: path)
+// into sc; img names it in logs and selects registry credentials
+func (s *imageScanner) scanInput(ctx context.Context, img, input string, sc *Scan) error {
 	defer func(t time.Time) {
 		s.log.Debug("[Vulscan] perf",
 			"image", img,
@@ -255,10 +533,32 @@ func (s *imageScanner) scan(_ context.Context, img string, sc *Scan) error {
 		)
 	}(time.Now())
 
-	s.log.Info("Starting vulnerability scan", "image", img)
+	profile := matchProfileFor(ctx)
+	opts := withMatchProfile(s.opts, profile)
+	platform := platformFor(ctx)
+	if platform != "" {
+		o := *opts
+		o.Platform = platform
+		opts = &o
+	}
+	s.log.Info("Starting vulnerability scan", "image", img, "matchProfile", profile, "platform", platform)
+
+	// Hold a reference to the current provider so a concurrent RefreshDB can't
+	// close it mid-scan, even after runWithTimeout has given up waiting
+	s.mx.RLock()
+	shared := s.vulnProvider
+	if shared != nil {
+		shared.acquire()
+	}
+	s.mx.RUnlock()
+	if shared == nil {
+		return fmt.Errorf("vulnerability db not loaded")
+	}
+	defer shared.release()
+	vulnProvider := shared.Provider
 
 	var errs error
-	packages, pkgContext, _, err := pkg.Provide(img, getProviderConfig(s.opts))
+	packages, pkgContext, sb, err := pkg.Provide(input, getProviderConfig(ctx, opts, img))
 	if err != nil {
 		s.log.Error("Failed to catalog packages", "image", img, "error", err)
 		errs = errors.Join(errs, fmt.Errorf("failed to catalog %s: %w", img, err))
@@ -266,21 +566,22 @@ func (s *imageScanner) scan(_ context.Context, img string, sc *Scan) error {
 	}
 
 	s.log.Info("Cataloged packages", "image", img, "packages", len(packages))
+	sc.Licenses = ExtractLicenses(sb)
 
 	vexProcessor, err := vex.NewProcessor(vex.ProcessorOptions{
-		Documents:   s.opts.VexDocuments,
-		IgnoreRules: s.opts.Ignore,
+		Documents:   opts.VexDocuments,
+		IgnoreRules: opts.Ignore,
 	})
 	if err != nil {
 		errs = errors.Join(errs, fmt.Errorf("failed to create vex processor: %w", err))
 	}
 
 	v := grype.VulnerabilityMatcher{
-		VulnerabilityProvider: s.vulnProvider,
-		IgnoreRules:           s.opts.Ignore,
-		NormalizeByCVE:        s.opts.ByCVE,
-		FailSeverity:          s.opts.FailOnSeverity(),
-		Matchers:              getMatchers(s.opts),
+		VulnerabilityProvider: vulnProvider,
+		IgnoreRules:           opts.Ignore,
+		NormalizeByCVE:        opts.ByCVE,
+		FailSeverity:          opts.FailOnSeverity(),
+		Matchers:              getMatchers(opts),
 		VexProcessor:          vexProcessor,
 	}
 
@@ -292,7 +593,7 @@ func (s *imageScanner) scan(_ context.Context, img string, sc *Scan) error {
 
 	s.log.Info("Found vulnerability matches", "image", img, "matches", mm.Count())
 
-	if err := sc.run(mm, s.vulnProvider); err != nil {
+	if err := sc.run(mm, vulnProvider); err != nil {
 		s.log.Error("Failed to process scan results", "image", img, "error", err)
 		errs = errors.Join(errs, err)
 	}
@@ -302,8 +603,89 @@ func (s *imageScanner) scan(_ context.Context, img string, sc *Scan) error {
 	return errs
 }
 
-// getProviderConfig creates provider config like K9s
-func getProviderConfig(opts *options.Grype) pkg.ProviderConfig {
+// GenerateSBOM catalogs img with syft and returns the SBOM as syft JSON along
+// with its license report. Vulnerability matching is skipped, so the DB does
+// not need to be loaded. With AttachedSBOMs, an SBOM attached to a registry
+// image is used instead when there is one.
+func (s *imageScanner) GenerateSBOM(ctx context.Context, img string) ([]byte, *LicenseReport, error) {
+	s.mx.RLock()
+	opts, id := s.opts, s.id
+	s.mx.RUnlock()
+	if opts == nil {
+		return nil, nil, fmt.Errorf("vulnerability scanner not initialized")
+	}
+
+	input := img
+	in, err := ParseImageInput(img, "")
+	if err == nil {
+		input = in.SyftInput()
+	}
+
+	if s.config.AttachedSBOMs && err == nil && in.Transport == SourceRemote {
+		if sb, err := s.attachedSBOM(ctx, in.Ref); err != nil {
+			s.log.Info("No usable attached SBOM, cataloging the image", "image", img, "reason", err)
+		} else {
+			return encodeSBOM(sb, img)
+		}
+	}
+
+	s.log.Info("Generating SBOM", "image", img)
+
+	sb, err := runWithTimeout(ctx, "sbom", img, timeoutFor(ctx, s.config.Timeout), func(ctx context.Context) (*sbom.SBOM, error) {
+		_, _, sb, err := pkg.Provide(input, getProviderConfig(ctx, opts, img))
+		return sb, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to catalog %s: %w", img, err)
+	}
+	if sb == nil {
+		return nil, nil, fmt.Errorf("no SBOM produced for %s", img)
+	}
+	sb.Descriptor = sbom.Descriptor{Name: id.Name, Version: id.Version}
+
+	s.log.Info("SBOM generated", "image", img, "packages", sb.Artifacts.Packages.PackageCount())
+	return encodeSBOM(sb, img)
+}
+
+// attachedSBOM returns the first SBOM attached to the registry image ref that
+// syft can decode (syft JSON, SPDX or CycloneDX)
+func (s *imageScanner) attachedSBOM(ctx context.Context, ref string) (*sbom.SBOM, error) {
+	referrers, err := ListReferrers(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range referrers {
+		if r.Kind != ReferrerSBOM {
+			continue
+		}
+		data, err := FetchReferrer(ctx, ref, r)
+		if err != nil {
+			s.log.Warn("Failed to fetch attached SBOM", "image", ref, "digest", r.Digest, "error", err)
+			continue
+		}
+		sb, formatID, _, err := format.Decode(bytes.NewReader(data))
+		if err != nil || sb == nil {
+			s.log.Warn("Failed to decode attached SBOM", "image", ref, "digest", r.Digest, "artifactType", r.ArtifactType, "error", err)
+			continue
+		}
+		s.log.Info("Using attached SBOM", "image", ref, "digest", r.Digest, "format", formatID, "packages", sb.Artifacts.Packages.PackageCount())
+		return sb, nil
+	}
+	return nil, fmt.Errorf("no SBOM among %d referrers", len(referrers))
+}
+
+// encodeSBOM encodes sb as syft JSON and extracts its license report
+func encodeSBOM(sb *sbom.SBOM, img string) ([]byte, *LicenseReport, error) {
+	out, err := format.Encode(*sb, syftjson.NewFormatEncoder())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode SBOM for %s: %w", img, err)
+	}
+	return out, ExtractLicenses(sb), nil
+}
+
+// getProviderConfig creates provider config like K9s, adding any registry
+// credentials carried by ctx for img
+func getProviderConfig(ctx context.Context, opts *options.Grype, img string) pkg.ProviderConfig {
 	// Create default SBOM configuration like K9s does
 	cfg := syft.DefaultCreateSBOMConfig()
 	cfg.Packages.JavaArchive.IncludeIndexedArchives = opts.Search.IncludeIndexedArchives
@@ -312,10 +694,16 @@ func getProviderConfig(opts *options.Grype) pkg.ProviderConfig {
 	// Handle packages with missing version information
 	cfg.Compliance.MissingVersion = cataloging.ComplianceActionDrop
 
+	registryOpts := opts.Registry.ToOptions()
+	registryOpts.Credentials = append(registryOpts.Credentials, registryCredentials(ctx, img)...)
+	if insecureRegistry(img) {
+		registryOpts.InsecureSkipTLSVerify = true
+	}
+
 	return pkg.ProviderConfig{
 		SyftProviderConfig: pkg.SyftProviderConfig{
 			SBOMOptions:            cfg,
-			RegistryOptions:        opts.Registry.ToOptions(),
+			RegistryOptions:        registryOpts,
 			Platform:               opts.Platform,
 			Name:                   opts.Name,
 			DefaultImagePullSource: opts.DefaultImagePullSource,
@@ -480,6 +868,85 @@ func (r row) Severity() string {
 	return ""
 }
 
+// FixStateOf derives a grype fix state ("fixed", "not-fixed", "wont-fix" or
+// "unknown") from a row's fix column as written by Scan.run.
+func FixStateOf(fix string) string {
+	switch fix {
+	case wontFix:
+		return string(vulnerability.FixStateWontFix)
+	case naValue:
+		return string(vulnerability.FixStateUnknown)
+	case "":
+		return string(vulnerability.FixStateNotFixed)
+	default:
+		return string(vulnerability.FixStateFixed)
+	}
+}
+
+// FixState returns the row's grype fix state, see FixStateOf
+func (r row) FixState() string {
+	return FixStateOf(r.Fix())
+}
+
+// FixAvailable reports whether a row's fix column names a fixed version
+func FixAvailable(fix string) bool {
+	return FixStateOf(fix) == string(vulnerability.FixStateFixed)
+}
+
+// HasFix reports whether a fixed version of the package is available
+func (r row) HasFix() bool {
+	return FixAvailable(r.Fix())
+}
+
+// ScanSummary is a condensed view of a Scan for LLM consumption: severity
+// counts plus only the Critical and High findings.
+type ScanSummary struct {
+	ID       string           `json:"id"`
+	Tally    tally            `json:"tally"`
+	Findings []SummaryFinding `json:"findings"`
+	Omitted  int              `json:"omitted"` // Medium/Low/Unknown findings left out
+}
+
+// SummaryFinding holds the key fields of a single vulnerability match
+type SummaryFinding struct {
+	Vulnerability string `json:"vulnerability"`
+	Package       string `json:"package"`
+	Version       string `json:"version"`
+	Fix           string `json:"fix"`
+	Type          string `json:"type"`
+	Severity      string `json:"severity"`
+	HasFix        bool   `json:"has_fix"` // A version bump remediates it
+}
+
+// Summary condenses the scan to its Critical and High findings, Critical
+// first and, within a severity, those with a fix available first.
+func (s *Scan) Summary() *ScanSummary {
+	summary := &ScanSummary{ID: s.ID, Tally: s.Tally, Findings: []SummaryFinding{}}
+	if s.Table == nil {
+		return summary
+	}
+	for _, severity := range []string{"Critical", "High"} {
+		for _, fixable := range []bool{true, false} {
+			for _, r := range s.Table.Rows {
+				if r.Severity() != severity || r.HasFix() != fixable {
+					continue
+				}
+				summary.Findings = append(summary.Findings, SummaryFinding{
+					Vulnerability: r.Vulnerability(),
+					Package:       r.Name(),
+					Version:       r.Version(),
+					Fix:           r.Fix(),
+					Type:          r.Type(),
+					Severity:      severity,
+					HasFix:        fixable,
+				})
+			}
+		}
+	}
+	summary.Omitted = len(s.Table.Rows) - len(summary.Findings)
+	return summary
+}
+
 func (cfg ImageScans) ShouldExclude(ns string, lbls map[string]string) bool {
 	// Check namespace exclusions
 	for _, excludeNS := range cfg.Exclusions.Namespaces {
@@ -5,12 +5,20 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/anchore/clio"
+	"github.com/anchore/grype/cmd/grype/cli/options"
+	"github.com/anchore/grype/grype/vulnerability"
 )
 
 func TestGrypeScan(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	// Fail fast when no DB can be fetched instead of backing off for ~30s
 	cfg := ImageScans{
-		Enable: true,
+		Enable:         true,
+		DBLoadAttempts: 2,
+		DBLoadBackoff:  10 * time.Millisecond,
 	}
 
 	scanner := NewImageScanner(cfg, logger)
@@ -42,3 +50,164 @@ func TestGrypeScan(t *testing.T) {
 	t.Logf("Critical: %d, High: %d, Medium: %d, Low: %d",
 		result.Tally.Critical, result.Tally.High, result.Tally.Medium, result.Tally.Low)
 }
+
+func TestDBUpdateEvent(t *testing.T) {
+	older := &vulnerability.ProviderStatus{Built: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newer := &vulnerability.ProviderStatus{Built: older.Built.Add(24 * time.Hour), SchemaVersion: "v6.0.2"}
+
+	if _, ok := dbUpdateEvent(nil, newer); ok {
+		t.Error("initial load reported as an update")
+	}
+	if _, ok := dbUpdateEvent(newer, older); ok {
+		t.Error("older DB reported as an update")
+	}
+	if _, ok := dbUpdateEvent(newer, newer); ok {
+		t.Error("same DB reported as an update")
+	}
+
+	ev, ok := dbUpdateEvent(older, newer)
+	if !ok {
+		t.Fatal("newer DB not reported")
+	}
+	if !ev.PreviousBuilt.Equal(older.Built) || !ev.Built.Equal(newer.Built) || ev.SchemaVersion != "v6.0.2" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+// closeCounter is a vulnerability provider that only counts Close calls
+type closeCounter struct {
+	vulnerability.Provider
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestSharedProviderClosesAfterLastScan(t *testing.T) {
+	old := &closeCounter{}
+	s := NewImageScanner(ImageScans{}, slog.Default())
+	s.vulnProvider = newSharedProvider(old, s.log)
+
+	// A scan in flight holds the provider across a refresh
+	s.mx.RLock()
+	inFlight := s.vulnProvider
+	inFlight.acquire()
+	s.mx.RUnlock()
+
+	s.mx.Lock()
+	prev := s.vulnProvider
+	s.vulnProvider = newSharedProvider(&closeCounter{}, s.log)
+	s.mx.Unlock()
+	prev.release()
+
+	if old.closed != 0 {
+		t.Fatal("swapped-out provider closed while a scan still uses it")
+	}
+	inFlight.release()
+	if old.closed != 1 {
+		t.Errorf("provider closed %d times after its last scan, want 1", old.closed)
+	}
+
+	s.Stop()
+	if s.vulnProvider != nil {
+		t.Error("provider still set after Stop")
+	}
+}
+
+func TestScanSummary(t *testing.T) {
+	scan := newScan("alpine:3.18")
+	scan.Table.Rows = []row{
+		newRow("busybox", "1.36.1", naValue, "apk", "CVE-2023-0001", "High"),
+		newRow("libcrypto3", "3.1.1", "3.1.2", "apk", "CVE-2023-0002", "Critical"),
+		newRow("zlib", "1.2.13", "1.3", "apk", "CVE-2023-0003", "Medium"),
+		newRow("musl", "1.2.4", wontFix, "apk", "CVE-2023-0004", "Unknown"),
+	}
+	scan.Tally = newTally(scan.Table)
+
+	summary := scan.Summary()
+	if len(summary.Findings) != 2 || summary.Omitted != 2 {
+		t.Fatalf("expected 2 findings and 2 omitted, got %d and %d", len(summary.Findings), summary.Omitted)
+	}
+	if f := summary.Findings[0]; f.Vulnerability != "CVE-2023-0002" || f.Fix != "3.1.2" {
+		t.Errorf("expected Critical finding first, got %+v", f)
+	}
+	if f := summary.Findings[1]; f.Vulnerability != "CVE-2023-0001" || f.HasFix {
+		t.Errorf("expected High finding without fix second, got %+v", f)
+	}
+	if summary.Tally.Total != 4 {
+		t.Errorf("tally total = %d, want 4", summary.Tally.Total)
+	}
+}
+
+func TestFixState(t *testing.T) {
+	tests := []struct {
+		fix    string
+		want   string
+		hasFix bool
+	}{
+		{"3.1.2", "fixed", true},
+		{"1.2.1, 1.3.0", "fixed", true},
+		{"", "not-fixed", false},
+		{wontFix, "wont-fix", false},
+		{naValue, "unknown", false},
+	}
+	for _, tt := range tests {
+		r := newRow("pkg", "1.0", tt.fix, "apk", "CVE-2023-0001", "High")
+		if r.FixState() != tt.want || r.HasFix() != tt.hasFix {
+			t.Errorf("fix %q: state = %q, has fix = %t; want %q, %t", tt.fix, r.FixState(), r.HasFix(), tt.want, tt.hasFix)
+		}
+	}
+}
+
+func TestPackageTypeFilter(t *testing.T) {
+	match, err := PackageTypeFilter([]string{"os", "Node", "go-module"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for packageType, want := range map[string]bool{
+		"apk": true, "deb": true, "npm": true, "go-module": true,
+		"python": false, "java-archive": false,
+	} {
+		if got := match(packageType); got != want {
+			t.Errorf("match(%q) = %t, want %t", packageType, got, want)
+		}
+	}
+
+	if all, _ := PackageTypeFilter(nil); !all("python") {
+		t.Error("empty filter should match everything")
+	}
+	if _, err := PackageTypeFilter([]string{"cobol"}); err == nil {
+		t.Error("unknown package type accepted")
+	}
+}
+
+func TestWithMatchProfile(t *testing.T) {
+	opts := options.DefaultGrype(clio.Identification{Name: "test"})
+	opts.GenerateMissingCPEs = true
+
+	if got := withMatchProfile(opts, MatchProfileDefault); got != opts {
+		t.Error("default profile should use the scanner options as is")
+	}
+
+	aggressive := withMatchProfile(opts, MatchProfileCPEAggressive)
+	if !aggressive.GenerateMissingCPEs || !aggressive.Match.Python.UseCPEs || !aggressive.Match.Javascript.UseCPEs || !aggressive.Match.Stock.UseCPEs {
+		t.Errorf("cpe-aggressive should enable CPE matching everywhere: %+v", aggressive.Match)
+	}
+	if opts.Match.Python.UseCPEs {
+		t.Error("profile modified the scanner options")
+	}
+
+	exact := withMatchProfile(opts, MatchProfileExactOnly)
+	if exact.GenerateMissingCPEs || exact.Match.Stock.UseCPEs || exact.Match.Golang.AlwaysUseCPEForStdlib {
+		t.Errorf("exact-only should disable CPE matching: %+v", exact.Match)
+	}
+
+	if _, err := ParseMatchProfile("fuzzy"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+	if p, _ := ParseMatchProfile(""); p != MatchProfileDefault {
+		t.Errorf("empty profile = %q, want %q", p, MatchProfileDefault)
+	}
+}
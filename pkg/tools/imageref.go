@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/types"
+)
+
+// SourceOCI selects an OCI image layout directory on disk
+const SourceOCI = "oci"
+
+// SourceLocal is a source hint asking ResolveRequestImage to sniff whether a
+// path is a docker-archive or an OCI layout
+const SourceLocal = "local"
+
+// LocalImageRoot is the only directory API callers may scan local images from.
+// Empty (the default) disables local image inputs over the API.
+var LocalImageRoot string
+
+// ImageInput is an image reference with its transport resolved. It lets every
+// tool accept registry images, docker-save tarballs and OCI layouts alike.
+type ImageInput struct {
+	Transport string `json:"transport"` // SourceRemote, SourceDockerArchive or SourceOCI
+	Ref       string `json:"ref"`       // Registry reference, or filesystem path (oci: path[:tag])
+}
+
+// ParseImageInput resolves input to an ImageInput without touching the
+// filesystem. An explicit transport prefix ("docker://", "docker-archive:",
+// "oci:") wins, then the source hint; anything else is a registry image.
+func ParseImageInput(input, hint string) (ImageInput, error) {
+	if input == "" {
+		return ImageInput{}, fmt.Errorf("image reference is required")
+	}
+
+	switch {
+	case strings.HasPrefix(input, "docker://"):
+		return ImageInput{Transport: SourceRemote, Ref: strings.TrimPrefix(input, "docker://")}, nil
+	case strings.HasPrefix(input, SourceDockerArchive+":"):
+		return ImageInput{Transport: SourceDockerArchive, Ref: strings.TrimPrefix(input, SourceDockerArchive+":")}, nil
+	case strings.HasPrefix(input, SourceOCI+":"):
+		return ImageInput{Transport: SourceOCI, Ref: strings.TrimPrefix(input, SourceOCI+":")}, nil
+	}
+
+	switch hint {
+	case SourceDockerArchive, SourceOCI:
+		return ImageInput{Transport: hint, Ref: input}, nil
+	case "", SourceRemote, SourceDocker, SourcePodman:
+		return ImageInput{Transport: SourceRemote, Ref: input}, nil
+	default:
+		return ImageInput{}, fmt.Errorf("unsupported image source %q", hint)
+	}
+}
+
+// ResolveRequestImage parses an image reference sent by an API caller. Local
+// images must lie inside LocalImageRoot, and the filesystem is only sniffed
+// when hint is SourceLocal; a bare reference is always a registry image.
+func ResolveRequestImage(input, hint string) (ImageInput, error) {
+	if hint == SourceLocal {
+		in, err := confineLocalImage(ImageInput{Transport: SourceOCI, Ref: input})
+		if err != nil {
+			return ImageInput{}, err
+		}
+		transport := sniffLocalImage(in.Ref)
+		if transport == "" {
+			return ImageInput{}, fmt.Errorf("%s is not a docker-archive or OCI layout", input)
+		}
+		in.Transport = transport
+		return in, nil
+	}
+
+	in, err := ParseImageInput(input, hint)
+	if err != nil || !in.IsLocal() {
+		return in, err
+	}
+	return confineLocalImage(in)
+}
+
+// confineLocalImage rewrites the path of a local image to its cleaned absolute
+// form under LocalImageRoot, rejecting paths that leave it. Relative paths are
+// taken relative to the root.
+func confineLocalImage(in ImageInput) (ImageInput, error) {
+	if LocalImageRoot == "" {
+		return ImageInput{}, errors.New("local image inputs are disabled (set LOCAL_IMAGE_ROOT to enable)")
+	}
+	root, err := filepath.Abs(LocalImageRoot)
+	if err != nil {
+		return ImageInput{}, fmt.Errorf("invalid LOCAL_IMAGE_ROOT: %w", err)
+	}
+
+	path, tag := in.Ref, ""
+	if in.Transport == SourceOCI {
+		if i := strings.LastIndex(path, ":"); i > 0 && !strings.ContainsRune(path[i+1:], filepath.Separator) {
+			path, tag = path[:i], path[i:]
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if !withinDir(root, path) {
+		return ImageInput{}, fmt.Errorf("local image %s is outside LOCAL_IMAGE_ROOT", in.Ref)
+	}
+
+	// A symlink inside the root must not lead out of it either
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		realRoot, rerr := filepath.EvalSymlinks(root)
+		if rerr != nil || !withinDir(realRoot, real) {
+			return ImageInput{}, fmt.Errorf("local image %s is outside LOCAL_IMAGE_ROOT", in.Ref)
+		}
+	}
+
+	in.Ref = path + tag
+	return in, nil
+}
+
+// withinDir reports whether the cleaned absolute path lies inside dir
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sniffLocalImage returns the transport for a path on disk, or "" if input is
+// not a local image. Directories with an oci-layout file are OCI layouts;
+// tar files are docker-archives.
+func sniffLocalImage(input string) string {
+	path := input
+	if _, err := os.Stat(path); err != nil {
+		// Allow an OCI "path:tag" suffix
+		i := strings.LastIndex(input, ":")
+		if i <= 0 {
+			return ""
+		}
+		path = input[:i]
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(path, "oci-layout")); err == nil {
+			return SourceOCI
+		}
+		return ""
+	}
+	if path == input && isTarFile(path) {
+		return SourceDockerArchive
+	}
+	return ""
+}
+
+func isTarFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = tar.NewReader(f).Next()
+	return err == nil || err == io.EOF
+}
+
+// IsLocal reports whether the image lives on the local filesystem
+func (in ImageInput) IsLocal() bool {
+	return in.Transport == SourceDockerArchive || in.Transport == SourceOCI
+}
+
+// Path returns the filesystem path of a local image (without any OCI tag)
+func (in ImageInput) Path() string {
+	if in.Transport != SourceOCI {
+		return in.Ref
+	}
+	if _, err := os.Stat(in.Ref); err == nil {
+		return in.Ref
+	}
+	if i := strings.LastIndex(in.Ref, ":"); i > 0 {
+		return in.Ref[:i]
+	}
+	return in.Ref
+}
+
+// String returns the transport-prefixed form, which round-trips through ParseImageInput
+func (in ImageInput) String() string {
+	if in.Transport == SourceRemote {
+		return in.Ref
+	}
+	return in.Transport + ":" + in.Ref
+}
+
+// SyftInput returns the user input string grype/syft understand for this image
+func (in ImageInput) SyftInput() string {
+	switch in.Transport {
+	case SourceDockerArchive:
+		return "docker-archive:" + in.Ref
+	case SourceOCI:
+		return "oci-dir:" + in.Path()
+	default:
+		return in.Ref
+	}
+}
+
+// containersRef returns the containers/image reference for this input
+func (in ImageInput) containersRef() (types.ImageReference, error) {
+	switch in.Transport {
+	case SourceDockerArchive:
+		return archive.ParseReference(in.Ref)
+	case SourceOCI:
+		return layout.ParseReference(in.Ref)
+	default:
+		return parseImageReference(in.Ref)
+	}
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseImageInputDoesNotSniff(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nginx"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nginx", "oci-layout"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	in, err := ParseImageInput("nginx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Transport != SourceRemote {
+		t.Errorf("bare ref resolved to %s, want %s", in.Transport, SourceRemote)
+	}
+}
+
+func TestResolveRequestImage(t *testing.T) {
+	defer func(prev string) { LocalImageRoot = prev }(LocalImageRoot)
+	root := t.TempDir()
+	outside := t.TempDir()
+	layout := filepath.Join(root, "app")
+	if err := os.MkdirAll(layout, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(layout, "oci-layout"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	LocalImageRoot = ""
+	if _, err := ResolveRequestImage("oci:"+layout, ""); err == nil {
+		t.Error("local input accepted with LOCAL_IMAGE_ROOT unset")
+	}
+	if in, err := ResolveRequestImage("nginx:latest", ""); err != nil || in.Transport != SourceRemote {
+		t.Errorf("registry ref = %+v, %v", in, err)
+	}
+
+	LocalImageRoot = root
+	accepted := map[string]string{
+		"oci:" + layout + ":v1": "oci:" + layout + ":v1",
+		"oci:app":               "oci:" + layout,
+		"docker-archive:x.tar":  "docker-archive:" + filepath.Join(root, "x.tar"),
+	}
+	for ref, want := range accepted {
+		in, err := ResolveRequestImage(ref, "")
+		if err != nil {
+			t.Errorf("ResolveRequestImage(%q): %v", ref, err)
+			continue
+		}
+		if got := in.String(); got != want {
+			t.Errorf("ResolveRequestImage(%q) = %q, want %q", ref, got, want)
+		}
+	}
+
+	rejected := []string{
+		"oci:" + outside,
+		"oci:../" + filepath.Base(outside),
+		"docker-archive:/etc/passwd",
+		"docker-archive:" + root + "/../x.tar",
+		"oci:escape",
+	}
+	for _, ref := range rejected {
+		if _, err := ResolveRequestImage(ref, ""); err == nil {
+			t.Errorf("ResolveRequestImage(%q) escaped LOCAL_IMAGE_ROOT", ref)
+		}
+	}
+
+	if in, err := ResolveRequestImage("app", SourceLocal); err != nil || in.Transport != SourceOCI || in.Ref != layout {
+		t.Errorf("sniffed layout = %+v, %v", in, err)
+	}
+	if _, err := ResolveRequestImage("/etc", SourceLocal); err == nil {
+		t.Error("sniffed a path outside LOCAL_IMAGE_ROOT")
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"path"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// InsecureRegistries are registry host patterns whose TLS certificates are
+// not verified, e.g. "registry.internal:5000" or "*.corp.example" (path.Match
+// syntax, with or without a port). Every other registry keeps strict TLS.
+// Set from IMAGE_INSECURE_REGISTRIES; applies to the inspector, grype, dockle
+// and dive pulls alike.
+var InsecureRegistries []string
+
+// ParseRegistryPatterns splits a comma-separated IMAGE_INSECURE_REGISTRIES value
+func ParseRegistryPatterns(v string) []string {
+	var patterns []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// insecureRegistry reports whether imageName's registry matches InsecureRegistries.
+// A pattern without a port matches the host on any port.
+func insecureRegistry(imageName string) bool {
+	host := strings.ToLower(RegistryHost(imageName))
+	if host == "" {
+		return false
+	}
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		hostname = host[:i]
+	}
+	for _, pattern := range InsecureRegistries {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, hostname); ok && !strings.Contains(pattern, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// insecureTLS is the containers/image TLS setting for imageName: skip
+// verification for InsecureRegistries, otherwise the default (verify)
+func insecureTLS(imageName string) types.OptionalBool {
+	if insecureRegistry(imageName) {
+		return types.OptionalBoolTrue
+	}
+	return types.OptionalBoolUndefined
+}
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/anchore/syft/syft/sbom"
+)
+
+// License categories
+const (
+	LicensePermissive = "permissive"
+	LicenseCopyleft   = "copyleft"
+	LicenseUnknown    = "unknown"
+)
+
+// License identifier prefixes (upper-cased SPDX IDs) per category. Anything
+// not listed is reported as unknown.
+var (
+	copyleftLicenses = []string{
+		"AGPL", "GPL", "LGPL", "MPL", "EPL", "CDDL", "EUPL", "OSL", "CPL",
+		"CC-BY-SA", "CECILL", "SLEEPYCAT", "APSL",
+	}
+	permissiveLicenses = []string{
+		"MIT", "BSD", "0BSD", "APACHE", "ISC", "ZLIB", "UNLICENSE", "CC0",
+		"BSL-1.0", "PSF", "PYTHON", "X11", "WTFPL", "ARTISTIC", "POSTGRESQL",
+		"OPENSSL", "SSLEAY", "CURL", "NCSA", "UPL", "PUBLIC-DOMAIN", "PUBLIC DOMAIN",
+		"BLUEOAK", "MS-PL", "HPND",
+	}
+)
+
+// PackageLicense is the license info for a single cataloged package
+type PackageLicense struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Type     string   `json:"type"`
+	Licenses []string `json:"licenses"`
+	Category string   `json:"category"`
+}
+
+// LicenseReport summarizes package licenses for compliance review
+type LicenseReport struct {
+	Summary  map[string]int   `json:"summary"`  // Package count per category
+	Licenses map[string]int   `json:"licenses"` // Package count per license expression
+	HasGPL   bool             `json:"has_gpl"`
+	HasAGPL  bool             `json:"has_agpl"`
+	Flagged  []PackageLicense `json:"flagged"` // Packages under a GPL or AGPL license
+	Packages []PackageLicense `json:"packages"`
+}
+
+// ExtractLicenses builds a LicenseReport from a syft SBOM
+func ExtractLicenses(s *sbom.SBOM) *LicenseReport {
+	report := &LicenseReport{
+		Summary: map[string]int{
+			LicensePermissive: 0,
+			LicenseCopyleft:   0,
+			LicenseUnknown:    0,
+		},
+		Licenses: map[string]int{},
+		Flagged:  []PackageLicense{},
+		Packages: []PackageLicense{},
+	}
+	if s == nil || s.Artifacts.Packages == nil {
+		return report
+	}
+
+	for _, p := range s.Artifacts.Packages.Sorted() {
+		pl := PackageLicense{
+			Name:     p.Name,
+			Version:  p.Version,
+			Type:     string(p.Type),
+			Licenses: []string{},
+		}
+
+		seen := map[string]bool{}
+		for _, l := range p.Licenses.ToSlice() {
+			expr := l.SPDXExpression
+			if expr == "" {
+				expr = l.Value
+			}
+			expr = strings.TrimSpace(expr)
+			if expr == "" || seen[expr] {
+				continue
+			}
+			seen[expr] = true
+			pl.Licenses = append(pl.Licenses, expr)
+			report.Licenses[expr]++
+		}
+		sort.Strings(pl.Licenses)
+
+		pl.Category = classifyLicenses(pl.Licenses)
+		report.Summary[pl.Category]++
+
+		gpl, agpl := hasGPL(pl.Licenses)
+		report.HasGPL = report.HasGPL || gpl
+		report.HasAGPL = report.HasAGPL || agpl
+		if gpl || agpl {
+			report.Flagged = append(report.Flagged, pl)
+		}
+
+		report.Packages = append(report.Packages, pl)
+	}
+
+	return report
+}
+
+// classifyLicenses returns the category of a package given all its declared
+// license expressions. Any copyleft expression makes the package copyleft.
+func classifyLicenses(exprs []string) string {
+	category := LicenseUnknown
+	for _, expr := range exprs {
+		switch classifyExpression(expr) {
+		case LicenseCopyleft:
+			return LicenseCopyleft
+		case LicensePermissive:
+			category = LicensePermissive
+		}
+	}
+	return category
+}
+
+// classifyExpression categorizes an SPDX expression. For "OR" the most
+// permissive alternative wins since the user may choose it; for "AND"/"WITH"
+// the most restrictive term wins.
+func classifyExpression(expr string) string {
+	expr = strings.NewReplacer("(", " ", ")", " ").Replace(strings.ToUpper(expr))
+
+	if alts := strings.Split(expr, " OR "); len(alts) > 1 {
+		best := LicenseUnknown
+		for _, alt := range alts {
+			switch classifyExpression(alt) {
+			case LicensePermissive:
+				return LicensePermissive
+			case LicenseCopyleft:
+				best = LicenseCopyleft
+			}
+		}
+		return best
+	}
+
+	category := LicensePermissive
+	for _, term := range strings.Split(strings.ReplaceAll(expr, " WITH ", " AND "), " AND ") {
+		switch classifyLicenseID(strings.TrimSpace(term)) {
+		case LicenseCopyleft:
+			return LicenseCopyleft
+		case LicenseUnknown:
+			category = LicenseUnknown
+		}
+	}
+	return category
+}
+
+// classifyLicenseID categorizes a single upper-cased license identifier
+func classifyLicenseID(id string) string {
+	// License exceptions (e.g. Classpath-exception-2.0) only relax the base license
+	if strings.Contains(id, "EXCEPTION") {
+		return LicensePermissive
+	}
+	for _, prefix := range copyleftLicenses {
+		if strings.HasPrefix(id, prefix) {
+			return LicenseCopyleft
+		}
+	}
+	for _, prefix := range permissiveLicenses {
+		if strings.HasPrefix(id, prefix) {
+			return LicensePermissive
+		}
+	}
+	return LicenseUnknown
+}
+
+// hasGPL reports whether any expression references a GPL or AGPL license.
+// LGPL is not flagged since it does not extend to linking code.
+func hasGPL(exprs []string) (gpl, agpl bool) {
+	for _, expr := range exprs {
+		fields := strings.FieldsFunc(strings.ToUpper(expr), func(r rune) bool {
+			return r == ' ' || r == '(' || r == ')'
+		})
+		for _, id := range fields {
+			switch {
+			case strings.HasPrefix(id, "AGPL"):
+				agpl = true
+			case strings.HasPrefix(id, "GPL"):
+				gpl = true
+			}
+		}
+	}
+	return gpl, agpl
+}
@@ -0,0 +1,36 @@
+package tools
+
+import "testing"
+
+func TestClassifyExpression(t *testing.T) {
+	cases := map[string]string{
+		"MIT":                  LicensePermissive,
+		"Apache-2.0":           LicensePermissive,
+		"GPL-2.0-only":         LicenseCopyleft,
+		"AGPL-3.0-or-later":    LicenseCopyleft,
+		"MIT OR GPL-3.0-only":  LicensePermissive,
+		"MIT AND GPL-2.0-only": LicenseCopyleft,
+		"GPL-2.0-only WITH Classpath-exception-2.0": LicenseCopyleft,
+		"(BSD-3-Clause OR LGPL-2.1-only)":           LicensePermissive,
+		"LicenseRef-Proprietary":                    LicenseUnknown,
+		"MIT AND LicenseRef-Proprietary":            LicenseUnknown,
+	}
+
+	for expr, want := range cases {
+		if got := classifyExpression(expr); got != want {
+			t.Errorf("classifyExpression(%q) = %s, want %s", expr, got, want)
+		}
+	}
+}
+
+func TestHasGPL(t *testing.T) {
+	gpl, agpl := hasGPL([]string{"LGPL-2.1-only", "MIT"})
+	if gpl || agpl {
+		t.Errorf("LGPL/MIT flagged as gpl=%t agpl=%t", gpl, agpl)
+	}
+
+	gpl, agpl = hasGPL([]string{"(MIT OR GPL-2.0-or-later)", "AGPL-3.0-only"})
+	if !gpl || !agpl {
+		t.Errorf("expected gpl and agpl, got gpl=%t agpl=%t", gpl, agpl)
+	}
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anchore/grype/cmd/grype/cli/options"
+)
+
+// Grype match profiles trade recall for precision. CPE matching finds
+// vulnerabilities for packages the language ecosystems' advisories don't
+// cover (binaries, vendored code, missing metadata), at the cost of false
+// positives where a package's name happens to match an unrelated CPE.
+const (
+	// MatchProfileDefault uses ecosystem advisories for language packages and
+	// CPEs for everything else (grype's defaults, plus generated CPEs for
+	// packages syft couldn't assign one)
+	MatchProfileDefault = "default"
+	// MatchProfileCPEAggressive also matches every language package by CPE:
+	// the most findings, and the most false positives
+	MatchProfileCPEAggressive = "cpe-aggressive"
+	// MatchProfileExactOnly never matches by CPE, only by ecosystem and distro
+	// advisories: fewer, higher-confidence findings, but packages without an
+	// advisory source (e.g. unpackaged binaries) go unreported
+	MatchProfileExactOnly = "exact-only"
+)
+
+// ParseMatchProfile validates a match profile; empty means MatchProfileDefault
+func ParseMatchProfile(s string) (string, error) {
+	switch s {
+	case "":
+		return MatchProfileDefault, nil
+	case MatchProfileDefault, MatchProfileCPEAggressive, MatchProfileExactOnly:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid match_profile %q: want %s, %s or %s", s, MatchProfileDefault, MatchProfileCPEAggressive, MatchProfileExactOnly)
+}
+
+type matchProfileKey struct{}
+
+// WithMatchProfile returns a context selecting the grype match profile for
+// scans run with it. Empty or MatchProfileDefault leaves ctx unchanged.
+func WithMatchProfile(ctx context.Context, profile string) context.Context {
+	if profile == "" || profile == MatchProfileDefault {
+		return ctx
+	}
+	return context.WithValue(ctx, matchProfileKey{}, profile)
+}
+
+// matchProfileFor returns the match profile carried by ctx
+func matchProfileFor(ctx context.Context) string {
+	if p, ok := ctx.Value(matchProfileKey{}).(string); ok {
+		return p
+	}
+	return MatchProfileDefault
+}
+
+// withMatchProfile returns a copy of opts with the CPE settings of profile
+func withMatchProfile(opts *options.Grype, profile string) *options.Grype {
+	if profile == MatchProfileDefault {
+		return opts
+	}
+
+	useCPEs := profile == MatchProfileCPEAggressive
+	o := *opts
+	o.GenerateMissingCPEs = useCPEs
+	o.Match.Java.UseCPEs = useCPEs
+	o.Match.Ruby.UseCPEs = useCPEs
+	o.Match.Python.UseCPEs = useCPEs
+	o.Match.Dotnet.UseCPEs = useCPEs
+	o.Match.Javascript.UseCPEs = useCPEs
+	o.Match.Golang.UseCPEs = useCPEs
+	o.Match.Golang.AlwaysUseCPEForStdlib = useCPEs
+	o.Match.Stock.UseCPEs = useCPEs
+	return &o
+}
+
+// scanKey is the scan cache key of img under profile, for platform if one
+// was selected
+func scanKey(img, profile, platform string) string {
+	key := img
+	if profile != MatchProfileDefault {
+		key += "#" + profile
+	}
+	if platform != "" {
+		key += "@" + platform
+	}
+	return key
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anchore/syft/syft/pkg"
+)
+
+// packageTypeGroups maps the ecosystems accepted by package-type filters to
+// the syft package types grype reports for them
+var packageTypeGroups = map[string][]pkg.Type{
+	"os":         {pkg.ApkPkg, pkg.DebPkg, pkg.RpmPkg, pkg.AlpmPkg, pkg.PortagePkg, pkg.NixPkg, pkg.LinuxKernelPkg, pkg.LinuxKernelModulePkg},
+	"python":     {pkg.PythonPkg, pkg.CondaPkg},
+	"javascript": {pkg.NpmPkg},
+	"java":       {pkg.JavaPkg, pkg.JenkinsPluginPkg, pkg.GraalVMNativeImagePkg},
+	"golang":     {pkg.GoModulePkg},
+	"ruby":       {pkg.GemPkg},
+	"dotnet":     {pkg.DotnetPkg},
+	"rust":       {pkg.RustPkg},
+	"php":        {pkg.PhpComposerPkg, pkg.PhpPearPkg, pkg.PhpPeclPkg},
+	"binary":     {pkg.BinaryPkg},
+}
+
+// packageTypeAliases are alternative names for packageTypeGroups
+var packageTypeAliases = map[string]string{
+	"go":   "golang",
+	"node": "javascript",
+	"js":   "javascript",
+}
+
+// PackageTypeFilter returns a predicate matching grype package types (e.g.
+// "deb", "npm") against names, which are ecosystems ("os", "python",
+// "javascript", "java", "golang", "ruby", "dotnet", "rust", "php", "binary")
+// or syft package types themselves ("gem", "go-module", ...). An empty names
+// matches everything.
+func PackageTypeFilter(names []string) (func(packageType string) bool, error) {
+	if len(names) == 0 {
+		return func(string) bool { return true }, nil
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := packageTypeAliases[name]; ok {
+			name = alias
+		}
+		if group, ok := packageTypeGroups[name]; ok {
+			for _, t := range group {
+				allowed[string(t)] = true
+			}
+			continue
+		}
+		if t, ok := packageTypeNamed(name); ok {
+			allowed[string(t)] = true
+			continue
+		}
+		return nil, fmt.Errorf("unknown package type %q: want one of %s, or a syft package type", name, strings.Join(packageTypeGroupNames(), ", "))
+	}
+	return func(packageType string) bool { return allowed[packageType] }, nil
+}
+
+// packageTypeNamed returns the syft package type named name, ignoring case
+func packageTypeNamed(name string) (pkg.Type, bool) {
+	for _, t := range pkg.AllPkgs {
+		if strings.EqualFold(string(t), name) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// packageTypeGroupNames lists the ecosystem names, sorted
+func packageTypeGroupNames() []string {
+	names := make([]string, 0, len(packageTypeGroups))
+	for name := range packageTypeGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
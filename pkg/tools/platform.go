@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/manifest"
+)
+
+// DefaultPlatform is the platform images are analyzed for unless a scan asks
+// for another (see WithPlatform)
+const DefaultPlatform = "linux/amd64"
+
+// Platform is one entry of a manifest list, e.g. linux/arm/v7
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform as os/arch[/variant], the form grype accepts
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Slug returns the platform for use in artifact names, e.g. linux-arm-v7
+func (p Platform) Slug() string {
+	return strings.ReplaceAll(p.String(), "/", "-")
+}
+
+type platformKey struct{}
+
+// WithPlatform returns a context selecting the platform (os/arch[/variant])
+// that scans run with it pick from a multi-platform image. Empty leaves ctx
+// unchanged.
+func WithPlatform(ctx context.Context, platform string) context.Context {
+	if platform == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, platformKey{}, platform)
+}
+
+// platformFor returns the platform carried by ctx, empty for the default
+func platformFor(ctx context.Context) string {
+	p, _ := ctx.Value(platformKey{}).(string)
+	return p
+}
+
+// ListPlatforms returns the platforms of imageName's manifest list, none if
+// it is a single-platform image. Entries without a usable platform, such as
+// buildx attestation manifests (unknown/unknown), are left out.
+func (i *ImageInspector) ListPlatforms(ctx context.Context, imageName string, auth *ImageAuth) ([]Platform, error) {
+	raw, mimeType, err := i.GetRawManifest(ctx, imageName, auth)
+	if err != nil {
+		return nil, err
+	}
+	return manifestPlatforms(raw, mimeType)
+}
+
+// manifestPlatforms returns the distinct platforms listed in a manifest list
+func manifestPlatforms(raw []byte, mimeType string) ([]Platform, error) {
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(raw)
+	}
+	if !manifest.MIMETypeIsMultiImage(mimeType) {
+		return nil, nil
+	}
+	list, err := manifest.ListFromBlob(raw, mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest list: %w", err)
+	}
+
+	var platforms []Platform
+	seen := map[string]bool{}
+	for _, d := range list.Instances() {
+		instance, err := list.Instance(d)
+		if err != nil {
+			return nil, err
+		}
+		p := instance.ReadOnly.Platform
+		if p == nil || p.OS == "" || p.OS == "unknown" || p.Architecture == "" || p.Architecture == "unknown" {
+			continue
+		}
+		platform := Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}
+		if !seen[platform.String()] {
+			seen[platform.String()] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms, nil
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testIndex = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111", "platform": {"os": "linux", "architecture": "amd64"}},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:3333333333333333333333333333333333333333333333333333333333333333", "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:4444444444444444444444444444444444444444444444444444444444444444", "platform": {"os": "unknown", "architecture": "unknown"}}
+  ]
+}`
+
+func TestManifestPlatforms(t *testing.T) {
+	platforms, err := manifestPlatforms([]byte(testIndex), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range platforms {
+		got = append(got, p.String())
+	}
+	want := []string{"linux/amd64", "linux/arm64/v8", "linux/arm/v7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("platforms = %v, want %v (attestation manifest left out)", got, want)
+	}
+	if slug := platforms[2].Slug(); slug != "linux-arm-v7" {
+		t.Errorf("Slug() = %q, want linux-arm-v7", slug)
+	}
+
+	single := `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 1, "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111"}, "layers": []}`
+	platforms, err = manifestPlatforms([]byte(single), "")
+	if err != nil || platforms != nil {
+		t.Errorf("single-platform manifest = %v, %v; want none", platforms, err)
+	}
+}
+
+func TestScanKey(t *testing.T) {
+	cases := []struct {
+		profile, platform, want string
+	}{
+		{MatchProfileDefault, "", "nginx:1.25"},
+		{MatchProfileExactOnly, "", "nginx:1.25#exact-only"},
+		{MatchProfileDefault, "linux/arm64", "nginx:1.25@linux/arm64"},
+		{MatchProfileCPEAggressive, "linux/arm64", "nginx:1.25#cpe-aggressive@linux/arm64"},
+	}
+	for _, c := range cases {
+		if got := scanKey("nginx:1.25", c.profile, c.platform); got != c.want {
+			t.Errorf("scanKey(%q, %q) = %q, want %q", c.profile, c.platform, got, c.want)
+		}
+	}
+}
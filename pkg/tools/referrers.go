@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Kinds of artifacts attached to an image
+const (
+	ReferrerSBOM        = "sbom"
+	ReferrerSignature   = "signature"
+	ReferrerAttestation = "attestation"
+	ReferrerOther       = "other"
+)
+
+// maxReferrerBytes caps how much of an attached artifact is read
+const maxReferrerBytes = 256 << 20
+
+// Referrer is an artifact (SBOM, signature, attestation, ...) attached to an
+// image through the OCI referrers API
+type Referrer struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Kind         string            `json:"kind"` // ReferrerSBOM, ReferrerSignature, ReferrerAttestation or ReferrerOther
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// referrerKind classifies an OCI artifact type
+func referrerKind(artifactType string) string {
+	t := strings.ToLower(artifactType)
+	switch {
+	case strings.Contains(t, "spdx"), strings.Contains(t, "cyclonedx"), strings.Contains(t, "syft"):
+		return ReferrerSBOM
+	case strings.Contains(t, "in-toto"), strings.Contains(t, "dsse"), strings.Contains(t, ".att."):
+		return ReferrerAttestation
+	case strings.Contains(t, "signature"), strings.Contains(t, "sigstore"), strings.Contains(t, ".sig."):
+		return ReferrerSignature
+	}
+	return ReferrerOther
+}
+
+// ListReferrers returns the artifacts attached to a registry image, using the
+// registry's referrers API or, where unsupported, the referrers tag schema.
+// Registry credentials are taken from ctx (see WithImageAuth).
+func ListReferrers(ctx context.Context, imageName string) ([]Referrer, error) {
+	subject, opts, err := resolveSubject(ctx, imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := remote.Referrers(subject, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %s: %w", subject, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read referrers of %s: %w", subject, err)
+	}
+
+	referrers := make([]Referrer, 0, len(manifest.Manifests))
+	for _, d := range manifest.Manifests {
+		referrers = append(referrers, Referrer{
+			Digest:       d.Digest.String(),
+			MediaType:    string(d.MediaType),
+			ArtifactType: d.ArtifactType,
+			Kind:         referrerKind(d.ArtifactType),
+			Size:         d.Size,
+			Annotations:  d.Annotations,
+		})
+	}
+	return referrers, nil
+}
+
+// ResolveDigest returns the manifest digest imageName currently points at, so
+// tags of the same image can be told apart from different images. Registry
+// credentials are taken from ctx (see WithImageAuth).
+func ResolveDigest(ctx context.Context, imageName string) (string, error) {
+	d, _, err := resolveSubject(ctx, imageName)
+	if err != nil {
+		return "", err
+	}
+	return d.DigestStr(), nil
+}
+
+// FetchReferrer returns the content of an artifact attached to imageName: the
+// first layer of the referrer manifest with digest ref.Digest
+func FetchReferrer(ctx context.Context, imageName string, ref Referrer) ([]byte, error) {
+	imageName = strings.TrimPrefix(imageName, "docker://")
+	image, err := name.ParseReference(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+	repo, opts := image.Context(), registryOptions(ctx, imageName)
+
+	desc, err := remote.Get(repo.Digest(ref.Digest), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch referrer %s: %w", ref.Digest, err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse referrer %s: %w", ref.Digest, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("referrer %s has no content", ref.Digest)
+	}
+
+	layer, err := remote.Layer(repo.Digest(manifest.Layers[0].Digest.String()), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch referrer %s content: %w", ref.Digest, err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch referrer %s content: %w", ref.Digest, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxReferrerBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read referrer %s content: %w", ref.Digest, err)
+	}
+	if len(data) > maxReferrerBytes {
+		return nil, fmt.Errorf("referrer %s content exceeds %d bytes", ref.Digest, maxReferrerBytes)
+	}
+	return data, nil
+}
+
+// resolveSubject resolves imageName to the manifest digest its referrers point
+// at, along with the registry options to reach it
+func resolveSubject(ctx context.Context, imageName string) (name.Digest, []remote.Option, error) {
+	imageName = strings.TrimPrefix(imageName, "docker://")
+	ref, err := name.ParseReference(imageName)
+	if err != nil {
+		return name.Digest{}, nil, fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+	opts := registryOptions(ctx, imageName)
+
+	if d, ok := ref.(name.Digest); ok {
+		return d, opts, nil
+	}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return name.Digest{}, nil, fmt.Errorf("failed to resolve %s: %w", imageName, err)
+	}
+	return ref.Context().Digest(desc.Digest.String()), opts, nil
+}
+
+// registryOptions are the go-containerregistry options for imageName: the
+// credentials carried by ctx (else the docker config) and the
+// IMAGE_INSECURE_REGISTRIES TLS setting
+func registryOptions(ctx context.Context, imageName string) []remote.Option {
+	opts := []remote.Option{remote.WithContext(ctx)}
+	if auth := imageAuthFor(ctx); auth != nil {
+		opts = append(opts, remote.WithAuth(&authn.Basic{Username: auth.Username, Password: auth.Password}))
+	} else {
+		opts = append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	}
+	if insecureRegistry(imageName) {
+		t := remote.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		opts = append(opts, remote.WithTransport(t))
+	}
+	return opts
+}
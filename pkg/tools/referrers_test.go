@@ -0,0 +1,21 @@
+package tools
+
+import "testing"
+
+func TestReferrerKind(t *testing.T) {
+	for artifactType, want := range map[string]string{
+		"application/spdx+json":                         ReferrerSBOM,
+		"application/vnd.cyclonedx+json":                ReferrerSBOM,
+		"application/vnd.syft+json":                     ReferrerSBOM,
+		"application/vnd.dev.sigstore.bundle.v0.3+json": ReferrerSignature,
+		"application/vnd.cncf.notary.signature":         ReferrerSignature,
+		"application/vnd.in-toto+json":                  ReferrerAttestation,
+		"application/vnd.dsse.envelope.v1+json":         ReferrerAttestation,
+		"application/vnd.example.readme":                ReferrerOther,
+		"":                                              ReferrerOther,
+	} {
+		if got := referrerKind(artifactType); got != want {
+			t.Errorf("referrerKind(%q) = %q, want %q", artifactType, got, want)
+		}
+	}
+}
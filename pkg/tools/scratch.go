@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anchore/stereoscope"
+)
+
+const defaultScratchMaxAge = 2 * time.Hour
+
+// Global scratch directory the tools write temporary files to. Nil means
+// os.TempDir(), without sweeping.
+var Scratch *ScratchDir
+
+// ScratchConfig holds configuration for the scratch directory
+type ScratchConfig struct {
+	Dir    string        `json:"dir"`    // Defaults to $TMPDIR/reefline
+	MaxAge time.Duration `json:"maxAge"` // Leftovers older than this are removed by Sweep
+}
+
+// ScratchDir is a directory dedicated to the tools' temporary files: pulled
+// image archives, buffered blobs and the layers syft extracts. Each scan
+// removes what it wrote; Sweep removes what crashed or abandoned scans left.
+type ScratchDir struct {
+	config ScratchConfig
+	log    *slog.Logger
+}
+
+// ScratchUsage is the disk usage of the scratch directory
+type ScratchUsage struct {
+	UsedBytes int64  `json:"usedBytes"` // Held by files in the directory
+	FreeBytes uint64 `json:"freeBytes"` // Available on its filesystem
+}
+
+// NewScratchDir creates the scratch directory and points TMPDIR at it, so
+// syft, stereoscope and containers/image, which only honor TMPDIR, extract
+// there too. Call it after NewBlobCache, whose default directory derives from
+// TMPDIR.
+func NewScratchDir(cfg ScratchConfig, l *slog.Logger) (*ScratchDir, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "reefline")
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultScratchMaxAge
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Dir = dir
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	if err := os.Setenv("TMPDIR", cfg.Dir); err != nil {
+		return nil, err
+	}
+	return &ScratchDir{
+		config: cfg,
+		log:    l.With("subsys", "scratch"),
+	}, nil
+}
+
+// Path returns the scratch directory
+func (s *ScratchDir) Path() string {
+	return s.config.Dir
+}
+
+// scratchPath returns the directory temporary files go to
+func scratchPath() string {
+	if Scratch != nil {
+		return Scratch.Path()
+	}
+	return os.TempDir()
+}
+
+// Sweep removes entries of the scratch directory not modified for MaxAge,
+// i.e. left by a scan that crashed or was abandoned on timeout. Stereoscope's
+// per-process directories are kept, as a running worker reuses its own, but
+// their stale image directories go. The layer cache is never touched.
+func (s *ScratchDir) Sweep() (removed int, freed int64) {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		s.log.Warn("Failed to read scratch dir", "dir", s.config.Dir, "error", err)
+		return 0, 0
+	}
+	cutoff := time.Now().Add(-s.config.MaxAge)
+	for _, e := range entries {
+		p := filepath.Join(s.config.Dir, e.Name())
+		if LayerCache != nil && p == LayerCache.config.Dir {
+			continue
+		}
+		if e.IsDir() && strings.HasPrefix(e.Name(), "stereoscope-") {
+			children, err := os.ReadDir(p)
+			if err != nil {
+				continue
+			}
+			for _, c := range children {
+				if n, ok := removeStale(filepath.Join(p, c.Name()), cutoff); ok {
+					removed++
+					freed += n
+				}
+			}
+			continue
+		}
+		if n, ok := removeStale(p, cutoff); ok {
+			removed++
+			freed += n
+		}
+	}
+	if removed > 0 {
+		s.log.Info("Swept stale scratch files", "dir", s.config.Dir, "removed", removed, "freed_bytes", freed)
+	}
+	return removed, freed
+}
+
+// removeStale removes p if it wasn't modified since cutoff, returning the
+// bytes it held
+func removeStale(p string, cutoff time.Time) (int64, bool) {
+	info, err := os.Lstat(p)
+	if err != nil || info.ModTime().After(cutoff) {
+		return 0, false
+	}
+	size := diskUsage(p)
+	if err := os.RemoveAll(p); err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// Usage returns the bytes held in the scratch directory and the space left
+// on its filesystem
+func (s *ScratchDir) Usage() (ScratchUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.config.Dir, &st); err != nil {
+		return ScratchUsage{}, err
+	}
+	return ScratchUsage{
+		UsedBytes: diskUsage(s.config.Dir),
+		FreeBytes: uint64(st.Bavail) * uint64(st.Bsize),
+	}, nil
+}
+
+// LogUsage logs the disk usage of the scratch directory, tagged with when
+func (s *ScratchDir) LogUsage(when string) {
+	u, err := s.Usage()
+	if err != nil {
+		s.log.Warn("Failed to read scratch disk usage", "dir", s.config.Dir, "error", err)
+		return
+	}
+	s.log.Info("Scratch disk usage", "when", when, "dir", s.config.Dir, "used_bytes", u.UsedBytes, "free_bytes", u.FreeBytes)
+}
+
+// Cleanup removes stereoscope's directories of this process, which it
+// otherwise keeps until exit. Call it on shutdown, once no scan runs.
+func (s *ScratchDir) Cleanup() {
+	stereoscope.Cleanup()
+}
+
+// diskUsage sums the sizes of the files under p
+func diskUsage(p string) int64 {
+	var total int64
+	_ = filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+type workspaceKey struct{}
+
+// withWorkspace creates a private directory for one scan by tool in the
+// scratch directory. Temporary files written with the returned context go
+// there, and cleanup removes them all, whether the scan succeeded, failed or
+// panicked.
+func withWorkspace(ctx context.Context, tool string) (context.Context, func(), error) {
+	dir, err := os.MkdirTemp(scratchPath(), "reefline-"+tool+"-*")
+	if err != nil {
+		return ctx, func() {}, fmt.Errorf("failed to create %s workspace: %w", tool, err)
+	}
+	return context.WithValue(ctx, workspaceKey{}, dir), func() { os.RemoveAll(dir) }, nil
+}
+
+// tempDirFor returns the workspace carried by ctx, else the scratch directory
+func tempDirFor(ctx context.Context) string {
+	if dir, ok := ctx.Value(workspaceKey{}).(string); ok {
+		return dir
+	}
+	return scratchPath()
+}
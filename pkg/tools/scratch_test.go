@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScratchSweep(t *testing.T) {
+	t.Setenv("TMPDIR", os.TempDir())
+	s, err := NewScratchDir(ScratchConfig{Dir: t.TempDir(), MaxAge: time.Hour}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(name string, stale bool) string {
+		t.Helper()
+		p := filepath.Join(s.Path(), name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("layer"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if stale {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return p
+	}
+
+	stalePull := write("reefline-pull-1.tar", true)
+	freshPull := write("reefline-pull-2.tar", false)
+	staleLayers := write("stereoscope-1/oci-registry-image-1/layer.tar", false)
+	freshLayers := write("stereoscope-1/oci-registry-image-2/layer.tar", false)
+	if err := os.Chtimes(filepath.Dir(staleLayers), old, old); err != nil {
+		t.Fatal(err)
+	}
+	// The per-process root is old but still in use
+	if err := os.Chtimes(filepath.Join(s.Path(), "stereoscope-1"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, freed := s.Sweep()
+	if removed != 2 || freed != 10 {
+		t.Errorf("Sweep() = %d, %d; want 2 entries, 10 bytes", removed, freed)
+	}
+	for _, p := range []string{stalePull, staleLayers} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s not swept", p)
+		}
+	}
+	for _, p := range []string{freshPull, freshLayers} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s swept: %v", p, err)
+		}
+	}
+
+	u, err := s.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UsedBytes != 10 || u.FreeBytes == 0 {
+		t.Errorf("Usage() = %+v, want 10 bytes used and some free", u)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	ctx, cleanup, err := withWorkspace(context.Background(), "dive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDirFor(ctx)
+	if filepath.Dir(dir) != os.TempDir() {
+		t.Fatalf("workspace %s not in %s", dir, os.TempDir())
+	}
+	f, err := os.CreateTemp(dir, "reefline-pull-*.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("workspace %s left behind", dir)
+	}
+	if got := tempDirFor(context.Background()); got != os.TempDir() {
+		t.Errorf("tempDirFor without workspace = %s, want %s", got, os.TempDir())
+	}
+}
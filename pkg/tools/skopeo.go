@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -23,9 +25,11 @@ var ImgInspector *ImageInspector
 
 // ImageInspectorConfig holds configuration for the image inspector
 type ImageInspectorConfig struct {
-	Enable                bool          `json:"enable"`
-	Timeout               time.Duration `json:"timeout"`
-	InsecureSkipTLSVerify bool          `json:"insecureSkipTLSVerify"`
+	Enable                bool             `json:"enable"`
+	Timeout               time.Duration    `json:"timeout"`
+	InsecureSkipTLSVerify bool             `json:"insecureSkipTLSVerify"`
+	Sources               []string         `json:"sources,omitempty"` // Ordered fallback: "docker", "podman", "remote"
+	Signatures            *SignatureConfig `json:"-"`                 // Check cosign signatures of remote images; nil disables
 }
 
 // ImageAuth holds per-request authentication credentials
@@ -57,10 +61,13 @@ type InspectResult struct {
 	Labels        map[string]string  `json:"labels,omitempty"`
 	Env           []string           `json:"env,omitempty"`
 	Layers        []InspectLayerInfo `json:"layers"`
+	History       []HistoryEntry     `json:"history,omitempty"` // Build steps from the config blob, oldest first
 	RawManifest   []byte             `json:"rawManifest,omitempty"`
 	RawConfig     []byte             `json:"rawConfig,omitempty"`
 	InspectTime   time.Time          `json:"inspectTime"`
-	Status        string             `json:"status"` // "completed", "error"
+	Source        string             `json:"source,omitempty"`    // Pull source that succeeded
+	Signature     *SignatureStatus   `json:"signature,omitempty"` // Cosign signatures/provenance; nil when not checked
+	Status        string             `json:"status"`              // "completed", "error"
 	Error         string             `json:"error,omitempty"`
 }
 
@@ -72,11 +79,64 @@ type InspectLayerInfo struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
+// HistoryEntry is one build step recorded in the image config's history
+type HistoryEntry struct {
+	Created    *time.Time `json:"created,omitempty"`
+	CreatedBy  string     `json:"createdBy,omitempty"` // The command, e.g. "RUN /bin/sh -c apk add curl"
+	Author     string     `json:"author,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	EmptyLayer bool       `json:"emptyLayer,omitempty"` // Metadata-only step (ENV, CMD, ...) that added no layer
+	Layer      string     `json:"layer,omitempty"`      // Digest of the layer the step produced
+}
+
+// parseHistory reads the build history from a docker or OCI config blob and
+// pairs each layer-producing step with its layer, in order
+func parseHistory(config []byte, layers []InspectLayerInfo) []HistoryEntry {
+	if len(config) == 0 {
+		return nil
+	}
+	var cfg struct {
+		History []struct {
+			Created    *time.Time `json:"created"`
+			CreatedBy  string     `json:"created_by"`
+			Author     string     `json:"author"`
+			Comment    string     `json:"comment"`
+			EmptyLayer bool       `json:"empty_layer"`
+		} `json:"history"`
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil
+	}
+
+	history := make([]HistoryEntry, 0, len(cfg.History))
+	layer := 0
+	for _, h := range cfg.History {
+		e := HistoryEntry{
+			Created:    h.Created,
+			CreatedBy:  h.CreatedBy,
+			Author:     h.Author,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		}
+		if !h.EmptyLayer {
+			if layer < len(layers) {
+				e.Layer = layers[layer].Digest
+			}
+			layer++
+		}
+		history = append(history, e)
+	}
+	return history
+}
+
 // NewImageInspector creates a new image inspector
 func NewImageInspector(cfg ImageInspectorConfig, l *slog.Logger) *ImageInspector {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = inspectTimeout
 	}
+	if len(cfg.Sources) == 0 {
+		cfg.Sources = append([]string(nil), DefaultSourceOrder...)
+	}
 	return &ImageInspector{
 		config: cfg,
 		cache:  make(map[string]*InspectResult),
@@ -126,54 +186,123 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 	if imageName == "" {
 		return nil, fmt.Errorf("image name is required")
 	}
+	if auth == nil {
+		auth = imageAuthFor(ctx)
+	}
 
 	start := time.Now()
 	i.log.Info("Inspecting image", "image", imageName)
 
-	ctx, cancel := context.WithTimeout(ctx, i.config.Timeout)
+	timeout := timeoutFor(ctx, i.config.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// Parse image reference
-	ref, err := parseImageReference(imageName)
+	// Try each configured source in order, keeping the first that works
+	sources, name := i.resolveSources(ctx, imageName)
+	var (
+		result *InspectResult
+		errs   []string
+	)
+	for _, src := range sources {
+		var err error
+		result, err = i.inspectFromSource(ctx, name, src, auth)
+		if err == nil {
+			result.Source = src
+			if i.config.Signatures != nil {
+				result.Signature = i.verifySignatures(ctx, name, src, result.Digest, auth)
+			}
+			i.setInspection(imageName, result)
+			i.log.Info("Image inspection completed",
+				"image", imageName,
+				"source", src,
+				"digest", result.Digest,
+				"arch", result.Architecture,
+				"os", result.Os,
+				"layers", len(result.Layers),
+				"elapsed", time.Since(start),
+			)
+			return result, nil
+		}
+		i.log.Warn("Inspect source failed, trying next", "image", imageName, "source", src, "error", err)
+		errs = append(errs, fmt.Sprintf("%s: %v", src, err))
+	}
+
+	if result == nil {
+		result = &InspectResult{Image: imageName, InspectTime: time.Now(), Status: "error"}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err := &TimeoutError{Tool: "inspect", Image: imageName, Timeout: timeout}
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Error = fmt.Sprintf("all image sources failed (%s)", strings.Join(errs, "; "))
+	i.setInspection(imageName, result)
+	return result, fmt.Errorf("failed to inspect image %s: %s", imageName, result.Error)
+}
+
+// verifySignatures checks the cosign signatures of an image inspected via src.
+// A daemon's digest is not the registry's, so images that came from a local
+// source are reported as not verified rather than left without a status.
+func (i *ImageInspector) verifySignatures(ctx context.Context, name, src, imageDigest string, auth *ImageAuth) *SignatureStatus {
+	if src != SourceRemote {
+		return &SignatureStatus{Skipped: fmt.Sprintf("not verified (local %s source)", src)}
+	}
+	return i.config.Signatures.VerifySignatures(ctx, name, imageDigest, i.buildSystemContext(name, auth))
+}
+
+// resolveSources returns the pull sources to try for imageName and the name to
+// pass them: those set with WithSources, else the configured ones. Local
+// docker-archive/OCI inputs bypass the daemon/remote fallback.
+func (i *ImageInspector) resolveSources(ctx context.Context, imageName string) ([]string, string) {
+	sources := sourcesFor(ctx, i.config.Sources)
+	in, err := ParseImageInput(imageName, "")
 	if err != nil {
-		result := &InspectResult{
+		return sources, imageName
+	}
+	if in.IsLocal() {
+		return []string{in.Transport}, in.String()
+	}
+	return sources, in.Ref
+}
+
+// inspectFromSource inspects imageName via a single pull source. On failure it
+// returns whatever partial result was gathered along with the error.
+func (i *ImageInspector) inspectFromSource(ctx context.Context, imageName, src string, auth *ImageAuth) (*InspectResult, error) {
+	// Build system context with auth
+	sysCtx := i.buildSystemContext(imageName, auth)
+
+	// Resolve the reference for this source
+	ref, err := sourceReference(src, imageName, sysCtx)
+	if err != nil {
+		return &InspectResult{
 			Image:       imageName,
 			InspectTime: time.Now(),
 			Status:      "error",
 			Error:       err.Error(),
-		}
-		i.setInspection(imageName, result)
-		return result, fmt.Errorf("failed to parse image reference %s: %w", imageName, err)
+		}, fmt.Errorf("failed to parse image reference %s: %w", imageName, err)
 	}
 
-	// Build system context with auth
-	sysCtx := i.buildSystemContext(auth)
-
 	// Create image source (for raw manifest)
 	imgSrc, err := ref.NewImageSource(ctx, sysCtx)
 	if err != nil {
-		result := &InspectResult{
+		return &InspectResult{
 			Image:       imageName,
 			InspectTime: time.Now(),
 			Status:      "error",
 			Error:       err.Error(),
-		}
-		i.setInspection(imageName, result)
-		return result, fmt.Errorf("failed to create image source for %s: %w", imageName, err)
+		}, fmt.Errorf("failed to create image source for %s: %w", imageName, err)
 	}
 	defer imgSrc.Close()
 
 	// Get raw manifest
 	manifestBytes, mimeType, err := imgSrc.GetManifest(ctx, nil)
 	if err != nil {
-		result := &InspectResult{
+		return &InspectResult{
 			Image:       imageName,
 			InspectTime: time.Now(),
 			Status:      "error",
 			Error:       err.Error(),
-		}
-		i.setInspection(imageName, result)
-		return result, fmt.Errorf("failed to get manifest for %s: %w", imageName, err)
+		}, fmt.Errorf("failed to get manifest for %s: %w", imageName, err)
 	}
 
 	// Compute digest
@@ -185,7 +314,7 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 	// Create full image (parses config)
 	img, err := ref.NewImage(ctx, sysCtx)
 	if err != nil {
-		result := &InspectResult{
+		return &InspectResult{
 			Image:       imageName,
 			Digest:      dgst.String(),
 			MediaType:   mimeType,
@@ -193,16 +322,14 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 			InspectTime: time.Now(),
 			Status:      "error",
 			Error:       fmt.Sprintf("manifest retrieved but failed to parse image config: %s", err.Error()),
-		}
-		i.setInspection(imageName, result)
-		return result, fmt.Errorf("failed to create image for %s: %w", imageName, err)
+		}, fmt.Errorf("failed to create image for %s: %w", imageName, err)
 	}
 	defer img.Close()
 
 	// Get image inspect info
 	inspectInfo, err := img.Inspect(ctx)
 	if err != nil {
-		result := &InspectResult{
+		return &InspectResult{
 			Image:       imageName,
 			Digest:      dgst.String(),
 			MediaType:   mimeType,
@@ -210,9 +337,7 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 			InspectTime: time.Now(),
 			Status:      "error",
 			Error:       err.Error(),
-		}
-		i.setInspection(imageName, result)
-		return result, fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+		}, fmt.Errorf("failed to inspect image %s: %w", imageName, err)
 	}
 
 	// Get config blob
@@ -241,7 +366,7 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 		}
 	}
 
-	result := &InspectResult{
+	return &InspectResult{
 		Image:         imageName,
 		Digest:        dgst.String(),
 		MediaType:     mimeType,
@@ -254,24 +379,12 @@ func (i *ImageInspector) InspectImage(ctx context.Context, imageName string, aut
 		Labels:        inspectInfo.Labels,
 		Env:           inspectInfo.Env,
 		Layers:        layers,
+		History:       parseHistory(configBlob, layers),
 		RawManifest:   manifestBytes,
 		RawConfig:     configBlob,
 		InspectTime:   time.Now(),
 		Status:        "completed",
-	}
-
-	i.setInspection(imageName, result)
-
-	i.log.Info("Image inspection completed",
-		"image", imageName,
-		"digest", dgst.String(),
-		"arch", inspectInfo.Architecture,
-		"os", inspectInfo.Os,
-		"layers", len(layers),
-		"elapsed", time.Since(start),
-	)
-
-	return result, nil
+	}, nil
 }
 
 // GetRawManifest retrieves only the raw manifest bytes for an image
@@ -282,29 +395,45 @@ func (i *ImageInspector) GetRawManifest(ctx context.Context, imageName string, a
 	if imageName == "" {
 		return nil, "", fmt.Errorf("image name is required")
 	}
+	if auth == nil {
+		auth = imageAuthFor(ctx)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, i.config.Timeout)
+	timeout := timeoutFor(ctx, i.config.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	ref, err := parseImageReference(imageName)
+	sources, name := i.resolveSources(ctx, imageName)
+	var errs []string
+	for _, src := range sources {
+		manifestBytes, mimeType, err := i.rawManifestFromSource(ctx, name, src, auth)
+		if err == nil {
+			return manifestBytes, mimeType, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", src, err))
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, "", &TimeoutError{Tool: "inspect", Image: imageName, Timeout: timeout}
+	}
+	return nil, "", fmt.Errorf("failed to get manifest for %s: all image sources failed (%s)", imageName, strings.Join(errs, "; "))
+}
+
+func (i *ImageInspector) rawManifestFromSource(ctx context.Context, imageName, src string, auth *ImageAuth) ([]byte, string, error) {
+	sysCtx := i.buildSystemContext(imageName, auth)
+
+	ref, err := sourceReference(src, imageName, sysCtx)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to parse image reference %s: %w", imageName, err)
 	}
 
-	sysCtx := i.buildSystemContext(auth)
-
 	imgSrc, err := ref.NewImageSource(ctx, sysCtx)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create image source for %s: %w", imageName, err)
 	}
 	defer imgSrc.Close()
 
-	manifestBytes, mimeType, err := imgSrc.GetManifest(ctx, nil)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to get manifest for %s: %w", imageName, err)
-	}
-
-	return manifestBytes, mimeType, nil
+	return imgSrc.GetManifest(ctx, nil)
 }
 
 // Stop cleans up the inspector
@@ -315,8 +444,9 @@ func (i *ImageInspector) Stop() {
 	i.log.Info("Image inspector stopped")
 }
 
-// buildSystemContext creates a types.SystemContext with optional auth and TLS config
-func (i *ImageInspector) buildSystemContext(auth *ImageAuth) *types.SystemContext {
+// buildSystemContext creates a types.SystemContext for imageName with optional
+// auth and TLS config
+func (i *ImageInspector) buildSystemContext(imageName string, auth *ImageAuth) *types.SystemContext {
 	sysCtx := &types.SystemContext{}
 
 	if auth != nil && auth.Username != "" {
@@ -328,6 +458,8 @@ func (i *ImageInspector) buildSystemContext(auth *ImageAuth) *types.SystemContex
 
 	if i.config.InsecureSkipTLSVerify {
 		sysCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+	} else {
+		sysCtx.DockerInsecureSkipTLSVerify = insecureTLS(imageName)
 	}
 
 	// Force Linux/AMD64 platform for consistent analysis
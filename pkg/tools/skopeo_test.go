@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -57,3 +58,66 @@ func TestSkopeoInspect(t *testing.T) {
 		t.Errorf("Expected OS linux, got %s", result.Os)
 	}
 }
+
+func TestParseHistory(t *testing.T) {
+	config := []byte(`{
+		"architecture": "amd64",
+		"history": [
+			{"created": "2024-01-26T23:44:50Z", "created_by": "/bin/sh -c #(nop) ADD file:37a76ec18f9887751cd8473744917d08b7431fc4085097bb6a09d81b41775473 in / "},
+			{"created_by": "/bin/sh -c #(nop)  CMD [\"/bin/sh\"]", "empty_layer": true},
+			{"created_by": "RUN /bin/sh -c apk add --no-cache curl # buildkit", "comment": "buildkit.dockerfile.v0"}
+		]
+	}`)
+	layers := []InspectLayerInfo{{Digest: "sha256:aaa"}, {Digest: "sha256:bbb"}}
+
+	history := parseHistory(config, layers)
+	if len(history) != 3 {
+		t.Fatalf("got %d history entries, want 3", len(history))
+	}
+	if history[0].Layer != "sha256:aaa" || history[0].Created == nil {
+		t.Errorf("first step = %+v, want layer sha256:aaa with a created time", history[0])
+	}
+	if !history[1].EmptyLayer || history[1].Layer != "" {
+		t.Errorf("CMD step = %+v, want an empty layer", history[1])
+	}
+	if history[2].Layer != "sha256:bbb" || history[2].CreatedBy != "RUN /bin/sh -c apk add --no-cache curl # buildkit" {
+		t.Errorf("RUN step = %+v, want layer sha256:bbb", history[2])
+	}
+
+	if parseHistory(nil, layers) != nil || parseHistory([]byte("not json"), layers) != nil {
+		t.Error("missing or invalid config should have no history")
+	}
+}
+
+func TestResolveSourcesFromContext(t *testing.T) {
+	inspector := NewImageInspector(ImageInspectorConfig{Sources: DefaultSourceOrder}, slog.Default())
+
+	sources, name := inspector.resolveSources(context.Background(), "docker://nginx:1.25")
+	if len(sources) != len(DefaultSourceOrder) || name != "nginx:1.25" {
+		t.Errorf("default sources = %v for %q", sources, name)
+	}
+
+	ctx := WithSources(context.Background(), SourceRemote)
+	if sources, _ := inspector.resolveSources(ctx, "nginx:1.25"); len(sources) != 1 || sources[0] != SourceRemote {
+		t.Errorf("remote-only context resolved to %v", sources)
+	}
+	if sources, _ := inspector.resolveSources(ctx, "docker-archive:/images/app.tar"); len(sources) != 1 || sources[0] != SourceDockerArchive {
+		t.Errorf("local input resolved to %v, want its own transport", sources)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	cases := map[string][]string{
+		"":                      DefaultSourceOrder,
+		"podman, Remote":        {SourcePodman, SourceRemote},
+		"docker-archive":        DefaultSourceOrder,
+		"docker-archive,remote": {SourceRemote},
+		"bogus,,docker":         {SourceDocker},
+	}
+	for in, want := range cases {
+		got := ParseSources(in)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("ParseSources(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
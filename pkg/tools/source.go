@@ -0,0 +1,290 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/docker/daemon"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// Image pull sources. Analyzers try them in order and use the first that works.
+const (
+	SourceDocker        = "docker"         // local Docker daemon
+	SourcePodman        = "podman"         // local Podman (CLI or Docker-compatible socket)
+	SourceRemote        = "remote"         // daemonless pull straight from the registry
+	SourceDockerArchive = "docker-archive" // `docker save` tarball on disk
+)
+
+// DefaultSourceOrder is the fallback order used when no sources are configured.
+var DefaultSourceOrder = []string{SourceDocker, SourcePodman, SourceRemote}
+
+// ParseSources parses a comma-separated source list (e.g. "podman,remote").
+// Unknown entries are dropped with a warning; an empty result falls back to
+// DefaultSourceOrder.
+func ParseSources(s string) []string {
+	var sources []string
+	for _, src := range strings.Split(s, ",") {
+		src = strings.ToLower(strings.TrimSpace(src))
+		switch src {
+		case SourceDocker, SourcePodman, SourceRemote:
+			sources = append(sources, src)
+		case "":
+		case SourceDockerArchive:
+			// Formerly DIVE_IMAGE_SOURCE=docker-archive read every image ref as a tarball path
+			slog.Warn("Ignoring image source docker-archive: submit local tarballs as docker-archive:<path> image refs instead", "source", src)
+		default:
+			slog.Warn("Ignoring unknown image source", "source", src, "valid", []string{SourceDocker, SourcePodman, SourceRemote})
+		}
+	}
+	if len(sources) == 0 {
+		return append([]string(nil), DefaultSourceOrder...)
+	}
+	return sources
+}
+
+type sourcesKey struct{}
+
+// WithSources returns a context restricting the pull sources the inspector
+// tries, e.g. to SourceRemote when only the registry's view of a tag counts.
+// An empty list leaves ctx unchanged.
+func WithSources(ctx context.Context, sources ...string) context.Context {
+	if len(sources) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, sourcesKey{}, sources)
+}
+
+// sourcesFor returns the pull sources carried by ctx, or def
+func sourcesFor(ctx context.Context, def []string) []string {
+	if s, ok := ctx.Value(sourcesKey{}).([]string); ok {
+		return s
+	}
+	return def
+}
+
+// podmanHost returns the Docker-compatible API socket exposed by Podman.
+// CONTAINER_HOST takes precedence, then the rootless socket, then the rootful one.
+func podmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// sourceReference resolves imageName to a containers/image reference for the
+// given pull source, pointing sysCtx at the right daemon where needed.
+func sourceReference(source, imageName string, sysCtx *types.SystemContext) (types.ImageReference, error) {
+	switch source {
+	case SourceRemote:
+		return parseImageReference(imageName)
+	case SourceDockerArchive, SourceOCI:
+		in, err := ParseImageInput(imageName, source)
+		if err != nil {
+			return nil, err
+		}
+		return in.containersRef()
+	case SourceDocker, SourcePodman:
+		named, err := reference.ParseNormalizedNamed(imageName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image reference %q: %w", imageName, err)
+		}
+		if source == SourcePodman {
+			sysCtx.DockerDaemonHost = podmanHost()
+		} else if host := os.Getenv("DOCKER_HOST"); host != "" {
+			sysCtx.DockerDaemonHost = host
+		}
+		return daemon.NewReference("", reference.TagNameOnly(named))
+	default:
+		return nil, fmt.Errorf("unsupported image source %q", source)
+	}
+}
+
+// pullStats records how many bytes a remote pull fetched versus reused
+type pullStats struct {
+	BytesFetched int64
+	BytesReused  int64
+}
+
+// pullToDockerArchive fetches imageName directly from its registry and writes it
+// to a temporary docker-archive tarball, so daemonless nodes can still run
+// archive-based analysis. When meta comes from a prior remote inspection its
+// manifest/config are reused instead of re-fetched, and layers are served from
+// LayerCache when present. The caller must remove the returned file.
+func pullToDockerArchive(ctx context.Context, imageName string, sysCtx *types.SystemContext, meta *InspectResult) (string, pullStats, error) {
+	ref, err := parseImageReference(imageName)
+	if err != nil {
+		return "", pullStats{}, err
+	}
+	return writeDockerArchive(ctx, ref, imageName, sysCtx, meta)
+}
+
+// writeDockerArchive copies the image at ref (any containers/image transport,
+// e.g. a registry or an OCI layout) into a temporary docker-archive tarball
+// tagged as imageName, in the workspace carried by ctx (see withWorkspace).
+func writeDockerArchive(ctx context.Context, ref types.ImageReference, imageName string, sysCtx *types.SystemContext, meta *InspectResult) (path string, stats pullStats, err error) {
+	imgSrc, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", stats, fmt.Errorf("failed to create image source for %s: %w", imageName, err)
+	}
+	defer imgSrc.Close()
+
+	var (
+		configBlob []byte
+		layers     []types.BlobInfo
+	)
+	if meta != nil && meta.Source == SourceRemote && len(meta.RawConfig) > 0 && len(meta.Layers) > 0 {
+		// Reuse the inspector's manifest/config; layer digests are registry blob digests
+		configBlob = meta.RawConfig
+		for _, l := range meta.Layers {
+			d, perr := digest.Parse(l.Digest)
+			if perr != nil {
+				return "", stats, fmt.Errorf("invalid layer digest %q: %w", l.Digest, perr)
+			}
+			layers = append(layers, types.BlobInfo{Digest: d, Size: l.Size, MediaType: l.MIMEType})
+		}
+		stats.BytesReused += int64(len(meta.RawManifest) + len(meta.RawConfig))
+	} else {
+		img, ierr := ref.NewImage(ctx, sysCtx)
+		if ierr != nil {
+			return "", stats, fmt.Errorf("failed to open remote image %s: %w", imageName, ierr)
+		}
+		defer img.Close()
+
+		if configBlob, err = img.ConfigBlob(ctx); err != nil {
+			return "", stats, fmt.Errorf("failed to get config blob for %s: %w", imageName, err)
+		}
+		layers = img.LayerInfos()
+		stats.BytesFetched += int64(len(configBlob))
+	}
+
+	dir := tempDirFor(ctx)
+	f, err := os.CreateTemp(dir, "reefline-pull-*.tar")
+	if err != nil {
+		return "", stats, fmt.Errorf("failed to create temp archive: %w", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+	configName := digest.FromBytes(configBlob).Encoded() + ".json"
+	if err = writeTarEntry(tw, dir, configName, int64(len(configBlob)), bytes.NewReader(configBlob)); err != nil {
+		return "", stats, err
+	}
+
+	var layerNames []string
+	for _, layer := range layers {
+		blob, size, reused, blobErr := openLayer(ctx, imgSrc, layer)
+		if blobErr != nil {
+			return "", stats, fmt.Errorf("failed to fetch layer %s: %w", layer.Digest, blobErr)
+		}
+		if reused {
+			stats.BytesReused += size
+		} else {
+			stats.BytesFetched += size
+		}
+		// Layers are stored under blobs/ so the archive reader sniffs their compression.
+		name := "blobs/" + layer.Digest.Algorithm().String() + "/" + layer.Digest.Encoded()
+		err = writeTarEntry(tw, dir, name, size, blob)
+		blob.Close()
+		if err != nil {
+			return "", stats, err
+		}
+		layerNames = append(layerNames, name)
+	}
+
+	manifestJSON, err := json.Marshal([]map[string]interface{}{{
+		"Config":   configName,
+		"RepoTags": []string{imageName},
+		"Layers":   layerNames,
+	}})
+	if err != nil {
+		return "", stats, err
+	}
+	if err = writeTarEntry(tw, dir, "manifest.json", int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		return "", stats, err
+	}
+	if err = tw.Close(); err != nil {
+		return "", stats, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return f.Name(), stats, nil
+}
+
+// openLayer returns a reader for a layer blob, serving it from LayerCache when
+// possible and populating the cache after a registry fetch.
+func openLayer(ctx context.Context, imgSrc types.ImageSource, layer types.BlobInfo) (rc io.ReadCloser, size int64, reused bool, err error) {
+	if LayerCache != nil {
+		if rc, size, ok := LayerCache.Open(layer.Digest); ok {
+			return rc, size, true, nil
+		}
+	}
+
+	blob, size, err := imgSrc.GetBlob(ctx, layer, none.NoCache)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if LayerCache == nil {
+		return blob, size, false, nil
+	}
+
+	if size > LayerCache.config.MaxBytes {
+		return blob, size, false, nil
+	}
+
+	rc, size, err = LayerCache.Put(layer.Digest, blob)
+	blob.Close()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return rc, size, false, nil
+}
+
+// writeTarEntry writes a single regular file to tw. A negative size means
+// unknown, in which case the content is buffered to a file in dir first.
+func writeTarEntry(tw *tar.Writer, dir, name string, size int64, r io.Reader) error {
+	if size < 0 {
+		tmp, err := os.CreateTemp(dir, "reefline-blob-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, r); err != nil {
+			return fmt.Errorf("failed to buffer %s: %w", name, err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = tmp
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return fmt.Errorf("failed to write header for %s: %w", name, err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
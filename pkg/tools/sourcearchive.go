@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrSourceTooLarge is returned when an extracted source archive exceeds its size limit
+var ErrSourceTooLarge = errors.New("source archive exceeds the size limit")
+
+// ExtractSourceArchive unpacks a tar (optionally gzip-compressed) source tree
+// into dest for ScanDirectory. Only directories and regular files are
+// written: links and devices are skipped, entries escaping dest are
+// rejected, and extraction stops with ErrSourceTooLarge once more than
+// maxBytes of file content has been written (0 means no limit).
+func ExtractSourceArchive(r io.Reader, dest string, maxBytes int64) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("invalid gzip source archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var written int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid source archive: %w", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("source archive entry %q escapes the extraction directory", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if maxBytes > 0 && written+hdr.Size > maxBytes {
+				return ErrSourceTooLarge
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			n, err := writeSourceFile(target, tr)
+			written += n
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeSourceFile writes one archive entry to path
+func writeSourceFile(path string, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sourceTarball builds a gzipped tar of files, plus a symlink entry
+func sourceTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtractSourceArchive(t *testing.T) {
+	dest := t.TempDir()
+	archive := sourceTarball(t, map[string]string{
+		"app/go.mod":             "module example.com/app\n",
+		"app/web/package.json":   `{"name":"web"}`,
+		"./app/requirements.txt": "flask==0.12\n",
+	})
+	if err := ExtractSourceArchive(bytes.NewReader(archive), dest, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app/go.mod", "app/web/package.json", "app/requirements.txt"} {
+		if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+			t.Errorf("%s not extracted: %v", name, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "link")); !os.IsNotExist(err) {
+		t.Error("symlink entry was extracted")
+	}
+
+	escaping := sourceTarball(t, map[string]string{"../evil": "x"})
+	if err := ExtractSourceArchive(bytes.NewReader(escaping), t.TempDir(), 0); err == nil {
+		t.Error("entry escaping the destination was accepted")
+	}
+
+	large := sourceTarball(t, map[string]string{"big.txt": "0123456789"})
+	if err := ExtractSourceArchive(bytes.NewReader(large), t.TempDir(), 5); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("oversized archive: got %v, want ErrSourceTooLarge", err)
+	}
+}
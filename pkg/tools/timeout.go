@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type timeoutKey struct{}
+
+// WithTimeout returns a context carrying a per-request timeout that overrides
+// each tool's configured timeout. Non-positive values leave ctx unchanged.
+func WithTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// timeoutFor returns the per-request timeout carried by ctx, or def
+func timeoutFor(ctx context.Context, def time.Duration) time.Duration {
+	if d, ok := ctx.Value(timeoutKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+	return def
+}
+
+// TimeoutFromEnv parses a timeout from the named env var. Both Go durations
+// ("10m") and plain seconds ("600") are accepted; unset or invalid values
+// return 0 so the tool default applies.
+func TimeoutFromEnv(key string) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d
+	}
+	return 0
+}
+
+// TimeoutError is returned when a tool does not finish within its timeout
+type TimeoutError struct {
+	Tool    string
+	Image   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s scan exceeded %ds timeout for image %s", e.Tool, int(e.Timeout.Seconds()), e.Image)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// runWithTimeout runs fn with a deadline of d and converts a deadline hit into
+// a *TimeoutError. Several underlying libraries ignore context cancellation, so
+// fn runs in its own goroutine and is abandoned (not killed) on timeout.
+func runWithTimeout[T any](ctx context.Context, tool, image string, d time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return r.v, &TimeoutError{Tool: tool, Image: image, Timeout: d}
+		}
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, &TimeoutError{Tool: tool, Image: image, Timeout: d}
+		}
+		return zero, ctx.Err()
+	}
+}
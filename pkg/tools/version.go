@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// toolModules maps each tool to the Go module it's embedded from
+var toolModules = map[string]string{
+	"sbom":   "github.com/anchore/syft",
+	"grype":  "github.com/anchore/grype",
+	"dockle": "github.com/goodwithtech/dockle",
+	"dive":   "github.com/wagoodman/dive",
+	"skopeo": "github.com/containers/image/v5",
+}
+
+var (
+	moduleVersionsOnce sync.Once
+	moduleVersions     map[string]string
+)
+
+// ToolVersion returns the version of the library behind tool (e.g. "v0.108.0"
+// for grype), as recorded in the binary's build info. Unknown tools and
+// binaries built without module info return "".
+func ToolVersion(tool string) string {
+	moduleVersionsOnce.Do(func() {
+		moduleVersions = map[string]string{}
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, dep := range info.Deps {
+			version := dep.Version
+			if dep.Replace != nil {
+				version = dep.Replace.Version
+			}
+			moduleVersions[dep.Path] = version
+		}
+	})
+	return moduleVersions[toolModules[tool]]
+}
@@ -0,0 +1,34 @@
+// Package version holds the build identification of the Reefline binaries.
+// The values are injected at build time, e.g.:
+//
+//	go build -ldflags "-X github.com/siddhantprateek/reefline/pkg/version.Version=v1.2.0 \
+//	  -X github.com/siddhantprateek/reefline/pkg/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/siddhantprateek/reefline/pkg/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+package version
+
+import "runtime"
+
+// Build identification, overridden via -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info is the build identification reported by the API
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build identification of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}